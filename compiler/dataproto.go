@@ -0,0 +1,68 @@
+// Package dataproto compiles DataProto schemas into generated sources.
+package dataproto
+
+import (
+	"errors"
+	"fmt"
+	"path"
+
+	"github.com/aurora/dataproto/internal/checker"
+	"github.com/aurora/dataproto/internal/codegen"
+	"github.com/aurora/dataproto/internal/parser"
+)
+
+// ErrInvalidSchema is returned by Compile when the checker reports errors.
+// The diagnostics themselves are returned alongside it.
+var ErrInvalidSchema = errors.New("dataproto: schema has errors")
+
+// Options configures Compile.
+type Options struct {
+	// Targets names the generators to run, e.g. "proto" or "sqlite"; any
+	// name registered with codegen.Register is accepted
+	Targets []string
+
+	// StrictRpcTypes requires rpc request and response types to be declared
+	// or generated from the schema; see checker.Checker.StrictRpcTypes
+	StrictRpcTypes bool
+}
+
+// Compile parses and checks source, then runs the generators selected in
+// opts. Generated files are keyed by "<target>/<filename>" so targets sharing
+// a filename do not collide. Checker warnings are returned with the files;
+// if the checker reports errors no code is generated and Compile returns
+// ErrInvalidSchema. Syntax errors are returned as a parser.ErrorList.
+func Compile(source, filename string, opts Options) (map[string]string, []checker.Error, error) {
+	generators := make([]codegen.Generator, len(opts.Targets))
+	for i, target := range opts.Targets {
+		g, ok := codegen.Get(target)
+		if !ok {
+			return nil, nil, fmt.Errorf("dataproto: unknown target %q", target)
+		}
+		generators[i] = g
+	}
+
+	file, err := parser.ParseFile(source, filename)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c := checker.New(file)
+	c.StrictRpcTypes = opts.StrictRpcTypes
+	diags := c.Check()
+	if checker.HasErrors(diags) {
+		return nil, diags, ErrInvalidSchema
+	}
+
+	result := make(map[string]string)
+	for i, target := range opts.Targets {
+		out, err := generators[i].Generate(file)
+		if err != nil {
+			return nil, diags, fmt.Errorf("dataproto: generating %s: %w", target, err)
+		}
+		for name, content := range out {
+			result[path.Join(target, name)] = content
+		}
+	}
+
+	return result, diags, nil
+}
@@ -0,0 +1,89 @@
+package dataproto
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aurora/dataproto/internal/checker"
+)
+
+func TestCompileCalendar(t *testing.T) {
+	source, err := os.ReadFile("../examples/aurora/calendar.dataproto")
+	if err != nil {
+		t.Fatalf("reading sample: %v", err)
+	}
+
+	files, diags, err := Compile(string(source), "calendar.dataproto", Options{Targets: []string{"proto", "sqlite"}})
+	if err != nil {
+		t.Fatalf("Compile error: %v (diagnostics: %v)", err, diags)
+	}
+	if checker.HasErrors(diags) {
+		t.Errorf("Expected no errors, got %v", diags)
+	}
+
+	proto, ok := files["proto/acos.proto"]
+	if !ok {
+		t.Fatalf("Expected proto/acos.proto, got files %v", keys(files))
+	}
+	if !strings.Contains(proto, "message CalendarEvent {") {
+		t.Errorf("Expected CalendarEvent message, got:\n%s", proto)
+	}
+
+	ddl, ok := files["sqlite/acos_schema.sql"]
+	if !ok {
+		t.Fatalf("Expected sqlite/acos_schema.sql, got files %v", keys(files))
+	}
+	if !strings.Contains(ddl, "CREATE TABLE IF NOT EXISTS calendar_events (") {
+		t.Errorf("Expected calendar_events table, got:\n%s", ddl)
+	}
+}
+
+func TestCompileReportsErrors(t *testing.T) {
+	files, diags, err := Compile(`
+package test;
+
+entity Item {
+    title: string;
+}
+`, "item.dataproto", Options{Targets: []string{"proto"}})
+	if !errors.Is(err, ErrInvalidSchema) {
+		t.Fatalf("Expected ErrInvalidSchema, got %v", err)
+	}
+	if files != nil || !checker.HasErrors(diags) {
+		t.Errorf("Expected diagnostics and no files, got %v, %v", files, diags)
+	}
+
+	if _, _, err := Compile("package test;", "x.dataproto", Options{Targets: []string{"cobol"}}); err == nil {
+		t.Error("Expected an error for an unknown target")
+	}
+}
+
+func keys(m map[string]string) []string {
+	var names []string
+	for name := range m {
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestCompileDoesNotReusePackage(t *testing.T) {
+	targets := []string{"java", "kotlin", "mongodb", "qt"}
+	for _, pkg := range []string{"alpha.one", "beta.two"} {
+		src := "package " + pkg + ";\n\nentity Item {\n    @pk id: string;\n}\n"
+		files, _, err := Compile(src, "item.dataproto", Options{Targets: targets})
+		if err != nil {
+			t.Fatalf("Compile %s: %v", pkg, err)
+		}
+		for name, want := range map[string]string{
+			"java/ItemRepository.java": "package " + pkg + ";",
+			"kotlin/Item.kt":           "package " + pkg,
+			"qt/item.h":                "namespace " + strings.ReplaceAll(pkg, ".", "::"),
+		} {
+			if !strings.Contains(files[name], want) {
+				t.Errorf("Expected %q in %s, got:\n%s", want, name, files[name])
+			}
+		}
+	}
+}
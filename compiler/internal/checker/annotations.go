@@ -0,0 +1,106 @@
+package checker
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/aurora/dataproto/internal/parser"
+)
+
+// annotationNamedArgs lists the named arguments each known annotation accepts.
+// A nil entry means the annotation takes positional arguments only.
+// This is the single source of truth for annotation argument names; keep it
+// in sync when adding annotations or new argument forms.
+var annotationNamedArgs = map[string][]string{
+	// Entity-level
+	"table":       {"schema", "name"},
+	"backends":    nil,
+	"index":       {"fields", "unique", "where"},
+	"soft_delete": {"field"},
+	"softdelete":  {"field"},
+	"view":        {"query"},
+
+	// Entities, fields, enum values and rpcs
+	"deprecated": nil,
+
+	// Field-level as Entity.field, entity-level with named arguments
+	"fk": {"fields", "references", "ondelete"},
+
+	// Field-level
+	"pk":            nil,
+	"autoincrement": nil,
+	"required":      nil,
+	"indexed":       nil,
+	"unique":        nil,
+	"default":       nil,
+	"length":        {"min", "max"},
+	"pattern":       nil,
+	"json":          nil,
+	"range":         {"min", "max"},
+	"min":           nil,
+	"max":           nil,
+	"format":        nil,
+	"ondelete":      nil,
+	"generated":     {"stored"},
+	"computed":      nil,
+	"proto":         {"number"},
+	"renamed_from":  nil,
+	// "column" is keyed by backend name and validated by checkColumn, which
+	// only warns about unknown backends
+}
+
+// checkAnnotationArgs reports named arguments that the annotation's schema
+// does not declare. Unknown annotations are reported elsewhere.
+func (c *Checker) checkAnnotationArgs(ann *parser.Annotation) {
+	allowed, known := annotationNamedArgs[ann.Name]
+	if !known {
+		return
+	}
+
+	for _, arg := range ann.Args {
+		if arg.Name == "" || containsString(allowed, arg.Name) {
+			continue
+		}
+		if len(allowed) == 0 {
+			c.addError(ann, "@%s does not accept named arguments (got %s)", ann.Name, arg.Name)
+			continue
+		}
+		sorted := append([]string(nil), allowed...)
+		sort.Strings(sorted)
+		c.addError(ann, "unknown argument %s for @%s (expected one of: %s)",
+			arg.Name, ann.Name, strings.Join(sorted, ", "))
+	}
+}
+
+// checkDeprecated validates @deprecated, which takes an optional message.
+func (c *Checker) checkDeprecated(ann *parser.Annotation) {
+	if len(ann.Args) > 1 {
+		c.addError(ann, "@deprecated takes at most a message")
+	} else if len(ann.Args) == 1 {
+		if _, ok := ann.Args[0].Value.(string); !ok {
+			c.addError(ann, "@deprecated message must be a string")
+		}
+	}
+}
+
+// checkOnlyDeprecated validates the annotations of declarations that only
+// accept @deprecated, such as enum values and rpcs.
+func (c *Checker) checkOnlyDeprecated(kind string, annotations []*parser.Annotation) {
+	for _, ann := range annotations {
+		if ann.Name != "deprecated" {
+			c.addError(ann, "unknown %s annotation: @%s", kind, ann.Name)
+			continue
+		}
+		c.checkAnnotationArgs(ann)
+		c.checkDeprecated(ann)
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
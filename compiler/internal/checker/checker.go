@@ -3,34 +3,124 @@ package checker
 
 import (
 	"fmt"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/aurora/dataproto/internal/lexer"
+	"github.com/aurora/dataproto/internal/naming"
 	"github.com/aurora/dataproto/internal/parser"
 )
 
 // Checker performs semantic analysis on a parsed DataProto file.
 type Checker struct {
-	file   *parser.File
-	errors []Error
+	// WarnOptionalIndexed warns when @indexed or @unique is applied to an
+	// optional field, for backends that want key columns non-nullable
+	WarnOptionalIndexed bool
+
+	// UnsupportedStreaming lists the RPC streaming kinds the target backend
+	// cannot serve; rpcs of those kinds are reported as errors
+	UnsupportedStreaming []parser.StreamingKind
+
+	// StrictRpcTypes requires each rpc request and response type to be an
+	// entity, a declared message, a well-known message such as Result, or a
+	// query message the proto generator derives from the schema
+	// (<Entity><Query>Request and <Entity><Query>Response). By default any
+	// name ending in Request, Response, Result or Chunk, or containing an
+	// entity name, is accepted.
+	StrictRpcTypes bool
+
+	// ResolveFieldAccess checks a dotted reference such as
+	// event.calendar.name in a query of entity below its root, which has
+	// already been resolved to a field or parameter. A returned error is
+	// reported at the reference. When nil, paths below a known root are
+	// accepted unchecked.
+	ResolveFieldAccess func(entity *parser.EntityDecl, root string, path []string) error
+
+	// Loader, when set, resolves the checked file's imports relative to its
+	// filename. An import it cannot load or parse is an error, and one none
+	// of whose declarations the file refers to is a warning. The
+	// declarations of the others are visible as if passed to AddImport.
+	Loader parser.Loader
+
+	file     *parser.File
+	imports  []*parser.File
+	resolved []resolvedImport
+	errors   []Error
+
+	// scope is the declaration being checked; diagnostics on nodes without
+	// a position are reported at its position instead
+	scope parser.Node
 
 	// Symbol tables
 	enums    map[string]*parser.EnumDecl
 	entities map[string]*parser.EntityDecl
+	messages map[string]*parser.MessageDecl
 	services map[string]*parser.ServiceDecl
 }
 
-// Error represents a semantic error.
+// Severity classifies a diagnostic.
+type Severity int
+
+const (
+	// SeverityError marks a diagnostic that makes the schema invalid.
+	SeverityError Severity = iota
+	// SeverityWarning marks an advisory diagnostic.
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	if s == SeverityWarning {
+		return "warning"
+	}
+	return "error"
+}
+
+// Error represents a semantic error or, with SeverityWarning, a warning.
 type Error struct {
 	Position parser.Node
 	Message  string
+	Severity Severity
+	// Suggestion is the known name an unknown one most likely misspells,
+	// also named in Message; empty when there is none
+	Suggestion string
 }
 
 func (e Error) Error() string {
-	if e.Position != nil {
+	msg := e.Message
+	if e.Severity == SeverityWarning {
+		msg = "warning: " + msg
+	}
+	if hasPosition(e.Position) {
 		pos := e.Position.Pos()
-		return fmt.Sprintf("%d:%d: %s", pos.Line, pos.Column, e.Message)
+		return fmt.Sprintf("%d:%d: %s", pos.Line, pos.Column, msg)
+	}
+	return msg
+}
+
+// RenderError renders e as a snippet of src, the source of the file it was
+// reported for, with a caret under the offending column.
+func RenderError(src string, e Error) string {
+	var pos lexer.Position
+	if hasPosition(e.Position) {
+		pos = e.Position.Pos()
 	}
-	return e.Message
+	return parser.RenderSnippet(src, pos, e.Severity.String(), e.Message)
+}
+
+// hasPosition reports whether node carries a real source location.
+func hasPosition(node parser.Node) bool {
+	return node != nil && node.Pos().Line > 0
+}
+
+// HasErrors reports whether errs contains anything more severe than a warning.
+func HasErrors(errs []Error) bool {
+	for _, e := range errs {
+		if e.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
 }
 
 // New creates a new Checker for the given file.
@@ -39,36 +129,106 @@ func New(file *parser.File) *Checker {
 		file:     file,
 		enums:    make(map[string]*parser.EnumDecl),
 		entities: make(map[string]*parser.EntityDecl),
+		messages: make(map[string]*parser.MessageDecl),
 		services: make(map[string]*parser.ServiceDecl),
 	}
 }
 
+// AddImport makes the declarations of an imported file visible to the checked
+// file. Imported declarations are resolved against but not checked themselves.
+func (c *Checker) AddImport(file *parser.File) {
+	c.imports = append(c.imports, file)
+}
+
 // Check performs semantic analysis and returns any errors.
 func (c *Checker) Check() []Error {
 	// Phase 1: Build symbol tables
+	if c.Loader != nil {
+		c.resolveImports()
+	}
 	c.buildSymbolTables()
 
+	if c.file.Package != nil {
+		c.checkPackage(c.file.Package)
+	}
+	for _, opt := range c.file.Options {
+		c.checkOption(opt)
+	}
+
+	for _, enum := range c.file.Enums {
+		c.checkEnum(enum)
+	}
+
 	// Phase 2: Check entities
 	for _, entity := range c.file.Entities {
 		c.checkEntity(entity)
 	}
 
+	for _, msg := range c.file.Messages {
+		c.checkMessage(msg)
+	}
+
 	// Phase 3: Check services
 	for _, svc := range c.file.Services {
 		c.checkService(svc)
 	}
 
+	c.checkUnusedImports()
 	return c.errors
 }
 
 func (c *Checker) addError(node parser.Node, format string, args ...interface{}) {
 	c.errors = append(c.errors, Error{
-		Position: node,
+		Position: c.locate(node),
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (c *Checker) addWarning(node parser.Node, format string, args ...interface{}) {
+	c.errors = append(c.errors, Error{
+		Position: c.locate(node),
 		Message:  fmt.Sprintf(format, args...),
+		Severity: SeverityWarning,
+	})
+}
+
+// addUnknown reports an unknown name, suggesting the closest of candidates
+// when one is near enough to be a likely typo.
+func (c *Checker) addUnknown(node parser.Node, kind, name string, candidates []string) {
+	suggestion := suggest(name, candidates)
+	c.errors = append(c.errors, Error{
+		Position:   c.locate(node),
+		Message:    fmt.Sprintf("unknown %s: %s%s", kind, name, didYouMean(suggestion)),
+		Suggestion: suggestion,
 	})
 }
 
+// locate returns node, or the enclosing declaration when node has no
+// position (e.g. synthesized AST nodes).
+func (c *Checker) locate(node parser.Node) parser.Node {
+	if !hasPosition(node) && hasPosition(c.scope) {
+		return c.scope
+	}
+	return node
+}
+
 func (c *Checker) buildSymbolTables() {
+	// Register imported declarations first so local duplicates are reported
+	for _, imp := range c.imports {
+		for _, enum := range imp.Enums {
+			c.enums[enum.Name] = enum
+		}
+		for _, entity := range imp.Entities {
+			c.entities[entity.Name] = entity
+		}
+		for _, msg := range imp.Messages {
+			c.messages[msg.Name] = msg
+		}
+		for _, svc := range imp.Services {
+			c.services[svc.Name] = svc
+		}
+	}
+
 	// Register enums
 	for _, enum := range c.file.Enums {
 		if _, exists := c.enums[enum.Name]; exists {
@@ -85,6 +245,16 @@ func (c *Checker) buildSymbolTables() {
 		c.entities[entity.Name] = entity
 	}
 
+	// Register messages; they share the proto namespace with entities
+	for _, msg := range c.file.Messages {
+		if _, exists := c.messages[msg.Name]; exists {
+			c.addError(msg, "duplicate message: %s", msg.Name)
+		} else if _, exists := c.entities[msg.Name]; exists {
+			c.addError(msg, "message %s has the same name as an entity", msg.Name)
+		}
+		c.messages[msg.Name] = msg
+	}
+
 	// Register services
 	for _, svc := range c.file.Services {
 		if _, exists := c.services[svc.Name]; exists {
@@ -94,57 +264,146 @@ func (c *Checker) buildSymbolTables() {
 	}
 }
 
+// aggregateFunctions are the functions allowed in a query's select list.
+var aggregateFunctions = map[string]bool{
+	"COUNT": true,
+	"SUM":   true,
+	"AVG":   true,
+	"MIN":   true,
+	"MAX":   true,
+}
+
+// arity is the number of arguments a function accepts; max -1 means any
+// number from min up.
+type arity struct {
+	min, max int
+}
+
+// functionArity lists the functions queries may call.
+var functionArity = map[string]arity{
+	"NOW":      {0, 0},
+	"COUNT":    {1, 1},
+	"SUM":      {1, 1},
+	"AVG":      {1, 1},
+	"MIN":      {1, 1},
+	"MAX":      {1, 1},
+	"COALESCE": {1, -1},
+}
+
+// checkPackage validates that each package segment is usable as a package or
+// namespace name in the generated languages.
+func (c *Checker) checkPackage(pkg *parser.PackageDecl) {
+	for _, segment := range strings.Split(pkg.Name, ".") {
+		if !isIdentifier(segment) {
+			c.addError(pkg, "invalid package segment: %q", segment)
+			continue
+		}
+		if langs := reservedIn(segment); len(langs) > 0 {
+			c.addWarning(pkg, "package segment %q is a reserved word in %s",
+				segment, strings.Join(langs, ", "))
+		}
+	}
+}
+
+// isIdentifier reports whether s is an ASCII identifier.
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
 func (c *Checker) checkEntity(entity *parser.EntityDecl) {
+	c.scope = entity
+	defer func() { c.scope = nil }()
+
 	// Check annotations
 	c.checkEntityAnnotations(entity)
+	c.checkExtends(entity)
 
 	// Check fields
-	fieldNames := make(map[string]bool)
-	hasPrimaryKey := false
+	fieldNames := make(map[string]*parser.FieldDecl)
 
 	for _, field := range entity.Fields {
 		// Check duplicate field names
-		if fieldNames[field.Name] {
-			c.addError(field, "duplicate field: %s", field.Name)
+		if prev := fieldNames[field.Name]; prev != nil {
+			if prev.Inherited != "" && field.Inherited == "" {
+				c.addError(field, "field %s of entity %s conflicts with field inherited from %s",
+					field.Name, entity.Name, prev.Inherited)
+			} else {
+				c.addError(field, "duplicate field: %s", field.Name)
+			}
 		}
-		fieldNames[field.Name] = true
+		fieldNames[field.Name] = field
 
 		// Check field type
 		c.checkType(field.Type)
 
 		// Check field annotations
-		c.checkFieldAnnotations(field)
-
-		// Track primary key
-		if field.IsPrimaryKey() {
-			if hasPrimaryKey {
-				c.addError(field, "entity %s has multiple primary keys", entity.Name)
-			}
-			hasPrimaryKey = true
-		}
+		c.checkFieldAnnotations(entity, field)
 	}
 
-	// Warn if no primary key
-	if !hasPrimaryKey && len(entity.Fields) > 0 {
+	// Warn if no primary key; multiple @pk fields form a composite key.
+	// Views are read-only and take no keys, and abstract entities leave
+	// the key to the entities extending them.
+	if len(entity.PrimaryKeyFields()) == 0 && len(entity.Fields) > 0 && entity.View() == nil && !entity.IsAbstract() {
 		c.addError(entity, "entity %s has no primary key (@pk)", entity.Name)
 	}
 
+	c.checkForeignKeys(entity)
+	c.checkChecks(entity)
+	c.checkProtoNumbers(entity)
+	c.checkSQLNames(entity)
+	c.checkJSONNames(entity)
+
 	// Check queries
 	for _, query := range entity.Queries {
 		c.checkQuery(entity, query)
 	}
 }
 
+// checkExtends reports a base entity that is not declared, and an entity
+// whose chain of bases leads back to itself.
+func (c *Checker) checkExtends(entity *parser.EntityDecl) {
+	if entity.Extends == "" {
+		return
+	}
+	if _, ok := c.entities[entity.Extends]; !ok {
+		c.addError(entity, "entity %s extends unknown entity %s", entity.Name, entity.Extends)
+		return
+	}
+
+	chain := []string{entity.Name}
+	seen := map[string]bool{entity.Name: true}
+	for base := c.entities[entity.Extends]; base != nil; base = c.entities[base.Extends] {
+		chain = append(chain, base.Name)
+		if base == entity {
+			c.addError(entity, "cyclic inheritance: %s", strings.Join(chain, " extends "))
+			return
+		}
+		if seen[base.Name] {
+			// A cycle further up the chain; reported on its own entities
+			return
+		}
+		seen[base.Name] = true
+	}
+}
+
 func (c *Checker) checkEntityAnnotations(entity *parser.EntityDecl) {
 	for _, ann := range entity.Annotations {
+		c.checkAnnotationArgs(ann)
+
 		switch ann.Name {
 		case "table":
-			// Check that table name is provided
-			if len(ann.Args) == 0 {
-				c.addError(ann, "@table requires a table name")
-			} else if _, ok := ann.Args[0].Value.(string); !ok {
-				c.addError(ann, "@table argument must be a string")
-			}
+			c.checkTable(ann)
 
 		case "backends":
 			// Check that backends are valid
@@ -156,211 +415,1462 @@ func (c *Checker) checkEntityAnnotations(entity *parser.EntityDecl) {
 				}
 			}
 
-		default:
-			c.addError(ann, "unknown entity annotation: @%s", ann.Name)
-		}
-	}
-}
-
-func (c *Checker) checkFieldAnnotations(field *parser.FieldDecl) {
-	for _, ann := range field.Annotations {
-		switch ann.Name {
-		case "pk", "required", "indexed", "unique":
-			// No arguments required
+		case "fk":
+			// Validated in checkForeignKeys once all fields are known
 
-		case "default":
-			if len(ann.Args) == 0 {
-				c.addError(ann, "@default requires a value")
-			}
+		case "check":
+			// Validated in checkChecks once all fields are known
 
-		case "length":
-			// Check for valid length arguments
-			if len(ann.Args) == 0 {
-				c.addError(ann, "@length requires arguments")
-			}
+		case "index":
+			c.checkIndex(entity, ann)
 
-		case "pattern":
-			if len(ann.Args) == 0 {
-				c.addError(ann, "@pattern requires a regex string")
-			}
+		case "soft_delete", "softdelete":
+			c.checkSoftDelete(entity, ann)
 
-		case "range":
-			if len(ann.Args) < 2 {
-				c.addError(ann, "@range requires min and max values")
-			}
+		case "view":
+			c.checkView(entity, ann)
 
-		case "fk":
-			if len(ann.Args) == 0 {
-				c.addError(ann, "@fk requires Entity.field reference")
-			} else if ref, ok := ann.Args[0].Value.(string); ok {
-				parts := strings.Split(ref, ".")
-				if len(parts) != 2 {
-					c.addError(ann, "@fk must be in format Entity.field")
-				} else if _, exists := c.entities[parts[0]]; !exists {
-					c.addError(ann, "unknown entity in @fk: %s", parts[0])
-				}
-			}
+		case "deprecated":
+			c.checkDeprecated(ann)
 
-		case "ondelete":
-			if len(ann.Args) == 0 {
-				c.addError(ann, "@ondelete requires action (cascade, setnull, restrict)")
-			}
+		case "abstract":
+			c.checkAbstract(entity, ann)
 
 		default:
-			c.addError(ann, "unknown field annotation: @%s", ann.Name)
+			c.addError(ann, "unknown entity annotation: @%s", ann.Name)
 		}
 	}
-
-	// Check annotation combinations
-	if field.IsPrimaryKey() && field.Type.Optional {
-		c.addError(field, "primary key cannot be optional")
-	}
 }
 
-func (c *Checker) checkType(typeRef *parser.TypeRef) {
-	// Check if type is a built-in type
-	builtinTypes := map[string]bool{
-		"string":    true,
-		"int32":     true,
-		"int64":     true,
-		"float":     true,
-		"double":    true,
-		"bool":      true,
-		"bytes":     true,
-		"timestamp": true,
-	}
-
-	if builtinTypes[typeRef.Name] {
-		return
+// checkAbstract validates @abstract, which takes no arguments. An abstract
+// entity has no repository to put queries on, and no table for views to
+// read from.
+func (c *Checker) checkAbstract(entity *parser.EntityDecl, ann *parser.Annotation) {
+	if len(ann.Args) > 0 {
+		c.addError(ann, "@abstract takes no arguments")
 	}
-
-	// Check if type is a known enum
-	if _, exists := c.enums[typeRef.Name]; exists {
-		return
+	if entity.View() != nil {
+		c.addError(ann, "view %s cannot be abstract", entity.Name)
 	}
-
-	// Check if type is a known entity
-	if _, exists := c.entities[typeRef.Name]; exists {
-		return
+	for _, query := range entity.Queries {
+		c.addError(query, "abstract entity %s cannot declare queries", entity.Name)
 	}
-
-	c.addError(typeRef, "unknown type: %s", typeRef.Name)
 }
 
-func (c *Checker) checkQuery(entity *parser.EntityDecl, query *parser.QueryDecl) {
-	// Build a set of valid identifiers for the query
-	validIdents := make(map[string]bool)
-
-	// Add field names
-	for _, field := range entity.Fields {
-		validIdents[field.Name] = true
+// checkTable validates @table("name") and @table(schema: "s", name: "name").
+// The name is given one way or the other; every argument is a string.
+func (c *Checker) checkTable(ann *parser.Annotation) {
+	positional := 0
+	for _, arg := range ann.Args {
+		if _, ok := arg.Value.(string); !ok {
+			c.addError(ann, "@table argument must be a string")
+			return
+		}
+		if arg.Name == "" {
+			positional++
+		}
 	}
 
-	// Add parameter names
-	for _, param := range query.Params {
-		validIdents[param.Name] = true
-		c.checkType(param.Type)
+	named := ann.NamedArg("name") != nil
+	switch {
+	case positional == 0 && !named:
+		c.addError(ann, "@table requires a table name")
+	case positional > 1, positional == 1 && named:
+		c.addError(ann, "@table takes a single table name")
 	}
+}
 
-	// Check WHERE expression
-	if query.Where != nil {
-		c.checkExpr(query.Where, validIdents)
+// checkSoftDelete validates that the @soft_delete field exists and is a
+// nullable timestamp, since NULL marks a live row. @softdelete is an alias.
+func (c *Checker) checkSoftDelete(entity *parser.EntityDecl, ann *parser.Annotation) {
+	if ann != entity.SoftDeleteAnnotation() {
+		c.addError(ann, "entity %s declares soft deletion twice", entity.Name)
+		return
 	}
-
-	// Check ORDER BY fields
-	for _, ob := range query.OrderBy {
-		if !validIdents[ob.Field] {
-			c.addError(ob, "unknown field in ORDER BY: %s", ob.Field)
+	if len(ann.Args) > 1 {
+		c.addError(ann, "@%s takes a single field name", ann.Name)
+		return
+	}
+	if len(ann.Args) > 0 {
+		if _, ok := ann.Args[0].Value.(string); !ok {
+			c.addError(ann, "@%s field name must be a string", ann.Name)
+			return
 		}
 	}
-
-	// Check LIMIT
-	if query.Limit != nil {
-		c.checkExpr(query.Limit, validIdents)
+	name := entity.SoftDeleteField()
+	for _, f := range entity.Fields {
+		if f.Name != name {
+			continue
+		}
+		if f.Type.Name != "timestamp" || !f.Type.Optional {
+			c.addError(f, "@%s field %s must be an optional timestamp", ann.Name, name)
+		}
+		return
 	}
+	c.addError(ann, "@%s requires a nullable timestamp field %s", ann.Name, name)
 }
 
-func (c *Checker) checkExpr(expr parser.Expr, validIdents map[string]bool) {
-	switch e := expr.(type) {
-	case *parser.BinaryExpr:
-		c.checkExpr(e.Left, validIdents)
-		c.checkExpr(e.Right, validIdents)
+// viewWriteAnnotations are the annotations that constrain or index stored
+// rows, which a read-only view has none of.
+var viewWriteAnnotations = []string{"pk", "autoincrement", "required", "unique", "indexed", "default", "generated", "fk", "ondelete", "check"}
 
-	case *parser.UnaryExpr:
-		c.checkExpr(e.Operand, validIdents)
+// checkView validates a @view entity: it is defined by exactly one of an
+// embedded SELECT or a parameterless query of another entity, and declares
+// no keys, constraints or indexes.
+func (c *Checker) checkView(entity *parser.EntityDecl, ann *parser.Annotation) {
+	view := entity.View()
+	hasSelect := len(ann.Args) > 0 && ann.Args[0].Name == ""
+	hasQuery := ann.NamedArg("query") != nil
 
-	case *parser.IsNullExpr:
-		c.checkExpr(e.Operand, validIdents)
+	switch {
+	case hasSelect == hasQuery:
+		c.addError(ann, "@view requires either a SELECT statement or query: \"Entity.query\"")
+	case hasSelect && view.Select == "":
+		c.addError(ann, "@view SELECT statement must be a non-empty string")
+	case hasQuery:
+		c.checkViewQuery(entity, ann, view)
+	}
 
-	case *parser.IdentExpr:
-		// Allow known functions and SQL keywords
-		knownFunctions := map[string]bool{
-			"NOW":     true,
-			"COUNT":   true,
-			"SUM":     true,
-			"AVG":     true,
-			"MIN":     true,
-			"MAX":     true,
-			"COALESCE": true,
-		}
-		if !validIdents[e.Name] && !knownFunctions[e.Name] {
-			c.addError(e, "unknown identifier: %s", e.Name)
+	for _, a := range entity.Annotations {
+		switch a.Name {
+		case "index", "fk", "soft_delete", "softdelete", "check":
+			c.addError(a, "view %s cannot use @%s", entity.Name, a.Name)
 		}
-
-	case *parser.CallExpr:
-		for _, arg := range e.Args {
-			c.checkExpr(arg, validIdents)
+	}
+	for _, field := range entity.Fields {
+		for _, a := range field.Annotations {
+			if containsString(viewWriteAnnotations, a.Name) {
+				c.addError(a, "view %s cannot use @%s on field %s", entity.Name, a.Name, field.Name)
+			}
 		}
-
-	case *parser.ParenExpr:
-		c.checkExpr(e.Inner, validIdents)
-
-	case *parser.LiteralExpr:
-		// Literals are always valid
 	}
 }
 
-func (c *Checker) checkService(svc *parser.ServiceDecl) {
-	for _, rpc := range svc.Methods {
-		// Check request type
-		c.checkRpcType(rpc.RequestType)
-
-		// Check response type
-		c.checkRpcType(rpc.ResponseType)
+// checkViewQuery resolves the query a @view refers to. A view cannot pass
+// arguments, so the query must take no parameters.
+func (c *Checker) checkViewQuery(entity *parser.EntityDecl, ann *parser.Annotation, view *parser.View) {
+	if _, ok := ann.NamedArg("query").(string); !ok || view.Entity == "" || view.Query == "" {
+		c.addError(ann, "@view query must be a string of the form \"Entity.query\"")
+		return
+	}
+	if view.Entity == entity.Name {
+		c.addError(ann, "view %s cannot select from itself", entity.Name)
+		return
+	}
+	target := c.file.Entity(view.Entity)
+	if target == nil {
+		c.addError(ann, "@view query refers to unknown entity %s", view.Entity)
+		return
+	}
+	query := target.Query(view.Query)
+	if query == nil {
+		c.addError(ann, "@view query refers to unknown query %s.%s", view.Entity, view.Query)
+		return
+	}
+	if len(query.Params) > 0 {
+		c.addError(ann, "@view query %s.%s cannot take parameters", view.Entity, view.Query)
 	}
 }
 
-func (c *Checker) checkRpcType(rpcType *parser.RpcType) {
-	// Check if type is a known entity or a standard message type
-	knownTypes := map[string]bool{
-		"PushResult":    true,
-		"Result":        true,
-		"Empty":         true,
+// checkGenerated validates a @generated("expr", stored: bool) column.
+// Postgres only supports stored generated columns.
+func (c *Checker) checkGenerated(entity *parser.EntityDecl, field *parser.FieldDecl, ann *parser.Annotation) {
+	if len(ann.Args) == 0 || ann.Args[0].Name != "" {
+		c.addError(ann, "@generated requires an SQL expression")
+		return
 	}
-
-	if _, exists := c.entities[rpcType.Name]; exists {
+	if _, ok := ann.Args[0].Value.(string); !ok {
+		c.addError(ann, "@generated expression must be a string")
 		return
 	}
 
-	if knownTypes[rpcType.Name] {
+	if field.HasAnnotation("default") {
+		c.addError(ann, "generated column %s cannot have @default", field.Name)
+	}
+	if field.IsPrimaryKey() {
+		c.addError(ann, "generated column %s cannot be a primary key", field.Name)
+	}
+
+	stored := ann.NamedArg("stored")
+	if stored == nil {
+		return
+	}
+	if _, ok := stored.(bool); !ok {
+		c.addError(ann, "@generated stored must be true or false")
 		return
 	}
+	if stored == false && targetsBackend(entity, "postgres", "postgresql") {
+		c.addError(ann, "virtual generated column %s is not supported by postgres (use stored: true or restrict @backends)", field.Name)
+	}
+}
 
-	// Check for Request/Response message types
-	if strings.HasSuffix(rpcType.Name, "Request") || strings.HasSuffix(rpcType.Name, "Response") {
-		return // These are typically generated
+// checkComputed validates @computed, whose expression derives the field from
+// the entity's other fields. Postgres cannot base a generated column on
+// another one, so the expression may only use stored fields.
+func (c *Checker) checkComputed(entity *parser.EntityDecl, field *parser.FieldDecl, ann *parser.Annotation) {
+	src, expr, ok := field.Computed()
+	if !ok || len(ann.Args) != 1 || ann.Args[0].Name != "" {
+		c.addError(ann, "@computed requires a single expression string")
+		return
+	}
+	if expr == nil {
+		_, err := parser.ParseExpr(src)
+		c.addError(ann, "invalid @computed expression %q: %v", src, err)
+		return
 	}
 
-	// Allow any type that starts with entity name (e.g., GetEventsRequest for CalendarEvent)
-	for name := range c.entities {
-		if strings.Contains(rpcType.Name, name) {
-			return
+	for _, name := range []string{"generated", "default", "pk", "autoincrement"} {
+		if field.HasAnnotation(name) {
+			c.addError(ann, "computed field %s cannot have @%s", field.Name, name)
+		}
+	}
+
+	types := make(map[string]string)
+	for _, f := range entity.Fields {
+		types[f.Name] = f.Type.Name
+	}
+	for _, ident := range exprIdents(expr) {
+		switch ref := entity.Field(ident.Name); {
+		case ref == nil:
+			c.addError(ann, "@computed refers to unknown field %s of %s", ident.Name, entity.Name)
+		case ref == field:
+			c.addError(ann, "computed field %s cannot refer to itself", field.Name)
+		case ref.HasAnnotation("computed") || ref.HasAnnotation("generated"):
+			c.addError(ann, "computed field %s cannot refer to computed field %s", field.Name, ref.Name)
+		}
+	}
+	c.checkOperandTypes(expr, types)
+
+	got := exprType(expr, types)
+	if got != "" && got != field.Type.Name && !(numericTypes[got] && numericTypes[field.Type.Name]) {
+		c.addError(ann, "@computed expression of %s is %s, but the field is %s", field.Name, got, field.Type.Name)
+	}
+}
+
+// checkColumn validates @column, which overrides the column type per
+// backend. An unknown backend is only a warning, since the override is
+// simply never used.
+func (c *Checker) checkColumn(field *parser.FieldDecl, ann *parser.Annotation) {
+	if len(ann.Args) == 0 {
+		c.addError(ann, "@column requires a type for at least one backend, as in postgres: \"NUMERIC(10,2)\"")
+		return
+	}
+	for _, arg := range ann.Args {
+		if arg.Name == "" {
+			c.addError(ann, "@column types must be named by backend, as in postgres: \"NUMERIC(10,2)\"")
+			continue
+		}
+		if s, ok := arg.Value.(string); !ok || strings.TrimSpace(s) == "" {
+			c.addError(ann, "@column type for %s must be a non-empty string", arg.Name)
+			continue
+		}
+		if !isValidBackend(arg.Name) {
+			c.addWarning(ann, "@column on %s overrides the type for unknown backend %s", field.Name, arg.Name)
+		}
+	}
+}
+
+// checkAutoIncrement validates @autoincrement, which the database fills in
+// for a single integer primary key.
+func (c *Checker) checkAutoIncrement(entity *parser.EntityDecl, field *parser.FieldDecl, ann *parser.Annotation) {
+	if len(ann.Args) > 0 {
+		c.addError(ann, "@autoincrement takes no arguments")
+	}
+	if field.Type.Name != "int32" && field.Type.Name != "int64" {
+		c.addError(ann, "@autoincrement field %s must be int32 or int64, got %s", field.Name, field.Type.Name)
+	}
+	if field.Type.Optional {
+		c.addError(ann, "@autoincrement field %s cannot be optional", field.Name)
+	}
+	if !field.IsPrimaryKey() {
+		c.addError(ann, "@autoincrement requires @pk on %s", field.Name)
+	} else if len(entity.PrimaryKeyFields()) > 1 {
+		c.addError(ann, "@autoincrement field %s cannot be part of a composite primary key", field.Name)
+	}
+	if field.HasAnnotation("default") {
+		c.addError(ann, "@autoincrement field %s cannot have @default", field.Name)
+	}
+}
+
+// targetsBackend reports whether entity is generated for any of the named
+// backends. Entities without @backends target every backend.
+func targetsBackend(entity *parser.EntityDecl, names ...string) bool {
+	backends := entity.Backends()
+	if len(backends) == 0 {
+		return true
+	}
+	for _, name := range names {
+		if containsString(backends, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIndex validates an entity-level @index(fields: [...]) annotation and
+// its optional unique flag and where predicate.
+func (c *Checker) checkIndex(entity *parser.EntityDecl, ann *parser.Annotation) {
+	fields, ok := ann.NamedArg("fields").([]interface{})
+	if !ok || len(fields) == 0 {
+		c.addError(ann, "@index requires fields: [...]")
+		return
+	}
+
+	for _, v := range fields {
+		name, ok := v.(string)
+		if !ok {
+			c.addError(ann, "@index fields must be strings")
+			continue
+		}
+		name, _, ok = parser.ParseIndexField(name)
+		if !ok {
+			c.addError(ann, "@index field %q must be a field name, optionally followed by ASC or DESC", v)
+			continue
+		}
+		field := entity.Field(name)
+		if field == nil {
+			c.addError(ann, "unknown field in @index: %s", name)
+			continue
+		}
+		c.checkIndexable(field, "index", ann)
+	}
+
+	if unique := ann.NamedArg("unique"); unique != nil {
+		if _, ok := unique.(bool); !ok {
+			c.addError(ann, "@index unique must be true or false")
+		}
+	}
+
+	// A partial index predicate, like a @check condition, may only refer to
+	// the entity's own columns
+	if where := ann.NamedArg("where"); where != nil {
+		cond, ok := where.(string)
+		if !ok || cond == "" {
+			c.addError(ann, "@index where must be a condition string")
+			return
+		}
+		expr, err := parser.ParseExpr(cond)
+		if err != nil {
+			c.addError(ann, "invalid @index where condition %q: %v", cond, err)
+			return
+		}
+		for _, ident := range exprIdents(expr) {
+			if !hasField(entity, ident.Name) {
+				c.addError(ann, "@index where refers to unknown field %s of %s", ident.Name, entity.Name)
+			}
+		}
+	}
+}
+
+// checkIndexable rejects indexing a bytes field: blobs make large, rarely
+// useful index entries, and some backends cannot index them at all.
+func (c *Checker) checkIndexable(field *parser.FieldDecl, annotation string, ann *parser.Annotation) {
+	if field.Type.Name == "bytes" {
+		c.addError(ann, "@%s cannot be applied to bytes field %s; index a hash or digest of it in a separate field instead",
+			annotation, field.Name)
+	}
+}
+
+// hasField reports whether entity declares a field with the given name.
+func hasField(entity *parser.EntityDecl, name string) bool {
+	for _, f := range entity.Fields {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// checkChecks validates @check conditions: each must parse as an expression
+// over the entity's own fields, since it becomes a CHECK constraint.
+func (c *Checker) checkChecks(entity *parser.EntityDecl) {
+	for _, check := range entity.Checks() {
+		if check.Condition == "" {
+			c.addError(check.Annotation, "@check requires a condition string")
+			continue
+		}
+		if check.Expr == nil {
+			_, err := parser.ParseExpr(check.Condition)
+			c.addError(check.Annotation, "invalid @check condition %q: %v", check.Condition, err)
+			continue
+		}
+		for _, ident := range exprIdents(check.Expr) {
+			if !hasField(entity, ident.Name) {
+				c.addError(check.Annotation, "@check refers to unknown field %s of %s", ident.Name, entity.Name)
+			}
+		}
+	}
+}
+
+// exprIdents returns the identifiers expr refers to, in source order,
+// including the root of each field access.
+func exprIdents(expr parser.Expr) []*parser.IdentExpr {
+	var idents []*parser.IdentExpr
+	parser.WalkExpr(expr, func(e parser.Expr) {
+		if id, ok := e.(*parser.IdentExpr); ok {
+			idents = append(idents, id)
+		}
+	})
+	return idents
+}
+
+// checkForeignKeys validates entity-level multi-column @fk annotations: the
+// listed fields must exist and match the target's primary key in arity and type.
+func (c *Checker) checkForeignKeys(entity *parser.EntityDecl) {
+	fields := make(map[string]*parser.FieldDecl)
+	for _, f := range entity.Fields {
+		fields[f.Name] = f
+	}
+
+	for _, fk := range entity.ForeignKeys() {
+		if len(fk.Fields) == 0 || fk.References == "" {
+			c.addError(fk.Annotation, "@fk on an entity requires fields: [...] and references: \"Entity\"")
+			continue
+		}
+
+		if fk.OnDelete != "" && !isValidOnDelete(fk.OnDelete) {
+			c.addError(fk.Annotation, "unknown @fk ondelete action: %s (expected cascade, setnull, or restrict)", fk.OnDelete)
+		}
+
+		target, exists := c.entities[fk.References]
+		if !exists {
+			c.addError(fk.Annotation, "unknown entity in @fk: %s", fk.References)
+			continue
+		}
+		if target.IsAbstract() {
+			c.addError(fk.Annotation, "@fk cannot reference abstract entity %s", target.Name)
+			continue
+		}
+
+		pks := target.PrimaryKeyFields()
+		if len(fk.Fields) != len(pks) {
+			c.addError(fk.Annotation, "@fk lists %d fields but %s has %d primary key fields",
+				len(fk.Fields), target.Name, len(pks))
+			continue
+		}
+
+		for i, name := range fk.Fields {
+			field, ok := fields[name]
+			if !ok {
+				c.addError(fk.Annotation, "unknown field in @fk: %s", name)
+				continue
+			}
+			if field.Type.Name != pks[i].Type.Name {
+				c.addError(fk.Annotation, "@fk field %s has type %s but %s.%s is %s",
+					name, field.Type.Name, target.Name, pks[i].Name, pks[i].Type.Name)
+			}
+		}
+	}
+}
+
+// checkJSONNames reports fields that would be serialized under the same
+// name, whether derived or set with @json.
+func (c *Checker) checkJSONNames(entity *parser.EntityDecl) {
+	seen := make(map[string]string)
+	for _, field := range entity.Fields {
+		name := field.JSONName()
+		if name == "" {
+			name = naming.ToSnakeCase(field.Name)
+		}
+		if other, ok := seen[name]; ok && other != field.Name {
+			c.addError(field, "json name %q of field %s is already used by field %s", name, field.Name, other)
+			continue
+		}
+		seen[name] = field.Name
+	}
+}
+
+func (c *Checker) checkFieldAnnotations(entity *parser.EntityDecl, field *parser.FieldDecl) {
+	for _, ann := range field.Annotations {
+		c.checkAnnotationArgs(ann)
+
+		switch ann.Name {
+		case "pk", "required":
+			// No arguments required
+
+		case "autoincrement":
+			c.checkAutoIncrement(entity, field, ann)
+
+		case "indexed", "unique":
+			c.checkIndexable(field, ann.Name, ann)
+
+		case "default":
+			if len(ann.Args) == 0 {
+				c.addError(ann, "@default requires a value")
+			} else if field.Type.Name == "timestamp" {
+				c.checkTimestampDefault(field, ann)
+			}
+
+		case "length":
+			c.checkLength(field, ann)
+
+		case "pattern":
+			if len(ann.Args) == 0 {
+				c.addError(ann, "@pattern requires a regex string")
+			}
+
+		case "deprecated":
+			c.checkDeprecated(ann)
+
+		case "json":
+			if len(ann.Args) != 1 {
+				c.addError(ann, "@json requires a single name")
+			} else if name, ok := ann.Args[0].Value.(string); !ok || name == "" {
+				c.addError(ann, "@json name must be a non-empty string")
+			}
+
+		case "range":
+			c.checkRange(field, ann)
+
+		case "min", "max":
+			if len(ann.Args) != 1 {
+				c.addError(ann, "@%s requires a single numeric bound", ann.Name)
+			} else if !numericTypes[field.Type.Name] {
+				c.addError(ann, "@%s requires a numeric field, %s is %s", ann.Name, field.Name, field.Type.Name)
+			} else if _, ok := ann.Args[0].Value.(int64); ok {
+				c.checkBound(field, ann)
+			} else if _, ok := ann.Args[0].Value.(float64); ok {
+				c.checkBound(field, ann)
+			} else {
+				c.addError(ann, "@%s bound must be a number", ann.Name)
+			}
+
+		case "format":
+			if field.Type.Name != "string" {
+				c.addError(ann, "@format requires a string field, %s is %s", field.Name, field.Type.Name)
+			} else if format := field.Format(); format == "" {
+				c.addError(ann, "@format requires a format name")
+			} else if !validFormats[format] {
+				c.addError(ann, "unknown @format: %s (expected email, uri, or uuid)", format)
+			}
+
+		case "fk":
+			if len(ann.Args) == 0 {
+				c.addError(ann, "@fk requires Entity.field reference")
+			} else if ann.Args[0].Name != "" {
+				c.addError(ann, "multi-column @fk must be declared on the entity")
+			} else if ref, ok := ann.Args[0].Value.(string); ok {
+				parts := strings.Split(ref, ".")
+				if len(parts) != 2 {
+					c.addError(ann, "@fk must be in format Entity.field")
+				} else if target, exists := c.entities[parts[0]]; !exists {
+					c.addError(ann, "unknown entity in @fk: %s", parts[0])
+				} else if target.IsAbstract() {
+					c.addError(ann, "@fk cannot reference abstract entity %s", parts[0])
+				}
+			}
+
+		case "generated":
+			c.checkGenerated(entity, field, ann)
+
+		case "computed":
+			c.checkComputed(entity, field, ann)
+
+		case "column":
+			c.checkColumn(field, ann)
+
+		case "check":
+			// Validated in checkChecks with the entity-level checks
+
+		case "proto":
+			if n, ok := field.ProtoNumber(); !ok {
+				c.addError(ann, "@proto requires number: N")
+			} else if n < 1 || n > maxProtoFieldNumber {
+				c.addError(ann, "proto field number %d must be between 1 and %d", n, maxProtoFieldNumber)
+			} else if n >= 19000 && n <= 19999 {
+				c.addError(ann, "proto field number %d is in the range reserved by protobuf (19000-19999)", n)
+			}
+
+		case "renamed_from":
+			if len(ann.Args) != 1 {
+				c.addError(ann, "@renamed_from requires the field's previous name")
+			} else if name, ok := ann.Args[0].Value.(string); !ok || name == "" {
+				c.addError(ann, "@renamed_from name must be a non-empty string")
+			} else if name == field.Name || entity.Field(name) != nil {
+				c.addError(ann, "field %s is renamed from %s, which entity %s still declares", field.Name, name, entity.Name)
+			}
+
+		case "ondelete":
+			if len(ann.Args) == 0 {
+				c.addError(ann, "@ondelete requires action (cascade, setnull, restrict)")
+			} else if action, ok := ann.Args[0].Value.(string); !ok || !isValidOnDelete(action) {
+				c.addError(ann, "unknown @ondelete action: %v (expected cascade, setnull, or restrict)", ann.Args[0].Value)
+			} else if !field.HasAnnotation("fk") {
+				c.addError(ann, "@ondelete requires @fk on the same field")
+			}
+
+		default:
+			c.addError(ann, "unknown field annotation: @%s", ann.Name)
+		}
+	}
+
+	// Check annotation combinations
+	if field.IsPrimaryKey() && field.Type.Optional {
+		c.addError(field, "primary key cannot be optional")
+	}
+	if field.IsPrimaryKey() && !primaryKeyTypes[field.Type.Name] {
+		c.addError(field, "primary key %s must be string, int32 or int64, got %s", field.Name, field.Type.Name)
+	}
+	if c.WarnOptionalIndexed && field.Type.Optional {
+		if field.IsIndexed() {
+			c.addWarning(field, "@indexed field %s is optional", field.Name)
+		}
+		if field.IsUnique() {
+			c.addWarning(field, "@unique field %s is optional", field.Name)
+		}
+	}
+}
+
+// checkTimestampDefault validates a string @default of a timestamp field:
+// it is either now or an RFC 3339 time, which generators convert to epoch
+// milliseconds or a native literal.
+func (c *Checker) checkTimestampDefault(field *parser.FieldDecl, ann *parser.Annotation) {
+	v, ok := ann.Args[0].Value.(string)
+	if !ok || strings.EqualFold(v, "now") {
+		return
+	}
+	if _, err := time.Parse(time.RFC3339, v); err != nil {
+		c.addError(ann, "@default of timestamp field %s must be now or an RFC 3339 time such as 2024-01-01T00:00:00Z, got %q",
+			field.Name, v)
+	}
+}
+
+// primaryKeyTypes are the field types a @pk field, or each field of a
+// composite key, may have. Other types compare or hash unreliably across
+// backends.
+var primaryKeyTypes = map[string]bool{
+	"string": true,
+	"int32":  true,
+	"int64":  true,
+}
+
+// numericTypes are the field types @range can bound.
+var numericTypes = map[string]bool{
+	"int32":  true,
+	"int64":  true,
+	"float":  true,
+	"double": true,
+}
+
+// checkEnum reports duplicate value names, and duplicate numbers unless the
+// enum sets option allow_alias = true.
+func (c *Checker) checkEnum(enum *parser.EnumDecl) {
+	for _, opt := range enum.Options {
+		if opt.Name != "allow_alias" {
+			c.addWarning(opt, "unknown enum option %q", opt.Name)
+		} else if _, ok := opt.Value.(bool); !ok {
+			c.addError(opt, "option allow_alias requires a bool value")
+		}
+	}
+
+	names := make(map[string]bool)
+	numbers := make(map[int]string)
+	for _, val := range enum.Values {
+		c.checkOnlyDeprecated("enum value", val.Annotations)
+
+		if names[val.Name] {
+			c.addError(val, "duplicate enum value %s in %s", val.Name, enum.Name)
+			continue
+		}
+		names[val.Name] = true
+
+		prev, dup := numbers[val.Number]
+		if !dup {
+			numbers[val.Number] = val.Name
+		} else if !enum.AllowAlias() {
+			c.addError(val, "enum value %s reuses number %d of %s; set option allow_alias = true to alias it",
+				val.Name, val.Number, prev)
+		}
+	}
+}
+
+// maxProtoFieldNumber is the largest field number protobuf allows.
+const maxProtoFieldNumber = parser.ReservedMax
+
+// checkProtoNumbers reports explicit proto field numbers used twice within
+// an entity, and fields reusing a reserved number or name.
+func (c *Checker) checkProtoNumbers(entity *parser.EntityDecl) {
+	for _, decl := range entity.Reserved {
+		for _, r := range decl.Ranges {
+			if r.Start < 1 || r.End > maxProtoFieldNumber || r.Start > r.End {
+				c.addError(decl, "invalid reserved range %d to %d", r.Start, r.End)
+			}
+		}
+	}
+
+	seen := make(map[int64]string)
+	for _, field := range entity.Fields {
+		if entity.IsReservedName(field.Name) {
+			c.addError(field, "field name %s is reserved", field.Name)
+		}
+		n, ok := field.ProtoNumber()
+		if !ok {
+			continue
+		}
+		if entity.IsReservedNumber(n) {
+			c.addError(field.GetAnnotation("proto"), "proto field number %d of %s is reserved", n, field.Name)
+			continue
+		}
+		if prev, dup := seen[n]; dup {
+			c.addError(field.GetAnnotation("proto"), "proto field number %d of %s is already used by %s", n, field.Name, prev)
+			continue
+		}
+		seen[n] = field.Name
+	}
+}
+
+// validFormats are the names accepted by @format.
+var validFormats = map[string]bool{
+	"email": true,
+	"uri":   true,
+	"uuid":  true,
+}
+
+// checkLength validates the bounds of a @length annotation.
+func (c *Checker) checkLength(field *parser.FieldDecl, ann *parser.Annotation) {
+	if len(ann.Args) == 0 {
+		c.addError(ann, "@length requires arguments")
+		return
+	}
+
+	positional := 0
+	for _, arg := range ann.Args {
+		if arg.Name == "" {
+			positional++
+		}
+		if _, ok := arg.Value.(int64); !ok {
+			c.addError(ann, "@length bounds must be integers")
+			return
+		}
+	}
+	if positional > 2 {
+		c.addError(ann, "@length takes at most a min and a max")
+		return
+	}
+
+	lc, _ := field.LengthConstraint()
+	if lc.Min != nil && *lc.Min < 0 {
+		c.addError(ann, "@length min %d must not be negative", *lc.Min)
+	}
+	if lc.Max != nil && *lc.Max < 0 {
+		c.addError(ann, "@length max %d must not be negative", *lc.Max)
+	}
+	if lc.Min != nil && lc.Max != nil && *lc.Min > *lc.Max {
+		c.addError(ann, "@length min %d is greater than max %d", *lc.Min, *lc.Max)
+	}
+}
+
+// checkRange validates that @range bounds a numeric field with numeric
+// bounds where min does not exceed max.
+func (c *Checker) checkRange(field *parser.FieldDecl, ann *parser.Annotation) {
+	if len(ann.Args) < 2 {
+		c.addError(ann, "@range requires min and max values")
+		return
+	}
+	if !numericTypes[field.Type.Name] {
+		c.addError(ann, "@range requires a numeric field, %s is %s", field.Name, field.Type.Name)
+		return
+	}
+	min, max, ok := field.Range()
+	if !ok {
+		c.addError(ann, "@range bounds must be numbers")
+		return
+	}
+	if min > max {
+		c.addError(ann, "@range min %v is greater than max %v", min, max)
+	}
+}
+
+// checkBound reports a @min or @max bound that disagrees with the field's
+// @range on the same side, and a @max below the field's @min. Codegen merges
+// the annotations into a single constraint, so a conflict has no meaning.
+func (c *Checker) checkBound(field *parser.FieldDecl, ann *parser.Annotation) {
+	min, max := field.NumericBounds()
+	bound := min
+	if ann.Name == "max" {
+		bound = max
+	}
+	if lo, hi, ok := field.Range(); ok {
+		rangeBound := lo
+		if ann.Name == "max" {
+			rangeBound = hi
+		}
+		if *bound != rangeBound {
+			c.addError(ann, "@%s %v conflicts with @range %s %v", ann.Name, *bound, ann.Name, rangeBound)
+		}
+		return
+	}
+	if ann.Name == "max" && min != nil && *min > *max {
+		c.addError(ann, "@min %v is greater than @max %v", *min, *max)
+	}
+}
+
+func (c *Checker) checkType(typeRef *parser.TypeRef) {
+	// Check if type is a built-in type
+	builtinTypes := map[string]bool{
+		"string":    true,
+		"int32":     true,
+		"int64":     true,
+		"float":     true,
+		"double":    true,
+		"bool":      true,
+		"bytes":     true,
+		"timestamp": true,
+	}
+
+	if builtinTypes[typeRef.Name] {
+		return
+	}
+
+	// Check if type is a known enum
+	if _, exists := c.enums[typeRef.Name]; exists {
+		return
+	}
+
+	// Check if type is a known entity; an abstract one is never generated
+	if entity, exists := c.entities[typeRef.Name]; exists {
+		if entity.IsAbstract() {
+			c.addError(typeRef, "abstract entity %s cannot be used as a type", typeRef.Name)
+		}
+		return
+	}
+
+	c.addError(typeRef, "unknown type: %s", typeRef.Name)
+}
+
+// checkMessage checks a standalone message. Its fields may refer to other
+// messages as well as entities and enums, and only @deprecated applies to
+// them since messages are never stored.
+func (c *Checker) checkMessage(msg *parser.MessageDecl) {
+	c.scope = msg
+	defer func() { c.scope = nil }()
+
+	c.checkOnlyDeprecated("message", msg.Annotations)
+
+	names := make(map[string]bool)
+	for _, field := range msg.Fields {
+		if names[field.Name] {
+			c.addError(field, "duplicate field: %s", field.Name)
+		}
+		names[field.Name] = true
+
+		if _, ok := c.messages[field.Type.Name]; !ok {
+			c.checkType(field.Type)
+		}
+		c.checkOnlyDeprecated("message field", field.Annotations)
+	}
+
+	// Messages take no @proto numbers, but reserve ranges and names as
+	// entities do
+	c.checkProtoNumbers(&parser.EntityDecl{Name: msg.Name, Fields: msg.Fields, Reserved: msg.Reserved})
+}
+
+func (c *Checker) checkQuery(entity *parser.EntityDecl, query *parser.QueryDecl) {
+	c.checkQueryAnnotations(entity, query)
+
+	// Build a set of valid identifiers for the query
+	validIdents := make(map[string]bool)
+
+	// Add field names
+	for _, field := range entity.Fields {
+		validIdents[field.Name] = true
+	}
+
+	// Add parameter names. A parameter shadows a field of the same name:
+	// every reference binds the parameter, so the field is unreachable.
+	for _, param := range query.Params {
+		if validIdents[param.Name] {
+			c.addWarning(param, "parameter %s of query %s shadows field %s; references to %s bind the parameter",
+				param.Name, query.Name, param.Name, param.Name)
+		}
+		validIdents[param.Name] = true
+		if param.Optional && param.HasDefault() {
+			c.addError(param, "optional parameter %s of query %s cannot have a default; use one or the other",
+				param.Name, query.Name)
+		}
+		c.checkType(param.Type)
+		c.checkParamAnnotations(param)
+		c.checkParamDefault(query, param)
+	}
+
+	// Check WHERE expression
+	if query.Where != nil {
+		c.checkExpr(query.Where, validIdents)
+		c.checkOperandTypes(query.Where, queryIdentTypes(entity, query))
+	}
+
+	// Check GROUP BY fields
+	grouped := make(map[string]bool)
+	for _, name := range query.GroupBy {
+		if !hasField(entity, name) {
+			c.addError(query, "unknown field in GROUP BY: %s", name)
+		}
+		grouped[name] = true
+	}
+
+	// Check HAVING; outside aggregates it may only use grouped fields
+	if query.Having != nil {
+		if len(grouped) == 0 {
+			c.addError(query.Having, "HAVING requires GROUP BY in query %s", query.Name)
+		}
+		c.checkHaving(query, query.Having, grouped, validIdents, "")
+		c.checkOperandTypes(query.Having, queryIdentTypes(entity, query))
+	}
+
+	// Check SELECT items; in a grouped query plain fields must be grouped
+	for _, item := range query.Select {
+		fn, field := parser.SplitSelectItem(item)
+		if fn != "" && !aggregateFunctions[fn] {
+			c.addError(query, "unknown aggregate in SELECT: %s", fn)
+			continue
+		}
+		if field == "*" {
+			if fn != "COUNT" {
+				c.addError(query, "only COUNT accepts * in SELECT")
+			}
+			continue
+		}
+		if !hasField(entity, field) {
+			c.addError(query, "unknown field in SELECT: %s", field)
+		} else if fn == "" && len(grouped) > 0 && !grouped[field] {
+			c.addError(query, "SELECT field %s must appear in GROUP BY or an aggregate", field)
+		}
+	}
+
+	// Check ORDER BY fields; a grouped query can only sort by grouped columns
+	for _, ob := range query.OrderBy {
+		if !validIdents[ob.Field] {
+			c.addError(ob, "unknown field in ORDER BY: %s", ob.Field)
+		} else if len(grouped) > 0 && !grouped[ob.Field] {
+			c.addError(ob, "ORDER BY field %s must appear in GROUP BY", ob.Field)
+		}
+	}
+
+	// Check LIMIT; a limit taken from a parameter should be capped
+	if query.Limit != nil {
+		c.checkExpr(query.Limit, validIdents)
+		if ident, ok := query.Limit.(*parser.IdentExpr); ok {
+			if param := query.Param(ident.Name); param != nil && param.GetAnnotation("max") == nil {
+				c.addWarning(ident, "limit parameter %s has no upper bound; cap it with @max(n)", param.Name)
+			}
+		}
+	}
+}
+
+// checkHaving validates a HAVING expression. Its operands must be grouped
+// fields, parameters, literals or aggregates; aggregate is the aggregate
+// function enclosing expr, if any.
+func (c *Checker) checkHaving(query *parser.QueryDecl, expr parser.Expr, grouped, validIdents map[string]bool, aggregate string) {
+	switch e := expr.(type) {
+	case *parser.IdentExpr:
+		switch {
+		case e.Name == "*":
+			if aggregate != "COUNT" {
+				c.addError(e, "only COUNT accepts * in HAVING")
+			}
+		case !validIdents[e.Name]:
+			c.addError(e, "unknown identifier: %s", e.Name)
+		case aggregate == "" && query.Param(e.Name) == nil && !grouped[e.Name]:
+			c.addError(e, "HAVING field %s must appear in GROUP BY or an aggregate", e.Name)
+		}
+
+	case *parser.CallExpr:
+		c.checkCall(e)
+		if aggregateFunctions[e.Name] {
+			if aggregate != "" {
+				c.addError(e, "aggregate %s cannot be nested in %s", e.Name, aggregate)
+			}
+			aggregate = e.Name
+		}
+		for _, arg := range e.Args {
+			c.checkHaving(query, arg, grouped, validIdents, aggregate)
+		}
+
+	case *parser.FieldAccessExpr:
+		c.checkFieldAccess(e, validIdents)
+		if root, _ := e.Path(); root != nil && aggregate == "" && query.Param(root.Name) == nil && !grouped[root.Name] {
+			c.addError(e, "HAVING field %s must appear in GROUP BY or an aggregate", root.Name)
+		}
+
+	case *parser.BinaryExpr:
+		c.checkHaving(query, e.Left, grouped, validIdents, aggregate)
+		c.checkHaving(query, e.Right, grouped, validIdents, aggregate)
+
+	case *parser.UnaryExpr:
+		c.checkHaving(query, e.Operand, grouped, validIdents, aggregate)
+
+	case *parser.IsNullExpr:
+		c.checkHaving(query, e.Operand, grouped, validIdents, aggregate)
+
+	case *parser.ParenExpr:
+		c.checkHaving(query, e.Inner, grouped, validIdents, aggregate)
+
+	case *parser.TupleExpr:
+		for _, elem := range e.Elements {
+			c.checkHaving(query, elem, grouped, validIdents, aggregate)
+		}
+
+	case *parser.CaseExpr:
+		for _, when := range e.Whens {
+			c.checkHaving(query, when.Cond, grouped, validIdents, aggregate)
+			c.checkHaving(query, when.Result, grouped, validIdents, aggregate)
+		}
+		if e.Else != nil {
+			c.checkHaving(query, e.Else, grouped, validIdents, aggregate)
+		}
+	}
+}
+
+// checkQueryAnnotations validates @returns and @include_deleted, the query
+// annotations.
+func (c *Checker) checkQueryAnnotations(entity *parser.EntityDecl, query *parser.QueryDecl) {
+	for _, ann := range query.Annotations {
+		switch ann.Name {
+		case "returns":
+			c.checkReturns(query, ann)
+
+		case "include_deleted":
+			if len(ann.Args) > 0 {
+				c.addError(ann, "@include_deleted takes no arguments")
+			} else if entity.SoftDeleteAnnotation() == nil {
+				c.addWarning(ann, "@include_deleted on query %s has no effect; entity %s has no @soft_delete",
+					query.Name, entity.Name)
+			}
+
+		default:
+			c.addError(ann, "unknown query annotation: @%s", ann.Name)
+		}
+	}
+}
+
+// checkReturns validates @returns(one|many) and warns when a query returning
+// one row asks for more.
+func (c *Checker) checkReturns(query *parser.QueryDecl, ann *parser.Annotation) {
+	c.checkAnnotationArgs(ann)
+
+	if len(ann.Args) != 1 || (ann.Args[0].Value != "one" && ann.Args[0].Value != "many") {
+		c.addError(ann, "@returns on query %s must be one or many", query.Name)
+		return
+	}
+	if lit, ok := query.Limit.(*parser.LiteralExpr); ok && query.ReturnsOne() {
+		if n, ok := lit.Value.(int64); ok && n > 1 {
+			c.addWarning(lit, "query %s returns one row but has limit %d", query.Name, n)
+		}
+	}
+}
+
+// checkParamAnnotations validates the annotations of a query parameter.
+// Only @max(n), capping an integer parameter such as a page size, is
+// accepted.
+func (c *Checker) checkParamAnnotations(param *parser.QueryParam) {
+	for _, ann := range param.Annotations {
+		if ann.Name != "max" {
+			c.addError(ann, "unknown query parameter annotation: @%s", ann.Name)
+			continue
+		}
+		c.checkAnnotationArgs(ann)
+
+		max, ok := param.MaxValue()
+		if len(ann.Args) != 1 || !ok {
+			c.addError(ann, "@max on parameter %s requires an integer", param.Name)
+			continue
+		}
+		if param.Type.Name != "int32" && param.Type.Name != "int64" {
+			c.addError(ann, "@max requires an integer parameter, %s is %s", param.Name, param.Type.Name)
+		}
+		if max < 1 {
+			c.addError(ann, "@max on parameter %s must be positive", param.Name)
+		}
+		if def, ok := param.Default.(int64); ok && def > max {
+			c.addError(ann, "default %d of parameter %s exceeds @max(%d)", def, param.Name, max)
+		}
+	}
+}
+
+// checkParamDefault validates a parameter's default expression. It is
+// evaluated before the query runs, so it may use functions and the
+// parameters declared before it, but not fields.
+func (c *Checker) checkParamDefault(query *parser.QueryDecl, param *parser.QueryParam) {
+	if param.DefaultExpr == nil {
+		return
+	}
+
+	params := make(map[string]bool)
+	types := make(map[string]string)
+	declared := true
+	for _, p := range query.Params {
+		params[p.Name] = true
+		if p == param {
+			declared = false
+		} else if declared {
+			types[p.Name] = p.Type.Name
+		}
+	}
+	for _, ident := range exprIdents(param.DefaultExpr) {
+		switch {
+		case ident.Name == param.Name:
+			c.addError(ident, "default of parameter %s cannot refer to itself", param.Name)
+		case params[ident.Name] && types[ident.Name] == "":
+			c.addError(ident, "default of parameter %s refers to parameter %s, which is declared after it",
+				param.Name, ident.Name)
+		}
+	}
+	c.checkExpr(param.DefaultExpr, params)
+	c.checkOperandTypes(param.DefaultExpr, types)
+
+	got := exprType(param.DefaultExpr, types)
+	if call, ok := unparen(param.DefaultExpr).(*parser.CallExpr); ok && strings.EqualFold(call.Name, "NOW") {
+		got = "timestamp"
+	}
+	want := param.Type.Name
+	if got != "" && got != want && !(numericTypes[got] && numericTypes[want]) {
+		c.addError(param.DefaultExpr, "default of parameter %s is %s, but the parameter is %s", param.Name, got, want)
+	}
+}
+
+// bareFunctions are the functions an expression may name without calling
+// them, such as NOW.
+var bareFunctions = map[string]bool{
+	"NOW":      true,
+	"COUNT":    true,
+	"SUM":      true,
+	"AVG":      true,
+	"MIN":      true,
+	"MAX":      true,
+	"COALESCE": true,
+}
+
+func (c *Checker) checkExpr(expr parser.Expr, validIdents map[string]bool) {
+	switch e := expr.(type) {
+	case *parser.BinaryExpr:
+		c.checkRowComparison(e)
+		c.checkExpr(e.Left, validIdents)
+		c.checkExpr(e.Right, validIdents)
+
+	case *parser.UnaryExpr:
+		c.checkExpr(e.Operand, validIdents)
+
+	case *parser.IsNullExpr:
+		c.checkExpr(e.Operand, validIdents)
+
+	case *parser.IdentExpr:
+		// Allow known functions and SQL keywords
+		if !validIdents[e.Name] && !bareFunctions[e.Name] {
+			c.addUnknown(e, "identifier", e.Name, append(sortedKeys(validIdents), sortedKeys(bareFunctions)...))
+		}
+
+	case *parser.FieldAccessExpr:
+		c.checkFieldAccess(e, validIdents)
+
+	case *parser.CallExpr:
+		c.checkCall(e)
+		for _, arg := range e.Args {
+			c.checkExpr(arg, validIdents)
+		}
+
+	case *parser.ParenExpr:
+		c.checkExpr(e.Inner, validIdents)
+
+	case *parser.TupleExpr:
+		for _, elem := range e.Elements {
+			c.checkExpr(elem, validIdents)
+		}
+
+	case *parser.CaseExpr:
+		for _, when := range e.Whens {
+			c.checkExpr(when.Cond, validIdents)
+			c.checkExpr(when.Result, validIdents)
+		}
+		if e.Else != nil {
+			c.checkExpr(e.Else, validIdents)
+		}
+
+	case *parser.LiteralExpr:
+		// Literals are always valid
+	}
+}
+
+// rowComparisonOps are the operators that compare row values element by
+// element.
+var rowComparisonOps = map[string]bool{"=": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true}
+
+// checkRowComparison reports a comparison between row values of different
+// sizes, or between a row value and a single value. IN is left alone: its
+// right side is a list of candidates, not a row.
+func (c *Checker) checkRowComparison(e *parser.BinaryExpr) {
+	if !rowComparisonOps[e.Op] {
+		return
+	}
+	left, leftRow := unparen(e.Left).(*parser.TupleExpr)
+	right, rightRow := unparen(e.Right).(*parser.TupleExpr)
+	switch {
+	case leftRow && rightRow:
+		if len(left.Elements) != len(right.Elements) {
+			c.addError(e, "row comparison %s has %d values on the left but %d on the right",
+				e.Op, len(left.Elements), len(right.Elements))
+		}
+	case leftRow:
+		c.addError(e, "cannot compare a row of %d values with a single value", len(left.Elements))
+	case rightRow:
+		c.addError(e, "cannot compare a single value with a row of %d values", len(right.Elements))
+	}
+}
+
+// unparen strips any enclosing parentheses from expr.
+func unparen(expr parser.Expr) parser.Expr {
+	for {
+		p, ok := expr.(*parser.ParenExpr)
+		if !ok {
+			return expr
+		}
+		expr = p.Inner
+	}
+}
+
+// checkFieldAccess resolves the root of a dotted reference against the
+// query's fields and parameters and hands the rest of the path to
+// ResolveFieldAccess.
+func (c *Checker) checkFieldAccess(access *parser.FieldAccessExpr, validIdents map[string]bool) {
+	root, path := access.Path()
+	if root == nil {
+		c.checkExpr(access.Base, validIdents)
+		return
+	}
+	if !validIdents[root.Name] {
+		c.addUnknown(root, "identifier", root.Name, sortedKeys(validIdents))
+		return
+	}
+	if c.ResolveFieldAccess == nil {
+		return
+	}
+	entity, _ := c.scope.(*parser.EntityDecl)
+	if err := c.ResolveFieldAccess(entity, root.Name, path); err != nil {
+		c.addError(access, "cannot resolve %s: %v", access, err)
+	}
+}
+
+// checkCall validates a function call against functionArity.
+func (c *Checker) checkCall(call *parser.CallExpr) {
+	want, ok := functionArity[call.Name]
+	if !ok {
+		c.addUnknown(call, "function", call.Name, sortedKeys(functionArity))
+		return
+	}
+
+	got := len(call.Args)
+	switch {
+	case want.max < 0 && got < want.min:
+		c.addError(call, "%s expects at least %d %s, got %d", call.Name, want.min, plural(want.min, "argument"), got)
+	case want.max >= 0 && (got < want.min || got > want.max):
+		c.addError(call, "%s expects %d %s, got %d", call.Name, want.min, plural(want.min, "argument"), got)
+	}
+}
+
+// plural returns word, with an s appended unless n is 1.
+func plural(n int, word string) string {
+	if n == 1 {
+		return word
+	}
+	return word + "s"
+}
+
+// queryIdentTypes maps the fields and parameters visible in a query to their
+// type names. Parameters shadow fields of the same name.
+func queryIdentTypes(entity *parser.EntityDecl, query *parser.QueryDecl) map[string]string {
+	types := make(map[string]string)
+	for _, field := range entity.Fields {
+		types[field.Name] = field.Type.Name
+	}
+	for _, param := range query.Params {
+		types[param.Name] = param.Type.Name
+	}
+	return types
+}
+
+// unprintableTypes are the types || cannot concatenate: they have no text
+// form that reads the same in every dialect.
+var unprintableTypes = map[string]bool{"bool": true, "bytes": true}
+
+// checkOperandTypes reports LIKE and ILIKE comparisons whose operands are
+// known not to be strings, and || concatenations of unprintable operands.
+func (c *Checker) checkOperandTypes(expr parser.Expr, types map[string]string) {
+	switch e := expr.(type) {
+	case *parser.BinaryExpr:
+		switch e.Op {
+		case "LIKE", "ILIKE":
+			for _, operand := range []parser.Expr{e.Left, e.Right} {
+				if typeName := exprType(operand, types); typeName != "" && typeName != "string" {
+					c.addError(e, "%s requires string operands, got %s", e.Op, typeName)
+				}
+			}
+		case "||":
+			for _, operand := range []parser.Expr{e.Left, e.Right} {
+				if typeName := exprType(operand, types); unprintableTypes[typeName] {
+					c.addError(e, "|| requires string or printable operands, got %s", typeName)
+				}
+			}
+		}
+		c.checkOperandTypes(e.Left, types)
+		c.checkOperandTypes(e.Right, types)
+
+	case *parser.UnaryExpr:
+		c.checkOperandTypes(e.Operand, types)
+
+	case *parser.IsNullExpr:
+		c.checkOperandTypes(e.Operand, types)
+
+	case *parser.CallExpr:
+		for _, arg := range e.Args {
+			c.checkOperandTypes(arg, types)
+		}
+
+	case *parser.ParenExpr:
+		c.checkOperandTypes(e.Inner, types)
+
+	case *parser.TupleExpr:
+		for _, elem := range e.Elements {
+			c.checkOperandTypes(elem, types)
+		}
+
+	case *parser.CaseExpr:
+		for _, when := range e.Whens {
+			c.checkOperandTypes(when.Cond, types)
+			c.checkOperandTypes(when.Result, types)
+		}
+		if e.Else != nil {
+			c.checkOperandTypes(e.Else, types)
+		}
+	}
+}
+
+// exprType returns the type name of expr, or empty string when it cannot be
+// determined without full type inference.
+func exprType(expr parser.Expr, types map[string]string) string {
+	switch e := expr.(type) {
+	case *parser.IdentExpr:
+		return types[e.Name]
+
+	case *parser.LiteralExpr:
+		switch e.Value.(type) {
+		case string:
+			return "string"
+		case int64:
+			return "int64"
+		case float64:
+			return "double"
+		case bool:
+			return "bool"
+		}
+
+	case *parser.BinaryExpr:
+		if e.Op == "||" {
+			return "string"
+		}
+
+	case *parser.ParenExpr:
+		return exprType(e.Inner, types)
+	}
+	return ""
+}
+
+func (c *Checker) checkService(svc *parser.ServiceDecl) {
+	c.scope = svc
+	defer func() { c.scope = nil }()
+
+	for _, rpc := range svc.Methods {
+		c.checkOnlyDeprecated("rpc", rpc.Annotations)
+
+		// Check request type
+		c.checkRpcType(rpc.RequestType)
+
+		// Check response type
+		c.checkRpcType(rpc.ResponseType)
+
+		kind := rpc.StreamingKind()
+		for _, unsupported := range c.UnsupportedStreaming {
+			if kind == unsupported {
+				c.addError(rpc, "rpc %s is %s, which the target backend does not support", rpc.Name, kind)
+			}
+		}
+	}
+}
+
+func (c *Checker) checkRpcType(rpcType *parser.RpcType) {
+	// Check if type is a known entity or a standard message type
+	knownTypes := map[string]bool{
+		"PushResult":    true,
+		"Result":        true,
+		"Empty":         true,
+	}
+
+	if _, exists := c.entities[rpcType.Name]; exists {
+		return
+	}
+	if _, exists := c.messages[rpcType.Name]; exists {
+		return
+	}
+
+	if knownTypes[rpcType.Name] {
+		return
+	}
+
+	if c.StrictRpcTypes {
+		if !c.isQueryMessage(rpcType.Name) {
+			c.addError(rpcType, "unknown RPC type: %s", rpcType.Name)
+		}
+		return
+	}
+
+	// Check for Request/Response, Result and Chunk message types
+	for _, suffix := range []string{"Request", "Response", "Result", "Chunk"} {
+		if strings.HasSuffix(rpcType.Name, suffix) {
+			return // These are typically generated
+		}
+	}
+
+	// Allow any type that starts with entity name (e.g., GetEventsRequest for CalendarEvent)
+	for _, name := range sortedKeys(c.entities) {
+		if strings.Contains(rpcType.Name, name) {
+			return
 		}
 	}
 
 	c.addError(rpcType, "unknown RPC type: %s", rpcType.Name)
 }
 
+// isQueryMessage reports whether name is a request or response message the
+// proto generator derives from a query of a local entity.
+func (c *Checker) isQueryMessage(name string) bool {
+	for _, entity := range c.file.Entities {
+		for _, query := range entity.Queries {
+			base := entity.Name + naming.ToPascalCase(query.Name)
+			if name == base+"Request" || name == base+"Response" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sortedKeys returns the keys of m in order, for iterating maps where the
+// order can show up in diagnostics.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func isValidOnDelete(action string) bool {
+	switch strings.ToLower(action) {
+	case "cascade", "setnull", "restrict":
+		return true
+	}
+	return false
+}
+
 func isValidBackend(backend string) bool {
 	validBackends := map[string]bool{
 		"sqlite":   true,
@@ -376,3 +1886,18 @@ func Check(file *parser.File) []Error {
 	c := New(file)
 	return c.Check()
 }
+
+// CheckProgram checks every file in a program, resolving each file's types
+// against the files it imports. Imports a file never refers to are warnings.
+func CheckProgram(prog *parser.Program) []Error {
+	var errs []Error
+	for _, file := range prog.Files {
+		c := New(file)
+		for i, imp := range prog.ImportsOf(file) {
+			c.AddImport(imp)
+			c.resolved = append(c.resolved, resolvedImport{decl: file.Imports[i], file: imp})
+		}
+		errs = append(errs, c.Check()...)
+	}
+	return errs
+}
@@ -0,0 +1,2207 @@
+package checker
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aurora/dataproto/internal/parser"
+)
+
+// checkSource parses input and runs the checker, failing the test on parse errors.
+func checkSource(t *testing.T, input string) []Error {
+	t.Helper()
+	file, err := parser.Parse(input)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	return Check(file)
+}
+
+// hasError reports whether any error message contains substr.
+func hasError(errs []Error, substr string) bool {
+	for _, e := range errs {
+		if strings.Contains(e.Message, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCompositePrimaryKey(t *testing.T) {
+	input := `
+package test;
+
+entity EventTag {
+    @pk event_id: string;
+    @pk tag_id: string;
+    note: string?;
+}
+`
+
+	errs := checkSource(t, input)
+	if len(errs) != 0 {
+		t.Fatalf("Expected no errors, got %v", errs)
+	}
+}
+
+func TestMissingPrimaryKey(t *testing.T) {
+	input := `
+package test;
+
+entity Item {
+    title: string;
+}
+`
+
+	errs := checkSource(t, input)
+	if !hasError(errs, "has no primary key") {
+		t.Errorf("Expected no-primary-key error, got %v", errs)
+	}
+}
+
+func TestAnnotationNamedArgs(t *testing.T) {
+	valid := `
+package test;
+
+entity Item {
+    @pk id: string;
+    @length(min: 1, max: 500) title: string;
+}
+`
+	if errs := checkSource(t, valid); len(errs) != 0 {
+		t.Errorf("Expected no errors, got %v", errs)
+	}
+
+	unknown := `
+package test;
+
+entity Item {
+    @pk id: string;
+    @length(maximum: 10) title: string;
+}
+`
+	errs := checkSource(t, unknown)
+	if !hasError(errs, "unknown argument maximum for @length (expected one of: max, min)") {
+		t.Errorf("Expected unknown argument error, got %v", errs)
+	}
+}
+
+func TestCheckProgramResolvesImportedTypes(t *testing.T) {
+	files := map[string]string{
+		"common.dataproto": `
+package acos;
+
+enum Status {
+    ACTIVE = 0;
+    DONE = 1;
+}
+`,
+		"task.dataproto": `
+package acos;
+
+import "common.dataproto";
+
+entity Task {
+    @pk id: string;
+    status: Status;
+}
+`,
+	}
+
+	prog, err := parser.ParseFilesWithLoader([]string{"task.dataproto"}, func(path string) (string, error) {
+		return files[path], nil
+	})
+	if err != nil {
+		t.Fatalf("ParseFiles error: %v", err)
+	}
+
+	if errs := CheckProgram(prog); len(errs) != 0 {
+		t.Errorf("Expected no errors, got %v", errs)
+	}
+
+	// Checked alone, the task file cannot see Status
+	errs := Check(prog.File("task.dataproto"))
+	if !hasError(errs, "unknown type: Status") {
+		t.Errorf("Expected unknown type error without imports, got %v", errs)
+	}
+}
+
+func TestImportLoader(t *testing.T) {
+	files := map[string]string{
+		"schemas/common.dataproto": `
+package acos;
+
+enum Status {
+    ACTIVE = 0;
+    DONE = 1;
+}
+`,
+		"schemas/audit.dataproto": `
+package acos;
+
+entity AuditLog {
+    @pk id: string;
+}
+`,
+	}
+	file, err := parser.ParseFile(`
+package acos;
+
+import "common.dataproto";
+import "audit.dataproto";
+import "missing.dataproto";
+
+entity Task {
+    @pk id: string;
+    status: Status;
+}
+`, "schemas/task.dataproto")
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	c := New(file)
+	c.Loader = func(path string) (string, error) {
+		src, ok := files[path]
+		if !ok {
+			return "", fmt.Errorf("file not found: %s", path)
+		}
+		return src, nil
+	}
+	errs := c.Check()
+
+	if !hasError(errs, `cannot resolve import "missing.dataproto": file not found: schemas/missing.dataproto`) {
+		t.Errorf("Expected an error for the missing import, got %v", errs)
+	}
+	if !hasError(errs, `import "audit.dataproto" is not used`) {
+		t.Errorf("Expected a warning for the unused import, got %v", errs)
+	}
+	if hasError(errs, "common.dataproto") || hasError(errs, "unknown type") {
+		t.Errorf("Expected common.dataproto to resolve Status, got %v", errs)
+	}
+	if len(errs) != 2 || errs[0].Severity != SeverityError || errs[1].Severity != SeverityWarning {
+		t.Errorf("Expected one error and one warning, got %v", errs)
+	}
+}
+
+func TestCompositeForeignKey(t *testing.T) {
+	target := `
+package test;
+
+entity OrderLine {
+    @pk order_id: string;
+    @pk line_no: int32;
+}
+`
+
+	tests := []struct {
+		name   string
+		fk     string
+		fields string
+		want   string
+	}{
+		{
+			name:   "valid",
+			fk:     `@fk(fields: ["order_id", "line_no"], references: "OrderLine")`,
+			fields: "order_id: string; line_no: int32;",
+		},
+		{
+			name:   "arity mismatch",
+			fk:     `@fk(fields: ["order_id"], references: "OrderLine")`,
+			fields: "order_id: string;",
+			want:   "@fk lists 1 fields but OrderLine has 2 primary key fields",
+		},
+		{
+			name:   "type mismatch",
+			fk:     `@fk(fields: ["order_id", "line_no"], references: "OrderLine")`,
+			fields: "order_id: string; line_no: string;",
+			want:   "@fk field line_no has type string but OrderLine.line_no is int32",
+		},
+		{
+			name:   "unknown field",
+			fk:     `@fk(fields: ["order_id", "line"], references: "OrderLine")`,
+			fields: "order_id: string;",
+			want:   "unknown field in @fk: line",
+		},
+		{
+			name:   "unknown entity",
+			fk:     `@fk(fields: ["order_id"], references: "Order")`,
+			fields: "order_id: string;",
+			want:   "unknown entity in @fk: Order",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := target + tt.fk + "\nentity Shipment {\n    @pk id: string;\n    " + tt.fields + "\n}\n"
+			errs := checkSource(t, input)
+
+			if tt.want == "" {
+				if len(errs) != 0 {
+					t.Errorf("Expected no errors, got %v", errs)
+				}
+			} else if !hasError(errs, tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, errs)
+			}
+		})
+	}
+}
+
+func TestWarnOptionalIndexed(t *testing.T) {
+	input := `
+package test;
+
+entity Item {
+    @pk id: string;
+    @indexed category: string?;
+    @unique code: string?;
+    @indexed title: string;
+}
+`
+
+	file, err := parser.Parse(input)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	// Off by default
+	if errs := Check(file); len(errs) != 0 {
+		t.Errorf("Expected no diagnostics by default, got %v", errs)
+	}
+
+	c := New(file)
+	c.WarnOptionalIndexed = true
+	errs := c.Check()
+
+	if len(errs) != 2 {
+		t.Fatalf("Expected 2 warnings, got %v", errs)
+	}
+	for _, e := range errs {
+		if e.Severity != SeverityWarning {
+			t.Errorf("Expected warning severity, got %v: %v", e.Severity, e)
+		}
+	}
+	if !hasError(errs, "@indexed field category is optional") {
+		t.Errorf("Expected @indexed warning, got %v", errs)
+	}
+	if !hasError(errs, "@unique field code is optional") {
+		t.Errorf("Expected @unique warning, got %v", errs)
+	}
+	if HasErrors(errs) {
+		t.Error("Expected warnings not to count as errors")
+	}
+	if got := errs[0].Error(); got != "6:14: warning: @indexed field category is optional" {
+		t.Errorf("Unexpected formatting: %q", got)
+	}
+}
+
+func TestErrorLocations(t *testing.T) {
+	input := `
+package test;
+
+entity Item {
+    title: string;
+}
+`
+
+	file, err := parser.Parse(input)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	errs := Check(file)
+	if len(errs) != 1 {
+		t.Fatalf("Expected 1 error, got %v", errs)
+	}
+	if got := errs[0].Error(); got != "4:1: entity Item has no primary key (@pk)" {
+		t.Errorf("Unexpected no-primary-key diagnostic: %q", got)
+	}
+
+	// A synthesized field without a position is reported at its entity
+	entity := file.Entities[0]
+	entity.Fields = append(entity.Fields, &parser.FieldDecl{
+		Name: "owner",
+		Type: &parser.TypeRef{Name: "Owner"},
+	})
+
+	for _, e := range Check(file) {
+		if strings.HasPrefix(e.Error(), "0:0") {
+			t.Errorf("Diagnostic without location: %q", e.Error())
+		}
+		if e.Position.Pos().Line == 0 {
+			t.Errorf("Expected fallback to entity position for %q", e.Message)
+		}
+	}
+
+	// Without any enclosing declaration the location is omitted
+	if got := (Error{Position: &parser.FieldDecl{}, Message: "boom"}).Error(); got != "boom" {
+		t.Errorf("Expected bare message, got %q", got)
+	}
+}
+
+func TestIndexAnnotation(t *testing.T) {
+	input := `
+package test;
+
+@index(fields: ["owner", "title"], unique: true)
+@index(fields: ["missing"])
+@index(unique: true)
+entity Item {
+    @pk id: string;
+    owner: string;
+    title: string;
+}
+`
+
+	errs := checkSource(t, input)
+	if len(errs) != 2 {
+		t.Errorf("Expected 2 errors, got %v", errs)
+	}
+	if !hasError(errs, "unknown field in @index: missing") {
+		t.Errorf("Expected unknown field error, got %v", errs)
+	}
+	if !hasError(errs, "@index requires fields: [...]") {
+		t.Errorf("Expected missing fields error, got %v", errs)
+	}
+}
+
+func TestIndexDirectionAndPredicate(t *testing.T) {
+	input := `
+package test;
+
+@index(fields: ["owner", "title DESC"], where: "archived_at IS NULL")
+@index(fields: ["title SIDEWAYS"])
+@index(fields: ["owner"], where: "deleted_at IS NULL")
+@index(fields: ["owner"], where: "owner ==")
+entity Item {
+    @pk id: string;
+    owner: string;
+    title: string;
+    archived_at: timestamp?;
+}
+`
+
+	errs := checkSource(t, input)
+	if len(errs) != 3 {
+		t.Errorf("Expected 3 errors, got %v", errs)
+	}
+	for _, want := range []string{
+		`@index field "title SIDEWAYS" must be a field name, optionally followed by ASC or DESC`,
+		"@index where refers to unknown field deleted_at of Item",
+		`invalid @index where condition "owner =="`,
+	} {
+		if !hasError(errs, want) {
+			t.Errorf("Expected %q, got %v", want, errs)
+		}
+	}
+}
+
+func TestOnDeleteAction(t *testing.T) {
+	input := `
+package test;
+
+entity Event {
+    @pk id: string;
+}
+
+entity Attachment {
+    @pk id: string;
+    @fk(Event.id) @ondelete(cascade) event_id: string;
+    @fk(Event.id) @ondelete(nuke) other_event_id: string;
+    @ondelete(restrict) orphan: string;
+}
+`
+
+	errs := checkSource(t, input)
+	if len(errs) != 2 {
+		t.Errorf("Expected 2 errors, got %v", errs)
+	}
+	if !hasError(errs, "unknown @ondelete action: nuke") {
+		t.Errorf("Expected invalid action error, got %v", errs)
+	}
+	if !hasError(errs, "@ondelete requires @fk on the same field") {
+		t.Errorf("Expected missing @fk error, got %v", errs)
+	}
+}
+
+func TestQueryGroupBy(t *testing.T) {
+	input := `
+package test;
+
+entity Event {
+    @pk id: string;
+    calendar_name: string;
+    start_date: timestamp;
+
+    query byCalendar() {
+        group_by calendar_name
+        order_by calendar_name ASC
+    }
+
+    query byMissing() {
+        group_by calendar
+    }
+
+    query sortedByUngrouped() {
+        group_by calendar_name
+        order_by start_date DESC
+    }
+}
+`
+
+	errs := checkSource(t, input)
+	if len(errs) != 2 {
+		t.Errorf("Expected 2 errors, got %v", errs)
+	}
+	if !hasError(errs, "unknown field in GROUP BY: calendar") {
+		t.Errorf("Expected unknown GROUP BY field error, got %v", errs)
+	}
+	if !hasError(errs, "ORDER BY field start_date must appear in GROUP BY") {
+		t.Errorf("Expected ungrouped ORDER BY error, got %v", errs)
+	}
+}
+
+func TestPackageReservedWord(t *testing.T) {
+	errs := checkSource(t, `package acos.class.calendar;`)
+
+	if len(errs) != 1 {
+		t.Fatalf("Expected 1 warning, got %v", errs)
+	}
+	if errs[0].Severity != SeverityWarning {
+		t.Errorf("Expected a warning, got %v", errs[0])
+	}
+	if !hasError(errs, `package segment "class" is a reserved word in Java, Kotlin, C++`) {
+		t.Errorf("Unexpected diagnostic: %v", errs)
+	}
+
+	if errs := checkSource(t, `package acos.calendar;`); len(errs) != 0 {
+		t.Errorf("Expected no diagnostics, got %v", errs)
+	}
+}
+
+func TestQuerySelect(t *testing.T) {
+	input := `
+package test;
+
+entity Event {
+    @pk id: string;
+    title: string;
+    calendar_name: string;
+
+    query titles() {
+        select id, title
+    }
+
+    query counts() {
+        select calendar_name, COUNT(id)
+        group_by calendar_name
+    }
+
+    query badColumn() {
+        select id, subject
+    }
+
+    query ungrouped() {
+        select title, COUNT(*)
+        group_by calendar_name
+    }
+}
+`
+
+	errs := checkSource(t, input)
+	if len(errs) != 2 {
+		t.Errorf("Expected 2 errors, got %v", errs)
+	}
+	if !hasError(errs, "unknown field in SELECT: subject") {
+		t.Errorf("Expected unknown SELECT field error, got %v", errs)
+	}
+	if !hasError(errs, "SELECT field title must appear in GROUP BY or an aggregate") {
+		t.Errorf("Expected ungrouped SELECT error, got %v", errs)
+	}
+}
+
+func TestGeneratedColumn(t *testing.T) {
+	input := `
+package test;
+
+@backends(sqlite)
+entity LocalLine {
+    @pk id: string;
+    price: double;
+    quantity: int32;
+    @generated("price * quantity", stored: false) total: double;
+}
+
+entity SharedLine {
+    @pk id: string;
+    price: double;
+    quantity: int32;
+    @generated("price * quantity") total: double;
+    @generated("price * quantity", stored: false) virtual_total: double;
+    @generated("price * 2") @default(0) doubled: double;
+}
+`
+
+	errs := checkSource(t, input)
+	if len(errs) != 2 {
+		t.Errorf("Expected 2 errors, got %v", errs)
+	}
+	if !hasError(errs, "virtual generated column virtual_total is not supported by postgres") {
+		t.Errorf("Expected virtual-on-postgres error, got %v", errs)
+	}
+	if !hasError(errs, "generated column doubled cannot have @default") {
+		t.Errorf("Expected @default conflict error, got %v", errs)
+	}
+}
+
+func TestProtoFileOptions(t *testing.T) {
+	input := `
+package test;
+
+option optimize_for = SPEED;
+option java_multiple_files = true;
+option go_package = "example.com/test";
+option (custom.opt).level = 2;
+`
+	if errs := checkSource(t, input); len(errs) != 0 {
+		t.Errorf("Expected no diagnostics, got %v", errs)
+	}
+
+	errs := checkSource(t, `
+package test;
+
+option optimize_for = FAST;
+option java_package = com;
+option java_multiple_files = "yes";
+option my_option = 1;
+option (custom.tags) = [1, 2];
+`)
+	for _, want := range []string{
+		"option (custom.tags) has a list value, which the proto generator cannot emit",
+		"option optimize_for must be one of SPEED, CODE_SIZE, LITE_RUNTIME",
+		"option java_package requires a string value",
+		"option java_multiple_files requires a bool value",
+		`unknown proto option "my_option"`,
+	} {
+		if !hasError(errs, want) {
+			t.Errorf("Expected %q, got %v", want, errs)
+		}
+	}
+	for _, err := range errs {
+		if strings.Contains(err.Message, "my_option") && err.Severity != SeverityWarning {
+			t.Errorf("Expected unknown option to be a warning, got %v", err)
+		}
+	}
+}
+
+func TestRangeValidation(t *testing.T) {
+	errs := checkSource(t, `
+package test;
+
+entity Item {
+    @pk id: string;
+    @range(1, 10) name: string;
+    @range(10, 1) quantity: int32;
+    @range(min: 0, max: 99.5) price: double;
+}
+`)
+	for _, want := range []string{
+		"@range requires a numeric field, name is string",
+		"@range min 10 is greater than max 1",
+	} {
+		if !hasError(errs, want) {
+			t.Errorf("Expected %q, got %v", want, errs)
+		}
+	}
+	if len(errs) != 2 {
+		t.Errorf("Expected 2 errors, got %v", errs)
+	}
+}
+
+func TestBoundConflicts(t *testing.T) {
+	errs := checkSource(t, `
+package test;
+
+entity Item {
+    @pk id: string;
+    @min(0) stock: int32;
+    @range(0, 10) @min(0) rating: int32;
+    @range(0, 10) @min(1) level: int32;
+    @range(0, 10) @max(20) score: double;
+    @min(5) @max(1) size: int64;
+    @min(7.5) @max(2.5) weight: double;
+    @range(0, 10) @min(5.5) ratio: double;
+    @min(0.5) @max(2) scale: float;
+}
+`)
+	for _, want := range []string{
+		"@min 1 conflicts with @range min 0",
+		"@max 20 conflicts with @range max 10",
+		"@min 5 is greater than @max 1",
+		"@min 7.5 is greater than @max 2.5",
+		"@min 5.5 conflicts with @range min 0",
+	} {
+		if !hasError(errs, want) {
+			t.Errorf("Expected %q, got %v", want, errs)
+		}
+	}
+	if len(errs) != 5 {
+		t.Errorf("Expected 5 errors, got %v", errs)
+	}
+}
+
+func TestParamDefaultExpressions(t *testing.T) {
+	errs := checkSource(t, `
+package test;
+
+entity Event {
+    @pk id: string;
+    start_date: timestamp;
+    title: string;
+
+    query upcoming(after: timestamp = NOW(), before: timestamp = after + 1000) {
+        where start_date >= after AND start_date < before
+    }
+
+    query invalid(a: int64 = b + 1, b: int64 = b, c: int64 = start_date + 1, d: string = NOW()) {
+        where start_date > a AND start_date > b AND start_date > c AND title = d
+    }
+}
+`)
+	for _, want := range []string{
+		"default of parameter a refers to parameter b, which is declared after it",
+		"default of parameter b cannot refer to itself",
+		"unknown identifier: start_date",
+		"default of parameter d is timestamp, but the parameter is string",
+	} {
+		if !hasError(errs, want) {
+			t.Errorf("Expected %q, got %v", want, errs)
+		}
+	}
+	if len(errs) != 4 {
+		t.Errorf("Expected 4 errors, got %v", errs)
+	}
+}
+
+func TestValidationAnnotations(t *testing.T) {
+	errs := checkSource(t, `
+package test;
+
+entity Account {
+    @pk id: string;
+    @format("email") email: string;
+    @format("phone") phone: string;
+    @format("uuid") age: int32;
+    @min(0) @max(1.5) score: double;
+    @min(1) name: string;
+}
+`)
+	for _, want := range []string{
+		"unknown @format: phone (expected email, uri, or uuid)",
+		"@format requires a string field, age is int32",
+		"@min requires a numeric field, name is string",
+	} {
+		if !hasError(errs, want) {
+			t.Errorf("Expected %q, got %v", want, errs)
+		}
+	}
+	if len(errs) != 3 {
+		t.Errorf("Expected 3 errors, got %v", errs)
+	}
+}
+
+func TestSoftDelete(t *testing.T) {
+	valid := `
+package test;
+
+@soft_delete("removed_at")
+entity Note {
+    @pk id: string;
+    removed_at: timestamp?;
+}
+`
+	if errs := checkSource(t, valid); len(errs) != 0 {
+		t.Errorf("Expected no diagnostics, got %v", errs)
+	}
+
+	errs := checkSource(t, `
+package test;
+
+@soft_delete
+entity Note {
+    @pk id: string;
+}
+
+@soft_delete
+entity Task {
+    @pk id: string;
+    deleted_at: timestamp;
+}
+`)
+	for _, want := range []string{
+		"@soft_delete requires a nullable timestamp field deleted_at",
+		"@soft_delete field deleted_at must be an optional timestamp",
+	} {
+		if !hasError(errs, want) {
+			t.Errorf("Expected %q, got %v", want, errs)
+		}
+	}
+}
+
+func TestSoftDeleteAlias(t *testing.T) {
+	valid := `
+package test;
+
+@softdelete(field: "removed_at")
+entity Note {
+    @pk id: string;
+    removed_at: timestamp?;
+
+    @include_deleted
+    query everything() {
+        limit 10
+    }
+}
+`
+	if errs := checkSource(t, valid); len(errs) != 0 {
+		t.Errorf("Expected no diagnostics, got %v", errs)
+	}
+
+	errs := checkSource(t, `
+package test;
+
+@softdelete(field: "removed_at")
+entity Note {
+    @pk id: string;
+    removed_at: string;
+}
+
+@soft_delete
+@softdelete
+entity Task {
+    @pk id: string;
+    deleted_at: timestamp?;
+}
+
+entity Plain {
+    @pk id: string;
+
+    @include_deleted
+    query everything() {
+        limit 10
+    }
+}
+`)
+	for _, want := range []string{
+		"@softdelete field removed_at must be an optional timestamp",
+		"entity Task declares soft deletion twice",
+		"@include_deleted on query everything has no effect; entity Plain has no @soft_delete",
+	} {
+		if !hasError(errs, want) {
+			t.Errorf("Expected %q, got %v", want, errs)
+		}
+	}
+}
+
+func TestUnsupportedStreaming(t *testing.T) {
+	file, err := parser.Parse(`
+package test;
+
+entity Event {
+    @pk id: string;
+}
+
+service Sync {
+    rpc Get(GetEventRequest) returns (Event);
+    rpc Watch(WatchEventRequest) returns (stream Event);
+    rpc Upload(stream Event) returns (Result);
+    rpc Chat(stream Event) returns (stream Event);
+}
+`)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	if errs := Check(file); len(errs) != 0 {
+		t.Errorf("Expected every streaming kind to be allowed by default, got %v", errs)
+	}
+
+	c := New(file)
+	c.UnsupportedStreaming = []parser.StreamingKind{parser.ClientStreaming, parser.BidiStreaming}
+	errs := c.Check()
+	for _, want := range []string{
+		"rpc Upload is client-streaming, which the target backend does not support",
+		"rpc Chat is bidirectional-streaming, which the target backend does not support",
+	} {
+		if !hasError(errs, want) {
+			t.Errorf("Expected %q, got %v", want, errs)
+		}
+	}
+	if len(errs) != 2 {
+		t.Errorf("Expected 2 errors, got %v", errs)
+	}
+}
+
+func TestStrictRpcTypes(t *testing.T) {
+	file, err := parser.Parse(`
+package test;
+
+entity Event {
+    @pk id: string;
+    start_date: timestamp;
+
+    query upcoming(after: timestamp) {
+        where start_date >= after
+    }
+}
+
+service Events {
+    rpc Upcoming(EventUpcomingRequest) returns (EventUpcomingResponse);
+    rpc Push(stream Event) returns (Result);
+    rpc GetEvents(GetEvntsRequest) returns (stream Event);
+    rpc Sync(EventSyncChunk) returns (Empty);
+}
+`)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	if errs := Check(file); len(errs) != 0 {
+		t.Errorf("Expected lenient mode to accept every type, got %v", errs)
+	}
+
+	c := New(file)
+	c.StrictRpcTypes = true
+	errs := c.Check()
+	for _, want := range []string{
+		"unknown RPC type: GetEvntsRequest",
+		"unknown RPC type: EventSyncChunk",
+	} {
+		if !hasError(errs, want) {
+			t.Errorf("Expected %q, got %v", want, errs)
+		}
+	}
+	if len(errs) != 2 {
+		t.Errorf("Expected 2 errors, got %v", errs)
+	}
+}
+
+func TestMessages(t *testing.T) {
+	file, err := parser.Parse(`
+package test;
+
+entity Event {
+    @pk id: string;
+}
+
+message GetEventsRequest {
+    since: timestamp?;
+    page: Page;
+    owner: Event?;
+}
+
+message Page {
+    size: int32;
+}
+
+service Events {
+    rpc GetEvents(GetEventsRequest) returns (stream Event);
+}
+`)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	c := New(file)
+	c.StrictRpcTypes = true
+	if errs := c.Check(); len(errs) != 0 {
+		t.Errorf("Expected a declared request message to resolve strictly, got %v", errs)
+	}
+
+	errs := checkSource(t, `
+package test;
+
+entity Event {
+    @pk id: string;
+}
+
+message Event {
+    id: string;
+}
+
+message Filter {
+    @pk id: string;
+    id: string;
+    kind: Kind;
+}
+
+message Filter {
+    name: string;
+}
+`)
+	for _, want := range []string{
+		"message Event has the same name as an entity",
+		"unknown message field annotation: @pk",
+		"duplicate field: id",
+		"unknown type: Kind",
+		"duplicate message: Filter",
+	} {
+		if !hasError(errs, want) {
+			t.Errorf("Expected %q, got %v", want, errs)
+		}
+	}
+	if len(errs) != 5 {
+		t.Errorf("Expected 5 errors, got %v", errs)
+	}
+}
+
+func TestProtoFieldNumbers(t *testing.T) {
+	errs := checkSource(t, `
+package test;
+
+entity Event {
+    @pk @proto(number: 1) id: string;
+    @proto(number: 1) title: string;
+    @proto(number: 0) notes: string;
+    @proto(number: -2) color: string;
+    location: string;
+}
+`)
+	for _, want := range []string{
+		"proto field number 1 of title is already used by id",
+		"proto field number 0 must be between 1 and 536870911",
+		"proto field number -2 must be between 1 and 536870911",
+	} {
+		if !hasError(errs, want) {
+			t.Errorf("Expected %q, got %v", want, errs)
+		}
+	}
+	if len(errs) != 3 {
+		t.Errorf("Expected 3 errors, got %v", errs)
+	}
+}
+
+func TestReservedFields(t *testing.T) {
+	errs := checkSource(t, `
+package test;
+
+entity Event {
+    reserved 2, 5 to 9;
+    reserved "old_name";
+    @pk @proto(number: 1) id: string;
+    @proto(number: 6) title: string;
+    old_name: string;
+}
+`)
+	for _, want := range []string{
+		"proto field number 6 of title is reserved",
+		"field name old_name is reserved",
+	} {
+		if !hasError(errs, want) {
+			t.Errorf("Expected %q, got %v", want, errs)
+		}
+	}
+	if len(errs) != 2 {
+		t.Errorf("Expected 2 errors, got %v", errs)
+	}
+
+	errs = checkSource(t, `
+package test;
+
+message Request {
+    reserved 0;
+    reserved "old_name";
+    old_name: string;
+}
+`)
+	for _, want := range []string{
+		"invalid reserved range 0 to 0",
+		"field name old_name is reserved",
+	} {
+		if !hasError(errs, want) {
+			t.Errorf("Expected %q, got %v", want, errs)
+		}
+	}
+}
+
+func TestEnumAliases(t *testing.T) {
+	errs := checkSource(t, `
+package test;
+
+enum Status {
+    ACTIVE = 0;
+    DELETED = 3;
+    REMOVED = 3;
+}
+`)
+	if !hasError(errs, "enum value REMOVED reuses number 3 of DELETED; set option allow_alias = true to alias it") {
+		t.Errorf("Expected duplicate number error, got %v", errs)
+	}
+
+	errs = checkSource(t, `
+package test;
+
+enum Status {
+    option allow_alias = true;
+    ACTIVE = 0;
+    DELETED = 3;
+    REMOVED = 3;
+}
+`)
+	if len(errs) != 0 {
+		t.Errorf("Expected aliases to be allowed, got %v", errs)
+	}
+}
+
+func TestILikeRequiresStrings(t *testing.T) {
+	errs := checkSource(t, `
+package test;
+
+entity Item {
+    @pk id: string;
+    title: string;
+    priority: int32;
+
+    query search(term: string) {
+        where title ILIKE "%" || term || "%"
+    }
+
+    query byPriority(term: string) {
+        where priority ILIKE term
+    }
+}
+`)
+	if len(errs) != 1 || !hasError(errs, "ILIKE requires string operands, got int32") {
+		t.Errorf("Expected a single operand type error, got %v", errs)
+	}
+}
+
+func TestLikeLiteralRequiresString(t *testing.T) {
+	errs := checkSource(t, `
+package test;
+
+entity Item {
+    @pk id: string;
+    title: string;
+
+    query byNumber() {
+        where title LIKE 42
+    }
+
+    query byPattern() {
+        where title LIKE "a%"
+    }
+}
+`)
+	if len(errs) != 1 || !hasError(errs, "LIKE requires string operands, got int64") {
+		t.Errorf("Expected a single operand type error, got %v", errs)
+	}
+}
+
+func TestConcatRequiresPrintable(t *testing.T) {
+	errs := checkSource(t, `
+package test;
+
+entity Item {
+    @pk id: string;
+    title: string;
+    rank: int32;
+    done: bool;
+    data: bytes;
+
+    query labelled(term: string) {
+        where title || ":" || rank = term
+    }
+
+    query flagged(term: string) {
+        where title || done = term
+    }
+
+    query blob(term: string) {
+        where data || title = term
+    }
+}
+`)
+	for _, want := range []string{
+		"|| requires string or printable operands, got bool",
+		"|| requires string or printable operands, got bytes",
+	} {
+		if !hasError(errs, want) {
+			t.Errorf("Expected %q, got %v", want, errs)
+		}
+	}
+	if len(errs) != 2 {
+		t.Errorf("Expected 2 errors, got %v", errs)
+	}
+}
+
+func TestCaseExprBranchesChecked(t *testing.T) {
+	errs := checkSource(t, `
+package test;
+
+entity Event {
+    @pk id: string;
+    is_all_day: bool;
+
+    query ranked() {
+        where CASE WHEN is_all_day THEN 1 ELSE missing END > 0
+    }
+}
+`)
+	if !hasError(errs, "unknown identifier: missing") {
+		t.Errorf("Expected the ELSE branch to be checked, got %v", errs)
+	}
+}
+
+func TestFunctionArity(t *testing.T) {
+	errs := checkSource(t, `
+package test;
+
+entity Event {
+    @pk id: string;
+    start_date: timestamp;
+    priority: int32?;
+
+    query a() { where start_date >= NOW(start_date) }
+    query b() { where COALESCE() > 0 }
+    query c() { where COALESCE(priority, 0, 1) > 0 AND start_date < NOW() }
+    query d() { where LOWER(id) = "x" }
+}
+`)
+	for _, want := range []string{
+		"NOW expects 0 arguments, got 1",
+		"COALESCE expects at least 1 argument, got 0",
+		"unknown function: LOWER",
+	} {
+		if !hasError(errs, want) {
+			t.Errorf("Expected error %q, got %v", want, errs)
+		}
+	}
+	if len(errs) != 3 {
+		t.Errorf("Expected exactly 3 errors, got %v", errs)
+	}
+}
+
+func TestLengthValidation(t *testing.T) {
+	errs := checkSource(t, `
+package test;
+
+entity Item {
+    @pk id: string;
+    @length(5) code: string;
+    @length(min: 10, max: 2) title: string;
+    @length("long") notes: string;
+    @length(min: -1) tag: string;
+}
+`)
+	for _, want := range []string{
+		"@length min 10 is greater than max 2",
+		"@length bounds must be integers",
+		"@length min -1 must not be negative",
+	} {
+		if !hasError(errs, want) {
+			t.Errorf("Expected error %q, got %v", want, errs)
+		}
+	}
+	if len(errs) != 3 {
+		t.Errorf("Expected exactly 3 errors, got %v", errs)
+	}
+}
+
+func TestReservedSQLNames(t *testing.T) {
+	errs := checkSource(t, `
+package test;
+
+entity Order {
+    @pk id: string;
+    order: int32;
+    title: string;
+}
+
+@backends("postgres")
+entity Account {
+    @pk id: string;
+    user: string;
+}
+`)
+	if HasErrors(errs) {
+		t.Fatalf("Expected only warnings, got %v", errs)
+	}
+	for _, want := range []string{
+		`table name "order" is a reserved SQL keyword in sqlite, postgres`,
+		`column name "order" of field order is a reserved SQL keyword in sqlite, postgres`,
+		`column name "user" of field user is a reserved SQL keyword in postgres`,
+	} {
+		if !hasError(errs, want) {
+			t.Errorf("Expected %q, got %v", want, errs)
+		}
+	}
+	if len(errs) != 3 {
+		t.Errorf("Expected 3 warnings, got %v", errs)
+	}
+}
+
+func TestJSONNames(t *testing.T) {
+	errs := checkSource(t, `
+package test;
+
+entity Contact {
+    @pk id: string;
+    @json("phone_number") mobile: string;
+    phone_number: string?;
+    @json(1) label: string;
+    @json("a", "b") note: string;
+}
+`)
+	for _, want := range []string{
+		`json name "phone_number" of field phone_number is already used by field mobile`,
+		"@json name must be a non-empty string",
+		"@json requires a single name",
+	} {
+		if !hasError(errs, want) {
+			t.Errorf("Expected %q, got %v", want, errs)
+		}
+	}
+}
+
+func TestCheckDeterministic(t *testing.T) {
+	src := `
+package test;
+
+entity Event {
+    @pk id: string;
+    @fk(Missing.id) owner_id: string;
+    @range(10, 1) priority: int32;
+    @json("id") key: string;
+    unknown_type: Widget;
+    order: int32;
+}
+
+entity Note {
+    title: string;
+}
+
+service EventService {
+    rpc A(Foo) returns (Bar);
+    rpc B(Baz) returns (Qux);
+}
+`
+	first := checkSource(t, src)
+	if len(first) < 5 {
+		t.Fatalf("Expected several diagnostics, got %v", first)
+	}
+	for i := 0; i < 10; i++ {
+		again := checkSource(t, src)
+		if !reflect.DeepEqual(first, again) {
+			t.Fatalf("Diagnostics differ between runs:\n%v\n%v", first, again)
+		}
+	}
+}
+
+func TestDeprecatedAnnotation(t *testing.T) {
+	errs := checkSource(t, `
+package test;
+
+enum Priority {
+    @deprecated LOW = 0;
+    @indexed HIGH = 1;
+}
+
+@deprecated("use Event")
+entity LegacyEvent {
+    @pk id: string;
+    @deprecated(1) name: string;
+    @deprecated("a", "b") title: string;
+}
+
+service EventService {
+    @deprecated rpc GetLegacy(LegacyEvent) returns (LegacyEvent);
+    @pk rpc GetOther(LegacyEvent) returns (LegacyEvent);
+}
+`)
+	for _, want := range []string{
+		"unknown enum value annotation: @indexed",
+		"@deprecated message must be a string",
+		"@deprecated takes at most a message",
+		"unknown rpc annotation: @pk",
+	} {
+		if !hasError(errs, want) {
+			t.Errorf("Expected %q, got %v", want, errs)
+		}
+	}
+	if len(errs) != 4 {
+		t.Errorf("Expected 4 errors, got %v", errs)
+	}
+}
+
+func TestViewEntities(t *testing.T) {
+	errs := checkSource(t, `
+package test;
+
+@view(query: "Event.upcoming")
+entity UpcomingEvent {
+    id: string;
+    title: string?;
+}
+
+@view("SELECT owner_id, COUNT(*) AS total FROM event GROUP BY owner_id")
+entity EventCount {
+    ownerId: string;
+    total: int64;
+}
+
+entity Event {
+    @pk id: string;
+    title: string?;
+    ownerId: string;
+
+    query upcoming() {
+        where title IS NOT NULL
+    }
+
+    query byOwner(owner: string) {
+        where ownerId = owner
+    }
+}
+`)
+	if len(errs) != 0 {
+		t.Errorf("Expected valid views, got %v", errs)
+	}
+
+	errs = checkSource(t, `
+package test;
+
+@view
+entity Empty {
+    id: string;
+}
+
+@view("SELECT * FROM event", query: "Event.byOwner")
+entity Mixed {
+    id: string;
+}
+
+@view(query: "Event.byOwner")
+@index(fields: ["id"])
+entity ByOwner {
+    @pk id: string;
+    @required title: string?;
+}
+
+@view(query: "Event.missing")
+entity Missing {
+    id: string;
+}
+
+@view(query: "upcoming")
+entity Unqualified {
+    id: string;
+}
+
+entity Event {
+    @pk id: string;
+    title: string?;
+    ownerId: string;
+
+    query byOwner(owner: string) {
+        where ownerId = owner
+    }
+}
+`)
+	for _, want := range []string{
+		"@view requires either a SELECT statement or query: \"Entity.query\"",
+		"@view query Event.byOwner cannot take parameters",
+		"view ByOwner cannot use @index",
+		"view ByOwner cannot use @pk on field id",
+		"view ByOwner cannot use @required on field title",
+		"@view query refers to unknown query Event.missing",
+		"@view query must be a string of the form \"Entity.query\"",
+	} {
+		if !hasError(errs, want) {
+			t.Errorf("Expected %q, got %v", want, errs)
+		}
+	}
+	if len(errs) != 8 {
+		t.Errorf("Expected 8 errors, got %v", errs)
+	}
+}
+
+func TestIndexedBytes(t *testing.T) {
+	errs := checkSource(t, `
+package test;
+
+@index(fields: ["name", "avatar"])
+entity Profile {
+    @pk id: string;
+    @indexed name: string;
+    @indexed avatar: bytes;
+    @unique thumbnail: bytes?;
+}
+`)
+	for _, want := range []string{
+		"@indexed cannot be applied to bytes field avatar",
+		"@unique cannot be applied to bytes field thumbnail",
+		"@index cannot be applied to bytes field avatar",
+	} {
+		if !hasError(errs, want) {
+			t.Errorf("Expected %q, got %v", want, errs)
+		}
+	}
+	if len(errs) != 3 {
+		t.Errorf("Expected 3 errors, got %v", errs)
+	}
+}
+
+func TestFieldAccess(t *testing.T) {
+	file, err := parser.Parse(`
+package test;
+
+entity Calendar {
+    @pk id: string;
+    name: string;
+}
+
+entity Event {
+    @pk id: string;
+    calendar: Calendar;
+
+    query inCalendar(name: string) {
+        where calendar.name = name
+    }
+
+    query byOwner(owner: string) {
+        where calendar.owner = owner
+    }
+
+    query unknownRoot() {
+        where venue.name = "x"
+    }
+}
+`)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	// Without a resolver only the root is checked
+	errs := Check(file)
+	if len(errs) != 1 || !hasError(errs, "unknown identifier: venue") {
+		t.Errorf("Expected only the unknown root, got %v", errs)
+	}
+
+	c := New(file)
+	c.ResolveFieldAccess = func(entity *parser.EntityDecl, root string, path []string) error {
+		target := file.Entity(entity.Field(root).Type.Name)
+		for _, name := range path {
+			if target == nil || target.Field(name) == nil {
+				return fmt.Errorf("no field %s", name)
+			}
+			target = file.Entity(target.Field(name).Type.Name)
+		}
+		return nil
+	}
+	errs = c.Check()
+	for _, want := range []string{
+		"cannot resolve calendar.owner: no field owner",
+		"unknown identifier: venue",
+	} {
+		if !hasError(errs, want) {
+			t.Errorf("Expected %q, got %v", want, errs)
+		}
+	}
+	if len(errs) != 2 {
+		t.Errorf("Expected 2 errors, got %v", errs)
+	}
+}
+
+func TestPrimaryKeyTypes(t *testing.T) {
+	errs := checkSource(t, `
+package test;
+
+entity Blob {
+    @pk digest: bytes;
+}
+
+entity Reading {
+    @pk value: float;
+}
+
+entity Sample {
+    @pk sensor: string;
+    @pk taken: timestamp;
+}
+
+entity Valid {
+    @pk tenant: int32;
+    @pk seq: int64;
+    @pk code: string;
+}
+`)
+	for _, want := range []string{
+		"primary key digest must be string, int32 or int64, got bytes",
+		"primary key value must be string, int32 or int64, got float",
+		"primary key taken must be string, int32 or int64, got timestamp",
+	} {
+		if !hasError(errs, want) {
+			t.Errorf("Expected %q, got %v", want, errs)
+		}
+	}
+	if len(errs) != 3 {
+		t.Errorf("Expected 3 errors, got %v", errs)
+	}
+}
+
+func TestTimestampDefaults(t *testing.T) {
+	errs := checkSource(t, `
+package test;
+
+entity Event {
+    @pk id: string;
+    @default("2024-01-01T00:00:00Z") start_date: timestamp;
+    @default("2024-01-01T09:30:00+02:00") end_date: timestamp;
+    @default(now) created_at: timestamp;
+    @default(0) updated_at: timestamp;
+    @default("01/02/2024") due_date: timestamp;
+}
+`)
+	want := `@default of timestamp field due_date must be now or an RFC 3339 time such as 2024-01-01T00:00:00Z, got "01/02/2024"`
+	if !hasError(errs, want) {
+		t.Errorf("Expected %q, got %v", want, errs)
+	}
+	if len(errs) != 1 {
+		t.Errorf("Expected 1 error, got %v", errs)
+	}
+}
+
+func TestChecks(t *testing.T) {
+	errs := checkSource(t, `
+package test;
+
+@check("end_date >= start_date")
+@check("finish > start_date")
+entity Booking {
+    @pk id: string;
+    start_date: timestamp;
+    end_date: timestamp;
+    @check("guests > 0") guests: int32;
+    @check("nights >") nights: int32;
+    @check(3) rooms: int32;
+}
+`)
+	for _, want := range []string{
+		"@check refers to unknown field finish of Booking",
+		`invalid @check condition "nights >"`,
+		"@check requires a condition string",
+	} {
+		if !hasError(errs, want) {
+			t.Errorf("Expected %q, got %v", want, errs)
+		}
+	}
+	if len(errs) != 3 {
+		t.Errorf("Expected 3 errors, got %v", errs)
+	}
+}
+
+func TestOptionalParamDefault(t *testing.T) {
+	errs := checkSource(t, `
+package test;
+
+entity Event {
+    @pk id: string;
+    startDate: timestamp;
+
+    query upcoming(after?: timestamp) {
+        where startDate > after
+    }
+
+    query recent(n?: int32 = 10) {
+        limit n
+    }
+}
+`)
+	want := "optional parameter n of query recent cannot have a default"
+	if !hasError(errs, want) {
+		t.Errorf("Expected %q, got %v", want, errs)
+	}
+	if len(errs) != 2 || !hasError(errs, "limit parameter n has no upper bound") {
+		t.Errorf("Expected the default error and a limit warning, got %v", errs)
+	}
+}
+
+func TestHaving(t *testing.T) {
+	errs := checkSource(t, `
+package test;
+
+entity Event {
+    @pk id: string;
+    calendarName: string;
+    title: string;
+
+    query busy(min: int32) {
+        select calendarName, COUNT(id)
+        group_by calendarName
+        having COUNT(*) > min AND calendarName != ""
+    }
+
+    query byTitle() {
+        select calendarName
+        group_by calendarName
+        having title = "x" OR SUM(*) > 1 OR COUNT(MAX(id)) > 1
+    }
+
+    query ungrouped() {
+        having COUNT(id) > 1
+    }
+}
+`)
+	for _, want := range []string{
+		"HAVING field title must appear in GROUP BY or an aggregate",
+		"only COUNT accepts * in HAVING",
+		"aggregate MAX cannot be nested in COUNT",
+		"HAVING requires GROUP BY in query ungrouped",
+	} {
+		if !hasError(errs, want) {
+			t.Errorf("Expected %q, got %v", want, errs)
+		}
+	}
+	if len(errs) != 4 {
+		t.Errorf("Expected 4 errors, got %v", errs)
+	}
+}
+
+func TestRenderError(t *testing.T) {
+	src := "package test;\nentity Event {\n    @pk @bogus id: string;\n}\n"
+	errs := checkSource(t, src)
+	if len(errs) != 1 {
+		t.Fatalf("Expected 1 error, got %v", errs)
+	}
+
+	got := RenderError(src, errs[0])
+	want := "error: unknown field annotation: @bogus\n" +
+		" --> 3:9\n" +
+		"  |\n" +
+		"3 |     @pk @bogus id: string;\n" +
+		"  |         ^\n"
+	if got != want {
+		t.Errorf("RenderError() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestColumnOverrides(t *testing.T) {
+	errs := checkSource(t, `
+package test;
+
+entity Product {
+    @pk id: string;
+    @column(postgres: "NUMERIC(10,2)", oracle: "NUMBER(10,2)") price: double;
+    @column("TEXT") name: string;
+    @column(sqlite: 3) stock: int32;
+}
+`)
+	for _, want := range []string{
+		"@column on price overrides the type for unknown backend oracle",
+		"@column types must be named by backend",
+		"@column type for sqlite must be a non-empty string",
+	} {
+		if !hasError(errs, want) {
+			t.Errorf("Expected %q, got %v", want, errs)
+		}
+	}
+	if len(errs) != 3 {
+		t.Errorf("Expected 3 diagnostics, got %v", errs)
+	}
+	for _, e := range errs {
+		if strings.Contains(e.Message, "oracle") && e.Severity != SeverityWarning {
+			t.Errorf("Expected the unknown backend to be a warning, got %v", e)
+		}
+	}
+}
+
+func TestAutoIncrement(t *testing.T) {
+	errs := checkSource(t, `
+package test;
+
+entity Ticket {
+    @pk @autoincrement id: int64;
+}
+
+entity Tag {
+    @pk @autoincrement id: string;
+}
+
+entity Note {
+    @pk id: string;
+    @autoincrement seq: int32?;
+}
+`)
+	for _, want := range []string{
+		"@autoincrement field id must be int32 or int64, got string",
+		"@autoincrement field seq cannot be optional",
+		"@autoincrement requires @pk on seq",
+	} {
+		if !hasError(errs, want) {
+			t.Errorf("Expected %q, got %v", want, errs)
+		}
+	}
+	if len(errs) != 3 {
+		t.Errorf("Expected 3 errors, got %v", errs)
+	}
+}
+
+func TestParamShadowsField(t *testing.T) {
+	errs := checkSource(t, `
+package test;
+
+entity Article {
+    @pk id: string;
+    title: string;
+
+    query byTitle(title: string) {
+        where title = title
+    }
+
+    query byName(name: string) {
+        where title = name
+    }
+}
+`)
+	want := "parameter title of query byTitle shadows field title; references to title bind the parameter"
+	if !hasError(errs, want) {
+		t.Errorf("Expected %q, got %v", want, errs)
+	}
+	if len(errs) != 1 {
+		t.Errorf("Expected 1 diagnostic, got %v", errs)
+	}
+}
+
+func TestCappedLimitParams(t *testing.T) {
+	errs := checkSource(t, `
+package test;
+
+entity Event {
+    @pk id: string;
+
+    query page(@max(100) pageSize: int32 = 20) {
+        limit pageSize
+    }
+
+    query all(count: int32) {
+        limit count
+    }
+
+    query invalid(@max(10) size: int32 = 50, @max("x") a: int32, @max(5) b: string, @pk c: int32, @max(0) d: int64) {
+        limit 10
+    }
+}
+`)
+	for _, want := range []string{
+		"limit parameter count has no upper bound; cap it with @max(n)",
+		"default 50 of parameter size exceeds @max(10)",
+		"@max on parameter a requires an integer",
+		"@max requires an integer parameter, b is string",
+		"unknown query parameter annotation: @pk",
+		"@max on parameter d must be positive",
+	} {
+		if !hasError(errs, want) {
+			t.Errorf("Expected %q, got %v", want, errs)
+		}
+	}
+	if len(errs) != 6 {
+		t.Errorf("Expected 6 diagnostics, got %v", errs)
+	}
+
+	file, err := parser.Parse(`entity E { @pk id: string; query q(@max(50) n: int32) { limit n } }`)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	if max, ok := file.Entities[0].Queries[0].Params[0].MaxValue(); !ok || max != 50 {
+		t.Errorf("Expected MaxValue 50, got %d, %v", max, ok)
+	}
+}
+
+func TestEntityExtends(t *testing.T) {
+	errs := checkSource(t, `
+package test;
+
+entity Audited {
+    @pk id: string;
+    created_at: timestamp;
+}
+
+entity Note extends Audited {
+    body: string;
+
+    query recent(since: timestamp) {
+        where created_at > since
+    }
+}
+`)
+	if len(errs) != 0 {
+		t.Errorf("Expected inherited fields to satisfy @pk and queries, got %v", errs)
+	}
+}
+
+func TestEntityExtendsConflict(t *testing.T) {
+	errs := checkSource(t, `
+package test;
+
+entity Audited {
+    @pk id: string;
+    created_at: timestamp;
+}
+
+entity Note extends Audited {
+    created_at: string;
+}
+`)
+	if len(errs) != 1 || !hasError(errs, "field created_at of entity Note conflicts with field inherited from Audited") {
+		t.Errorf("Expected a single conflict error, got %v", errs)
+	}
+}
+
+func TestEntityExtendsCycle(t *testing.T) {
+	errs := checkSource(t, `
+package test;
+
+entity A extends B {
+    @pk id: string;
+}
+
+entity B extends A {
+    @pk id: string;
+}
+
+entity C extends Missing {
+    @pk id: string;
+}
+`)
+	if !hasError(errs, "cyclic inheritance: A extends B extends A") {
+		t.Errorf("Expected a cycle error for A, got %v", errs)
+	}
+	if !hasError(errs, "cyclic inheritance: B extends A extends B") {
+		t.Errorf("Expected a cycle error for B, got %v", errs)
+	}
+	if !hasError(errs, "entity C extends unknown entity Missing") {
+		t.Errorf("Expected an unknown base error, got %v", errs)
+	}
+}
+
+func TestAbstractEntity(t *testing.T) {
+	errs := checkSource(t, `
+package test;
+
+@abstract
+entity Timestamps {
+    created_at: timestamp;
+    updated_at: timestamp;
+}
+
+entity Note extends Timestamps {
+    @pk id: string;
+    body: string;
+}
+`)
+	if len(errs) != 0 {
+		t.Errorf("Expected an abstract entity to need no @pk, got %v", errs)
+	}
+
+	errs = checkSource(t, `
+package test;
+
+@abstract("x")
+entity Timestamps {
+    created_at: timestamp;
+
+    query recent(since: timestamp) {
+        where created_at > since
+    }
+}
+
+entity Note extends Timestamps {
+    @pk id: string;
+    @fk("Timestamps.created_at") created: timestamp;
+    parent: Timestamps;
+}
+`)
+	for _, want := range []string{
+		"@abstract takes no arguments",
+		"abstract entity Timestamps cannot declare queries",
+		"@fk cannot reference abstract entity Timestamps",
+		"abstract entity Timestamps cannot be used as a type",
+	} {
+		if !hasError(errs, want) {
+			t.Errorf("Expected error %q, got %v", want, errs)
+		}
+	}
+}
+
+func TestQueryReturns(t *testing.T) {
+	errs := checkSource(t, `
+package test;
+
+entity Task {
+    @pk id: string;
+    slug: string;
+
+    @returns(one)
+    query bySlug(s: string) {
+        where slug = s
+    }
+
+    @returns(many)
+    query all() {
+        limit 10
+    }
+
+    @returns(some)
+    query bad() {
+        limit 10
+    }
+
+    @returns(one)
+    query capped() {
+        limit 5
+    }
+
+    @cached
+    query unknown() {
+        limit 10
+    }
+}
+`)
+	if !hasError(errs, "@returns on query bad must be one or many") {
+		t.Errorf("Expected an error for @returns(some), got %v", errs)
+	}
+	if !hasError(errs, "query capped returns one row but has limit 5") {
+		t.Errorf("Expected a warning for a limit above one, got %v", errs)
+	}
+	if !hasError(errs, "unknown query annotation: @cached") {
+		t.Errorf("Expected an error for @cached, got %v", errs)
+	}
+	if len(errs) != 3 {
+		t.Errorf("Expected 3 diagnostics, got %v", errs)
+	}
+}
+
+func TestTableAnnotation(t *testing.T) {
+	errs := checkSource(t, `
+package test;
+
+@table("events")
+entity Event {
+    @pk id: string;
+}
+
+@table(schema: "app", name: "tasks")
+entity Task {
+    @pk id: string;
+}
+
+@table(schema: "app", "notes")
+entity Note {
+    @pk id: string;
+}
+`)
+	if len(errs) != 0 {
+		t.Errorf("Expected every @table form to be valid, got %v", errs)
+	}
+
+	errs = checkSource(t, `
+package test;
+
+@table(schema: "app")
+entity Event {
+    @pk id: string;
+}
+
+@table("tasks", name: "tasks")
+entity Task {
+    @pk id: string;
+}
+
+@table(schema: 1, name: "notes")
+entity Note {
+    @pk id: string;
+}
+`)
+	for _, want := range []string{
+		"@table requires a table name",
+		"@table takes a single table name",
+		"@table argument must be a string",
+	} {
+		if !hasError(errs, want) {
+			t.Errorf("Expected %q, got %v", want, errs)
+		}
+	}
+}
+
+func TestRenamedFrom(t *testing.T) {
+	errs := checkSource(t, `
+package test;
+
+entity Task {
+    @pk id: string;
+    @renamed_from("title") name: string;
+    @renamed_from("id") key: string;
+    @renamed_from(1) rank: int32;
+}
+`)
+	if len(errs) != 2 {
+		t.Errorf("Expected 2 errors, got %v", errs)
+	}
+	for _, want := range []string{
+		"field key is renamed from id, which entity Task still declares",
+		"@renamed_from name must be a non-empty string",
+	} {
+		if !hasError(errs, want) {
+			t.Errorf("Expected %q, got %v", want, errs)
+		}
+	}
+}
+
+func TestComputedField(t *testing.T) {
+	errs := checkSource(t, `
+package test;
+
+entity Person {
+    @pk id: string;
+    first: string;
+    last: string;
+    age: int32;
+    @computed("first || ' ' || last") full_name: string;
+    @computed("age + 1") next_age: int64;
+}
+`)
+	if len(errs) != 0 {
+		t.Errorf("Expected computed fields to be valid, got %v", errs)
+	}
+
+	errs = checkSource(t, `
+package test;
+
+entity Person {
+    @pk id: string;
+    first: string;
+    active: bool;
+    @computed("first || nickname") a: string;
+    @computed("b || first") b: string;
+    @computed("a || first") c: string;
+    @computed("first || active") d: string;
+    @computed("first || ' '") e: int32;
+    @computed("first ||") f: string;
+    @computed("first") @default("x") g: string;
+}
+`)
+	for _, want := range []string{
+		"@computed refers to unknown field nickname of Person",
+		"computed field b cannot refer to itself",
+		"computed field c cannot refer to computed field a",
+		"|| requires string or printable operands, got bool",
+		"@computed expression of e is string, but the field is int32",
+		"invalid @computed expression",
+		"computed field g cannot have @default",
+	} {
+		if !hasError(errs, want) {
+			t.Errorf("Expected %q, got %v", want, errs)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	fields := []string{"start_date", "end_date", "status", "id"}
+	for _, tt := range []struct {
+		name, want string
+	}{
+		{"start_dat", "start_date"},
+		{"Start_Date", "start_date"},
+		{"statsu", "status"},
+		{"ix", "id"},
+		{"owner", ""},
+		{"x", ""},
+	} {
+		if got := suggest(tt.name, fields); got != tt.want {
+			t.Errorf("suggest(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestUnknownIdentifierSuggestion(t *testing.T) {
+	errs := checkSource(t, `
+package test;
+
+entity Event {
+    @pk id: string;
+    start_date: timestamp;
+
+    query after(since: timestamp) {
+        where start_dat > sinse AND COUNTT(id) > 0
+    }
+
+    query owned() {
+        where owner_id = 1
+    }
+}
+`)
+	for _, want := range []string{
+		"unknown identifier: start_dat; did you mean start_date?",
+		"unknown identifier: sinse; did you mean since?",
+		"unknown function: COUNTT; did you mean COUNT?",
+	} {
+		if !hasError(errs, want) {
+			t.Errorf("Expected %q, got %v", want, errs)
+		}
+	}
+
+	var found bool
+	for _, e := range errs {
+		switch {
+		case strings.HasPrefix(e.Message, "unknown identifier: start_dat"):
+			found = true
+			if e.Suggestion != "start_date" {
+				t.Errorf("Expected suggestion start_date, got %q", e.Suggestion)
+			}
+		case strings.HasPrefix(e.Message, "unknown identifier: owner_id"):
+			if e.Suggestion != "" || strings.Contains(e.Message, "did you mean") {
+				t.Errorf("Expected no suggestion for owner_id, got %q", e.Message)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("Expected an error for start_dat, got %v", errs)
+	}
+}
+
+func TestRowComparison(t *testing.T) {
+	errs := checkSource(t, `
+package test;
+
+entity Event {
+    @pk id: string;
+    start_date: timestamp;
+
+    query after(after_date: timestamp, after_id: string) {
+        where (start_date, id) > (after_date, after_id)
+    }
+
+    query mismatched(after_date: timestamp) {
+        where (start_date, id) >= (after_date)
+    }
+
+    query uneven(after_date: timestamp, after_id: string) {
+        where (start_date, id) < (after_date, after_id, 1) OR (start_dat, id) = (after_date, after_id)
+    }
+}
+`)
+	for _, want := range []string{
+		"cannot compare a row of 2 values with a single value",
+		"row comparison < has 2 values on the left but 3 on the right",
+		"unknown identifier: start_dat",
+	} {
+		if !hasError(errs, want) {
+			t.Errorf("Expected %q, got %v", want, errs)
+		}
+	}
+	if len(errs) != 3 {
+		t.Errorf("Expected 3 errors, got %v", errs)
+	}
+}
@@ -0,0 +1,128 @@
+package checker
+
+import (
+	"strings"
+
+	"github.com/aurora/dataproto/internal/parser"
+)
+
+// resolvedImport is an import of the checked file that Loader resolved.
+type resolvedImport struct {
+	decl *parser.ImportDecl
+	file *parser.File
+}
+
+// resolveImports loads and parses each import of the checked file with
+// Loader, reporting those that cannot be loaded or parsed. The declarations
+// of the others become visible to the file, as with AddImport.
+func (c *Checker) resolveImports() {
+	for _, imp := range c.file.Imports {
+		path := parser.ResolveImport(c.file.Position.Filename, imp.Path)
+		src, err := c.Loader(path)
+		if err != nil {
+			c.addError(imp, "cannot resolve import %q: %v", imp.Path, err)
+			continue
+		}
+		file, err := parser.ParseFile(src, path)
+		if err != nil {
+			c.addError(imp, "import %q does not parse: %v", imp.Path, err)
+			continue
+		}
+		c.imports = append(c.imports, file)
+		c.resolved = append(c.resolved, resolvedImport{decl: imp, file: file})
+	}
+}
+
+// checkUnusedImports warns about resolved imports none of whose
+// declarations the checked file refers to.
+func (c *Checker) checkUnusedImports() {
+	if len(c.resolved) == 0 {
+		return
+	}
+	used := referencedNames(c.file)
+	for _, imp := range c.resolved {
+		if !declaresAny(imp.file, used) {
+			c.addWarning(imp.decl, "import %q is not used", imp.decl.Path)
+		}
+	}
+}
+
+// referencedNames returns the names of the declarations file refers to: the
+// types of fields, parameters and rpcs, base entities, and the entities
+// named by @fk and @view.
+func referencedNames(file *parser.File) map[string]bool {
+	names := make(map[string]bool)
+	addType := func(t *parser.TypeRef) {
+		if t != nil {
+			names[t.Name] = true
+		}
+	}
+	addRef := func(ref string) {
+		if i := strings.IndexAny(ref, ".("); i >= 0 {
+			ref = ref[:i]
+		}
+		names[strings.TrimSpace(ref)] = true
+	}
+
+	for _, entity := range file.Entities {
+		if entity.Extends != "" {
+			names[entity.Extends] = true
+		}
+		for _, field := range entity.Fields {
+			addType(field.Type)
+			if fk := field.GetAnnotation("fk"); fk != nil && len(fk.Args) > 0 {
+				if ref, ok := fk.Args[0].Value.(string); ok {
+					addRef(ref)
+				}
+			}
+		}
+		for _, query := range entity.Queries {
+			for _, param := range query.Params {
+				addType(param.Type)
+			}
+		}
+		for _, fk := range entity.ForeignKeys() {
+			addRef(fk.References)
+		}
+		if view := entity.View(); view != nil && view.Entity != "" {
+			names[view.Entity] = true
+		}
+	}
+	for _, msg := range file.Messages {
+		for _, field := range msg.Fields {
+			addType(field.Type)
+		}
+	}
+	for _, svc := range file.Services {
+		for _, rpc := range svc.Methods {
+			if rpc.RequestType != nil {
+				names[rpc.RequestType.Name] = true
+			}
+			if rpc.ResponseType != nil {
+				names[rpc.ResponseType.Name] = true
+			}
+		}
+	}
+	return names
+}
+
+// declaresAny reports whether file declares an enum, entity or message named
+// in names.
+func declaresAny(file *parser.File, names map[string]bool) bool {
+	for _, enum := range file.Enums {
+		if names[enum.Name] {
+			return true
+		}
+	}
+	for _, entity := range file.Entities {
+		if names[entity.Name] {
+			return true
+		}
+	}
+	for _, msg := range file.Messages {
+		if names[msg.Name] {
+			return true
+		}
+	}
+	return false
+}
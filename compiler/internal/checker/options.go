@@ -0,0 +1,76 @@
+package checker
+
+import (
+	"strings"
+
+	"github.com/aurora/dataproto/internal/parser"
+)
+
+// optionKind is the value kind a file option accepts.
+type optionKind int
+
+const (
+	optionString optionKind = iota
+	optionBool
+	optionEnum
+)
+
+// protoOption describes a known protobuf file option.
+type protoOption struct {
+	kind   optionKind
+	values []string // allowed identifiers for optionEnum
+}
+
+// protoFileOptions lists the protobuf file options the proto generator passes
+// through. Other options are still emitted but reported as warnings.
+var protoFileOptions = map[string]protoOption{
+	"optimize_for":         {kind: optionEnum, values: []string{"SPEED", "CODE_SIZE", "LITE_RUNTIME"}},
+	"java_package":         {kind: optionString},
+	"java_outer_classname": {kind: optionString},
+	"java_multiple_files":  {kind: optionBool},
+	"go_package":           {kind: optionString},
+	"objc_class_prefix":    {kind: optionString},
+	"csharp_namespace":     {kind: optionString},
+	"swift_prefix":         {kind: optionString},
+	"php_namespace":        {kind: optionString},
+	"ruby_package":         {kind: optionString},
+	"cc_enable_arenas":     {kind: optionBool},
+	"deprecated":           {kind: optionBool},
+}
+
+// checkOption validates a file-level option against protoFileOptions.
+func (c *Checker) checkOption(opt *parser.OptionDecl) {
+	if opt.IsExtension() {
+		// Custom options are declared in other .proto files we cannot see
+		if _, isList := opt.Value.([]interface{}); isList {
+			c.addWarning(opt, "option %s has a list value, which the proto generator cannot emit", opt.Name)
+		}
+		return
+	}
+	known, ok := protoFileOptions[opt.Name]
+	if !ok {
+		c.addWarning(opt, "unknown proto option %q", opt.Name)
+		return
+	}
+
+	switch known.kind {
+	case optionString:
+		if _, isString := opt.Value.(string); !isString || opt.Ident {
+			c.addError(opt, "option %s requires a string value", opt.Name)
+		}
+	case optionBool:
+		if _, isBool := opt.Value.(bool); !isBool {
+			c.addError(opt, "option %s requires a bool value", opt.Name)
+		}
+	case optionEnum:
+		value, _ := opt.Value.(string)
+		if opt.Ident {
+			for _, allowed := range known.values {
+				if value == allowed {
+					return
+				}
+			}
+		}
+		c.addError(opt, "option %s must be one of %s", opt.Name, strings.Join(known.values, ", "))
+	}
+}
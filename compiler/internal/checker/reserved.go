@@ -0,0 +1,112 @@
+package checker
+
+import (
+	"strings"
+
+	"github.com/aurora/dataproto/internal/naming"
+	"github.com/aurora/dataproto/internal/parser"
+)
+
+// reservedWordLanguages lists target languages in the order their reserved
+// words are reported.
+var reservedWordLanguages = []string{"Go", "Java", "Kotlin", "C++"}
+
+// reservedWords holds, per target language, identifiers that cannot be used
+// as package or namespace segments in generated code.
+var reservedWords = map[string]map[string]bool{
+	"Go": wordSet(
+		"break", "case", "chan", "const", "continue", "default", "defer", "else",
+		"fallthrough", "for", "func", "go", "goto", "if", "import", "interface",
+		"map", "package", "range", "return", "select", "struct", "switch", "type", "var",
+	),
+	"Java": wordSet(
+		"abstract", "assert", "boolean", "break", "byte", "case", "catch", "char",
+		"class", "const", "continue", "default", "do", "double", "else", "enum",
+		"extends", "final", "finally", "float", "for", "goto", "if", "implements",
+		"import", "instanceof", "int", "interface", "long", "native", "new",
+		"package", "private", "protected", "public", "return", "short", "static",
+		"strictfp", "super", "switch", "synchronized", "this", "throw", "throws",
+		"transient", "try", "void", "volatile", "while", "true", "false", "null",
+	),
+	"Kotlin": wordSet(
+		"as", "break", "class", "continue", "do", "else", "false", "for", "fun",
+		"if", "in", "interface", "is", "null", "object", "package", "return",
+		"super", "this", "throw", "true", "try", "typealias", "typeof", "val",
+		"var", "when", "while",
+	),
+	"C++": wordSet(
+		"alignas", "alignof", "and", "asm", "auto", "bool", "break", "case",
+		"catch", "char", "class", "const", "constexpr", "continue", "decltype",
+		"default", "delete", "do", "double", "else", "enum", "explicit", "export",
+		"extern", "false", "float", "for", "friend", "goto", "if", "inline", "int",
+		"long", "mutable", "namespace", "new", "noexcept", "not", "nullptr",
+		"operator", "or", "private", "protected", "public", "register", "return",
+		"short", "signed", "sizeof", "static", "struct", "switch", "template",
+		"this", "throw", "true", "try", "typedef", "typeid", "typename", "union",
+		"unsigned", "using", "virtual", "void", "volatile", "while",
+	),
+}
+
+func wordSet(words ...string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		set[w] = true
+	}
+	return set
+}
+
+// reservedIn returns the target languages in which word is reserved.
+func reservedIn(word string) []string {
+	var langs []string
+	for _, lang := range reservedWordLanguages {
+		if reservedWords[lang][word] {
+			langs = append(langs, lang)
+		}
+	}
+	return langs
+}
+
+// sqlDialects lists the SQL dialects in the order reserved keywords are
+// reported, with the backend names that select them.
+var sqlDialects = []struct {
+	dialect  string
+	backends []string
+}{
+	{"sqlite", []string{"sqlite"}},
+	{"postgres", []string{"postgres", "postgresql"}},
+}
+
+// sqlReservedIn returns the SQL dialects, among those the entity is stored
+// in, in which name is a reserved keyword.
+func sqlReservedIn(entity *parser.EntityDecl, name string) []string {
+	var dialects []string
+	for _, d := range sqlDialects {
+		if !targetsBackend(entity, d.backends...) {
+			continue
+		}
+		if naming.IsReservedSQLKeyword(name, d.dialect) {
+			dialects = append(dialects, d.dialect)
+		}
+	}
+	return dialects
+}
+
+// checkSQLNames warns when an entity's table or column names are reserved
+// SQL keywords, which break the generated DDL unless identifiers are quoted.
+func (c *Checker) checkSQLNames(entity *parser.EntityDecl) {
+	table := entity.TableName()
+	if table == "" {
+		table = naming.ToSnakeCase(entity.Name)
+	}
+	if dialects := sqlReservedIn(entity, table); len(dialects) > 0 {
+		c.addWarning(entity, "table name %q is a reserved SQL keyword in %s",
+			table, strings.Join(dialects, ", "))
+	}
+	for _, field := range entity.Fields {
+		column := naming.ToSnakeCase(field.Name)
+		if dialects := sqlReservedIn(entity, column); len(dialects) > 0 {
+			c.addWarning(field, "column name %q of field %s is a reserved SQL keyword in %s",
+				column, field.Name, strings.Join(dialects, ", "))
+		}
+	}
+}
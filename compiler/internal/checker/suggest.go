@@ -0,0 +1,63 @@
+package checker
+
+import (
+	"sort"
+	"strings"
+)
+
+// suggest returns the candidate closest to name by edit distance, ignoring
+// case, when it is close enough to be a likely typo: at most a third of
+// name's length away, and at least one edit. It returns empty string when no
+// candidate qualifies. Ties go to the candidate that sorts first.
+func suggest(name string, candidates []string) string {
+	sorted := append([]string(nil), candidates...)
+	sort.Strings(sorted)
+
+	limit := len(name) / 3
+	if limit < 1 {
+		limit = 1
+	}
+	best, bestDist := "", limit+1
+	for _, cand := range sorted {
+		if cand == name {
+			continue
+		}
+		d := levenshtein(strings.ToLower(name), strings.ToLower(cand))
+		if d < bestDist && d < len(name) {
+			best, bestDist = cand, d
+		}
+	}
+	return best
+}
+
+// levenshtein returns the number of single-rune insertions, deletions and
+// substitutions that turn a into b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+// didYouMean formats a suggestion as the tail of a diagnostic, or returns
+// empty string without one.
+func didYouMean(suggestion string) string {
+	if suggestion == "" {
+		return ""
+	}
+	return "; did you mean " + suggestion + "?"
+}
@@ -3,9 +3,10 @@ package codegen
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
-	"unicode"
 
+	"github.com/aurora/dataproto/internal/naming"
 	"github.com/aurora/dataproto/internal/parser"
 )
 
@@ -14,14 +15,36 @@ type Generator interface {
 	Generate(file *parser.File) (map[string]string, error)
 }
 
+// concreteFile returns file without its @abstract entities. Their fields are
+// already copied into the entities extending them, and they generate nothing
+// of their own.
+func concreteFile(file *parser.File) *parser.File {
+	var entities []*parser.EntityDecl
+	for _, entity := range file.Entities {
+		if !entity.IsAbstract() {
+			entities = append(entities, entity)
+		}
+	}
+	if len(entities) == len(file.Entities) {
+		return file
+	}
+	cp := *file
+	cp.Entities = entities
+	return &cp
+}
+
 // TypeMapping provides type mappings for different backends.
 type TypeMapping struct {
 	Proto    string
 	SQLite   string
 	Postgres string
+	MySQL    string
 	Java     string
 	Swift    string
 	Python   string
+	Rust     string
+	Go       string
+	GraphQL  string
 }
 
 // GetTypeMapping returns the type mapping for a DataProto type.
@@ -32,72 +55,104 @@ func GetTypeMapping(typeName string) TypeMapping {
 			Proto:    "string",
 			SQLite:   "TEXT",
 			Postgres: "TEXT",
+			MySQL:    "VARCHAR(255)",
 			Java:     "String",
 			Swift:    "String",
 			Python:   "str",
+			Rust:     "String",
+			Go:       "string",
+			GraphQL:  "String",
 		}
 	case "int32":
 		return TypeMapping{
 			Proto:    "int32",
 			SQLite:   "INTEGER",
 			Postgres: "INTEGER",
+			MySQL:    "INT",
 			Java:     "int",
 			Swift:    "Int32",
 			Python:   "int",
+			Rust:     "i32",
+			Go:       "int32",
+			GraphQL:  "Int",
 		}
 	case "int64":
 		return TypeMapping{
 			Proto:    "int64",
 			SQLite:   "INTEGER",
 			Postgres: "BIGINT",
+			MySQL:    "BIGINT",
 			Java:     "long",
 			Swift:    "Int64",
 			Python:   "int",
+			Rust:     "i64",
+			Go:       "int64",
+			GraphQL:  "Int64",
 		}
 	case "float":
 		return TypeMapping{
 			Proto:    "float",
 			SQLite:   "REAL",
 			Postgres: "REAL",
+			MySQL:    "FLOAT",
 			Java:     "float",
 			Swift:    "Float",
 			Python:   "float",
+			Rust:     "f32",
+			Go:       "float32",
+			GraphQL:  "Float",
 		}
 	case "double":
 		return TypeMapping{
 			Proto:    "double",
 			SQLite:   "REAL",
 			Postgres: "DOUBLE PRECISION",
+			MySQL:    "DOUBLE",
 			Java:     "double",
 			Swift:    "Double",
 			Python:   "float",
+			Rust:     "f64",
+			Go:       "float64",
+			GraphQL:  "Float",
 		}
 	case "bool":
 		return TypeMapping{
 			Proto:    "bool",
 			SQLite:   "INTEGER",
 			Postgres: "BOOLEAN",
+			MySQL:    "BOOLEAN",
 			Java:     "boolean",
 			Swift:    "Bool",
 			Python:   "bool",
+			Rust:     "bool",
+			Go:       "bool",
+			GraphQL:  "Boolean",
 		}
 	case "bytes":
 		return TypeMapping{
 			Proto:    "bytes",
 			SQLite:   "BLOB",
 			Postgres: "BYTEA",
+			MySQL:    "BLOB",
 			Java:     "byte[]",
 			Swift:    "Data",
 			Python:   "bytes",
+			Rust:     "Vec<u8>",
+			Go:       "[]byte",
+			GraphQL:  "String",
 		}
 	case "timestamp":
 		return TypeMapping{
 			Proto:    "int64",
 			SQLite:   "INTEGER",
 			Postgres: "BIGINT",
+			MySQL:    "BIGINT",
 			Java:     "long",
 			Swift:    "Int64",
 			Python:   "int",
+			Rust:     "i64",
+			Go:       "int64",
+			GraphQL:  "DateTime",
 		}
 	default:
 		// Custom type (enum or entity reference)
@@ -105,86 +160,76 @@ func GetTypeMapping(typeName string) TypeMapping {
 			Proto:    typeName,
 			SQLite:   "TEXT",
 			Postgres: "TEXT",
+			MySQL:    "VARCHAR(255)",
 			Java:     typeName,
 			Swift:    typeName,
 			Python:   typeName,
+			Rust:     typeName,
+			Go:       typeName,
+			GraphQL:  typeName,
 		}
 	}
 }
 
+// TimestampMode selects how timestamp fields are represented.
+type TimestampMode int
+
+const (
+	// TimestampEpochMillis represents timestamps as int64 epoch milliseconds.
+	TimestampEpochMillis TimestampMode = iota
+	// TimestampNative uses each backend's own date/time type.
+	TimestampNative
+)
+
+func (m TimestampMode) String() string {
+	if m == TimestampNative {
+		return "native"
+	}
+	return "epoch_millis"
+}
+
+// GetTypeMappingWithMode returns the type mapping for a DataProto type with
+// timestamps represented according to mode. In native mode a timestamp is
+// TIMESTAMPTZ in Postgres, DATETIME in SQLite, DATETIME(3) in MySQL and
+// google.protobuf.Timestamp in proto.
+func GetTypeMappingWithMode(typeName string, mode TimestampMode) TypeMapping {
+	mapping := GetTypeMapping(typeName)
+	if typeName == "timestamp" && mode == TimestampNative {
+		mapping.Proto = "google.protobuf.Timestamp"
+		mapping.SQLite = "DATETIME"
+		mapping.Postgres = "TIMESTAMPTZ"
+		mapping.MySQL = "DATETIME(3)"
+	}
+	return mapping
+}
+
 // ToPascalCase converts a string to PascalCase.
 func ToPascalCase(s string) string {
-	words := splitWords(s)
-	for i, word := range words {
-		if len(word) > 0 {
-			words[i] = strings.ToUpper(string(word[0])) + strings.ToLower(word[1:])
-		}
-	}
-	return strings.Join(words, "")
+	return naming.ToPascalCase(s)
 }
 
 // ToCamelCase converts a string to camelCase.
 func ToCamelCase(s string) string {
-	pascal := ToPascalCase(s)
-	if len(pascal) == 0 {
-		return pascal
+	return naming.ToCamelCase(s)
+}
+
+// JSONName returns the name a field is serialized under: its @json override,
+// or the snake_case field name.
+func JSONName(field *parser.FieldDecl) string {
+	if name := field.JSONName(); name != "" {
+		return name
 	}
-	return strings.ToLower(string(pascal[0])) + pascal[1:]
+	return ToSnakeCase(field.Name)
 }
 
 // ToSnakeCase converts a string to snake_case.
 func ToSnakeCase(s string) string {
-	var result strings.Builder
-	for i, r := range s {
-		if unicode.IsUpper(r) {
-			if i > 0 {
-				result.WriteRune('_')
-			}
-			result.WriteRune(unicode.ToLower(r))
-		} else {
-			result.WriteRune(r)
-		}
-	}
-	return result.String()
+	return naming.ToSnakeCase(s)
 }
 
 // ToScreamingSnakeCase converts a string to SCREAMING_SNAKE_CASE.
 func ToScreamingSnakeCase(s string) string {
-	return strings.ToUpper(ToSnakeCase(s))
-}
-
-// splitWords splits a string into words based on underscores and case changes.
-func splitWords(s string) []string {
-	var words []string
-	var current strings.Builder
-
-	for i, r := range s {
-		if r == '_' {
-			if current.Len() > 0 {
-				words = append(words, current.String())
-				current.Reset()
-			}
-			continue
-		}
-
-		if unicode.IsUpper(r) && i > 0 {
-			prev := rune(s[i-1])
-			if !unicode.IsUpper(prev) && prev != '_' {
-				if current.Len() > 0 {
-					words = append(words, current.String())
-					current.Reset()
-				}
-			}
-		}
-
-		current.WriteRune(r)
-	}
-
-	if current.Len() > 0 {
-		words = append(words, current.String())
-	}
-
-	return words
+	return naming.ToScreamingSnakeCase(s)
 }
 
 // ExprToSQL converts an expression AST to SQL string.
@@ -209,6 +254,9 @@ func ExprToSQL(expr parser.Expr) string {
 	case *parser.IdentExpr:
 		return e.Name
 
+	case *parser.FieldAccessExpr:
+		return ExprToSQL(e.Base) + "." + e.Field
+
 	case *parser.LiteralExpr:
 		switch v := e.Value.(type) {
 		case string:
@@ -216,7 +264,7 @@ func ExprToSQL(expr parser.Expr) string {
 		case int64:
 			return fmt.Sprintf("%d", v)
 		case float64:
-			return fmt.Sprintf("%f", v)
+			return sqlFloat(v)
 		case bool:
 			if v {
 				return "1"
@@ -236,42 +284,107 @@ func ExprToSQL(expr parser.Expr) string {
 	case *parser.ParenExpr:
 		return fmt.Sprintf("(%s)", ExprToSQL(e.Inner))
 
+	case *parser.TupleExpr:
+		var elems []string
+		for _, elem := range e.Elements {
+			elems = append(elems, ExprToSQL(elem))
+		}
+		return fmt.Sprintf("(%s)", strings.Join(elems, ", "))
+
+	case *parser.CaseExpr:
+		return caseToSQL(e, ExprToSQL)
+
 	default:
 		return ""
 	}
 }
 
-// ExprToSQLWithParams converts an expression to parameterized SQL.
-// Returns the SQL string and a list of parameter names.
-// DEPRECATED: Use ExprToSQLWithKnownParams for accurate parameter detection.
-func ExprToSQLWithParams(expr parser.Expr, paramPrefix string) (string, []string) {
-	var params []string
-	sql := exprToSQLWithParamsInternal(expr, paramPrefix, &params, nil)
-	return sql, params
+// sqlFloat renders a float literal in the shortest form that round-trips,
+// keeping a decimal point on integral values so the database still treats
+// the literal as real rather than integer.
+func sqlFloat(v float64) string {
+	s := strconv.FormatFloat(v, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+
+// ExprToSQLWithParams converts an expression to parameterized SQL for a
+// query with the given parameters in dialect. An identifier naming one of
+// them becomes a placeholder in the given style; any other identifier is a
+// column in snake_case. The returned names are the bound parameters in
+// placeholder order, whatever the style. A comparison against a nullable
+// parameter is guarded as (param IS NULL OR comparison), so null skips it,
+// and a parameter with a default expression reads COALESCE(param, default).
+func ExprToSQLWithParams(expr parser.Expr, params []*parser.QueryParam, dialect Dialect, style PlaceholderStyle) (string, []string) {
+	ph := newPlaceholders(dialect, style)
+	sql := exprToSQLWithParamsInternal(SimplifyExpr(FoldConstants(guardOptionalParams(injectParamDefaults(expr, params), params))), ph, paramSet(params), dialect)
+	return sql, ph.names
+}
+
+// paramSet returns the names of params as a set.
+func paramSet(params []*parser.QueryParam) map[string]bool {
+	set := make(map[string]bool, len(params))
+	for _, p := range params {
+		set[p.Name] = true
+	}
+	return set
 }
 
 // ExprToSQLWithKnownParams converts an expression to parameterized SQL.
 // knownParams is a set of parameter names that should be converted to ? placeholders.
 // Other identifiers are treated as column names and output in snake_case.
+// A parameter named like a field shadows it, so every reference binds the
+// parameter; the checker warns about such queries.
+// The expression is constant-folded and simplified first.
 func ExprToSQLWithKnownParams(expr parser.Expr, knownParams map[string]bool) (string, []string) {
-	var params []string
-	sql := exprToSQLWithParamsInternal(expr, "", &params, knownParams)
-	return sql, params
+	return ExprToDialectSQL(expr, knownParams, DialectSQLite)
 }
 
-func exprToSQLWithParamsInternal(expr parser.Expr, prefix string, params *[]string, knownParams map[string]bool) string {
+// ExprToDialectSQL is ExprToSQLWithKnownParams for a specific SQL dialect,
+// using the dialect's own placeholders.
+func ExprToDialectSQL(expr parser.Expr, knownParams map[string]bool, dialect Dialect) (string, []string) {
+	return exprToDialectSQL(expr, knownParams, dialect, plainIdent, TimestampEpochMillis)
+}
+
+// exprToDialectSQL is ExprToDialectSQL with columns written by ident and
+// NOW() in the timestamp mode.
+func exprToDialectSQL(expr parser.Expr, knownParams map[string]bool, dialect Dialect, ident func(string) string, mode TimestampMode) (string, []string) {
+	ph := newPlaceholders(dialect, PlaceholderDialect)
+	ph.ident = ident
+	ph.timestamps = mode
+	sql := exprToSQLWithParamsInternal(SimplifyExpr(FoldConstants(expr)), ph, knownParams, dialect)
+	return sql, ph.names
+}
+
+func exprToSQLWithParamsInternal(expr parser.Expr, ph *placeholders, knownParams map[string]bool, dialect Dialect) string {
 	switch e := expr.(type) {
 	case *parser.BinaryExpr:
-		left := exprToSQLWithParamsInternal(e.Left, prefix, params, knownParams)
-		right := exprToSQLWithParamsInternal(e.Right, prefix, params, knownParams)
-		return fmt.Sprintf("%s %s %s", left, e.Op, right)
+		if e.Op == "||" && dialect == DialectMySQL {
+			// MySQL reads || as logical OR; a chain becomes one CONCAT
+			var args []string
+			for _, operand := range concatOperands(e) {
+				args = append(args, exprToSQLWithParamsInternal(operand, ph, knownParams, dialect))
+			}
+			return fmt.Sprintf("CONCAT(%s)", strings.Join(args, ", "))
+		}
+		left := exprToSQLWithParamsInternal(e.Left, ph, knownParams, dialect)
+		right := exprToSQLWithParamsInternal(e.Right, ph, knownParams, dialect)
+		op := e.Op
+		if op == "ILIKE" && dialect != DialectPostgres {
+			// SQLite's LIKE already ignores case for ASCII letters, and
+			// MySQL's does under its default collations
+			op = "LIKE"
+		}
+		return fmt.Sprintf("%s %s %s", left, op, right)
 
 	case *parser.UnaryExpr:
-		operand := exprToSQLWithParamsInternal(e.Operand, prefix, params, knownParams)
+		operand := exprToSQLWithParamsInternal(e.Operand, ph, knownParams, dialect)
 		return fmt.Sprintf("%s %s", e.Op, operand)
 
 	case *parser.IsNullExpr:
-		operand := exprToSQLWithParamsInternal(e.Operand, prefix, params, knownParams)
+		operand := exprToSQLWithParamsInternal(e.Operand, ph, knownParams, dialect)
 		if e.Not {
 			return fmt.Sprintf("%s IS NOT NULL", operand)
 		}
@@ -280,11 +393,13 @@ func exprToSQLWithParamsInternal(expr parser.Expr, prefix string, params *[]stri
 	case *parser.IdentExpr:
 		// Check if this is a known parameter (from query signature)
 		if knownParams != nil && knownParams[e.Name] {
-			*params = append(*params, e.Name)
-			return "?"
+			return ph.next(e.Name)
 		}
 		// Otherwise, treat as column name - convert to snake_case
-		return ToSnakeCase(e.Name)
+		return ph.ident(ToSnakeCase(e.Name))
+
+	case *parser.FieldAccessExpr:
+		return exprToSQLWithParamsInternal(e.Base, ph, knownParams, dialect) + "." + ph.ident(ToSnakeCase(e.Field))
 
 	case *parser.LiteralExpr:
 		switch v := e.Value.(type) {
@@ -293,8 +408,15 @@ func exprToSQLWithParamsInternal(expr parser.Expr, prefix string, params *[]stri
 		case int64:
 			return fmt.Sprintf("%d", v)
 		case float64:
-			return fmt.Sprintf("%f", v)
+			return sqlFloat(v)
 		case bool:
+			if dialect == DialectPostgres {
+				// A Postgres BOOLEAN does not compare equal to an integer
+				if v {
+					return "TRUE"
+				}
+				return "FALSE"
+			}
 			if v {
 				return "1"
 			}
@@ -306,22 +428,59 @@ func exprToSQLWithParamsInternal(expr parser.Expr, prefix string, params *[]stri
 	case *parser.CallExpr:
 		var args []string
 		for _, arg := range e.Args {
-			args = append(args, exprToSQLWithParamsInternal(arg, prefix, params, knownParams))
+			args = append(args, exprToSQLWithParamsInternal(arg, ph, knownParams, dialect))
 		}
 		// Handle special functions
 		if e.Name == "NOW" {
-			return "(strftime('%s', 'now') * 1000)"
+			return nowSQL(dialect, ph.timestamps)
 		}
 		return fmt.Sprintf("%s(%s)", e.Name, strings.Join(args, ", "))
 
 	case *parser.ParenExpr:
-		return fmt.Sprintf("(%s)", exprToSQLWithParamsInternal(e.Inner, prefix, params, knownParams))
+		return fmt.Sprintf("(%s)", exprToSQLWithParamsInternal(e.Inner, ph, knownParams, dialect))
+
+	case *parser.TupleExpr:
+		// A row value; SQLite, Postgres and MySQL all compare these
+		// element by element
+		var elems []string
+		for _, elem := range e.Elements {
+			elems = append(elems, exprToSQLWithParamsInternal(elem, ph, knownParams, dialect))
+		}
+		return fmt.Sprintf("(%s)", strings.Join(elems, ", "))
+
+	case *parser.CaseExpr:
+		// Branches render in source order so placeholders stay in step with params
+		return caseToSQL(e, func(expr parser.Expr) string {
+			return exprToSQLWithParamsInternal(expr, ph, knownParams, dialect)
+		})
 
 	default:
 		return ""
 	}
 }
 
+// concatOperands returns the operands of a chain of || in source order.
+func concatOperands(expr parser.Expr) []parser.Expr {
+	if bin, ok := expr.(*parser.BinaryExpr); ok && bin.Op == "||" {
+		return append(concatOperands(bin.Left), concatOperands(bin.Right)...)
+	}
+	return []parser.Expr{expr}
+}
+
+// caseToSQL renders a CASE expression, converting each branch with toSQL.
+func caseToSQL(e *parser.CaseExpr, toSQL func(parser.Expr) string) string {
+	var sb strings.Builder
+	sb.WriteString("CASE")
+	for _, when := range e.Whens {
+		sb.WriteString(fmt.Sprintf(" WHEN %s THEN %s", toSQL(when.Cond), toSQL(when.Result)))
+	}
+	if e.Else != nil {
+		sb.WriteString(" ELSE " + toSQL(e.Else))
+	}
+	sb.WriteString(" END")
+	return sb.String()
+}
+
 // IndentLines indents each line of a string.
 func IndentLines(s string, indent string) string {
 	lines := strings.Split(s, "\n")
@@ -332,3 +491,76 @@ func IndentLines(s string, indent string) string {
 	}
 	return strings.Join(lines, "\n")
 }
+
+// ReindentLines replaces the leading indentation of each line, counted in
+// units of from, with the same number of units of to. Indentation that is
+// not a whole number of units keeps its remainder.
+func ReindentLines(s string, from, to string) string {
+	if from == "" || from == to {
+		return s
+	}
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		depth := 0
+		for strings.HasPrefix(line, from) {
+			line = line[len(from):]
+			depth++
+		}
+		lines[i] = strings.Repeat(to, depth) + line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// GenOptions are output options shared by the generators that implement
+// OptionsGenerator. The zero value keeps each generator's defaults.
+type GenOptions struct {
+	// Indent is one level of indentation, such as "  " or "\t"; empty
+	// keeps the generator's own
+	Indent string
+	// Header is a banner, such as a license notice, written as comment
+	// lines above the generated-code marker of every file
+	Header string
+	// Package overrides the package name taken from the schema
+	Package string
+}
+
+// OptionsGenerator is a Generator that also accepts GenOptions.
+type OptionsGenerator interface {
+	Generator
+	GenerateWith(file *parser.File, opts GenOptions) (map[string]string, error)
+}
+
+// packageName returns the package override, or the schema's package name,
+// or empty string when there is neither.
+func (o GenOptions) packageName(file *parser.File) string {
+	if o.Package != "" {
+		return o.Package
+	}
+	if file.Package != nil {
+		return file.Package.Name
+	}
+	return ""
+}
+
+// header renders Header as comment lines starting with prefix, followed by
+// a blank line, or empty string without a header.
+func (o GenOptions) header(prefix string) string {
+	if o.Header == "" {
+		return ""
+	}
+	var sb strings.Builder
+	for _, line := range strings.Split(strings.TrimRight(o.Header, "\n"), "\n") {
+		sb.WriteString(strings.TrimRight(prefix+" "+line, " ") + "\n")
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+// indent converts code indented with native, the generator's own unit, to
+// the configured Indent.
+func (o GenOptions) indent(code, native string) string {
+	if o.Indent == "" {
+		return code
+	}
+	return ReindentLines(code, native, o.Indent)
+}
@@ -0,0 +1,506 @@
+package codegen
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aurora/dataproto/internal/parser"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+// mustParse parses input, failing the test on parse errors.
+func mustParse(t *testing.T, input string) *parser.File {
+	t.Helper()
+	file, err := parser.Parse(input)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	return file
+}
+
+// generateOne runs g and returns the single generated file's content.
+func generateOne(t *testing.T, g Generator, file *parser.File) string {
+	t.Helper()
+	out, err := g.Generate(file)
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+	if len(out) != 1 {
+		t.Fatalf("Expected 1 generated file, got %d", len(out))
+	}
+	for _, content := range out {
+		return content
+	}
+	return ""
+}
+
+// mustParseFile parses a schema from disk, failing the test on errors.
+func mustParseFile(t *testing.T, path string) *parser.File {
+	t.Helper()
+	src, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Read error: %v", err)
+	}
+	return mustParse(t, string(src))
+}
+
+// assertGolden compares got with the golden file testdata/name.
+// Run the tests with -update to rewrite golden files.
+func assertGolden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Read golden file: %v (run with -update to create it)", err)
+	}
+	if got != string(want) {
+		t.Errorf("Output does not match %s:\n--- got ---\n%s\n--- want ---\n%s", path, got, want)
+	}
+}
+
+func TestGenOptionsIndentAndHeader(t *testing.T) {
+	file := mustParse(t, `
+package acme.calendar;
+
+enum Priority {
+    LOW = 0;
+}
+
+entity Event {
+    @pk id: string;
+    title: string;
+}
+`)
+
+	generateWith := func(g OptionsGenerator, opts GenOptions) string {
+		t.Helper()
+		out, err := g.GenerateWith(file, opts)
+		if err != nil {
+			t.Fatalf("GenerateWith error: %v", err)
+		}
+		// Leave out the proto field numbers sidecar
+		for name := range out {
+			if strings.HasSuffix(name, ".fieldnumbers.json") {
+				delete(out, name)
+			}
+		}
+		if len(out) != 1 {
+			t.Fatalf("Expected one file, got %d", len(out))
+		}
+		for _, content := range out {
+			return content
+		}
+		return ""
+	}
+
+	header := "Copyright ACME\n\nLicensed under MIT"
+	for _, tt := range []struct {
+		name      string
+		gen       OptionsGenerator
+		twoSpaces string
+		tabs      string
+	}{
+		{"proto", NewProtoGenerator(), "\n  string id = 1;\n", "\n\tstring id = 1;\n"},
+		{"go", NewGoGenerator(), "\n  ID    string `json:\"id\"`\n", "\n\tID    string `json:\"id\"`\n"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			spaced := generateWith(tt.gen, GenOptions{Indent: "  ", Header: header})
+			tabbed := generateWith(tt.gen, GenOptions{Indent: "\t", Header: header})
+
+			if !strings.Contains(spaced, tt.twoSpaces) {
+				t.Errorf("Expected two-space indent %q, got:\n%s", tt.twoSpaces, spaced)
+			}
+			if !strings.Contains(tabbed, tt.tabs) {
+				t.Errorf("Expected tab indent %q, got:\n%s", tt.tabs, tabbed)
+			}
+			if ReindentLines(spaced, "  ", "\t") != tabbed {
+				t.Errorf("Expected runs to differ only in indentation:\n%s\n---\n%s", spaced, tabbed)
+			}
+
+			wantHeader := "// Copyright ACME\n//\n// Licensed under MIT\n\n// Code generated by dataprotoc. DO NOT EDIT.\n"
+			if !strings.HasPrefix(spaced, wantHeader) {
+				t.Errorf("Expected header banner, got:\n%s", spaced)
+			}
+		})
+	}
+
+	out, err := NewGoGenerator().GenerateWith(file, GenOptions{Package: "models"})
+	if err != nil {
+		t.Fatalf("GenerateWith error: %v", err)
+	}
+	if content, ok := out["models.go"]; !ok || !strings.Contains(content, "\npackage models\n") {
+		t.Errorf("Expected models.go in package models, got %v", out)
+	}
+}
+
+func TestTypeMappingMatrix(t *testing.T) {
+	tests := []struct {
+		typeName string
+		mode     TimestampMode
+		proto    string
+		sqlite   string
+		postgres string
+		mysql    string
+	}{
+		{"string", TimestampEpochMillis, "string", "TEXT", "TEXT", "VARCHAR(255)"},
+		{"int32", TimestampEpochMillis, "int32", "INTEGER", "INTEGER", "INT"},
+		{"int64", TimestampEpochMillis, "int64", "INTEGER", "BIGINT", "BIGINT"},
+		{"float", TimestampEpochMillis, "float", "REAL", "REAL", "FLOAT"},
+		{"double", TimestampEpochMillis, "double", "REAL", "DOUBLE PRECISION", "DOUBLE"},
+		{"bool", TimestampEpochMillis, "bool", "INTEGER", "BOOLEAN", "BOOLEAN"},
+		{"bytes", TimestampEpochMillis, "bytes", "BLOB", "BYTEA", "BLOB"},
+		{"timestamp", TimestampEpochMillis, "int64", "INTEGER", "BIGINT", "BIGINT"},
+		{"timestamp", TimestampNative, "google.protobuf.Timestamp", "DATETIME", "TIMESTAMPTZ", "DATETIME(3)"},
+		{"double", TimestampNative, "double", "REAL", "DOUBLE PRECISION", "DOUBLE"},
+		{"Status", TimestampEpochMillis, "Status", "TEXT", "TEXT", "VARCHAR(255)"},
+	}
+	for _, tt := range tests {
+		m := GetTypeMappingWithMode(tt.typeName, tt.mode)
+		if m.Proto != tt.proto || m.SQLite != tt.sqlite || m.Postgres != tt.postgres || m.MySQL != tt.mysql {
+			t.Errorf("%s (%s): got proto %q, sqlite %q, postgres %q, mysql %q; want %q, %q, %q, %q",
+				tt.typeName, tt.mode, m.Proto, m.SQLite, m.Postgres, m.MySQL, tt.proto, tt.sqlite, tt.postgres, tt.mysql)
+		}
+	}
+}
+
+func TestFloatAndDoubleStayDistinct(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+entity Reading {
+    @pk id: string;
+    ratio: float;
+    value: double;
+}
+`)
+
+	proto := generateProto(t, NewProtoGenerator(), file)
+	for _, want := range []string{"float ratio = ", "double value = "} {
+		if !strings.Contains(proto, want) {
+			t.Errorf("Expected %q in proto, got:\n%s", want, proto)
+		}
+	}
+	postgres := generateOne(t, NewPostgresGenerator(), file)
+	for _, want := range []string{"ratio REAL NOT NULL", "value DOUBLE PRECISION NOT NULL"} {
+		if !strings.Contains(postgres, want) {
+			t.Errorf("Expected %q in Postgres DDL, got:\n%s", want, postgres)
+		}
+	}
+	sqlite := generateOne(t, NewSQLiteGenerator(), file)
+	for _, want := range []string{"ratio REAL NOT NULL", "value REAL NOT NULL"} {
+		if !strings.Contains(sqlite, want) {
+			t.Errorf("Expected %q in SQLite DDL, got:\n%s", want, sqlite)
+		}
+	}
+}
+
+func TestNowDefaults(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+entity Task {
+    @pk id: string;
+    @default(now) createdAt: timestamp;
+
+    query createdBefore(before: timestamp = now) {
+        where createdAt < before
+    }
+}
+`)
+
+	for _, tt := range []struct {
+		name string
+		gen  Generator
+		want []string
+	}{
+		{"kotlin", NewKotlinGenerator(), []string{"val createdAt: Long = System.currentTimeMillis()"}},
+		{"swift", NewSwiftGenerator(), []string{
+			"createdAt: Int64 = Int64(Date().timeIntervalSince1970 * 1000)",
+			"createdBefore(before: Int64 = Int64(Date().timeIntervalSince1970 * 1000))",
+		}},
+		{"python", NewPythonGenerator(), []string{
+			"created_at: int = field(default_factory=lambda: int(time.time() * 1000))",
+			"def created_before(self, before: Optional[int] = None)",
+			"        if before is None:\n            before = int(time.time() * 1000)\n",
+		}},
+		{"qt", NewQtGenerator(), []string{
+			"m_createdAt(QDateTime::currentMSecsSinceEpoch())",
+			"createdBefore(qint64 before = QDateTime::currentMSecsSinceEpoch()",
+		}},
+	} {
+		out, err := tt.gen.Generate(file)
+		if err != nil {
+			t.Fatalf("%s: Generate error: %v", tt.name, err)
+		}
+		var all strings.Builder
+		for _, content := range out {
+			all.WriteString(content)
+		}
+		code := all.String()
+		for _, want := range tt.want {
+			if !strings.Contains(code, want) {
+				t.Errorf("%s: expected %q, got:\n%s", tt.name, want, code)
+			}
+		}
+		if strings.Contains(code, `"now"`) {
+			t.Errorf("%s: expected no \"now\" string default, got:\n%s", tt.name, code)
+		}
+	}
+}
+
+func TestViewRepositoriesAreReadOnly(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+@view("SELECT id, title FROM tasks WHERE done = 0")
+entity OpenTask {
+    id: string;
+    title: string;
+
+    query titled(name: string) {
+        where title = name
+    }
+}
+`)
+
+	for _, tt := range []struct {
+		name  string
+		gen   Generator
+		read  string
+		write []string
+	}{
+		{"java", NewJavaGenerator(), "public List<OpenTask> findAll()", []string{"upsert(", "delete("}},
+		{"python", NewPythonGenerator(), "def find_all(self)", []string{"def upsert(", "def delete("}},
+		{"swift", NewSwiftGenerator(), "public func findAll()", []string{"func upsert(", "func delete("}},
+		{"qt", NewQtGenerator(), "findAll(QObject *parent", []string{"upsert(", "remove("}},
+		{"mongodb", NewMongoDBGenerator(), "def find_all(self)", []string{"def upsert(", "def delete("}},
+	} {
+		out, err := tt.gen.Generate(file)
+		if err != nil {
+			t.Fatalf("%s: Generate error: %v", tt.name, err)
+		}
+		var all strings.Builder
+		for _, content := range out {
+			all.WriteString(content)
+		}
+		code := all.String()
+		if !strings.Contains(code, tt.read) {
+			t.Errorf("%s: expected %q, got:\n%s", tt.name, tt.read, code)
+		}
+		for _, write := range tt.write {
+			if strings.Contains(code, write) {
+				t.Errorf("%s: expected no %q for a view, got:\n%s", tt.name, write, code)
+			}
+		}
+	}
+}
+
+func TestOptionalQueryParams(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+entity Task {
+    @pk id: string;
+    priority: int32;
+
+    query atLeast(minPriority?: int32) {
+        where priority >= minPriority
+    }
+}
+`)
+
+	for _, tt := range []struct {
+		name string
+		gen  Generator
+		want []string
+	}{
+		{"java", NewJavaGenerator(), []string{"public List<Task> atLeast(Integer minPriority)"}},
+		{"python", NewPythonGenerator(), []string{"def at_least(self, min_priority: Optional[int])"}},
+		{"swift", NewSwiftGenerator(), []string{
+			"public func atLeast(minPriority: Int32?)",
+			"if let v = minPriority { sqlite3_bind_int(stmt, 1, v) } else { sqlite3_bind_null(stmt, 1) }",
+		}},
+		{"qt", NewQtGenerator(), []string{
+			"atLeast(std::optional<int> minPriority, QObject *parent = nullptr);",
+			"query.addBindValue(minPriority ? QVariant(*minPriority) : QVariant());",
+		}},
+	} {
+		out, err := tt.gen.Generate(file)
+		if err != nil {
+			t.Fatalf("%s: Generate error: %v", tt.name, err)
+		}
+		var all strings.Builder
+		for _, content := range out {
+			all.WriteString(content)
+		}
+		code := all.String()
+		for _, want := range tt.want {
+			if !strings.Contains(code, want) {
+				t.Errorf("%s: expected %q, got:\n%s", tt.name, want, code)
+			}
+		}
+	}
+}
+
+func TestReturnsOneQueries(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+entity Task {
+    @pk id: string;
+    slug: string;
+    title: string;
+
+    @returns(one)
+    query bySlug(s: string) {
+        where slug = s
+        order_by title DESC
+    }
+
+    @returns(one)
+    query titleOf(s: string) {
+        select id, title
+        where slug = s
+    }
+}
+`)
+
+	for _, tt := range []struct {
+		name string
+		gen  Generator
+		want []string
+	}{
+		{"swift", NewSwiftGenerator(), []string{
+			"public func bySlug(s: String) throws -> Task? {",
+			"guard sqlite3_step(stmt) == SQLITE_ROW else { return nil }\n        return mapRow(stmt)\n",
+			"public func titleOf(s: String) throws -> TaskTitleOfRow? {",
+		}},
+		{"qt", NewQtGenerator(), []string{
+			"Task* bySlug(QString s, QObject *parent = nullptr);",
+			"if (!query.next()) {\n        return nullptr;\n    }\n    return mapRow(query, parent);\n",
+			"std::optional<TaskTitleOfRow> titleOf(QString s);",
+			"return std::nullopt;",
+		}},
+		{"mongodb", NewMongoDBGenerator(), []string{
+			"def by_slug(self, s: str) -> Optional[Task]:",
+			"doc = self.collection.find_one(query_filter, sort=[('title', -1)])\n" +
+				"        if doc is None:\n            return None\n",
+		}},
+	} {
+		out, err := tt.gen.Generate(file)
+		if err != nil {
+			t.Fatalf("%s: Generate error: %v", tt.name, err)
+		}
+		var all strings.Builder
+		for _, content := range out {
+			all.WriteString(content)
+		}
+		code := all.String()
+		for _, want := range tt.want {
+			if !strings.Contains(code, want) {
+				t.Errorf("%s: expected %q, got:\n%s", tt.name, want, code)
+			}
+		}
+	}
+}
+
+func TestAbstractEntitiesGenerateNothing(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+@abstract
+entity Audited {
+    createdAt: timestamp;
+    updatedAt: timestamp;
+}
+
+entity Note extends Audited {
+    @pk id: string;
+    body: string;
+}
+`)
+
+	for _, name := range []string{
+		"proto", "sqlite", "postgres", "java", "kotlin", "swift", "python", "qt",
+		"mongodb", "rust", "go", "jsonschema", "graphql", "mermaid", "openapi",
+	} {
+		g, _ := Get(name)
+		out, err := g.Generate(file)
+		if err != nil {
+			t.Fatalf("%s: Generate error: %v", name, err)
+		}
+		var all strings.Builder
+		for filename, content := range out {
+			all.WriteString(filename + "\n" + content)
+		}
+		code := all.String()
+		if strings.Contains(code, "Audited") || strings.Contains(code, "audited") {
+			t.Errorf("%s: expected no output for the abstract entity, got:\n%s", name, code)
+		}
+		if !strings.Contains(strings.ToLower(code), "updated") {
+			t.Errorf("%s: expected Note to inherit updatedAt, got:\n%s", name, code)
+		}
+	}
+}
+
+func TestDefaultExprQueryParams(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+entity Event {
+    @pk id: string;
+    startDate: timestamp;
+
+    query since(after: timestamp = NOW()) {
+        where startDate >= after
+    }
+}
+`)
+
+	for _, tt := range []struct {
+		name string
+		gen  Generator
+		want []string
+	}{
+		{"python", NewPythonGenerator(), []string{"def since(self, after: Optional[int] = None)"}},
+		{"swift", NewSwiftGenerator(), []string{
+			"public func since(after: Int64? = nil)",
+			"if let v = after { sqlite3_bind_int64(stmt, 1, v) } else { sqlite3_bind_null(stmt, 1) }",
+		}},
+		{"qt", NewQtGenerator(), []string{
+			"since(std::optional<qint64> after = std::nullopt, QObject *parent = nullptr);",
+			"since(std::optional<qint64> after, QObject *parent)",
+			"query.addBindValue(after ? QVariant(*after) : QVariant());",
+		}},
+	} {
+		out, err := tt.gen.Generate(file)
+		if err != nil {
+			t.Fatalf("%s: Generate error: %v", tt.name, err)
+		}
+		var all strings.Builder
+		for _, content := range out {
+			all.WriteString(content)
+		}
+		code := all.String()
+		for _, want := range tt.want {
+			if !strings.Contains(code, want) {
+				t.Errorf("%s: expected %q, got:\n%s", tt.name, want, code)
+			}
+		}
+	}
+}
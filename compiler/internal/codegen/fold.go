@@ -0,0 +1,203 @@
+package codegen
+
+import (
+	"math"
+
+	"github.com/aurora/dataproto/internal/parser"
+)
+
+// FoldConstants returns an equivalent expression in which every sub-tree made
+// only of numeric literals and the arithmetic operators (+, -, *, /, %, unary
+// -) is replaced by its value. Integer operands stay integers; mixing in a
+// float yields a float. Division or modulo by zero, and integer arithmetic
+// that would overflow int64, is left unfolded so the database reports it. The
+// input tree is not modified.
+func FoldConstants(expr parser.Expr) parser.Expr {
+	switch e := expr.(type) {
+	case *parser.BinaryExpr:
+		left := FoldConstants(e.Left)
+		right := FoldConstants(e.Right)
+		if lit := foldBinary(e, left, right); lit != nil {
+			return lit
+		}
+		if left == e.Left && right == e.Right {
+			return e
+		}
+		return &parser.BinaryExpr{Position: e.Position, Left: left, Op: e.Op, Right: right}
+
+	case *parser.UnaryExpr:
+		operand := FoldConstants(e.Operand)
+		if e.Op == "-" {
+			switch v := numericLiteral(operand).(type) {
+			case int64:
+				if v != math.MinInt64 {
+					return &parser.LiteralExpr{Position: e.Position, Value: -v}
+				}
+			case float64:
+				return &parser.LiteralExpr{Position: e.Position, Value: -v}
+			}
+		}
+		if operand == e.Operand {
+			return e
+		}
+		return &parser.UnaryExpr{Position: e.Position, Op: e.Op, Operand: operand}
+
+	case *parser.ParenExpr:
+		inner := FoldConstants(e.Inner)
+		if numericLiteral(inner) != nil {
+			return inner
+		}
+		if inner == e.Inner {
+			return e
+		}
+		return &parser.ParenExpr{Position: e.Position, Inner: inner}
+
+	case *parser.TupleExpr:
+		if elems, changed := mapExprs(e.Elements, FoldConstants); changed {
+			return &parser.TupleExpr{Position: e.Position, Elements: elems}
+		}
+		return e
+
+	case *parser.IsNullExpr:
+		operand := FoldConstants(e.Operand)
+		if operand == e.Operand {
+			return e
+		}
+		return &parser.IsNullExpr{Position: e.Position, Operand: operand, Not: e.Not}
+
+	case *parser.CallExpr:
+		var args []parser.Expr
+		changed := false
+		for _, arg := range e.Args {
+			folded := FoldConstants(arg)
+			changed = changed || folded != arg
+			args = append(args, folded)
+		}
+		if !changed {
+			return e
+		}
+		return &parser.CallExpr{Position: e.Position, Name: e.Name, Args: args}
+
+	case *parser.CaseExpr:
+		folded := &parser.CaseExpr{Position: e.Position}
+		changed := false
+		for _, when := range e.Whens {
+			cond := FoldConstants(when.Cond)
+			result := FoldConstants(when.Result)
+			changed = changed || cond != when.Cond || result != when.Result
+			folded.Whens = append(folded.Whens, &parser.CaseWhen{Position: when.Position, Cond: cond, Result: result})
+		}
+		if e.Else != nil {
+			folded.Else = FoldConstants(e.Else)
+			changed = changed || folded.Else != e.Else
+		}
+		if !changed {
+			return e
+		}
+		return folded
+
+	default:
+		return expr
+	}
+}
+
+// mapExprs applies fn to each of exprs, reporting whether any changed.
+func mapExprs(exprs []parser.Expr, fn func(parser.Expr) parser.Expr) ([]parser.Expr, bool) {
+	out := make([]parser.Expr, 0, len(exprs))
+	changed := false
+	for _, expr := range exprs {
+		mapped := fn(expr)
+		changed = changed || mapped != expr
+		out = append(out, mapped)
+	}
+	return out, changed
+}
+
+// foldBinary evaluates an arithmetic operation over two numeric literals,
+// returning nil when the operation cannot be folded.
+func foldBinary(e *parser.BinaryExpr, left, right parser.Expr) *parser.LiteralExpr {
+	l := numericLiteral(left)
+	r := numericLiteral(right)
+	if l == nil || r == nil {
+		return nil
+	}
+
+	li, lInt := l.(int64)
+	ri, rInt := r.(int64)
+	if lInt && rInt {
+		var v int64
+		switch e.Op {
+		case "+":
+			v = li + ri
+			if (ri > 0 && v < li) || (ri < 0 && v > li) {
+				return nil
+			}
+		case "-":
+			v = li - ri
+			if (ri < 0 && v < li) || (ri > 0 && v > li) {
+				return nil
+			}
+		case "*":
+			v = li * ri
+			if li != 0 && (v/li != ri || (li == -1 && ri == math.MinInt64)) {
+				return nil
+			}
+		case "/":
+			if ri == 0 || (li == math.MinInt64 && ri == -1) {
+				return nil
+			}
+			v = li / ri
+		case "%":
+			if ri == 0 {
+				return nil
+			}
+			v = li % ri
+		default:
+			return nil
+		}
+		return &parser.LiteralExpr{Position: e.Position, Value: v}
+	}
+
+	lf, rf := toFloat(l), toFloat(r)
+	var v float64
+	switch e.Op {
+	case "+":
+		v = lf + rf
+	case "-":
+		v = lf - rf
+	case "*":
+		v = lf * rf
+	case "/":
+		if rf == 0 {
+			return nil
+		}
+		v = lf / rf
+	default:
+		// % is only defined for integers
+		return nil
+	}
+	if math.IsInf(v, 0) || math.IsNaN(v) {
+		return nil
+	}
+	return &parser.LiteralExpr{Position: e.Position, Value: v}
+}
+
+// numericLiteral returns the int64 or float64 value of a literal, or nil.
+func numericLiteral(expr parser.Expr) interface{} {
+	lit, ok := expr.(*parser.LiteralExpr)
+	if !ok {
+		return nil
+	}
+	switch v := lit.Value.(type) {
+	case int64, float64:
+		return v
+	}
+	return nil
+}
+
+func toFloat(v interface{}) float64 {
+	if i, ok := v.(int64); ok {
+		return float64(i)
+	}
+	return v.(float64)
+}
@@ -0,0 +1,32 @@
+package codegen
+
+import "testing"
+
+func TestFoldConstants(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"integer addition", "priority > 10 + 5", "priority > 15"},
+		{"negative multiplication", "priority > -3 * 2", "priority > -6"},
+		{"mixed passes through", "priority + 1 > 3", "priority + 1 > 3"},
+		{"nested parentheses", "priority > (2 + 3) * 4", "priority > 20"},
+		{"float promotion", "priority > 1 + 0.5", "priority > 1.5"},
+		{"division by zero", "priority > 1 / 0", "priority > 1 / 0"},
+		{"addition overflow", "priority > 9223372036854775807 + 1", "priority > 9223372036854775807 + 1"},
+		{"multiplication overflow", "priority > 4611686018427387904 * 2", "priority > 4611686018427387904 * 2"},
+		{"float literal", "priority > 0.1", "priority > 0.1"},
+		{"integral float", "priority > 1.5 * 2", "priority > 3.0"},
+		{"call arguments", "priority > COALESCE(priority, 2 * 3)", "priority > COALESCE(priority, 6)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ExprToSQL(FoldConstants(parseWhere(t, tt.input)))
+			if got != tt.want {
+				t.Errorf("FoldConstants(%s) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
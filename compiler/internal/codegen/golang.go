@@ -0,0 +1,346 @@
+package codegen
+
+import (
+	"fmt"
+	"go/format"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aurora/dataproto/internal/naming"
+	"github.com/aurora/dataproto/internal/parser"
+)
+
+// GoGenerator generates Go structs from DataProto schemas: one with a
+// Validate method for each entity, and a plain one for each message.
+type GoGenerator struct{}
+
+// NewGoGenerator creates a new GoGenerator.
+func NewGoGenerator() *GoGenerator {
+	return &GoGenerator{}
+}
+
+// goInitialisms are words written in upper case in Go identifiers.
+var goInitialisms = map[string]bool{
+	"API": true, "HTML": true, "HTTP": true, "ID": true, "JSON": true,
+	"SQL": true, "URI": true, "URL": true, "UUID": true,
+}
+
+// goFormatPatterns are the regular expressions backing @format names.
+var goFormatPatterns = map[string]string{
+	"email": `^[^@\s]+@[^@\s]+\.[^@\s]+$`,
+	"uri":   `^[A-Za-z][A-Za-z0-9+.-]*:[^\s]+$`,
+	"uuid":  `^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`,
+}
+
+// goFile collects the package-level declarations a generated file needs.
+type goFile struct {
+	imports  map[string]bool
+	patterns []string // var declarations for compiled regexps
+	formats  map[string]bool
+}
+
+// Generate generates a single Go source file from a DataProto file.
+func (g *GoGenerator) Generate(file *parser.File) (map[string]string, error) {
+	return g.GenerateWith(file, GenOptions{})
+}
+
+// GenerateWith is Generate with output options. The default indent is
+// gofmt's tab; any other Indent replaces it after formatting.
+func (g *GoGenerator) GenerateWith(file *parser.File, opts GenOptions) (map[string]string, error) {
+	file = concreteFile(file)
+	result := make(map[string]string)
+	out := &goFile{imports: make(map[string]bool), formats: make(map[string]bool)}
+
+	var body strings.Builder
+	for _, enum := range file.Enums {
+		body.WriteString("\n")
+		body.WriteString(g.generateEnum(enum))
+	}
+	for _, entity := range file.Entities {
+		body.WriteString("\n")
+		body.WriteString(g.generateStruct(entity))
+		body.WriteString("\n")
+		body.WriteString(g.generateValidate(out, entity))
+	}
+	for _, msg := range file.Messages {
+		body.WriteString("\n")
+		body.WriteString(g.generateMessageStruct(msg))
+	}
+
+	pkgName := goPackageName(file, opts)
+
+	var sb strings.Builder
+
+	// Header
+	sb.WriteString(opts.header("//"))
+	sb.WriteString("// Code generated by dataprotoc. DO NOT EDIT.\n")
+	sb.WriteString("// source: ")
+	if file.Package != nil {
+		sb.WriteString(file.Package.Name)
+	}
+	sb.WriteString(".dataproto\n\n")
+	sb.WriteString(fmt.Sprintf("package %s\n", pkgName))
+
+	if len(out.imports) > 0 {
+		var imports []string
+		for imp := range out.imports {
+			imports = append(imports, imp)
+		}
+		sort.Strings(imports)
+		sb.WriteString("\nimport (\n")
+		for _, imp := range imports {
+			sb.WriteString(fmt.Sprintf("\t%q\n", imp))
+		}
+		sb.WriteString(")\n")
+	}
+
+	if len(out.formats) > 0 || len(out.patterns) > 0 {
+		sb.WriteString("\nvar (\n")
+		var formats []string
+		for name := range out.formats {
+			formats = append(formats, name)
+		}
+		sort.Strings(formats)
+		for _, name := range formats {
+			sb.WriteString(fmt.Sprintf("\t%sFormat = regexp.MustCompile(%s)\n", name, goStringLiteral(goFormatPatterns[name])))
+		}
+		for _, decl := range out.patterns {
+			sb.WriteString("\t" + decl + "\n")
+		}
+		sb.WriteString(")\n")
+	}
+
+	sb.WriteString(body.String())
+
+	// gofmt aligns struct fields and tags
+	src, err := format.Source([]byte(sb.String()))
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated Go: %w", err)
+	}
+
+	result[pkgName+".go"] = opts.indent(string(src), "\t")
+	return result, nil
+}
+
+// goPackageName returns the Go package generated code is written to: the
+// last element of the schema's package, or models without one.
+func goPackageName(file *parser.File, opts GenOptions) string {
+	packageName := opts.packageName(file)
+	if packageName == "" {
+		return "models"
+	}
+	parts := strings.Split(packageName, ".")
+	return strings.ToLower(parts[len(parts)-1])
+}
+
+func (g *GoGenerator) generateEnum(enum *parser.EnumDecl) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("type %s int32\n\n", enum.Name))
+	sb.WriteString("const (\n")
+	for _, val := range enum.Values {
+		if msg, ok := val.Deprecated(); ok {
+			sb.WriteString("\t" + goDeprecated(msg))
+		}
+		sb.WriteString(fmt.Sprintf("\t%s%s %s = %d\n", enum.Name, ToPascalCase(val.Name), enum.Name, val.Number))
+	}
+	sb.WriteString(")\n")
+	return sb.String()
+}
+
+func (g *GoGenerator) generateStruct(entity *parser.EntityDecl) string {
+	var sb strings.Builder
+
+	if msg, ok := entity.Deprecated(); ok {
+		sb.WriteString(goDeprecated(msg))
+	}
+	sb.WriteString(fmt.Sprintf("type %s struct {\n", entity.Name))
+	for _, field := range entity.Fields {
+		if msg, ok := field.Deprecated(); ok {
+			sb.WriteString("\t" + goDeprecated(msg))
+		}
+		sb.WriteString(fmt.Sprintf("\t%s %s `json:\"%s\"`\n",
+			goName(field.Name), g.goType(field.Type), JSONName(field)))
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// generateMessageStruct emits the struct for a message, such as an rpc's
+// request or response. Messages are not stored, so they have no Validate.
+func (g *GoGenerator) generateMessageStruct(msg *parser.MessageDecl) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("type %s struct {\n", msg.Name))
+	for _, field := range msg.Fields {
+		if dep, ok := field.Deprecated(); ok {
+			sb.WriteString("\t" + goDeprecated(dep))
+		}
+		sb.WriteString(fmt.Sprintf("\t%s %s `json:\"%s\"`\n",
+			goName(field.Name), g.goType(field.Type), JSONName(field)))
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// generateValidate emits a Validate method checking the field constraints
+// declared with @required, @length, @range, @min, @max, @pattern and @format.
+// All violations are reported together via errors.Join.
+func (g *GoGenerator) generateValidate(out *goFile, entity *parser.EntityDecl) string {
+	var checks strings.Builder
+	for _, field := range entity.Fields {
+		checks.WriteString(g.fieldChecks(out, entity, field))
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("// Validate checks the %s field constraints declared in the schema.\n", entity.Name))
+	sb.WriteString(fmt.Sprintf("func (m *%s) Validate() error {\n", entity.Name))
+	if checks.Len() == 0 {
+		sb.WriteString("\treturn nil\n}\n")
+		return sb.String()
+	}
+	out.imports["errors"] = true
+	sb.WriteString("\tvar errs []error\n")
+	sb.WriteString(checks.String())
+	sb.WriteString("\treturn errors.Join(errs...)\n}\n")
+	return sb.String()
+}
+
+func (g *GoGenerator) fieldChecks(out *goFile, entity *parser.EntityDecl, field *parser.FieldDecl) string {
+	name := ToSnakeCase(field.Name)
+	ref := "m." + goName(field.Name)
+	optional := field.Type.Optional && field.Type.Name != "bytes"
+
+	var sb strings.Builder
+
+	// @required: optional fields must be set, strings and bytes non-empty
+	if field.IsRequired() {
+		switch {
+		case optional:
+			sb.WriteString(goCheck("\t", ref+" == nil", name+" is required"))
+		case field.Type.Name == "string":
+			sb.WriteString(goCheck("\t", ref+` == ""`, name+" is required"))
+		case field.Type.Name == "bytes":
+			sb.WriteString(goCheck("\t", "len("+ref+") == 0", name+" is required"))
+		}
+	}
+
+	// Value checks apply to optional fields only when they are set
+	value := ref
+	indent := "\t"
+	if optional {
+		value = "*" + ref
+		indent = "\t\t"
+	}
+
+	var vs strings.Builder
+	if field.Type.Name == "string" {
+		if lc, _ := field.LengthConstraint(); lc.Min != nil || lc.Max != nil {
+			out.imports["unicode/utf8"] = true
+			count := "utf8.RuneCountInString(" + value + ")"
+			if lc.Min != nil {
+				vs.WriteString(goCheck(indent, fmt.Sprintf("%s < %d", count, *lc.Min),
+					fmt.Sprintf("%s must be at least %d characters", name, *lc.Min)))
+			}
+			if lc.Max != nil {
+				vs.WriteString(goCheck(indent, fmt.Sprintf("%s > %d", count, *lc.Max),
+					fmt.Sprintf("%s must be at most %d characters", name, *lc.Max)))
+			}
+		}
+		if pattern := field.Pattern(); pattern != "" {
+			out.imports["regexp"] = true
+			varName := ToCamelCase(entity.Name) + goName(field.Name) + "Pattern"
+			out.patterns = append(out.patterns,
+				fmt.Sprintf("%s = regexp.MustCompile(%s)", varName, goStringLiteral(pattern)))
+			vs.WriteString(goCheck(indent, "!"+varName+".MatchString("+value+")",
+				name+" must match "+pattern))
+		}
+		if format := field.Format(); goFormatPatterns[format] != "" {
+			out.imports["regexp"] = true
+			out.formats[format] = true
+			vs.WriteString(goCheck(indent, "!"+format+"Format.MatchString("+value+")",
+				name+" must be a valid "+format))
+		}
+	}
+
+	if min, max := field.NumericBounds(); min != nil || max != nil {
+		integer := strings.HasPrefix(field.Type.Name, "int")
+		if min != nil {
+			vs.WriteString(goCheck(indent, goCompare(value, "<", *min, integer),
+				fmt.Sprintf("%s must be at least %s", name, formatBound(*min))))
+		}
+		if max != nil {
+			vs.WriteString(goCheck(indent, goCompare(value, ">", *max, integer),
+				fmt.Sprintf("%s must be at most %s", name, formatBound(*max))))
+		}
+	}
+
+	if vs.Len() > 0 {
+		if optional {
+			sb.WriteString(fmt.Sprintf("\tif %s != nil {\n", ref))
+			sb.WriteString(vs.String())
+			sb.WriteString("\t}\n")
+		} else {
+			sb.WriteString(vs.String())
+		}
+	}
+
+	return sb.String()
+}
+
+// goCheck emits an if statement appending msg to errs when cond holds.
+func goCheck(indent, cond, msg string) string {
+	return fmt.Sprintf("%sif %s {\n%s\terrs = append(errs, errors.New(%s))\n%s}\n",
+		indent, cond, indent, strconv.Quote(msg), indent)
+}
+
+// goCompare compares value against a numeric bound. Integer fields compared
+// with a fractional bound are converted to float64 so the code compiles.
+func goCompare(value, op string, bound float64, integer bool) string {
+	if integer && bound != math.Trunc(bound) {
+		return fmt.Sprintf("float64(%s) %s %s", value, op, formatBound(bound))
+	}
+	return fmt.Sprintf("%s %s %s", value, op, formatBound(bound))
+}
+
+// goStringLiteral quotes s as a raw string when possible, which keeps
+// regular expressions readable.
+func goStringLiteral(s string) string {
+	if strings.Contains(s, "`") {
+		return strconv.Quote(s)
+	}
+	return "`" + s + "`"
+}
+
+// goName converts a field name to an exported Go identifier, upper-casing
+// common initialisms such as ID and URL.
+func goName(s string) string {
+	var sb strings.Builder
+	for _, word := range naming.SplitWords(s) {
+		if goInitialisms[strings.ToUpper(word)] {
+			sb.WriteString(strings.ToUpper(word))
+			continue
+		}
+		sb.WriteString(strings.ToUpper(word[:1]) + strings.ToLower(word[1:]))
+	}
+	return sb.String()
+}
+
+func (g *GoGenerator) goType(typeRef *parser.TypeRef) string {
+	baseType := GetTypeMapping(typeRef.Name).Go
+	// A nil slice already represents an absent bytes value
+	if typeRef.Optional && typeRef.Name != "bytes" {
+		return "*" + baseType
+	}
+	return baseType
+}
+
+// goDeprecated renders the "Deprecated:" comment go vet and editors look for.
+func goDeprecated(msg string) string {
+	if msg == "" {
+		msg = "do not use."
+	}
+	return "// Deprecated: " + strings.Join(strings.Fields(msg), " ") + "\n"
+}
@@ -0,0 +1,297 @@
+package codegen
+
+import (
+	"fmt"
+	"go/format"
+	"strings"
+
+	"github.com/aurora/dataproto/internal/parser"
+)
+
+// GoGRPCGenerator generates Go servers for services, in the shape
+// protoc-gen-go-grpc gives them: a <Service>Server interface with one method
+// per rpc, a <Service>_<Method>Server stream interface for each streaming
+// rpc, an Unimplemented<Service>Server to embed for forward compatibility,
+// and the <Service>_ServiceDesc that Register<Service>Server registers with
+// a grpc.Server. Request and response types are the structs the Go
+// generator emits into the same package, so they must be entities or
+// messages declared in the schema.
+type GoGRPCGenerator struct{}
+
+// NewGoGRPCGenerator creates a new GoGRPCGenerator.
+func NewGoGRPCGenerator() *GoGRPCGenerator {
+	return &GoGRPCGenerator{}
+}
+
+// Generate generates a <package>_grpc.go file, or nothing for a schema
+// without services.
+func (g *GoGRPCGenerator) Generate(file *parser.File) (map[string]string, error) {
+	return g.GenerateWith(file, GenOptions{})
+}
+
+// GenerateWith is Generate with output options. The default indent is
+// gofmt's tab; any other Indent replaces it after formatting.
+func (g *GoGRPCGenerator) GenerateWith(file *parser.File, opts GenOptions) (map[string]string, error) {
+	file = concreteFile(file)
+	result := make(map[string]string)
+	if len(file.Services) == 0 {
+		return result, nil
+	}
+	pkgName := goPackageName(file, opts)
+
+	// Only import what the servers use: context for unary rpcs, and codes
+	// and status for the Unimplemented stubs
+	var unary, methods bool
+	for _, svc := range file.Services {
+		for _, method := range svc.Methods {
+			for _, t := range []*parser.RpcType{method.RequestType, method.ResponseType} {
+				if file.Entity(t.Name) == nil && file.Message(t.Name) == nil {
+					return nil, fmt.Errorf("rpc %s.%s: %s is not an entity or message declared in the schema",
+						svc.Name, method.Name, t.Name)
+				}
+			}
+			methods = true
+			if method.StreamingKind() == parser.Unary {
+				unary = true
+			}
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString(opts.header("//"))
+	sb.WriteString("// Code generated by dataprotoc. DO NOT EDIT.\n")
+	sb.WriteString("// source: ")
+	if file.Package != nil {
+		sb.WriteString(file.Package.Name)
+	}
+	sb.WriteString(".dataproto\n\n")
+	sb.WriteString(fmt.Sprintf("package %s\n\n", pkgName))
+	sb.WriteString("import (\n")
+	if unary {
+		sb.WriteString("\t\"context\"\n\n")
+	}
+	sb.WriteString("\t\"google.golang.org/grpc\"\n")
+	if methods {
+		sb.WriteString("\t\"google.golang.org/grpc/codes\"\n")
+		sb.WriteString("\t\"google.golang.org/grpc/status\"\n")
+	}
+	sb.WriteString(")\n")
+
+	protoPackage := opts.packageName(file)
+	for _, svc := range file.Services {
+		sb.WriteString("\n")
+		sb.WriteString(g.generateServer(svc))
+		sb.WriteString(g.generateServiceDesc(svc, protoPackage))
+	}
+
+	src, err := format.Source([]byte(sb.String()))
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated Go: %w", err)
+	}
+	result[pkgName+"_grpc.go"] = opts.indent(string(src), "\t")
+	return result, nil
+}
+
+// generateServer emits a service's server interface, its stream interfaces
+// and the Unimplemented implementation.
+func (g *GoGRPCGenerator) generateServer(svc *parser.ServiceDecl) string {
+	server := svc.Name + "Server"
+	unimplemented := "Unimplemented" + server
+
+	var iface, streams, stubs strings.Builder
+	for _, method := range svc.Methods {
+		if msg, ok := method.Deprecated(); ok {
+			iface.WriteString("\t" + goDeprecated(msg))
+		}
+		sig := g.methodSignature(svc, method)
+		iface.WriteString("\t" + sig + "\n")
+
+		result := ""
+		if method.StreamingKind() == parser.Unary {
+			result = "nil, "
+		}
+		stubs.WriteString(fmt.Sprintf("\nfunc (%s) %s {\n", unimplemented, sig))
+		stubs.WriteString(fmt.Sprintf("\treturn %sstatus.Errorf(codes.Unimplemented, \"method %s not implemented\")\n}\n",
+			result, method.Name))
+
+		if method.StreamingKind() != parser.Unary {
+			streams.WriteString("\n")
+			streams.WriteString(g.generateStream(svc, method))
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("// %s is the server API for the %s service.\n", server, svc.Name))
+	sb.WriteString(fmt.Sprintf("// Implementations should embed %s.\n", unimplemented))
+	sb.WriteString(fmt.Sprintf("type %s interface {\n", server))
+	sb.WriteString(iface.String())
+	sb.WriteString("}\n")
+	sb.WriteString(streams.String())
+	sb.WriteString(fmt.Sprintf("\n// %s returns codes.Unimplemented from every method.\n", unimplemented))
+	sb.WriteString(fmt.Sprintf("type %s struct{}\n", unimplemented))
+	sb.WriteString(stubs.String())
+	return sb.String()
+}
+
+// methodSignature returns a server method's signature for the rpc's
+// streaming kind. Unary rpcs take a context and return the response; the
+// others exchange messages through their stream, which carries the context.
+func (g *GoGRPCGenerator) methodSignature(svc *parser.ServiceDecl, method *parser.RpcDecl) string {
+	req, resp := goRPCType(method.RequestType), goRPCType(method.ResponseType)
+	stream := goStreamName(svc, method)
+	name := goMethodName(method)
+	switch method.StreamingKind() {
+	case parser.ServerStreaming:
+		return fmt.Sprintf("%s(req %s, stream %s) error", name, req, stream)
+	case parser.ClientStreaming, parser.BidiStreaming:
+		return fmt.Sprintf("%s(stream %s) error", name, stream)
+	default:
+		return fmt.Sprintf("%s(ctx context.Context, req %s) (%s, error)", name, req, resp)
+	}
+}
+
+// generateStream emits the stream interface a streaming rpc's handler is
+// given: Send for streamed responses, Recv for streamed requests, and
+// SendAndClose for the single response of a client-streaming rpc.
+func (g *GoGRPCGenerator) generateStream(svc *parser.ServiceDecl, method *parser.RpcDecl) string {
+	req, resp := goRPCType(method.RequestType), goRPCType(method.ResponseType)
+	name := goStreamName(svc, method)
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("// %s is the server side of the %s %s stream.\n", name, method.StreamingKind(), method.Name))
+	sb.WriteString(fmt.Sprintf("type %s interface {\n", name))
+	switch method.StreamingKind() {
+	case parser.ServerStreaming:
+		sb.WriteString(fmt.Sprintf("\tSend(%s) error\n", resp))
+	case parser.ClientStreaming:
+		sb.WriteString(fmt.Sprintf("\tSendAndClose(%s) error\n", resp))
+		sb.WriteString(fmt.Sprintf("\tRecv() (%s, error)\n", req))
+	case parser.BidiStreaming:
+		sb.WriteString(fmt.Sprintf("\tSend(%s) error\n", resp))
+		sb.WriteString(fmt.Sprintf("\tRecv() (%s, error)\n", req))
+	}
+	sb.WriteString("\tgrpc.ServerStream\n")
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// generateServiceDesc emits Register<Service>Server, the handler gRPC calls
+// for each rpc, the implementation of each stream interface, and the
+// grpc.ServiceDesc tying them together. Handlers decode into the request
+// type and call the server; methods are registered under the names the
+// proto generator declares them with.
+func (g *GoGRPCGenerator) generateServiceDesc(svc *parser.ServiceDecl, protoPackage string) string {
+	server := svc.Name + "Server"
+	serviceName := svc.Name
+	if protoPackage != "" {
+		serviceName = protoPackage + "." + svc.Name
+	}
+
+	var sb, methods, streams strings.Builder
+	sb.WriteString(fmt.Sprintf("\n// Register%s registers srv with s to serve the %s service.\n", server, svc.Name))
+	sb.WriteString(fmt.Sprintf("func Register%s(s grpc.ServiceRegistrar, srv %s) {\n", server, server))
+	sb.WriteString(fmt.Sprintf("\ts.RegisterService(&%s_ServiceDesc, srv)\n", svc.Name))
+	sb.WriteString("}\n")
+
+	for _, method := range svc.Methods {
+		req := method.RequestType.Name
+		name := goMethodName(method)
+		handler := fmt.Sprintf("_%s_%s_Handler", svc.Name, name)
+		impl := ToCamelCase(svc.Name) + name + "Server"
+		kind := method.StreamingKind()
+
+		if kind == parser.Unary {
+			sb.WriteString(fmt.Sprintf("\nfunc %s(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {\n", handler))
+			sb.WriteString(fmt.Sprintf("\tin := new(%s)\n", req))
+			sb.WriteString("\tif err := dec(in); err != nil {\n\t\treturn nil, err\n\t}\n")
+			sb.WriteString("\tif interceptor == nil {\n")
+			sb.WriteString(fmt.Sprintf("\t\treturn srv.(%s).%s(ctx, in)\n", server, name))
+			sb.WriteString("\t}\n")
+			sb.WriteString("\tinfo := &grpc.UnaryServerInfo{\n")
+			sb.WriteString("\t\tServer:     srv,\n")
+			sb.WriteString(fmt.Sprintf("\t\tFullMethod: \"/%s/%s\",\n", serviceName, method.Name))
+			sb.WriteString("\t}\n")
+			sb.WriteString("\thandler := func(ctx context.Context, req interface{}) (interface{}, error) {\n")
+			sb.WriteString(fmt.Sprintf("\t\treturn srv.(%s).%s(ctx, req.(*%s))\n", server, name, req))
+			sb.WriteString("\t}\n")
+			sb.WriteString("\treturn interceptor(ctx, in, info, handler)\n")
+			sb.WriteString("}\n")
+			methods.WriteString(fmt.Sprintf("\t\t{\n\t\t\tMethodName: \"%s\",\n\t\t\tHandler:    %s,\n\t\t},\n", method.Name, handler))
+			continue
+		}
+
+		sb.WriteString(fmt.Sprintf("\nfunc %s(srv interface{}, stream grpc.ServerStream) error {\n", handler))
+		if kind == parser.ServerStreaming {
+			sb.WriteString(fmt.Sprintf("\tm := new(%s)\n", req))
+			sb.WriteString("\tif err := stream.RecvMsg(m); err != nil {\n\t\treturn err\n\t}\n")
+			sb.WriteString(fmt.Sprintf("\treturn srv.(%s).%s(m, &%s{stream})\n", server, name, impl))
+		} else {
+			sb.WriteString(fmt.Sprintf("\treturn srv.(%s).%s(&%s{stream})\n", server, name, impl))
+		}
+		sb.WriteString("}\n")
+		sb.WriteString(g.generateStreamImpl(method, impl))
+
+		streams.WriteString(fmt.Sprintf("\t\t{\n\t\t\tStreamName:    \"%s\",\n\t\t\tHandler:       %s,\n", method.Name, handler))
+		if kind == parser.ServerStreaming || kind == parser.BidiStreaming {
+			streams.WriteString("\t\t\tServerStreams: true,\n")
+		}
+		if kind == parser.ClientStreaming || kind == parser.BidiStreaming {
+			streams.WriteString("\t\t\tClientStreams: true,\n")
+		}
+		streams.WriteString("\t\t},\n")
+	}
+
+	sb.WriteString(fmt.Sprintf("\n// %s_ServiceDesc is the grpc.ServiceDesc for the %s service.\n", svc.Name, svc.Name))
+	sb.WriteString(fmt.Sprintf("var %s_ServiceDesc = grpc.ServiceDesc{\n", svc.Name))
+	sb.WriteString(fmt.Sprintf("\tServiceName: \"%s\",\n", serviceName))
+	sb.WriteString(fmt.Sprintf("\tHandlerType: (*%s)(nil),\n", server))
+	sb.WriteString("\tMethods: []grpc.MethodDesc{\n")
+	sb.WriteString(methods.String())
+	sb.WriteString("\t},\n")
+	sb.WriteString("\tStreams: []grpc.StreamDesc{\n")
+	sb.WriteString(streams.String())
+	sb.WriteString("\t},\n")
+	sb.WriteString(fmt.Sprintf("\tMetadata: \"%s\",\n", protoFilename(protoPackage)))
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// generateStreamImpl emits impl, the implementation of a streaming rpc's
+// stream interface over the grpc.ServerStream it wraps.
+func (g *GoGRPCGenerator) generateStreamImpl(method *parser.RpcDecl, impl string) string {
+	req, resp := goRPCType(method.RequestType), goRPCType(method.ResponseType)
+	kind := method.StreamingKind()
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("\ntype %s struct {\n\tgrpc.ServerStream\n}\n", impl))
+	switch kind {
+	case parser.ServerStreaming, parser.BidiStreaming:
+		sb.WriteString(fmt.Sprintf("\nfunc (x *%s) Send(m %s) error {\n\treturn x.ServerStream.SendMsg(m)\n}\n", impl, resp))
+	case parser.ClientStreaming:
+		sb.WriteString(fmt.Sprintf("\nfunc (x *%s) SendAndClose(m %s) error {\n\treturn x.ServerStream.SendMsg(m)\n}\n", impl, resp))
+	}
+	if kind == parser.ClientStreaming || kind == parser.BidiStreaming {
+		sb.WriteString(fmt.Sprintf("\nfunc (x *%s) Recv() (%s, error) {\n", impl, req))
+		sb.WriteString(fmt.Sprintf("\tm := new(%s)\n", method.RequestType.Name))
+		sb.WriteString("\tif err := x.ServerStream.RecvMsg(m); err != nil {\n\t\treturn nil, err\n\t}\n")
+		sb.WriteString("\treturn m, nil\n")
+		sb.WriteString("}\n")
+	}
+	return sb.String()
+}
+
+// goMethodName returns the Go name of an rpc's server method.
+func goMethodName(method *parser.RpcDecl) string {
+	return ToPascalCase(method.Name)
+}
+
+// goStreamName names the stream interface of a streaming rpc.
+func goStreamName(svc *parser.ServiceDecl, method *parser.RpcDecl) string {
+	return svc.Name + "_" + goMethodName(method) + "Server"
+}
+
+// goRPCType returns the Go type of an rpc's request or response: a pointer
+// to the struct of that name.
+func goRPCType(t *parser.RpcType) string {
+	return "*" + t.Name
+}
@@ -0,0 +1,186 @@
+package codegen
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestGoGRPCServer(t *testing.T) {
+	file := mustParse(t, `
+package aurora.calendar;
+
+entity CalendarEvent {
+    @pk id: string;
+    title: string;
+}
+
+message GetEventsRequest {
+    calendar_name: string?;
+}
+
+message PushResult {
+    count: int32;
+}
+
+service CalendarService {
+    rpc GetEvents(GetEventsRequest) returns (stream CalendarEvent);
+    rpc SaveEvent(CalendarEvent) returns (CalendarEvent);
+    rpc PushEvents(stream CalendarEvent) returns (PushResult);
+    rpc SyncEvents(stream CalendarEvent) returns (stream CalendarEvent);
+}
+`)
+
+	out, err := NewGoGRPCGenerator().Generate(file)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	code, ok := out["calendar_grpc.go"]
+	if !ok {
+		t.Fatalf("Expected calendar_grpc.go, got %v", out)
+	}
+
+	for _, want := range []string{
+		"package calendar\n",
+		"type CalendarServiceServer interface {\n" +
+			"\tGetEvents(req *GetEventsRequest, stream CalendarService_GetEventsServer) error\n" +
+			"\tSaveEvent(ctx context.Context, req *CalendarEvent) (*CalendarEvent, error)\n" +
+			"\tPushEvents(stream CalendarService_PushEventsServer) error\n" +
+			"\tSyncEvents(stream CalendarService_SyncEventsServer) error\n" +
+			"}\n",
+		"type CalendarService_GetEventsServer interface {\n" +
+			"\tSend(*CalendarEvent) error\n" +
+			"\tgrpc.ServerStream\n" +
+			"}\n",
+		"type CalendarService_PushEventsServer interface {\n" +
+			"\tSendAndClose(*PushResult) error\n" +
+			"\tRecv() (*CalendarEvent, error)\n" +
+			"\tgrpc.ServerStream\n" +
+			"}\n",
+		"type CalendarService_SyncEventsServer interface {\n" +
+			"\tSend(*CalendarEvent) error\n" +
+			"\tRecv() (*CalendarEvent, error)\n" +
+			"\tgrpc.ServerStream\n" +
+			"}\n",
+		"func (UnimplementedCalendarServiceServer) SaveEvent(ctx context.Context, req *CalendarEvent) (*CalendarEvent, error) {\n" +
+			"\treturn nil, status.Errorf(codes.Unimplemented, \"method SaveEvent not implemented\")\n",
+		"func (UnimplementedCalendarServiceServer) GetEvents(req *GetEventsRequest, stream CalendarService_GetEventsServer) error {\n" +
+			"\treturn status.Errorf(codes.Unimplemented, \"method GetEvents not implemented\")\n",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("Expected %q, got:\n%s", want, code)
+		}
+	}
+}
+
+func TestGoGRPCServiceDesc(t *testing.T) {
+	file := mustParse(t, `
+package aurora.calendar;
+
+entity CalendarEvent {
+    @pk id: string;
+}
+
+service CalendarService {
+    rpc getEvents(CalendarEvent) returns (stream CalendarEvent);
+    rpc save_event(CalendarEvent) returns (CalendarEvent);
+    rpc PushEvents(stream CalendarEvent) returns (CalendarEvent);
+}
+`)
+
+	out, err := NewGoGRPCGenerator().Generate(file)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	code := out["calendar_grpc.go"]
+
+	for _, want := range []string{
+		"\tGetEvents(req *CalendarEvent, stream CalendarService_GetEventsServer) error\n",
+		"\tSaveEvent(ctx context.Context, req *CalendarEvent) (*CalendarEvent, error)\n",
+		"func RegisterCalendarServiceServer(s grpc.ServiceRegistrar, srv CalendarServiceServer) {\n" +
+			"\ts.RegisterService(&CalendarService_ServiceDesc, srv)\n}\n",
+		"\t\treturn srv.(CalendarServiceServer).SaveEvent(ctx, in)\n",
+		"\t\tFullMethod: \"/aurora.calendar.CalendarService/save_event\",\n",
+		"\treturn srv.(CalendarServiceServer).GetEvents(m, &calendarServiceGetEventsServer{stream})\n",
+		"func (x *calendarServicePushEventsServer) Recv() (*CalendarEvent, error) {\n",
+		"var CalendarService_ServiceDesc = grpc.ServiceDesc{\n" +
+			"\tServiceName: \"aurora.calendar.CalendarService\",\n" +
+			"\tHandlerType: (*CalendarServiceServer)(nil),\n" +
+			"\tMethods: []grpc.MethodDesc{\n" +
+			"\t\t{\n\t\t\tMethodName: \"save_event\",\n\t\t\tHandler:    _CalendarService_SaveEvent_Handler,\n\t\t},\n" +
+			"\t},\n" +
+			"\tStreams: []grpc.StreamDesc{\n" +
+			"\t\t{\n\t\t\tStreamName:    \"getEvents\",\n\t\t\tHandler:       _CalendarService_GetEvents_Handler,\n\t\t\tServerStreams: true,\n\t\t},\n" +
+			"\t\t{\n\t\t\tStreamName:    \"PushEvents\",\n\t\t\tHandler:       _CalendarService_PushEvents_Handler,\n\t\t\tClientStreams: true,\n\t\t},\n" +
+			"\t},\n" +
+			"\tMetadata: \"calendar.proto\",\n}\n",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("Expected %q, got:\n%s", want, code)
+		}
+	}
+}
+
+func TestGoGRPCImportsOnlyUsedPackages(t *testing.T) {
+	schema := `
+package aurora.calendar;
+
+entity CalendarEvent {
+    @pk id: string;
+}
+
+service CalendarService {
+    %s
+}
+`
+	for _, tc := range []struct {
+		rpc     string
+		want    []string
+		notWant []string
+	}{
+		{
+			rpc:  "rpc SaveEvent(CalendarEvent) returns (CalendarEvent);",
+			want: []string{"\t\"context\"\n", "\t\"google.golang.org/grpc\"\n"},
+		},
+		{
+			rpc:     "rpc SyncEvents(stream CalendarEvent) returns (stream CalendarEvent);",
+			want:    []string{"\t\"google.golang.org/grpc\"\n"},
+			notWant: []string{"\"context\""},
+		},
+	} {
+		out, err := NewGoGRPCGenerator().Generate(mustParse(t, fmt.Sprintf(schema, tc.rpc)))
+		if err != nil {
+			t.Fatalf("Generate failed: %v", err)
+		}
+		code := out["calendar_grpc.go"]
+		for _, want := range tc.want {
+			if !strings.Contains(code, want) {
+				t.Errorf("%s: expected %q, got:\n%s", tc.rpc, want, code)
+			}
+		}
+		for _, notWant := range tc.notWant {
+			if strings.Contains(code, notWant) {
+				t.Errorf("%s: unexpected %q, got:\n%s", tc.rpc, notWant, code)
+			}
+		}
+	}
+}
+
+func TestGoGRPCUndeclaredType(t *testing.T) {
+	file := mustParse(t, `
+package aurora.calendar;
+
+entity CalendarEvent {
+    @pk id: string;
+}
+
+service CalendarService {
+    rpc PushEvents(stream CalendarEvent) returns (PushResult);
+}
+`)
+
+	_, err := NewGoGRPCGenerator().Generate(file)
+	if err == nil || !strings.Contains(err.Error(), "PushResult is not an entity or message") {
+		t.Fatalf("Expected an undeclared type error, got %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package codegen
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGoValidateGolden(t *testing.T) {
+	file := mustParse(t, `
+package aurora.accounts;
+
+enum AccountStatus {
+    ACTIVE = 0;
+    SUSPENDED = 1;
+}
+
+entity Account {
+    @pk id: string;
+    @required @length(3, 32) @pattern("^[a-z0-9_]+$") username: string;
+    @format("email") email: string;
+    @length(max: 500) bio: string?;
+    @range(13, 130) age: int32;
+    @min(0) @max(1.5) score: double?;
+    @required avatar: bytes?;
+    status: AccountStatus;
+}
+
+entity Session {
+    @pk id: string;
+    account_id: string;
+}
+`)
+
+	code := generateOne(t, NewGoGenerator(), file)
+	assertGolden(t, "go/accounts.go.golden", code)
+}
+
+func TestGoDeprecated(t *testing.T) {
+	code := generateOne(t, NewGoGenerator(), mustParse(t, deprecatedSchema))
+	for _, want := range []string{
+		"\t// Deprecated: use LOW\n\tPriorityNone Priority = 1\n",
+		"// Deprecated: do not use.\ntype LegacyEvent struct {\n",
+		"\t// Deprecated: use title\n\tName ",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("Expected %q, got:\n%s", want, code)
+		}
+	}
+}
+
+func TestGoMessageStruct(t *testing.T) {
+	code := generateOne(t, NewGoGenerator(), mustParse(t, `
+package aurora.calendar;
+
+message GetEventsRequest {
+    calendar_name: string?;
+    limit: int32;
+}
+`))
+	want := "type GetEventsRequest struct {\n" +
+		"\tCalendarName *string `json:\"calendar_name\"`\n" +
+		"\tLimit        int32   `json:\"limit\"`\n" +
+		"}\n"
+	if !strings.Contains(code, want) {
+		t.Errorf("Expected %q, got:\n%s", want, code)
+	}
+	if strings.Contains(code, "func (m *GetEventsRequest) Validate") {
+		t.Errorf("Expected no Validate for a message, got:\n%s", code)
+	}
+}
@@ -0,0 +1,170 @@
+package codegen
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aurora/dataproto/internal/parser"
+)
+
+// GraphQLGenerator generates a GraphQL SDL schema from DataProto schemas.
+type GraphQLGenerator struct{}
+
+// NewGraphQLGenerator creates a new GraphQLGenerator.
+func NewGraphQLGenerator() *GraphQLGenerator {
+	return &GraphQLGenerator{}
+}
+
+// graphQLScalars are the custom scalars the type mapping relies on, in the
+// order they are declared. GraphQL's Int is 32-bit, so int64 needs its own.
+var graphQLScalars = []string{"DateTime", "Int64"}
+
+// Generate generates a single .graphql file from a DataProto file.
+func (g *GraphQLGenerator) Generate(file *parser.File) (map[string]string, error) {
+	file = concreteFile(file)
+	result := make(map[string]string)
+
+	entities := make(map[string]*parser.EntityDecl)
+	for _, entity := range file.Entities {
+		entities[entity.Name] = entity
+	}
+
+	var body strings.Builder
+	for _, enum := range file.Enums {
+		body.WriteString("\n")
+		body.WriteString(g.generateEnum(enum))
+	}
+	for _, entity := range file.Entities {
+		body.WriteString("\n")
+		body.WriteString(g.generateType("type", entity.Name, entity, nil))
+	}
+	for _, entity := range g.inputEntities(file, entities) {
+		body.WriteString("\n")
+		body.WriteString(g.generateType("input", entity.Name+"Input", entity, entities))
+	}
+
+	pkgName := "schema"
+	if file.Package != nil {
+		parts := strings.Split(file.Package.Name, ".")
+		pkgName = strings.ToLower(parts[len(parts)-1])
+	}
+
+	var sb strings.Builder
+
+	// Header
+	sb.WriteString("# Generated by dataprotoc. DO NOT EDIT.\n")
+	sb.WriteString("# source: ")
+	if file.Package != nil {
+		sb.WriteString(file.Package.Name)
+	}
+	sb.WriteString(".dataproto\n")
+
+	// Declare only the scalars the schema uses
+	used := make(map[string]bool)
+	for _, entity := range file.Entities {
+		for _, field := range entity.Fields {
+			used[GetTypeMapping(field.Type.Name).GraphQL] = true
+		}
+	}
+	var scalars []string
+	for _, scalar := range graphQLScalars {
+		if used[scalar] {
+			scalars = append(scalars, fmt.Sprintf("scalar %s\n", scalar))
+		}
+	}
+	if len(scalars) > 0 {
+		sb.WriteString("\n")
+		sb.WriteString(strings.Join(scalars, ""))
+	}
+
+	sb.WriteString(body.String())
+
+	result[pkgName+".graphql"] = sb.String()
+	return result, nil
+}
+
+func (g *GraphQLGenerator) generateEnum(enum *parser.EnumDecl) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("enum %s {\n", enum.Name))
+	for _, val := range enum.Values {
+		sb.WriteString(fmt.Sprintf("  %s%s\n", val.Name, graphQLDeprecated(val.Deprecated())))
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// generateType emits an object or input type for entity. For input types,
+// inputs holds the entities whose references must point at their input type.
+func (g *GraphQLGenerator) generateType(keyword, name string, entity *parser.EntityDecl, inputs map[string]*parser.EntityDecl) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("%s %s {\n", keyword, name))
+	for _, field := range entity.Fields {
+		// Input fields cannot be deprecated in the GraphQL version most
+		// servers implement
+		var directive string
+		if keyword == "type" {
+			directive = graphQLDeprecated(field.Deprecated())
+		}
+		sb.WriteString(fmt.Sprintf("  %s: %s%s\n", ToCamelCase(field.Name), g.fieldType(field, inputs), directive))
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// fieldType maps a field to its GraphQL type. Non-optional and @required
+// fields are non-null.
+func (g *GraphQLGenerator) fieldType(field *parser.FieldDecl, inputs map[string]*parser.EntityDecl) string {
+	typeName := GetTypeMapping(field.Type.Name).GraphQL
+	if _, ok := inputs[field.Type.Name]; ok {
+		typeName += "Input"
+	}
+	if !field.Type.Optional || field.IsRequired() {
+		typeName += "!"
+	}
+	return typeName
+}
+
+// inputEntities returns the entities used as rpc request messages, plus the
+// entities they reference, in declaration order.
+func (g *GraphQLGenerator) inputEntities(file *parser.File, entities map[string]*parser.EntityDecl) []*parser.EntityDecl {
+	needed := make(map[string]bool)
+	var visit func(name string)
+	visit = func(name string) {
+		entity, ok := entities[name]
+		if !ok || needed[name] {
+			return
+		}
+		needed[name] = true
+		for _, field := range entity.Fields {
+			visit(field.Type.Name)
+		}
+	}
+	for _, svc := range file.Services {
+		for _, method := range svc.Methods {
+			visit(method.RequestType.Name)
+		}
+	}
+
+	var inputs []*parser.EntityDecl
+	for _, entity := range file.Entities {
+		if needed[entity.Name] {
+			inputs = append(inputs, entity)
+		}
+	}
+	return inputs
+}
+
+// graphQLDeprecated renders the @deprecated directive for a deprecated
+// field or enum value, or nothing.
+func graphQLDeprecated(msg string, deprecated bool) string {
+	switch {
+	case !deprecated:
+		return ""
+	case msg == "":
+		return " @deprecated"
+	default:
+		return fmt.Sprintf(" @deprecated(reason: %q)", msg)
+	}
+}
@@ -0,0 +1,48 @@
+package codegen
+
+import "testing"
+
+func TestGraphQLCalendarGolden(t *testing.T) {
+	file := mustParse(t, `
+package aurora.calendar;
+
+enum EventStatus {
+    TENTATIVE = 0;
+    CONFIRMED = 1;
+    CANCELLED = 2;
+}
+
+@table("calendar_events")
+entity CalendarEvent {
+    @pk id: string;
+    @required title: string;
+    start_date: timestamp;
+    end_date: timestamp?;
+    is_all_day: bool;
+    status: EventStatus;
+    @required calendar_name: string?;
+    notes: string?;
+    attachment_count: int32;
+    size_bytes: int64;
+}
+
+entity EventFilter {
+    calendar_name: string?;
+    after: timestamp?;
+    status: EventStatus?;
+}
+
+entity GetEventsRequest {
+    filter: EventFilter;
+    max_results: int32?;
+}
+
+service CalendarService {
+    rpc GetEvents(GetEventsRequest) returns (stream CalendarEvent);
+    rpc SaveEvent(CalendarEvent) returns (CalendarEvent);
+}
+`)
+
+	code := generateOne(t, NewGraphQLGenerator(), file)
+	assertGolden(t, "graphql/calendar.graphql.golden", code)
+}
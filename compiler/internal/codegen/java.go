@@ -15,6 +15,7 @@ type JavaGenerator struct {
 	GenerateBuilders    bool   // Generate builder pattern
 	GenerateMappers     bool   // Generate proto<->entity mappers
 	GenerateRepository  bool   // Generate repository classes
+	QuoteIdentifiers    bool   // Double-quote table and column names in repository SQL
 }
 
 // NewJavaGenerator creates a new JavaGenerator with defaults.
@@ -30,6 +31,11 @@ func NewJavaGenerator() *JavaGenerator {
 
 // Generate generates Java code from a DataProto file.
 func (g *JavaGenerator) Generate(file *parser.File) (map[string]string, error) {
+	file = concreteFile(file)
+	// The package is per file; set it on a copy as g may be shared
+	cp := *g
+	g = &cp
+
 	result := make(map[string]string)
 
 	// Set package name from file
@@ -37,11 +43,14 @@ func (g *JavaGenerator) Generate(file *parser.File) (map[string]string, error) {
 		g.PackageName = file.Package.Name
 	}
 
+	// Prepared statement names are unique across the file
+	stmtNames := StatementNames(file)
+
 	// Generate code for each entity
 	for _, entity := range file.Entities {
 		// Repository class
 		if g.GenerateRepository {
-			repoCode := g.generateRepository(entity)
+			repoCode := g.generateRepository(file, entity, stmtNames)
 			filename := entity.Name + "Repository.java"
 			result[filename] = repoCode
 		}
@@ -57,7 +66,7 @@ func (g *JavaGenerator) Generate(file *parser.File) (map[string]string, error) {
 	return result, nil
 }
 
-func (g *JavaGenerator) generateRepository(entity *parser.EntityDecl) string {
+func (g *JavaGenerator) generateRepository(file *parser.File, entity *parser.EntityDecl, stmtNames map[*parser.QueryDecl]string) string {
 	var sb strings.Builder
 
 	// Header
@@ -81,6 +90,22 @@ func (g *JavaGenerator) generateRepository(entity *parser.EntityDecl) string {
 	// Class
 	sb.WriteString(fmt.Sprintf("public class %sRepository {\n\n", entity.Name))
 
+	tableName := entity.TableName()
+	if tableName == "" {
+		tableName = ToSnakeCase(entity.Name)
+	}
+	tableName = g.ident(tableName)
+
+	// Prepared statement names and SQL for declared queries
+	for _, query := range entity.Queries {
+		constName := ToScreamingSnakeCase(query.Name)
+		sb.WriteString(fmt.Sprintf("    /** Prepared statement for {@link #%s}. */\n", ToCamelCase(query.Name)))
+		sb.WriteString(fmt.Sprintf("    public static final String STMT_%s = \"%s\";\n", constName, stmtNames[query]))
+		sql, _ := selectSQL(DialectSQLite, entity, tableName, query, g.ident, TimestampEpochMillis)
+		sb.WriteString(fmt.Sprintf("    public static final String SQL_%s = %s;\n\n",
+			constName, sqlLiteral(sql)))
+	}
+
 	// Fields
 	sb.WriteString("    private final DataProtoRuntime runtime;\n\n")
 
@@ -92,31 +117,38 @@ func (g *JavaGenerator) generateRepository(entity *parser.EntityDecl) string {
 	sb.WriteString("        this.runtime = runtime;\n")
 	sb.WriteString("    }\n\n")
 
-	tableName := entity.TableName()
-	if tableName == "" {
-		tableName = ToSnakeCase(entity.Name)
+	// Generate CRUD methods; a view is read-only
+	readOnly := entity.View() != nil
+	if !readOnly {
+		sb.WriteString(g.generateUpsert(file, entity, tableName))
 	}
-
-	// Generate CRUD methods
-	sb.WriteString(g.generateUpsert(entity, tableName))
 	sb.WriteString(g.generateFindById(entity, tableName))
 	sb.WriteString(g.generateFindAll(entity, tableName))
-	sb.WriteString(g.generateDelete(entity, tableName))
+	if !readOnly {
+		sb.WriteString(g.generateDelete(entity, tableName))
+	}
 
 	// Generate query methods
 	for _, query := range entity.Queries {
-		sb.WriteString(g.generateQueryMethod(entity, query, tableName))
+		sb.WriteString(g.generateQueryMethod(file, entity, query, tableName))
 	}
 
 	// Generate row mapper
-	sb.WriteString(g.generateRowMapper(entity))
+	sb.WriteString(g.generateRowMapper(file, entity))
+
+	// Generate the rows projecting queries return
+	for _, query := range entity.Queries {
+		if fields := queryRowFields(entity, query); fields != nil {
+			sb.WriteString(g.generateQueryRow(file, entity, query, fields))
+		}
+	}
 
 	sb.WriteString("}\n")
 
 	return sb.String()
 }
 
-func (g *JavaGenerator) generateUpsert(entity *parser.EntityDecl, tableName string) string {
+func (g *JavaGenerator) generateUpsert(file *parser.File, entity *parser.EntityDecl, tableName string) string {
 	var sb strings.Builder
 
 	// Build column list
@@ -124,26 +156,23 @@ func (g *JavaGenerator) generateUpsert(entity *parser.EntityDecl, tableName stri
 	var placeholders []string
 	var setters []string
 
-	for _, field := range entity.Fields {
-		colName := ToSnakeCase(field.Name)
+	fields := upsertFields(entity)
+	for _, field := range fields {
+		colName := g.ident(ToSnakeCase(field.Name))
 		columns = append(columns, colName)
 		placeholders = append(placeholders, "?")
 	}
 
 	sb.WriteString(fmt.Sprintf("    public void upsert(%s entity) {\n", entity.Name))
-	sb.WriteString("        String sql = \"INSERT OR REPLACE INTO ")
-	sb.WriteString(tableName)
-	sb.WriteString(" (")
-	sb.WriteString(strings.Join(columns, ", "))
-	sb.WriteString(") VALUES (")
-	sb.WriteString(strings.Join(placeholders, ", "))
-	sb.WriteString(")\";\n\n")
+	sb.WriteString(fmt.Sprintf("        String sql = %s;\n\n", sqlLiteral(fmt.Sprintf(
+		"INSERT OR REPLACE INTO %s (%s) VALUES (%s)",
+		tableName, strings.Join(columns, ", "), strings.Join(placeholders, ", ")))))
 
 	sb.WriteString("        try (Connection conn = runtime.getConnection();\n")
 	sb.WriteString("             PreparedStatement stmt = conn.prepareStatement(sql)) {\n")
 
-	for i, field := range entity.Fields {
-		setter := g.getJavaSetter(field, i+1)
+	for i, field := range fields {
+		setter := g.getJavaSetter(file, field, i+1)
 		setters = append(setters, setter)
 		sb.WriteString(fmt.Sprintf("            %s\n", setter))
 	}
@@ -179,8 +208,7 @@ func (g *JavaGenerator) generateFindById(entity *parser.EntityDecl, tableName st
 
 	sb.WriteString(fmt.Sprintf("    public Optional<%s> findById(%s %s) {\n",
 		entity.Name, pkType, pkName))
-	sb.WriteString(fmt.Sprintf("        String sql = \"SELECT * FROM %s WHERE %s = ?\";\n\n",
-		tableName, pkCol))
+	sb.WriteString(fmt.Sprintf("        String sql = %s;\n\n", sqlLiteral(findByKeySQL(entity, tableName, pkCol, g.ident))))
 
 	sb.WriteString("        try (Connection conn = runtime.getConnection();\n")
 	sb.WriteString("             PreparedStatement stmt = conn.prepareStatement(sql)) {\n")
@@ -204,7 +232,7 @@ func (g *JavaGenerator) generateFindAll(entity *parser.EntityDecl, tableName str
 	var sb strings.Builder
 
 	sb.WriteString(fmt.Sprintf("    public List<%s> findAll() {\n", entity.Name))
-	sb.WriteString(fmt.Sprintf("        String sql = \"SELECT * FROM %s\";\n", tableName))
+	sb.WriteString(fmt.Sprintf("        String sql = %s;\n", sqlLiteral(findAllSQL(entity, tableName, g.ident))))
 	sb.WriteString(fmt.Sprintf("        List<%s> results = new ArrayList<>();\n\n", entity.Name))
 
 	sb.WriteString("        try (Connection conn = runtime.getConnection();\n")
@@ -243,8 +271,7 @@ func (g *JavaGenerator) generateDelete(entity *parser.EntityDecl, tableName stri
 	pkCol := ToSnakeCase(pkField.Name)
 
 	sb.WriteString(fmt.Sprintf("    public boolean delete(%s %s) {\n", pkType, pkName))
-	sb.WriteString(fmt.Sprintf("        String sql = \"DELETE FROM %s WHERE %s = ?\";\n\n",
-		tableName, pkCol))
+	sb.WriteString(fmt.Sprintf("        String sql = %s;\n\n", sqlLiteral(deleteSQL(entity, tableName, pkCol, g.ident, TimestampEpochMillis))))
 
 	sb.WriteString("        try (Connection conn = runtime.getConnection();\n")
 	sb.WriteString("             PreparedStatement stmt = conn.prepareStatement(sql)) {\n")
@@ -259,17 +286,28 @@ func (g *JavaGenerator) generateDelete(entity *parser.EntityDecl, tableName stri
 	return sb.String()
 }
 
-func (g *JavaGenerator) generateQueryMethod(entity *parser.EntityDecl, query *parser.QueryDecl, tableName string) string {
+func (g *JavaGenerator) generateQueryMethod(file *parser.File, entity *parser.EntityDecl, query *parser.QueryDecl, tableName string) string {
 	var sb strings.Builder
 
-	// Method signature
-	sb.WriteString(fmt.Sprintf("    public List<%s> %s(", entity.Name, ToCamelCase(query.Name)))
+	// A projecting query yields its own row type
+	rowType, mapper := entity.Name, "mapRow"
+	if queryRowFields(entity, query) != nil {
+		rowType = queryRowName(entity, query)
+		mapper = "map" + rowType
+	}
+
+	// Method signature; @returns(one) queries yield an Optional
+	returnType := fmt.Sprintf("List<%s>", rowType)
+	if query.ReturnsOne() {
+		returnType = fmt.Sprintf("Optional<%s>", rowType)
+	}
+	sb.WriteString(fmt.Sprintf("    public %s %s(", returnType, ToCamelCase(query.Name)))
 
 	// Parameters
 	var params []string
 	for _, p := range query.Params {
 		javaType := GetTypeMapping(p.Type.Name).Java
-		if p.Type.Optional || p.Default != nil {
+		if p.Nullable() || p.HasDefault() {
 			// Use wrapper type for optional
 			javaType = g.getWrapperType(javaType)
 		}
@@ -278,87 +316,58 @@ func (g *JavaGenerator) generateQueryMethod(entity *parser.EntityDecl, query *pa
 	sb.WriteString(strings.Join(params, ", "))
 	sb.WriteString(") {\n")
 
-	// Build set of known parameter names
-	knownParams := make(map[string]bool)
-	for _, p := range query.Params {
-		knownParams[p.Name] = true
-	}
-
-	// Build SQL
-	var sqlParts []string
-	sqlParts = append(sqlParts, fmt.Sprintf("SELECT * FROM %s", tableName))
-
-	// WHERE clause
-	if query.Where != nil {
-		whereSQL, paramNames := ExprToSQLWithKnownParams(query.Where, knownParams)
-		sqlParts = append(sqlParts, "WHERE "+whereSQL)
-		_ = paramNames // Used for parameter binding order
+	sb.WriteString(fmt.Sprintf("        String sql = SQL_%s;\n", ToScreamingSnakeCase(query.Name)))
+	if !query.ReturnsOne() {
+		sb.WriteString(fmt.Sprintf("        List<%s> results = new ArrayList<>();\n", rowType))
 	}
-
-	// ORDER BY
-	if len(query.OrderBy) > 0 {
-		var orderParts []string
-		for _, o := range query.OrderBy {
-			dir := "ASC"
-			if o.Descending {
-				dir = "DESC"
-			}
-			orderParts = append(orderParts, fmt.Sprintf("%s %s", ToSnakeCase(o.Field), dir))
-		}
-		sqlParts = append(sqlParts, "ORDER BY "+strings.Join(orderParts, ", "))
-	}
-
-	// LIMIT
-	if query.Limit != nil {
-		switch l := query.Limit.(type) {
-		case *parser.LiteralExpr:
-			if val, ok := l.Value.(int64); ok {
-				sqlParts = append(sqlParts, fmt.Sprintf("LIMIT %d", val))
-			}
-		case *parser.IdentExpr:
-			sqlParts = append(sqlParts, "LIMIT ?")
-		}
-	}
-
-	sb.WriteString(fmt.Sprintf("        String sql = \"%s\";\n", strings.Join(sqlParts, " ")))
-	sb.WriteString(fmt.Sprintf("        List<%s> results = new ArrayList<>();\n\n", entity.Name))
+	sb.WriteString("\n")
 
 	sb.WriteString("        try (Connection conn = runtime.getConnection();\n")
 	sb.WriteString("             PreparedStatement stmt = conn.prepareStatement(sql)) {\n")
 
-	// Bind parameters
-	paramIdx := 1
-	for _, p := range query.Params {
+	// Bind parameters in placeholder order, which may repeat or skip some
+	_, names := selectSQL(DialectSQLite, entity, tableName, query, g.ident, TimestampEpochMillis)
+	for i, name := range names {
+		p := query.Param(name)
 		setter := g.getPreparedStatementMethod(p.Type.Name)
-		sb.WriteString(fmt.Sprintf("            stmt.%s(%d, %s);\n",
-			setter, paramIdx, ToCamelCase(p.Name)))
-		paramIdx++
-	}
-
-	// Handle limit if it's a parameter
-	if query.Limit != nil {
-		if l, ok := query.Limit.(*parser.IdentExpr); ok {
-			sb.WriteString(fmt.Sprintf("            stmt.setInt(%d, %s);\n",
-				paramIdx, ToCamelCase(l.Name)))
+		value := ToCamelCase(name)
+		nullable := p.Nullable() || p.HasDefault()
+		if nullable {
+			// A boxed value may be null, which the primitive setters reject
+			setter = "setObject"
+		}
+		if typeEnum(file, p.Type) != nil {
+			value = javaEnumName(value, nullable)
 		}
+		sb.WriteString(fmt.Sprintf("            stmt.%s(%d, %s);\n",
+			setter, i+1, value))
 	}
 
 	sb.WriteString("            try (ResultSet rs = stmt.executeQuery()) {\n")
-	sb.WriteString("                while (rs.next()) {\n")
-	sb.WriteString("                    results.add(mapRow(rs));\n")
-	sb.WriteString("                }\n")
+	if query.ReturnsOne() {
+		sb.WriteString("                if (rs.next()) {\n")
+		sb.WriteString(fmt.Sprintf("                    return Optional.of(%s(rs));\n", mapper))
+		sb.WriteString("                }\n")
+		sb.WriteString("                return Optional.empty();\n")
+	} else {
+		sb.WriteString("                while (rs.next()) {\n")
+		sb.WriteString(fmt.Sprintf("                    results.add(%s(rs));\n", mapper))
+		sb.WriteString("                }\n")
+	}
 	sb.WriteString("            }\n")
 	sb.WriteString("        } catch (SQLException e) {\n")
 	sb.WriteString(fmt.Sprintf("            throw new RuntimeException(\"Failed to execute %s\", e);\n",
 		query.Name))
 	sb.WriteString("        }\n")
-	sb.WriteString("        return results;\n")
+	if !query.ReturnsOne() {
+		sb.WriteString("        return results;\n")
+	}
 	sb.WriteString("    }\n\n")
 
 	return sb.String()
 }
 
-func (g *JavaGenerator) generateRowMapper(entity *parser.EntityDecl) string {
+func (g *JavaGenerator) generateRowMapper(file *parser.File, entity *parser.EntityDecl) string {
 	var sb strings.Builder
 
 	sb.WriteString(fmt.Sprintf("    private %s mapRow(ResultSet rs) throws SQLException {\n", entity.Name))
@@ -366,7 +375,7 @@ func (g *JavaGenerator) generateRowMapper(entity *parser.EntityDecl) string {
 	if g.GenerateBuilders {
 		sb.WriteString(fmt.Sprintf("        return %s.newBuilder()\n", entity.Name))
 		for _, field := range entity.Fields {
-			getter := g.getResultSetGetter(field)
+			getter := g.getResultSetGetter(file, field)
 			setterName := "set" + ToPascalCase(field.Name)
 			sb.WriteString(fmt.Sprintf("            .%s(%s)\n", setterName, getter))
 		}
@@ -374,7 +383,7 @@ func (g *JavaGenerator) generateRowMapper(entity *parser.EntityDecl) string {
 	} else {
 		sb.WriteString(fmt.Sprintf("        %s entity = new %s();\n", entity.Name, entity.Name))
 		for _, field := range entity.Fields {
-			getter := g.getResultSetGetter(field)
+			getter := g.getResultSetGetter(file, field)
 			setterName := "set" + ToPascalCase(field.Name)
 			sb.WriteString(fmt.Sprintf("        entity.%s(%s);\n", setterName, getter))
 		}
@@ -386,6 +395,45 @@ func (g *JavaGenerator) generateRowMapper(entity *parser.EntityDecl) string {
 	return sb.String()
 }
 
+// generateQueryRow emits the class holding a row of a projecting query,
+// with one final field per selected column, and the method mapping a result
+// row to it.
+func (g *JavaGenerator) generateQueryRow(file *parser.File, entity *parser.EntityDecl, query *parser.QueryDecl, fields []*parser.FieldDecl) string {
+	var sb strings.Builder
+	rowType := queryRowName(entity, query)
+
+	var params, getters []string
+	sb.WriteString(fmt.Sprintf("\n    /** Row returned by {@link #%s}. */\n", ToCamelCase(query.Name)))
+	sb.WriteString(fmt.Sprintf("    public static final class %s {\n", rowType))
+	for _, field := range fields {
+		name := ToCamelCase(field.Name)
+		javaType := g.javaFieldType(file, field.Type)
+		sb.WriteString(fmt.Sprintf("        public final %s %s;\n", javaType, name))
+		params = append(params, fmt.Sprintf("%s %s", javaType, name))
+
+		getter := g.getResultSetGetter(file, field)
+		if primitive := GetTypeMapping(field.Type.Name).Java; field.Type.Optional && javaType != primitive {
+			// The primitive getters read NULL as zero
+			getter = fmt.Sprintf("rs.getObject(\"%s\") != null ? %s : null", ToSnakeCase(field.Name), getter)
+		}
+		getters = append(getters, getter)
+	}
+	sb.WriteString(fmt.Sprintf("\n        public %s(%s) {\n", rowType, strings.Join(params, ", ")))
+	for _, field := range fields {
+		name := ToCamelCase(field.Name)
+		sb.WriteString(fmt.Sprintf("            this.%s = %s;\n", name, name))
+	}
+	sb.WriteString("        }\n")
+	sb.WriteString("    }\n\n")
+
+	sb.WriteString(fmt.Sprintf("    private %s map%s(ResultSet rs) throws SQLException {\n", rowType, rowType))
+	sb.WriteString(fmt.Sprintf("        return new %s(\n", rowType))
+	sb.WriteString("            " + strings.Join(getters, ",\n            ") + ");\n")
+	sb.WriteString("    }\n")
+
+	return sb.String()
+}
+
 func (g *JavaGenerator) generateMapper(entity *parser.EntityDecl) string {
 	var sb strings.Builder
 
@@ -440,10 +488,36 @@ func (g *JavaGenerator) generateMapper(entity *parser.EntityDecl) string {
 
 // Helper methods
 
-func (g *JavaGenerator) getJavaSetter(field *parser.FieldDecl, index int) string {
+// javaFieldType returns the Java type of a value of type t, boxed when it
+// may be null.
+func (g *JavaGenerator) javaFieldType(file *parser.File, t *parser.TypeRef) string {
+	if enum := typeEnum(file, t); enum != nil {
+		return enum.Name
+	}
+	javaType := GetTypeMapping(t.Name).Java
+	if t.Optional {
+		javaType = g.getWrapperType(javaType)
+	}
+	return javaType
+}
+
+// javaEnumName returns the value name of the enum value, as the enum CHECK
+// constraints expect it stored.
+func javaEnumName(value string, nullable bool) string {
+	if nullable {
+		return fmt.Sprintf("%s != null ? %s.name() : null", value, value)
+	}
+	return value + ".name()"
+}
+
+func (g *JavaGenerator) getJavaSetter(file *parser.File, field *parser.FieldDecl, index int) string {
 	getter := "entity.get" + ToPascalCase(field.Name) + "()"
 	method := g.getPreparedStatementMethod(field.Type.Name)
 
+	if typeEnum(file, field.Type) != nil {
+		return fmt.Sprintf("stmt.setString(%d, %s);", index, javaEnumName(getter, field.Type.Optional))
+	}
+
 	// Handle boolean conversion for SQLite
 	if field.Type.Name == "bool" {
 		return fmt.Sprintf("stmt.setInt(%d, %s ? 1 : 0);", index, getter)
@@ -473,9 +547,17 @@ func (g *JavaGenerator) getPreparedStatementMethod(typeName string) string {
 	}
 }
 
-func (g *JavaGenerator) getResultSetGetter(field *parser.FieldDecl) string {
+func (g *JavaGenerator) getResultSetGetter(file *parser.File, field *parser.FieldDecl) string {
 	col := ToSnakeCase(field.Name)
 
+	if enum := typeEnum(file, field.Type); enum != nil {
+		// Enums are stored by value name
+		if field.Type.Optional {
+			return fmt.Sprintf("rs.getString(\"%s\") != null ? %s.valueOf(rs.getString(\"%s\")) : null", col, enum.Name, col)
+		}
+		return fmt.Sprintf("%s.valueOf(rs.getString(\"%s\"))", enum.Name, col)
+	}
+
 	switch field.Type.Name {
 	case "string":
 		return fmt.Sprintf("rs.getString(\"%s\")", col)
@@ -512,3 +594,12 @@ func (g *JavaGenerator) getWrapperType(primitiveType string) string {
 		return primitiveType
 	}
 }
+
+// ident returns name as it appears in repository SQL, quoted when
+// QuoteIdentifiers is set.
+func (g *JavaGenerator) ident(name string) string {
+	if g.QuoteIdentifiers {
+		return quoteIdent(name)
+	}
+	return name
+}
@@ -0,0 +1,237 @@
+package codegen
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestJavaReturnsOne(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+entity Task {
+    @pk id: string;
+    slug: string;
+
+    @returns(one)
+    query bySlug(s: string) {
+        where slug = s
+    }
+}
+`)
+
+	out, err := NewJavaGenerator().Generate(file)
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+
+	repo := out["TaskRepository.java"]
+	for _, want := range []string{
+		"public Optional<Task> bySlug(String s) {",
+		"return Optional.of(mapRow(rs));",
+	} {
+		if !strings.Contains(repo, want) {
+			t.Errorf("Expected %q in repository, got:\n%s", want, repo)
+		}
+	}
+	method := repo[strings.Index(repo, "bySlug("):]
+	method = method[:strings.Index(method, "\n    }\n")]
+	if strings.Contains(method, "List<Task> results") {
+		t.Errorf("Expected no result list for a single-row query, got:\n%s", method)
+	}
+}
+
+func TestJavaStatementConstants(t *testing.T) {
+	file := mustParseFile(t, calendarSchemaPath)
+
+	out, err := NewJavaGenerator().Generate(file)
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+
+	repo := out["CalendarEventRepository.java"]
+	for _, want := range []string{
+		`public static final String STMT_EVENTS_BY_DATE_RANGE = "CalendarEventEventsByDateRange";`,
+		`public static final String SQL_EVENTS_BY_DATE_RANGE = "SELECT * FROM calendar_events ` +
+			`WHERE start_date >= ? AND start_date < ? ORDER BY start_date ASC";`,
+		`public static final String STMT_UPCOMING_EVENTS = "CalendarEventUpcomingEvents";`,
+		`String sql = SQL_EVENTS_BY_DATE_RANGE;`,
+	} {
+		if !strings.Contains(repo, want) {
+			t.Errorf("Expected %q in repository, got:\n%s", want, repo)
+		}
+	}
+}
+
+func TestJavaBindsEachPlaceholder(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+entity Task {
+    @pk id: string;
+    state: string;
+    title: string;
+
+    query search(status?: string, term: string, n: int32) {
+        where state = status AND title LIKE term
+        limit n
+    }
+}
+`)
+
+	out, err := NewJavaGenerator().Generate(file)
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+
+	repo := out["TaskRepository.java"]
+	want := "            stmt.setObject(1, status);\n" +
+		"            stmt.setObject(2, status);\n" +
+		"            stmt.setString(3, term);\n" +
+		"            stmt.setInt(4, n);\n" +
+		"            try (ResultSet rs"
+	if !strings.Contains(repo, want) {
+		t.Errorf("Expected one binding per placeholder, got:\n%s", repo)
+	}
+}
+
+func TestJavaBindsDefaultedParams(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+entity Event {
+    @pk id: string;
+    at: timestamp;
+
+    query window(since: timestamp = NOW(), until: timestamp = since + 86400000) {
+        where at >= since AND at < until
+    }
+}
+`)
+
+	out, err := NewJavaGenerator().Generate(file)
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+
+	// until's default reads since, so since is bound a second time
+	repo := out["EventRepository.java"]
+	want := "            stmt.setObject(1, since);\n" +
+		"            stmt.setObject(2, until);\n" +
+		"            stmt.setObject(3, since);\n"
+	if !strings.Contains(repo, want) {
+		t.Errorf("Expected nullable bindings for each placeholder, got:\n%s", repo)
+	}
+}
+
+func TestJavaStoresEnumNames(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+enum Status {
+    ACTIVE = 0;
+    DONE = 1;
+}
+
+entity Task {
+    @pk id: string;
+    status: Status;
+    previous: Status?;
+
+    query byStatus(s: Status) {
+        where status = s
+    }
+}
+`)
+
+	out, err := NewJavaGenerator().Generate(file)
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+
+	repo := out["TaskRepository.java"]
+	for _, want := range []string{
+		"stmt.setString(2, entity.getStatus().name());",
+		"stmt.setString(3, entity.getPrevious() != null ? entity.getPrevious().name() : null);",
+		"stmt.setString(1, s.name());",
+		`Status.valueOf(rs.getString("status"))`,
+	} {
+		if !strings.Contains(repo, want) {
+			t.Errorf("Expected %q in repository, got:\n%s", want, repo)
+		}
+	}
+}
+
+func TestJavaProjectionRow(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+entity Sale {
+    @pk id: string;
+    customer: string;
+    total: double;
+
+    query totalsByCustomer() {
+        select customer, COUNT(id), SUM(total)
+        group_by customer
+    }
+}
+`)
+
+	out, err := NewJavaGenerator().Generate(file)
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+
+	repo := out["SaleRepository.java"]
+	for _, want := range []string{
+		"public List<SaleTotalsByCustomerRow> totalsByCustomer() {",
+		"results.add(mapSaleTotalsByCustomerRow(rs));",
+		"    public static final class SaleTotalsByCustomerRow {\n" +
+			"        public final String customer;\n" +
+			"        public final long countId;\n" +
+			"        public final Double sumTotal;\n",
+		"        return new SaleTotalsByCustomerRow(\n" +
+			"            rs.getString(\"customer\"),\n" +
+			"            rs.getLong(\"count_id\"),\n" +
+			"            rs.getObject(\"sum_total\") != null ? rs.getDouble(\"sum_total\") : null);\n",
+	} {
+		if !strings.Contains(repo, want) {
+			t.Errorf("Expected %q in repository, got:\n%s", want, repo)
+		}
+	}
+}
+
+func TestJavaGroupedRow(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+entity Sale {
+    @pk id: string;
+    customer: string;
+    region: string?;
+
+    query customers() {
+        group_by customer, region
+    }
+}
+`)
+
+	out, err := NewJavaGenerator().Generate(file)
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+
+	repo := out["SaleRepository.java"]
+	for _, want := range []string{
+		`public static final String SQL_CUSTOMERS = "SELECT customer, region FROM sale GROUP BY customer, region";`,
+		"public List<SaleCustomersRow> customers() {",
+		"        return new SaleCustomersRow(\n" +
+			"            rs.getString(\"customer\"),\n" +
+			"            rs.getString(\"region\"));\n",
+	} {
+		if !strings.Contains(repo, want) {
+			t.Errorf("Expected %q in repository, got:\n%s", want, repo)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package codegen
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/aurora/dataproto/internal/parser"
+)
+
+// JSONSchemaGenerator generates a JSON Schema (draft-07) document per entity
+// for validating API payloads.
+type JSONSchemaGenerator struct{}
+
+// NewJSONSchemaGenerator creates a new JSONSchemaGenerator.
+func NewJSONSchemaGenerator() *JSONSchemaGenerator {
+	return &JSONSchemaGenerator{}
+}
+
+// jsonSchema is the subset of JSON Schema the generator emits. Field order
+// here is the key order in the output.
+type jsonSchema struct {
+	Schema          string         `json:"$schema,omitempty"`
+	Ref             string         `json:"$ref,omitempty"`
+	Title           string         `json:"title,omitempty"`
+	Description     string         `json:"description,omitempty"`
+	AnyOf           []*jsonSchema  `json:"anyOf,omitempty"`
+	Type            interface{}    `json:"type,omitempty"`
+	Enum            []interface{}  `json:"enum,omitempty"`
+	Format          string         `json:"format,omitempty"`
+	ContentEncoding string         `json:"contentEncoding,omitempty"`
+	Pattern         string         `json:"pattern,omitempty"`
+	MinLength       *int64         `json:"minLength,omitempty"`
+	MaxLength       *int64         `json:"maxLength,omitempty"`
+	Minimum         *float64       `json:"minimum,omitempty"`
+	Maximum         *float64       `json:"maximum,omitempty"`
+	Properties      jsonProperties `json:"properties,omitempty"`
+	Required        []string       `json:"required,omitempty"`
+}
+
+// jsonMember is a named value of a jsonObject.
+type jsonMember[V any] struct {
+	Name  string
+	Value V
+}
+
+// jsonObject marshals as an object keeping declaration order.
+type jsonObject[V any] []jsonMember[V]
+
+func (obj jsonObject[V]) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, member := range obj {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if err := encodeJSON(&buf, member.Name, ""); err != nil {
+			return nil, err
+		}
+		buf.WriteByte(':')
+		if err := encodeJSON(&buf, member.Value, ""); err != nil {
+			return nil, err
+		}
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// jsonProperties maps property names to their schemas.
+type jsonProperties = jsonObject[*jsonSchema]
+
+// encodeJSON writes v without HTML escaping, so patterns such as a<b stay
+// readable.
+func encodeJSON(buf *bytes.Buffer, v interface{}, indent string) error {
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if indent != "" {
+		enc.SetIndent("", indent)
+	}
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	if indent == "" {
+		// Encode terminates each value with a newline
+		buf.Truncate(buf.Len() - 1)
+	}
+	return nil
+}
+
+// Generate generates one <entity>.schema.json file per entity.
+func (g *JSONSchemaGenerator) Generate(file *parser.File) (map[string]string, error) {
+	file = concreteFile(file)
+	result := make(map[string]string)
+
+	enums := make(map[string]*parser.EnumDecl)
+	for _, enum := range file.Enums {
+		enums[enum.Name] = enum
+	}
+
+	for _, entity := range file.Entities {
+		schema := &jsonSchema{
+			Schema: "http://json-schema.org/draft-07/schema#",
+			Title:  entity.Name,
+			Type:   "object",
+		}
+		for _, field := range entity.Fields {
+			name := JSONName(field)
+			schema.Properties = append(schema.Properties,
+				jsonMember[*jsonSchema]{Name: name, Value: g.fieldSchema(field, enums)})
+			if !field.Type.Optional || field.IsRequired() {
+				schema.Required = append(schema.Required, name)
+			}
+		}
+
+		var buf bytes.Buffer
+		if err := encodeJSON(&buf, schema, "  "); err != nil {
+			return nil, err
+		}
+		result[ToSnakeCase(entity.Name)+".schema.json"] = buf.String()
+	}
+
+	return result, nil
+}
+
+func (g *JSONSchemaGenerator) fieldSchema(field *parser.FieldDecl, enums map[string]*parser.EnumDecl) *jsonSchema {
+	if enum, ok := enums[field.Type.Name]; ok {
+		schema := &jsonSchema{}
+		for _, val := range enum.Values {
+			schema.Enum = append(schema.Enum, val.Name)
+		}
+		if nullableField(field) {
+			schema.Enum = append(schema.Enum, nil)
+		}
+		return schema
+	}
+
+	if schema := scalarFieldSchema(field); schema != nil {
+		return schema
+	}
+
+	// Entity reference
+	return &jsonSchema{Ref: ToSnakeCase(field.Type.Name) + ".schema.json"}
+}
+
+// nullableField reports whether a field's value may be null. @required makes
+// an optional field non-null, matching Validate.
+func nullableField(field *parser.FieldDecl) bool {
+	return field.Type.Optional && !field.IsRequired()
+}
+
+// scalarFieldSchema returns the schema of a field with a builtin type,
+// including its validation keywords, or nil for enum and entity fields.
+func scalarFieldSchema(field *parser.FieldDecl) *jsonSchema {
+	schema := &jsonSchema{}
+	nullable := nullableField(field)
+
+	var typeName string
+	switch field.Type.Name {
+	case "string":
+		typeName = "string"
+	case "int32", "int64", "timestamp":
+		typeName = "integer"
+	case "float", "double":
+		typeName = "number"
+	case "bool":
+		typeName = "boolean"
+	case "bytes":
+		typeName = "string"
+		schema.ContentEncoding = "base64"
+	default:
+		return nil
+	}
+
+	if nullable {
+		schema.Type = []string{typeName, "null"}
+	} else {
+		schema.Type = typeName
+	}
+
+	if typeName == "string" && schema.ContentEncoding == "" {
+		if lc, ok := field.LengthConstraint(); ok {
+			schema.MinLength, schema.MaxLength = lc.Min, lc.Max
+		}
+		schema.Pattern = field.Pattern()
+		schema.Format = field.Format()
+	}
+	if typeName == "integer" || typeName == "number" {
+		schema.Minimum, schema.Maximum = field.NumericBounds()
+	}
+
+	return schema
+}
@@ -0,0 +1,57 @@
+package codegen
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestJSONSchemaGolden(t *testing.T) {
+	file := mustParse(t, `
+package aurora.accounts;
+
+enum AccountStatus {
+    ACTIVE = 0;
+    SUSPENDED = 1;
+}
+
+entity Account {
+    @pk id: string;
+    @required @length(3, 32) @pattern("^[a-z0-9_<>]+$") username: string;
+    @format("email") email: string;
+    @length(max: 500) bio: string?;
+    @range(13, 130) age: int32;
+    @min(0) @max(1.5) score: double?;
+    avatar: bytes?;
+    status: AccountStatus;
+    @required last_login: timestamp?;
+}
+`)
+
+	code := generateOne(t, NewJSONSchemaGenerator(), file)
+	assertGolden(t, "jsonschema/account.schema.json.golden", code)
+}
+
+func TestJSONNameOverride(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+entity Contact {
+    @pk id: string;
+    @json("fullName") display_name: string;
+    phone_number: string?;
+}
+`)
+
+	schema := generateOne(t, NewJSONSchemaGenerator(), file)
+	if !strings.Contains(schema, `"fullName": {`) || !strings.Contains(schema, `"phone_number": {`) {
+		t.Errorf("Expected fullName and phone_number properties, got:\n%s", schema)
+	}
+	if strings.Contains(schema, `"display_name"`) {
+		t.Errorf("Expected display_name to be replaced by its @json name, got:\n%s", schema)
+	}
+
+	goCode := generateOne(t, NewGoGenerator(), file)
+	if !strings.Contains(goCode, "`json:\"fullName\"`") {
+		t.Errorf("Expected Go json tag fullName, got:\n%s", goCode)
+	}
+}
@@ -32,6 +32,11 @@ func NewKotlinGenerator() *KotlinGenerator {
 
 // Generate generates Kotlin code from a DataProto file.
 func (g *KotlinGenerator) Generate(file *parser.File) (map[string]string, error) {
+	file = concreteFile(file)
+	// The package is per file; set it on a copy as g may be shared
+	cp := *g
+	g = &cp
+
 	result := make(map[string]string)
 
 	// Set package name from file
@@ -364,7 +369,10 @@ func (g *KotlinGenerator) kotlinBaseType(typeName string) string {
 }
 
 func (g *KotlinGenerator) kotlinDefaultValue(value interface{}, typeName string) string {
-	switch v := value.(type) {
+	if isNowValue(value, typeName) {
+		return "System.currentTimeMillis()"
+	}
+	switch v := epochDefault(value, typeName).(type) {
 	case string:
 		return fmt.Sprintf("\"%s\"", v)
 	case bool:
@@ -0,0 +1,120 @@
+package codegen
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aurora/dataproto/internal/parser"
+)
+
+// MermaidGenerator generates a Mermaid entity-relationship diagram from
+// DataProto schemas, for documentation.
+type MermaidGenerator struct{}
+
+// NewMermaidGenerator creates a new MermaidGenerator.
+func NewMermaidGenerator() *MermaidGenerator {
+	return &MermaidGenerator{}
+}
+
+// Generate generates a single .mmd file holding an erDiagram block.
+func (g *MermaidGenerator) Generate(file *parser.File) (map[string]string, error) {
+	file = concreteFile(file)
+	result := make(map[string]string)
+
+	var sb strings.Builder
+
+	// Header
+	sb.WriteString("%% Generated by dataprotoc. DO NOT EDIT.\n")
+	sb.WriteString("%% source: ")
+	if file.Package != nil {
+		sb.WriteString(file.Package.Name)
+	}
+	sb.WriteString(".dataproto\n")
+	sb.WriteString("erDiagram\n")
+
+	for _, entity := range file.Entities {
+		sb.WriteString(g.generateEntity(entity))
+	}
+	for _, entity := range file.Entities {
+		sb.WriteString(g.generateRelationships(entity))
+	}
+
+	pkgName := "schema"
+	if file.Package != nil {
+		parts := strings.Split(file.Package.Name, ".")
+		pkgName = strings.ToLower(parts[len(parts)-1])
+	}
+
+	result[pkgName+".mmd"] = sb.String()
+	return result, nil
+}
+
+func (g *MermaidGenerator) generateEntity(entity *parser.EntityDecl) string {
+	var sb strings.Builder
+
+	// Columns of multi-column foreign keys are marked like @fk fields
+	fkColumns := make(map[string]bool)
+	for _, fk := range entity.ForeignKeys() {
+		for _, name := range fk.Fields {
+			fkColumns[name] = true
+		}
+	}
+
+	sb.WriteString(fmt.Sprintf("    %s {\n", entity.Name))
+	for _, field := range entity.Fields {
+		line := fmt.Sprintf("        %s %s", field.Type.Name, field.Name)
+
+		var keys []string
+		if field.IsPrimaryKey() {
+			keys = append(keys, "PK")
+		}
+		if field.HasAnnotation("fk") || fkColumns[field.Name] {
+			keys = append(keys, "FK")
+		}
+		if field.IsUnique() && !field.IsPrimaryKey() {
+			keys = append(keys, "UK")
+		}
+		if len(keys) > 0 {
+			line += " " + strings.Join(keys, ", ")
+		}
+
+		if field.Type.Optional && !field.IsRequired() {
+			line += ` "optional"`
+		}
+		sb.WriteString(line + "\n")
+	}
+	sb.WriteString("    }\n")
+	return sb.String()
+}
+
+// generateRelationships emits one line per foreign key of entity: the
+// referenced entity has zero or more rows of entity, labeled by the key
+// columns.
+func (g *MermaidGenerator) generateRelationships(entity *parser.EntityDecl) string {
+	var sb strings.Builder
+
+	for _, field := range entity.Fields {
+		fk := field.GetAnnotation("fk")
+		if fk == nil || len(fk.Args) == 0 {
+			continue
+		}
+		ref, ok := fk.Args[0].Value.(string)
+		if !ok {
+			continue
+		}
+		parts := strings.Split(ref, ".")
+		if len(parts) != 2 {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("    %s ||--o{ %s : %s\n", parts[0], entity.Name, field.Name))
+	}
+
+	for _, fk := range entity.ForeignKeys() {
+		if fk.References == "" || len(fk.Fields) == 0 {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("    %s ||--o{ %s : %q\n", fk.References, entity.Name, strings.Join(fk.Fields, ", ")))
+	}
+
+	return sb.String()
+}
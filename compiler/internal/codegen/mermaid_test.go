@@ -0,0 +1,26 @@
+package codegen
+
+import "testing"
+
+func TestMermaidGolden(t *testing.T) {
+	file := mustParse(t, `
+package aurora.calendar;
+
+entity Calendar {
+    @pk id: string;
+    @unique name: string;
+    color: string?;
+}
+
+entity Event {
+    @pk id: string;
+    @fk(Calendar.id) @ondelete(cascade) calendar_id: string;
+    title: string;
+    start_date: timestamp;
+    end_date: timestamp?;
+}
+`)
+
+	code := generateOne(t, NewMermaidGenerator(), file)
+	assertGolden(t, "mermaid/calendar.mmd.golden", code)
+}
@@ -0,0 +1,190 @@
+package codegen
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aurora/dataproto/internal/parser"
+)
+
+// ddlGenerator is the part of a DDL generator DiffDDL builds statements with.
+type ddlGenerator interface {
+	generateTable(file *parser.File, entity *parser.EntityDecl) (string, error)
+	generateIndexes(entity *parser.EntityDecl) (string, error)
+	generateColumn(field *parser.FieldDecl, compositePK bool) string
+	columnChecks(file *parser.File, field *parser.FieldDecl) []string
+	columnType(field *parser.FieldDecl) string
+	ident(name string) string
+}
+
+// DiffDDL returns the statements migrating a database created from the
+// schema from to the schema to, using the generators' default options.
+// Entities are matched by name and fields by name or, for a field annotated
+// @renamed_from("old"), by its previous name. New tables are created with
+// their indexes, renamed columns renamed, new columns added with their
+// constraints and indexes, and columns whose SQL type changed altered.
+// A new non-nullable column needs a @default to fill the existing rows.
+// Removed columns are dropped last, once every other statement has run.
+// Removed tables and views are left alone.
+//
+// SQLite cannot change a column's type in place, so a type change is an
+// error there; MySQL has no DDL generator and is not supported.
+func DiffDDL(from, to *parser.File, dialect Dialect) ([]string, error) {
+	from, to = concreteFile(from), concreteFile(to)
+	var g ddlGenerator
+	var backends []string
+	switch dialect {
+	case DialectSQLite:
+		g = NewSQLiteGenerator()
+		backends = []string{"sqlite"}
+	case DialectPostgres:
+		g = NewPostgresGenerator()
+		backends = []string{"postgres", "postgresql"}
+	default:
+		return nil, fmt.Errorf("DiffDDL does not support the %s dialect", dialect)
+	}
+
+	var stmts, drops []string
+	for _, entity := range to.Entities {
+		if entity.View() != nil || !onBackend(entity, backends) {
+			continue
+		}
+
+		old := from.Entity(entity.Name)
+		if old == nil || old.View() != nil || !onBackend(old, backends) {
+			ddl, err := g.generateTable(to, entity)
+			if err != nil {
+				return nil, err
+			}
+			indexes, err := g.generateIndexes(entity)
+			if err != nil {
+				return nil, err
+			}
+			stmts = append(stmts, strings.TrimSpace(ddl+indexes))
+			continue
+		}
+
+		tableName := entity.TableName()
+		if tableName == "" {
+			tableName = ToSnakeCase(entity.Name)
+		}
+		table := schemaTable(dialect, entity.SchemaName(), tableName, g.ident)
+
+		kept := make(map[string]bool)
+		for _, field := range entity.Fields {
+			prev := old.Field(field.Name)
+			if prev == nil && field.RenamedFrom() != "" && entity.Field(field.RenamedFrom()) == nil {
+				if prev = old.Field(field.RenamedFrom()); prev != nil {
+					stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s;",
+						table, g.ident(ToSnakeCase(prev.Name)), g.ident(ToSnakeCase(field.Name))))
+				}
+			}
+			if prev == nil {
+				added, err := addColumn(g, dialect, to, table, tableName, field)
+				if err != nil {
+					return nil, err
+				}
+				stmts = append(stmts, added...)
+				continue
+			}
+			kept[prev.Name] = true
+
+			oldType, newType := g.columnType(prev), g.columnType(field)
+			if oldType == newType {
+				continue
+			}
+			if dialect == DialectSQLite {
+				return nil, fmt.Errorf("sqlite cannot change the type of column %s.%s from %s to %s; recreate the table",
+					tableName, ToSnakeCase(field.Name), oldType, newType)
+			}
+			col := g.ident(ToSnakeCase(field.Name))
+			stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s USING %s::%s;",
+				table, col, newType, col, newType))
+		}
+
+		for _, field := range old.Fields {
+			if kept[field.Name] {
+				continue
+			}
+			col := g.ident(ToSnakeCase(field.Name))
+			if dialect == DialectPostgres {
+				drops = append(drops, fmt.Sprintf("ALTER TABLE %s DROP COLUMN IF EXISTS %s;", table, col))
+			} else {
+				drops = append(drops, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;", table, col))
+			}
+		}
+	}
+	return append(stmts, drops...), nil
+}
+
+// addColumn returns the statements adding field to an existing table: the
+// column itself with the CHECK and foreign key constraints a new table
+// gives it, then its @unique constraint and @indexed index, which ADD COLUMN
+// cannot carry. SQLite declares the constraints on the column; Postgres adds
+// them to the table, under the names a new table would use. Existing rows
+// need a value for the new column, so a non-nullable column without a
+// @default is an error.
+func addColumn(g ddlGenerator, dialect Dialect, file *parser.File, table, tableName string, field *parser.FieldDecl) ([]string, error) {
+	_, _, generated := field.Generated()
+	_, computed := computedSQL(field, dialect, g.ident, TimestampEpochMillis)
+	if notNullColumn(field) && field.GetAnnotation("default") == nil && !generated && !computed {
+		return nil, fmt.Errorf("cannot add required column %s.%s to existing rows; give it a @default or make it optional",
+			tableName, ToSnakeCase(field.Name))
+	}
+
+	colName := ToSnakeCase(field.Name)
+	column := g.generateColumn(field, false)
+	var constraints []string
+	for _, cond := range g.columnChecks(file, field) {
+		if dialect == DialectPostgres {
+			constraints = append(constraints, fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT ck_%s_%s CHECK (%s);",
+				table, tableName, colName, cond))
+		} else {
+			column += fmt.Sprintf(" CHECK (%s)", cond)
+		}
+	}
+	if fk, ok := fieldForeignKey(file, field); ok {
+		refTable := schemaTable(dialect, fk.RefSchema, fk.RefTable, g.ident)
+		if dialect == DialectPostgres {
+			constraints = append(constraints, fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT fk_%s_%s FOREIGN KEY (%s) REFERENCES %s(%s) ON DELETE %s;",
+				table, tableName, colName, g.ident(colName), refTable, joinIdents(fk.RefColumns, g.ident), fk.OnDelete))
+		} else {
+			column += fmt.Sprintf(" REFERENCES %s(%s) ON DELETE %s", refTable, joinIdents(fk.RefColumns, g.ident), fk.OnDelete)
+		}
+	}
+
+	stmts := []string{fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s;", table, column)}
+	stmts = append(stmts, constraints...)
+	if field.IsUnique() && !field.IsPrimaryKey() {
+		if dialect == DialectPostgres {
+			stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT uq_%s_%s UNIQUE (%s);",
+				table, tableName, colName, g.ident(colName)))
+		} else {
+			// SQLite cannot add a constraint to a table, only a unique index
+			stmts = append(stmts, fmt.Sprintf("CREATE UNIQUE INDEX IF NOT EXISTS uq_%s_%s ON %s (%s);",
+				tableName, colName, table, g.ident(colName)))
+		}
+	}
+	if field.IsIndexed() && !field.IsPrimaryKey() {
+		stmts = append(stmts, fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_%s ON %s (%s);",
+			tableName, colName, table, g.ident(colName)))
+	}
+	return stmts, nil
+}
+
+// onBackend reports whether entity is stored on one of backends, as every
+// entity without @backends is.
+func onBackend(entity *parser.EntityDecl, backends []string) bool {
+	names := entity.Backends()
+	if len(names) == 0 {
+		return true
+	}
+	for _, name := range names {
+		for _, backend := range backends {
+			if name == backend {
+				return true
+			}
+		}
+	}
+	return false
+}
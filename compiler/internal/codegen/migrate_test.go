@@ -0,0 +1,239 @@
+package codegen
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const migrateV1 = `
+package test;
+
+@table("tasks")
+entity Task {
+    @pk id: string;
+    title: string;
+    priority: int32;
+    legacy: string?;
+}
+`
+
+func TestDiffDDLAddColumn(t *testing.T) {
+	v2 := mustParse(t, `
+package test;
+
+@table("tasks")
+entity Task {
+    @pk id: string;
+    title: string;
+    priority: int32;
+    legacy: string?;
+    notes: string?;
+    @default(0) rank: int32;
+}
+`)
+	for _, tt := range []struct {
+		dialect Dialect
+		want    []string
+	}{
+		{DialectSQLite, []string{
+			"ALTER TABLE tasks ADD COLUMN notes TEXT;",
+			"ALTER TABLE tasks ADD COLUMN rank INTEGER NOT NULL DEFAULT 0;",
+		}},
+		{DialectPostgres, []string{
+			"ALTER TABLE tasks ADD COLUMN notes TEXT;",
+			"ALTER TABLE tasks ADD COLUMN rank INTEGER NOT NULL DEFAULT 0;",
+		}},
+	} {
+		got, err := DiffDDL(mustParse(t, migrateV1), v2, tt.dialect)
+		if err != nil {
+			t.Fatalf("%s: DiffDDL error: %v", tt.dialect, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.dialect, got, tt.want)
+		}
+	}
+}
+
+func TestDiffDDLAddIndexedColumns(t *testing.T) {
+	v2 := mustParse(t, `
+package test;
+
+@table("tasks")
+entity Task {
+    @pk id: string;
+    title: string;
+    priority: int32;
+    legacy: string?;
+    @unique slug: string?;
+    @indexed ownerId: string?;
+}
+`)
+	for _, tt := range []struct {
+		dialect Dialect
+		want    []string
+	}{
+		{DialectSQLite, []string{
+			"ALTER TABLE tasks ADD COLUMN slug TEXT;",
+			"CREATE UNIQUE INDEX IF NOT EXISTS uq_tasks_slug ON tasks (slug);",
+			"ALTER TABLE tasks ADD COLUMN owner_id TEXT;",
+			"CREATE INDEX IF NOT EXISTS idx_tasks_owner_id ON tasks (owner_id);",
+		}},
+		{DialectPostgres, []string{
+			"ALTER TABLE tasks ADD COLUMN slug TEXT;",
+			"ALTER TABLE tasks ADD CONSTRAINT uq_tasks_slug UNIQUE (slug);",
+			"ALTER TABLE tasks ADD COLUMN owner_id TEXT;",
+			"CREATE INDEX IF NOT EXISTS idx_tasks_owner_id ON tasks (owner_id);",
+		}},
+	} {
+		got, err := DiffDDL(mustParse(t, migrateV1), v2, tt.dialect)
+		if err != nil {
+			t.Fatalf("%s: DiffDDL error: %v", tt.dialect, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.dialect, got, tt.want)
+		}
+	}
+}
+
+func TestDiffDDLAddConstrainedColumns(t *testing.T) {
+	v2 := mustParse(t, `
+package test;
+
+enum State {
+    OPEN = 0;
+    DONE = 1;
+}
+
+entity Project {
+    @pk id: string;
+}
+
+@table("tasks")
+entity Task {
+    @pk id: string;
+    title: string;
+    priority: int32;
+    legacy: string?;
+    state: State?;
+    @fk("Project.id") @ondelete("cascade") projectId: string?;
+    @range(0, 5) stars: int32?;
+}
+`)
+	for _, tt := range []struct {
+		dialect Dialect
+		want    []string
+	}{
+		{DialectSQLite, []string{
+			"ALTER TABLE tasks ADD COLUMN state TEXT CHECK (state IN ('OPEN', 'DONE'));",
+			"ALTER TABLE tasks ADD COLUMN project_id TEXT REFERENCES project(id) ON DELETE CASCADE;",
+			"ALTER TABLE tasks ADD COLUMN stars INTEGER CHECK (stars >= 0 AND stars <= 5);",
+		}},
+		{DialectPostgres, []string{
+			"ALTER TABLE tasks ADD COLUMN state TEXT;",
+			"ALTER TABLE tasks ADD CONSTRAINT ck_tasks_state CHECK (state IN ('OPEN', 'DONE'));",
+			"ALTER TABLE tasks ADD COLUMN project_id TEXT;",
+			"ALTER TABLE tasks ADD CONSTRAINT fk_tasks_project_id FOREIGN KEY (project_id) REFERENCES project(id) ON DELETE CASCADE;",
+			"ALTER TABLE tasks ADD COLUMN stars INTEGER;",
+			"ALTER TABLE tasks ADD CONSTRAINT ck_tasks_stars CHECK (stars >= 0 AND stars <= 5);",
+		}},
+	} {
+		from := mustParse(t, migrateV1)
+		got, err := DiffDDL(from, v2, tt.dialect)
+		if err != nil {
+			t.Fatalf("%s: DiffDDL error: %v", tt.dialect, err)
+		}
+		// The new Project table comes first
+		if len(got) == 0 || !strings.HasPrefix(got[0], "CREATE TABLE IF NOT EXISTS project") {
+			t.Fatalf("%s: expected the project table first, got %q", tt.dialect, got)
+		}
+		if !reflect.DeepEqual(got[1:], tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.dialect, got[1:], tt.want)
+		}
+	}
+}
+
+func TestDiffDDLAddRequiredColumn(t *testing.T) {
+	v2 := mustParse(t, `
+package test;
+
+@table("tasks")
+entity Task {
+    @pk id: string;
+    title: string;
+    priority: int32;
+    legacy: string?;
+    owner: string;
+}
+`)
+	for _, dialect := range []Dialect{DialectSQLite, DialectPostgres} {
+		_, err := DiffDDL(mustParse(t, migrateV1), v2, dialect)
+		if err == nil || !strings.Contains(err.Error(), "tasks.owner") {
+			t.Errorf("%s: expected an error for the required column, got %v", dialect, err)
+		}
+	}
+}
+
+func TestDiffDDLChangeType(t *testing.T) {
+	v2 := mustParse(t, `
+package test;
+
+@table("tasks")
+entity Task {
+    @pk id: string;
+    title: string;
+    priority: double;
+    legacy: string?;
+}
+`)
+	got, err := DiffDDL(mustParse(t, migrateV1), v2, DialectPostgres)
+	if err != nil {
+		t.Fatalf("DiffDDL error: %v", err)
+	}
+	want := []string{"ALTER TABLE tasks ALTER COLUMN priority TYPE DOUBLE PRECISION USING priority::DOUBLE PRECISION;"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if _, err := DiffDDL(mustParse(t, migrateV1), v2, DialectSQLite); err == nil ||
+		!strings.Contains(err.Error(), "cannot change the type of column tasks.priority") {
+		t.Errorf("Expected SQLite type change error, got %v", err)
+	}
+}
+
+func TestDiffDDLRenameAndDrop(t *testing.T) {
+	v2 := mustParse(t, `
+package test;
+
+@table("tasks")
+entity Task {
+    @pk id: string;
+    @renamed_from("title") name: string;
+    priority: int32;
+    done: bool?;
+}
+
+entity Tag {
+    @pk id: string;
+    @indexed label: string;
+}
+`)
+	got, err := DiffDDL(mustParse(t, migrateV1), v2, DialectPostgres)
+	if err != nil {
+		t.Fatalf("DiffDDL error: %v", err)
+	}
+	want := []string{
+		"ALTER TABLE tasks RENAME COLUMN title TO name;",
+		"ALTER TABLE tasks ADD COLUMN done BOOLEAN;",
+		"CREATE TABLE IF NOT EXISTS tag (\n    id TEXT PRIMARY KEY,\n    label TEXT NOT NULL\n);\n" +
+			"CREATE INDEX IF NOT EXISTS idx_tag_label ON tag (label);",
+		"ALTER TABLE tasks DROP COLUMN IF EXISTS legacy;",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if _, err := DiffDDL(mustParse(t, migrateV1), v2, DialectMySQL); err == nil {
+		t.Error("Expected an error for the MySQL dialect")
+	}
+}
@@ -27,6 +27,11 @@ func NewMongoDBGenerator() *MongoDBGenerator {
 
 // Generate generates MongoDB schema and setup code from a DataProto file.
 func (g *MongoDBGenerator) Generate(file *parser.File) (map[string]string, error) {
+	file = concreteFile(file)
+	// The database name is per file; set it on a copy as g may be shared
+	cp := *g
+	g = &cp
+
 	result := make(map[string]string)
 
 	// Set database name from package
@@ -109,47 +114,21 @@ func (g *MongoDBGenerator) generateFieldSchema(field *parser.FieldDecl) string {
 	}
 
 	// Add constraints
-	if lenAnno := field.GetAnnotation("length"); lenAnno != nil {
-		if len(lenAnno.Args) > 0 {
-			if min, ok := lenAnno.Args[0].Value.(int64); ok {
-				sb.WriteString(fmt.Sprintf(",\n        \"minLength\": %d", min))
-			}
+	if lc, ok := field.LengthConstraint(); ok {
+		if lc.Min != nil {
+			sb.WriteString(fmt.Sprintf(",\n        \"minLength\": %d", *lc.Min))
 		}
-		if len(lenAnno.Args) > 1 {
-			if max, ok := lenAnno.Args[1].Value.(int64); ok {
-				sb.WriteString(fmt.Sprintf(",\n        \"maxLength\": %d", max))
-			}
-		}
-		// Handle @length(max: N) syntax
-		for _, arg := range lenAnno.Args {
-			if arg.Name == "max" {
-				if max, ok := arg.Value.(int64); ok {
-					sb.WriteString(fmt.Sprintf(",\n        \"maxLength\": %d", max))
-				}
-			}
-			if arg.Name == "min" {
-				if min, ok := arg.Value.(int64); ok {
-					sb.WriteString(fmt.Sprintf(",\n        \"minLength\": %d", min))
-				}
-			}
+		if lc.Max != nil {
+			sb.WriteString(fmt.Sprintf(",\n        \"maxLength\": %d", *lc.Max))
 		}
 	}
 
-	if patternAnno := field.GetAnnotation("pattern"); patternAnno != nil && len(patternAnno.Args) > 0 {
-		if pattern, ok := patternAnno.Args[0].Value.(string); ok {
-			sb.WriteString(fmt.Sprintf(",\n        \"pattern\": \"%s\"", escapeJSONString(pattern)))
-		}
+	min, max := field.NumericBounds()
+	if min != nil {
+		sb.WriteString(fmt.Sprintf(",\n        \"minimum\": %s", formatBound(*min)))
 	}
-
-	if rangeAnno := field.GetAnnotation("range"); rangeAnno != nil {
-		if len(rangeAnno.Args) >= 2 {
-			if min, ok := rangeAnno.Args[0].Value.(int64); ok {
-				sb.WriteString(fmt.Sprintf(",\n        \"minimum\": %d", min))
-			}
-			if max, ok := rangeAnno.Args[1].Value.(int64); ok {
-				sb.WriteString(fmt.Sprintf(",\n        \"maximum\": %d", max))
-			}
-		}
+	if max != nil {
+		sb.WriteString(fmt.Sprintf(",\n        \"maximum\": %s", formatBound(*max)))
 	}
 
 	sb.WriteString("\n      }")
@@ -274,7 +253,10 @@ func (g *MongoDBGenerator) generatePythonRepository(file *parser.File) string {
 
 	sb.WriteString("# Code generated by dataprotoc. DO NOT EDIT.\n\n")
 	sb.WriteString("from typing import Optional, List, Dict, Any\n")
-	sb.WriteString("from dataclasses import dataclass, asdict\n")
+	sb.WriteString("from dataclasses import dataclass, asdict, field\n")
+	if fields, params := pythonNowDefaults(file); fields || params {
+		sb.WriteString("import time\n")
+	}
 	sb.WriteString("from pymongo import MongoClient\n")
 	sb.WriteString("from pymongo.collection import Collection\n")
 	sb.WriteString("from bson import ObjectId\n\n")
@@ -310,7 +292,11 @@ func (g *MongoDBGenerator) generatePythonDataclass(entity *parser.EntityDecl) st
 			pyType = fmt.Sprintf("Optional[%s]", pyType)
 			sb.WriteString(fmt.Sprintf("    %s: %s = None\n", fieldName, pyType))
 		} else if def := field.GetAnnotation("default"); def != nil && len(def.Args) > 0 {
-			defaultVal := g.pythonDefaultValue(def.Args[0].Value)
+			defaultVal := g.pythonDefaultValue(def.Args[0].Value, field.Type.Name)
+			if isNowValue(def.Args[0].Value, field.Type.Name) {
+				// A plain default would be computed once, at import
+				defaultVal = fmt.Sprintf("field(default_factory=lambda: %s)", pythonNowMillis)
+			}
 			sb.WriteString(fmt.Sprintf("    %s: %s = %s\n", fieldName, pyType, defaultVal))
 		} else {
 			sb.WriteString(fmt.Sprintf("    %s: %s\n", fieldName, pyType))
@@ -344,18 +330,21 @@ func (g *MongoDBGenerator) generatePythonRepoClass(entity *parser.EntityDecl) st
 	sb.WriteString("    def __init__(self, db):\n")
 	sb.WriteString(fmt.Sprintf("        self.collection: Collection = db['%s']\n\n", collectionName))
 
-	// Upsert
-	sb.WriteString(fmt.Sprintf("    def upsert(self, entity: %s) -> None:\n", entity.Name))
-	sb.WriteString("        doc = asdict(entity)\n")
-	if pkField != nil {
-		pkName := ToSnakeCase(pkField.Name)
-		sb.WriteString(fmt.Sprintf("        self.collection.update_one(\n"))
-		sb.WriteString(fmt.Sprintf("            {'%s': entity.%s},\n", pkName, pkName))
-		sb.WriteString("            {'$set': doc},\n")
-		sb.WriteString("            upsert=True\n")
-		sb.WriteString("        )\n\n")
-	} else {
-		sb.WriteString("        self.collection.insert_one(doc)\n\n")
+	// Upsert; a view is read-only
+	readOnly := entity.View() != nil
+	if !readOnly {
+		sb.WriteString(fmt.Sprintf("    def upsert(self, entity: %s) -> None:\n", entity.Name))
+		sb.WriteString("        doc = asdict(entity)\n")
+		if pkField != nil {
+			pkName := ToSnakeCase(pkField.Name)
+			sb.WriteString(fmt.Sprintf("        self.collection.update_one(\n"))
+			sb.WriteString(fmt.Sprintf("            {'%s': entity.%s},\n", pkName, pkName))
+			sb.WriteString("            {'$set': doc},\n")
+			sb.WriteString("            upsert=True\n")
+			sb.WriteString("        )\n\n")
+		} else {
+			sb.WriteString("        self.collection.insert_one(doc)\n\n")
+		}
 	}
 
 	// Find by ID
@@ -380,7 +369,7 @@ func (g *MongoDBGenerator) generatePythonRepoClass(entity *parser.EntityDecl) st
 	sb.WriteString("        return results\n\n")
 
 	// Delete
-	if pkField != nil {
+	if pkField != nil && !readOnly {
 		pkName := ToSnakeCase(pkField.Name)
 		pkType := g.pythonType(pkField.Type.Name)
 		sb.WriteString(fmt.Sprintf("    def delete(self, %s: %s) -> bool:\n", pkName, pkType))
@@ -404,15 +393,30 @@ func (g *MongoDBGenerator) generatePythonQueryMethod(entity *parser.EntityDecl,
 
 	for _, p := range query.Params {
 		pyType := g.pythonType(p.Type.Name)
-		if p.Default != nil {
+		if isNowValue(p.Default, p.Type.Name) {
+			// A default argument would be computed once; None is replaced
+			// by the current time below
+			sb.WriteString(fmt.Sprintf(", %s: Optional[%s] = None", ToSnakeCase(p.Name), pyType))
+		} else if p.Default != nil {
 			sb.WriteString(fmt.Sprintf(", %s: %s = %s",
-				ToSnakeCase(p.Name), pyType, g.pythonDefaultValue(p.Default)))
+				ToSnakeCase(p.Name), pyType, g.pythonDefaultValue(p.Default, p.Type.Name)))
 		} else {
 			sb.WriteString(fmt.Sprintf(", %s: %s", ToSnakeCase(p.Name), pyType))
 		}
 	}
 
-	sb.WriteString(fmt.Sprintf(") -> List[%s]:\n", entity.Name))
+	if query.ReturnsOne() {
+		sb.WriteString(fmt.Sprintf(") -> Optional[%s]:\n", entity.Name))
+	} else {
+		sb.WriteString(fmt.Sprintf(") -> List[%s]:\n", entity.Name))
+	}
+	for _, p := range query.Params {
+		if isNowValue(p.Default, p.Type.Name) {
+			paramName := ToSnakeCase(p.Name)
+			sb.WriteString(fmt.Sprintf("        if %s is None:\n", paramName))
+			sb.WriteString(fmt.Sprintf("            %s = %s\n", paramName, pythonNowMillis))
+		}
+	}
 
 	// Build MongoDB query filter from WHERE clause
 	sb.WriteString("        query_filter = {}\n")
@@ -420,22 +424,34 @@ func (g *MongoDBGenerator) generatePythonQueryMethod(entity *parser.EntityDecl,
 		sb.WriteString(g.generateMongoQueryFilter(query.Where, "        "))
 	}
 
-	sb.WriteString("        cursor = self.collection.find(query_filter)")
-
 	// Sort
-	if len(query.OrderBy) > 0 {
-		sb.WriteString(".sort([")
-		for i, o := range query.OrderBy {
-			if i > 0 {
-				sb.WriteString(", ")
-			}
-			dir := "1"
-			if o.Descending {
-				dir = "-1"
-			}
-			sb.WriteString(fmt.Sprintf("('%s', %s)", ToSnakeCase(o.Field), dir))
+	var sort []string
+	for _, o := range query.OrderBy {
+		dir := "1"
+		if o.Descending {
+			dir = "-1"
 		}
-		sb.WriteString("])")
+		sort = append(sort, fmt.Sprintf("('%s', %s)", ToSnakeCase(o.Field), dir))
+	}
+
+	if query.ReturnsOne() {
+		// @returns(one) queries fetch the first matching document
+		if len(sort) > 0 {
+			sb.WriteString(fmt.Sprintf("        doc = self.collection.find_one(query_filter, sort=[%s])\n",
+				strings.Join(sort, ", ")))
+		} else {
+			sb.WriteString("        doc = self.collection.find_one(query_filter)\n")
+		}
+		sb.WriteString("        if doc is None:\n")
+		sb.WriteString("            return None\n")
+		sb.WriteString("        doc.pop('_id', None)\n")
+		sb.WriteString(fmt.Sprintf("        return %s(**doc)\n\n", entity.Name))
+		return sb.String()
+	}
+
+	sb.WriteString("        cursor = self.collection.find(query_filter)")
+	if len(sort) > 0 {
+		sb.WriteString(fmt.Sprintf(".sort([%s])", strings.Join(sort, ", ")))
 	}
 
 	// Limit
@@ -446,7 +462,13 @@ func (g *MongoDBGenerator) generatePythonQueryMethod(entity *parser.EntityDecl,
 				sb.WriteString(fmt.Sprintf(".limit(%d)", val))
 			}
 		case *parser.IdentExpr:
-			sb.WriteString(fmt.Sprintf(".limit(%s)", ToSnakeCase(l.Name)))
+			limit := ToSnakeCase(l.Name)
+			if param := query.Param(l.Name); param != nil {
+				if max, ok := param.MaxValue(); ok {
+					limit = fmt.Sprintf("min(%s, %d)", limit, max)
+				}
+			}
+			sb.WriteString(fmt.Sprintf(".limit(%s)", limit))
 		}
 	}
 
@@ -509,8 +531,14 @@ func (g *MongoDBGenerator) pythonType(typeName string) string {
 	}
 }
 
-func (g *MongoDBGenerator) pythonDefaultValue(value interface{}) string {
-	switch v := value.(type) {
+// pythonDefaultValue returns the Python literal of a default. Timestamps are
+// epoch milliseconds: now is the current time, and an RFC 3339 literal is
+// converted.
+func (g *MongoDBGenerator) pythonDefaultValue(value interface{}, typeName string) string {
+	if isNowValue(value, typeName) {
+		return pythonNowMillis
+	}
+	switch v := epochDefault(value, typeName).(type) {
 	case string:
 		return fmt.Sprintf("'%s'", v)
 	case bool:
@@ -0,0 +1,72 @@
+package codegen
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMongoDBLengthConstraints(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+entity User {
+    @pk id: string;
+    @length(5) code: string;
+    @length(max: 100) bio: string;
+    @length(2, 30) name: string;
+}
+`)
+
+	out, err := NewMongoDBGenerator().Generate(file)
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+
+	schema := out["user_schema.json"]
+	for _, want := range []string{
+		"\"code\": {\n        \"bsonType\": \"string\",\n        \"maxLength\": 5\n      }",
+		"\"bio\": {\n        \"bsonType\": \"string\",\n        \"maxLength\": 100\n      }",
+		"\"name\": {\n        \"bsonType\": \"string\",\n        \"minLength\": 2,\n        \"maxLength\": 30\n      }",
+	} {
+		if !strings.Contains(schema, want) {
+			t.Errorf("Expected %q in schema, got:\n%s", want, schema)
+		}
+	}
+}
+
+func TestMongoDBTimestampDefaults(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+entity Event {
+    @pk id: string;
+    @default(now) createdAt: timestamp;
+    @default("2024-01-02T03:04:05Z") epoch: timestamp;
+
+    query before(cutoff: timestamp = now) {
+        where createdAt < cutoff
+    }
+}
+`)
+
+	out, err := NewMongoDBGenerator().Generate(file)
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+
+	code := out["repositories.py"]
+	for _, want := range []string{
+		"import time\n",
+		"    created_at: int = field(default_factory=lambda: int(time.time() * 1000))\n",
+		"    epoch: int = 1704164645000\n",
+		"    def before(self, cutoff: Optional[int] = None) -> List[Event]:\n" +
+			"        if cutoff is None:\n            cutoff = int(time.time() * 1000)\n",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("Expected %q in output, got:\n%s", want, code)
+		}
+	}
+	if strings.Contains(code, "'now'") || strings.Contains(code, "'2024-") {
+		t.Errorf("Expected no string timestamp defaults, got:\n%s", code)
+	}
+}
@@ -0,0 +1,227 @@
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/aurora/dataproto/internal/parser"
+)
+
+// OpenAPIGenerator generates an OpenAPI 3.1 document describing services as
+// the JSON endpoints a gRPC gateway exposes: one POST operation per rpc at
+// /<package>.<Service>/<Method>.
+type OpenAPIGenerator struct {
+	// Version is the document's info.version; defaults to 1.0.0
+	Version string
+}
+
+// NewOpenAPIGenerator creates a new OpenAPIGenerator.
+func NewOpenAPIGenerator() *OpenAPIGenerator {
+	return &OpenAPIGenerator{}
+}
+
+type openAPIDocument struct {
+	OpenAPI    string                       `json:"openapi"`
+	Info       openAPIInfo                  `json:"info"`
+	Paths      jsonObject[*openAPIPathItem] `json:"paths"`
+	Components openAPIComponents            `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openAPIPathItem struct {
+	Post *openAPIOperation `json:"post"`
+}
+
+type openAPIOperation struct {
+	OperationID string                   `json:"operationId"`
+	Tags        []string                 `json:"tags"`
+	Description string                   `json:"description,omitempty"`
+	RequestBody *openAPIBody             `json:"requestBody"`
+	Responses   jsonObject[*openAPIBody] `json:"responses"`
+}
+
+type openAPIBody struct {
+	Description string                        `json:"description,omitempty"`
+	Required    bool                          `json:"required,omitempty"`
+	Content     jsonObject[*openAPIMediaType] `json:"content"`
+}
+
+type openAPIMediaType struct {
+	Schema *jsonSchema `json:"schema"`
+}
+
+type openAPIComponents struct {
+	Schemas jsonProperties `json:"schemas"`
+}
+
+// Generate generates a single <package>.openapi.json file.
+func (g *OpenAPIGenerator) Generate(file *parser.File) (map[string]string, error) {
+	file = concreteFile(file)
+	result := make(map[string]string)
+
+	enums := make(map[string]*parser.EnumDecl)
+	for _, enum := range file.Enums {
+		enums[enum.Name] = enum
+	}
+	// Declared messages are described like entities
+	types := append([]*parser.EntityDecl(nil), file.Entities...)
+	for _, msg := range file.Messages {
+		types = append(types, messageEntity(msg))
+	}
+	entities := make(map[string]*parser.EntityDecl)
+	for _, entity := range types {
+		entities[entity.Name] = entity
+	}
+
+	title := "schema"
+	pathPrefix := "/"
+	if file.Package != nil {
+		title = file.Package.Name
+		pathPrefix = "/" + file.Package.Name + "."
+	}
+	version := g.Version
+	if version == "" {
+		version = "1.0.0"
+	}
+
+	doc := &openAPIDocument{
+		OpenAPI: "3.1.0",
+		Info:    openAPIInfo{Title: title, Version: version},
+		Paths:   jsonObject[*openAPIPathItem]{},
+	}
+
+	// Messages used by rpcs but not declared as entities still need a schema
+	// for their refs to resolve
+	var undeclared []string
+	seen := make(map[string]bool)
+	for _, svc := range file.Services {
+		for _, method := range svc.Methods {
+			doc.Paths = append(doc.Paths, jsonMember[*openAPIPathItem]{
+				Name:  pathPrefix + svc.Name + "/" + method.Name,
+				Value: &openAPIPathItem{Post: g.generateOperation(svc, method)},
+			})
+			for _, name := range []string{method.RequestType.Name, method.ResponseType.Name} {
+				if _, ok := entities[name]; !ok && !seen[name] {
+					seen[name] = true
+					undeclared = append(undeclared, name)
+				}
+			}
+		}
+	}
+
+	schemas := jsonProperties{}
+	for _, enum := range file.Enums {
+		schema := &jsonSchema{Type: "string"}
+		for _, val := range enum.Values {
+			schema.Enum = append(schema.Enum, val.Name)
+		}
+		schemas = append(schemas, jsonMember[*jsonSchema]{Name: enum.Name, Value: schema})
+	}
+	for _, entity := range types {
+		schemas = append(schemas, jsonMember[*jsonSchema]{Name: entity.Name, Value: g.entitySchema(entity, enums, entities)})
+	}
+	for _, name := range undeclared {
+		schemas = append(schemas, jsonMember[*jsonSchema]{Name: name, Value: &jsonSchema{
+			Description: fmt.Sprintf("%s is not declared in this schema.", name),
+			Type:        "object",
+		}})
+	}
+	doc.Components.Schemas = schemas
+
+	var buf bytes.Buffer
+	if err := encodeJSON(&buf, doc, "  "); err != nil {
+		return nil, err
+	}
+
+	pkgName := "schema"
+	if file.Package != nil {
+		parts := strings.Split(file.Package.Name, ".")
+		pkgName = strings.ToLower(parts[len(parts)-1])
+	}
+
+	result[pkgName+".openapi.json"] = buf.String()
+	return result, nil
+}
+
+// generateOperation maps an rpc to a POST operation. The gateway carries
+// streams as newline-delimited JSON, which the description points out.
+func (g *OpenAPIGenerator) generateOperation(svc *parser.ServiceDecl, method *parser.RpcDecl) *openAPIOperation {
+	op := &openAPIOperation{
+		OperationID: svc.Name + "_" + method.Name,
+		Tags:        []string{svc.Name},
+		RequestBody: &openAPIBody{
+			Required: true,
+			Content:  jsonContent(method.RequestType.Name),
+		},
+	}
+
+	var notes []string
+	if method.RequestType.Stream {
+		notes = append(notes, fmt.Sprintf("The request body is a stream of %s messages.", method.RequestType.Name))
+	}
+	if method.ResponseType.Stream {
+		notes = append(notes, fmt.Sprintf("The response is a stream of %s messages.", method.ResponseType.Name))
+	}
+	if len(notes) > 0 {
+		op.Description = fmt.Sprintf("%s rpc, streamed as newline-delimited JSON. %s",
+			method.StreamingKind(), strings.Join(notes, " "))
+	}
+
+	op.Responses = jsonObject[*openAPIBody]{{
+		Name: "200",
+		Value: &openAPIBody{
+			Description: "OK",
+			Content:     jsonContent(method.ResponseType.Name),
+		},
+	}}
+	return op
+}
+
+func jsonContent(typeName string) jsonObject[*openAPIMediaType] {
+	return jsonObject[*openAPIMediaType]{{
+		Name:  "application/json",
+		Value: &openAPIMediaType{Schema: componentRef(typeName)},
+	}}
+}
+
+func componentRef(name string) *jsonSchema {
+	return &jsonSchema{Ref: "#/components/schemas/" + name}
+}
+
+func (g *OpenAPIGenerator) entitySchema(entity *parser.EntityDecl, enums map[string]*parser.EnumDecl, entities map[string]*parser.EntityDecl) *jsonSchema {
+	schema := &jsonSchema{Type: "object", Properties: jsonProperties{}}
+	for _, field := range entity.Fields {
+		name := JSONName(field)
+		schema.Properties = append(schema.Properties,
+			jsonMember[*jsonSchema]{Name: name, Value: g.fieldSchema(field, enums, entities)})
+		if !nullableField(field) {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return schema
+}
+
+// fieldSchema inlines builtin types and refers to enum and entity components.
+// A nullable ref is wrapped in anyOf with null.
+func (g *OpenAPIGenerator) fieldSchema(field *parser.FieldDecl, enums map[string]*parser.EnumDecl, entities map[string]*parser.EntityDecl) *jsonSchema {
+	if schema := scalarFieldSchema(field); schema != nil {
+		return schema
+	}
+
+	ref := componentRef(field.Type.Name)
+	if _, ok := enums[field.Type.Name]; !ok {
+		if _, ok := entities[field.Type.Name]; !ok {
+			// Imported type; there is no component to refer to
+			ref = &jsonSchema{Type: "object"}
+		}
+	}
+	if nullableField(field) {
+		return &jsonSchema{AnyOf: []*jsonSchema{ref, {Type: "null"}}}
+	}
+	return ref
+}
@@ -0,0 +1,38 @@
+package codegen
+
+import "testing"
+
+func TestOpenAPICalendarGolden(t *testing.T) {
+	file := mustParse(t, `
+package aurora.calendar;
+
+enum EventStatus {
+    TENTATIVE = 0;
+    CONFIRMED = 1;
+}
+
+entity CalendarEvent {
+    @pk id: string;
+    @required @length(1, 200) title: string;
+    start_date: timestamp;
+    end_date: timestamp?;
+    status: EventStatus;
+    previous_status: EventStatus?;
+    @json("notesText") notes: string?;
+}
+
+entity GetEventsRequest {
+    calendar_name: string?;
+    max_results: int32?;
+}
+
+service CalendarService {
+    rpc GetEvents(GetEventsRequest) returns (stream CalendarEvent);
+    rpc SaveEvent(CalendarEvent) returns (CalendarEvent);
+    rpc PushEvents(stream CalendarEvent) returns (PushResult);
+}
+`)
+
+	code := generateOne(t, NewOpenAPIGenerator(), file)
+	assertGolden(t, "openapi/calendar.openapi.json.golden", code)
+}
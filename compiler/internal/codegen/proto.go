@@ -1,7 +1,9 @@
 package codegen
 
 import (
+	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/aurora/dataproto/internal/parser"
@@ -10,6 +12,13 @@ import (
 // ProtoGenerator generates .proto files from DataProto schemas.
 type ProtoGenerator struct {
 	PackagePrefix string // Optional package prefix
+	// TimestampMode selects int64 epoch milliseconds or
+	// google.protobuf.Timestamp for timestamp fields
+	TimestampMode TimestampMode
+	// FieldNumbers holds the field numbers emitted before, keyed by message
+	// name, as read with ParseFieldNumbers from the sidecar a previous run
+	// wrote; fields keep them and new fields are numbered after them
+	FieldNumbers map[string]FieldNumbers
 }
 
 // NewProtoGenerator creates a new ProtoGenerator.
@@ -19,11 +28,21 @@ func NewProtoGenerator() *ProtoGenerator {
 
 // Generate generates .proto file content from a DataProto file.
 func (g *ProtoGenerator) Generate(file *parser.File) (map[string]string, error) {
+	return g.GenerateWith(file, GenOptions{})
+}
+
+// GenerateWith is Generate with output options. The default indent is four
+// spaces. Next to the .proto file it writes the field numbers sidecar, named
+// after it with a .fieldnumbers.json extension, to be passed back through
+// FieldNumbers on the next run.
+func (g *ProtoGenerator) GenerateWith(file *parser.File, opts GenOptions) (map[string]string, error) {
+	file = concreteFile(file)
 	result := make(map[string]string)
 
 	var sb strings.Builder
 
 	// Header
+	sb.WriteString(opts.header("//"))
 	sb.WriteString("// Code generated by dataprotoc. DO NOT EDIT.\n")
 	sb.WriteString("// source: ")
 	if file.Package != nil {
@@ -34,19 +53,24 @@ func (g *ProtoGenerator) Generate(file *parser.File) (map[string]string, error)
 	sb.WriteString("syntax = \"proto3\";\n\n")
 
 	// Package
-	if file.Package != nil {
-		packageName := file.Package.Name
+	if packageName := opts.packageName(file); packageName != "" {
 		if g.PackagePrefix != "" {
 			packageName = g.PackagePrefix + "." + packageName
 		}
 		sb.WriteString(fmt.Sprintf("package %s;\n\n", packageName))
 	}
 
+	if g.TimestampMode == TimestampNative && usesTimestamp(file) {
+		sb.WriteString("import \"google/protobuf/timestamp.proto\";\n\n")
+	}
+
 	// Options
+	var options strings.Builder
 	for _, opt := range file.Options {
-		sb.WriteString(g.generateOption(opt))
+		options.WriteString(g.generateOption(opt))
 	}
-	if len(file.Options) > 0 {
+	if options.Len() > 0 {
+		sb.WriteString(options.String())
 		sb.WriteString("\n")
 	}
 
@@ -56,10 +80,22 @@ func (g *ProtoGenerator) Generate(file *parser.File) (map[string]string, error)
 		sb.WriteString("\n")
 	}
 
-	// Messages (from entities)
-	for _, entity := range file.Entities {
-		sb.WriteString(g.generateMessage(entity))
+	// Messages (from entities); messages no longer declared keep their
+	// numbers in the sidecar
+	sidecar := make(map[string]FieldNumbers)
+	for name, numbers := range g.FieldNumbers {
+		sidecar[name] = numbers
+	}
+	messages := append([]*parser.EntityDecl(nil), file.Entities...)
+	for _, msg := range file.Messages {
+		messages = append(messages, messageEntity(msg))
+	}
+	for _, entity := range messages {
+		previous := g.FieldNumbers[entity.Name]
+		numbers := AssignStableFieldNumbers(entity, previous)
+		sb.WriteString(g.generateMessage(entity, numbers))
 		sb.WriteString("\n")
+		sidecar[entity.Name] = keepRemovedFields(numbers, previous)
 	}
 
 	// Services
@@ -74,21 +110,35 @@ func (g *ProtoGenerator) Generate(file *parser.File) (map[string]string, error)
 		sb.WriteString(supportingTypes)
 	}
 
-	// Generate filename
-	filename := "output.proto"
-	if file.Package != nil {
-		parts := strings.Split(file.Package.Name, ".")
-		filename = parts[len(parts)-1] + ".proto"
-	}
-
-	result[filename] = sb.String()
+	filename := protoFilename(opts.packageName(file))
+	result[filename] = opts.indent(sb.String(), "    ")
+	result[strings.TrimSuffix(filename, ".proto")+".fieldnumbers.json"] = FormatFieldNumbers(sidecar)
 	return result, nil
 }
 
+// protoFilename names the .proto file generated for a package: after its
+// last segment, or output.proto without one.
+func protoFilename(packageName string) string {
+	if packageName == "" {
+		return "output.proto"
+	}
+	parts := strings.Split(packageName, ".")
+	return parts[len(parts)-1] + ".proto"
+}
+
+// generateOption renders an option statement, or nothing for a list value,
+// which a proto option cannot be assigned.
 func (g *ProtoGenerator) generateOption(opt *parser.OptionDecl) string {
 	var value string
 	switch v := opt.Value.(type) {
+	case []interface{}:
+		return ""
 	case string:
+		if opt.Ident {
+			// Enum values such as SPEED are emitted unquoted
+			value = v
+			break
+		}
 		value = fmt.Sprintf("\"%s\"", v)
 	case bool:
 		value = fmt.Sprintf("%t", v)
@@ -107,31 +157,176 @@ func (g *ProtoGenerator) generateEnum(enum *parser.EnumDecl) string {
 
 	sb.WriteString(fmt.Sprintf("enum %s {\n", enum.Name))
 
+	for _, opt := range enum.Options {
+		sb.WriteString("    " + g.generateOption(opt))
+	}
+
 	for _, val := range enum.Values {
-		sb.WriteString(fmt.Sprintf("    %s = %d;\n", val.Name, val.Number))
+		var opts string
+		if _, ok := val.Deprecated(); ok {
+			opts = " [deprecated = true]"
+		}
+		sb.WriteString(fmt.Sprintf("    %s = %d%s;\n", val.Name, val.Number, opts))
 	}
 
 	sb.WriteString("}\n")
 	return sb.String()
 }
 
-func (g *ProtoGenerator) generateMessage(entity *parser.EntityDecl) string {
+func (g *ProtoGenerator) generateMessage(entity *parser.EntityDecl, numbers FieldNumbers) string {
 	var sb strings.Builder
 
 	sb.WriteString(fmt.Sprintf("message %s {\n", entity.Name))
 
-	fieldNumber := 1
+	if _, ok := entity.Deprecated(); ok {
+		sb.WriteString("    option deprecated = true;\n")
+	}
+
+	for _, decl := range entity.Reserved {
+		sb.WriteString(g.generateReserved(decl))
+	}
+
 	for _, field := range entity.Fields {
-		sb.WriteString(g.generateField(field, fieldNumber))
-		fieldNumber++
+		sb.WriteString(g.generateField(field, numbers[field.Name]))
 	}
 
 	sb.WriteString("}\n")
 	return sb.String()
 }
 
+// messageEntity adapts a declared message to the entity shape the message
+// generators take. It has no queries, keys or reserved numbers.
+func messageEntity(msg *parser.MessageDecl) *parser.EntityDecl {
+	return &parser.EntityDecl{
+		Position:    msg.Position,
+		Annotations: msg.Annotations,
+		Name:        msg.Name,
+		Fields:      msg.Fields,
+		Reserved:    msg.Reserved,
+	}
+}
+
+// FieldNumbers maps the fields of one message to their proto numbers by
+// field name. A sidecar file keeps the numbers emitted for each message so
+// that later runs can preserve them; see ParseFieldNumbers.
+type FieldNumbers map[string]int
+
+// ParseFieldNumbers reads a sidecar of previously emitted field numbers: a
+// JSON object keyed by message name, such as {"Task": {"id": 1, "title": 2}}.
+func ParseFieldNumbers(data []byte) (map[string]FieldNumbers, error) {
+	var numbers map[string]FieldNumbers
+	if err := json.Unmarshal(data, &numbers); err != nil {
+		return nil, fmt.Errorf("field numbers: %w", err)
+	}
+	return numbers, nil
+}
+
+// FormatFieldNumbers writes a sidecar of field numbers that
+// ParseFieldNumbers reads back.
+func FormatFieldNumbers(numbers map[string]FieldNumbers) string {
+	data, _ := json.MarshalIndent(numbers, "", "  ")
+	return string(data) + "\n"
+}
+
+// keepRemovedFields returns numbers with the fields of previous that the
+// message no longer declares, so their numbers stay above the ones later
+// fields take and are never reused.
+func keepRemovedFields(numbers, previous FieldNumbers) FieldNumbers {
+	for name, n := range previous {
+		if _, ok := numbers[name]; !ok {
+			numbers[name] = n
+		}
+	}
+	return numbers
+}
+
+// AssignStableFieldNumbers assigns each field of entity its proto number.
+// Numbers given with @proto(number: N) are kept, and so are the numbers in
+// previous, the assignments emitted for the message before, unless another
+// field now claims the number or it has been reserved. The other fields take
+// the lowest numbers above every number in previous that are neither used
+// nor reserved, in declaration order, so reordering or removing fields never
+// renumbers or reuses one. A nil previous numbers from 1.
+func AssignStableFieldNumbers(entity *parser.EntityDecl, previous FieldNumbers) FieldNumbers {
+	numbers := make(FieldNumbers)
+	used := make(map[int]bool)
+	for _, field := range entity.Fields {
+		if n, ok := field.ProtoNumber(); ok {
+			numbers[field.Name] = int(n)
+			used[int(n)] = true
+		}
+	}
+
+	for _, field := range entity.Fields {
+		n, ok := previous[field.Name]
+		if _, explicit := numbers[field.Name]; explicit || !ok || used[n] || entity.IsReservedNumber(int64(n)) {
+			continue
+		}
+		numbers[field.Name] = n
+		used[n] = true
+	}
+	next := 1
+	for _, n := range previous {
+		if n >= next {
+			next = n + 1
+		}
+	}
+
+	for _, field := range entity.Fields {
+		if _, ok := numbers[field.Name]; ok {
+			continue
+		}
+		for used[next] || entity.IsReservedNumber(int64(next)) {
+			next++
+		}
+		numbers[field.Name] = next
+		used[next] = true
+	}
+	return numbers
+}
+
+// generateReserved emits a reserved statement. proto does not allow numbers
+// and names in one statement, so each gets its own line.
+func (g *ProtoGenerator) generateReserved(decl *parser.ReservedDecl) string {
+	var sb strings.Builder
+	if len(decl.Ranges) > 0 {
+		var parts []string
+		for _, r := range decl.Ranges {
+			switch {
+			case r.Start == r.End:
+				parts = append(parts, fmt.Sprintf("%d", r.Start))
+			case r.End == parser.ReservedMax:
+				parts = append(parts, fmt.Sprintf("%d to max", r.Start))
+			default:
+				parts = append(parts, fmt.Sprintf("%d to %d", r.Start, r.End))
+			}
+		}
+		sb.WriteString(fmt.Sprintf("    reserved %s;\n", strings.Join(parts, ", ")))
+	}
+	if len(decl.Names) > 0 {
+		var names []string
+		for _, name := range decl.Names {
+			names = append(names, fmt.Sprintf("\"%s\"", name))
+		}
+		sb.WriteString(fmt.Sprintf("    reserved %s;\n", strings.Join(names, ", ")))
+	}
+	return sb.String()
+}
+
+// usesTimestamp reports whether any entity in file has a timestamp field.
+func usesTimestamp(file *parser.File) bool {
+	for _, entity := range file.Entities {
+		for _, field := range entity.Fields {
+			if field.Type.Name == "timestamp" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (g *ProtoGenerator) generateField(field *parser.FieldDecl, number int) string {
-	typeMapping := GetTypeMapping(field.Type.Name)
+	typeMapping := GetTypeMappingWithMode(field.Type.Name, g.TimestampMode)
 	protoType := typeMapping.Proto
 
 	var prefix string
@@ -142,7 +337,18 @@ func (g *ProtoGenerator) generateField(field *parser.FieldDecl, number int) stri
 	// Convert field name to proto style (snake_case)
 	fieldName := ToSnakeCase(field.Name)
 
-	return fmt.Sprintf("    %s%s %s = %d;\n", prefix, protoType, fieldName, number)
+	var sb strings.Builder
+	if field.Doc != "" {
+		for _, line := range strings.Split(field.Doc, "\n") {
+			sb.WriteString(strings.TrimRight("    // "+line, " ") + "\n")
+		}
+	}
+	var opts string
+	if _, ok := field.Deprecated(); ok {
+		opts = " [deprecated = true]"
+	}
+	sb.WriteString(fmt.Sprintf("    %s%s %s = %d%s;\n", prefix, protoType, fieldName, number, opts))
+	return sb.String()
 }
 
 func (g *ProtoGenerator) generateService(svc *parser.ServiceDecl) string {
@@ -160,15 +366,23 @@ func (g *ProtoGenerator) generateService(svc *parser.ServiceDecl) string {
 
 func (g *ProtoGenerator) generateRpc(rpc *parser.RpcDecl) string {
 	reqType := rpc.RequestType.Name
-	if rpc.RequestType.Stream {
-		reqType = "stream " + reqType
-	}
-
 	respType := rpc.ResponseType.Name
-	if rpc.ResponseType.Stream {
+
+	// Place the stream keyword on the side(s) the rpc's kind streams
+	switch rpc.StreamingKind() {
+	case parser.ClientStreaming:
+		reqType = "stream " + reqType
+	case parser.ServerStreaming:
+		respType = "stream " + respType
+	case parser.BidiStreaming:
+		reqType = "stream " + reqType
 		respType = "stream " + respType
 	}
 
+	if _, ok := rpc.Deprecated(); ok {
+		return fmt.Sprintf("    rpc %s(%s) returns (%s) {\n        option deprecated = true;\n    }\n",
+			rpc.Name, reqType, respType)
+	}
 	return fmt.Sprintf("    rpc %s(%s) returns (%s);\n",
 		rpc.Name, reqType, respType)
 }
@@ -180,6 +394,9 @@ func (g *ProtoGenerator) collectSupportingTypes(file *parser.File) string {
 	for _, entity := range file.Entities {
 		definedTypes[entity.Name] = true
 	}
+	for _, msg := range file.Messages {
+		definedTypes[msg.Name] = true
+	}
 
 	// Collect all types referenced by services
 	referencedTypes := make(map[string]bool)
@@ -203,8 +420,14 @@ func (g *ProtoGenerator) collectSupportingTypes(file *parser.File) string {
 	var sb strings.Builder
 	sb.WriteString("// Supporting message types for service methods\n\n")
 
-	// Generate common types based on naming conventions
+	// Generate common types based on naming conventions, in name order so
+	// the output is stable
+	names := make([]string, 0, len(referencedTypes))
 	for typeName := range referencedTypes {
+		names = append(names, typeName)
+	}
+	sort.Strings(names)
+	for _, typeName := range names {
 		sb.WriteString(g.generateSupportingMessage(typeName, file))
 		sb.WriteString("\n")
 	}
@@ -214,6 +437,10 @@ func (g *ProtoGenerator) collectSupportingTypes(file *parser.File) string {
 
 // generateSupportingMessage generates a message definition for a service-referenced type.
 func (g *ProtoGenerator) generateSupportingMessage(typeName string, file *parser.File) string {
+	if msg := g.queryMessage(file, typeName); msg != "" {
+		return msg
+	}
+
 	var sb strings.Builder
 
 	sb.WriteString(fmt.Sprintf("message %s {\n", typeName))
@@ -261,33 +488,69 @@ func (g *ProtoGenerator) GenerateRequestMessages(entity *parser.EntityDecl) stri
 	var sb strings.Builder
 
 	for _, query := range entity.Queries {
-		// Request message
-		sb.WriteString(fmt.Sprintf("message %s%sRequest {\n",
-			entity.Name, ToPascalCase(query.Name)))
-
-		fieldNumber := 1
-		for _, param := range query.Params {
-			typeMapping := GetTypeMapping(param.Type.Name)
-			protoType := typeMapping.Proto
-
-			var prefix string
-			if param.Type.Optional || param.Default != nil {
-				prefix = "optional "
-			}
+		sb.WriteString(g.queryRequestMessage(entity, query))
+		sb.WriteString("\n")
+		sb.WriteString(g.queryResponseMessage(entity, query))
+		sb.WriteString("\n")
+	}
+
+	return sb.String()
+}
 
-			sb.WriteString(fmt.Sprintf("    %s%s %s = %d;\n",
-				prefix, protoType, ToSnakeCase(param.Name), fieldNumber))
-			fieldNumber++
+// queryMessage returns the request or response message derived from a query
+// of a local entity that is named name, or "" when no query derives it.
+// These are the <Entity><Query>Request and Response names the checker
+// accepts as rpc types.
+func (g *ProtoGenerator) queryMessage(file *parser.File, name string) string {
+	for _, entity := range file.Entities {
+		for _, query := range entity.Queries {
+			base := entity.Name + ToPascalCase(query.Name)
+			switch name {
+			case base + "Request":
+				return g.queryRequestMessage(entity, query)
+			case base + "Response":
+				return g.queryResponseMessage(entity, query)
+			}
 		}
+	}
+	return ""
+}
 
-		sb.WriteString("}\n\n")
+// queryRequestMessage generates the request message for a query, with one
+// field per parameter.
+func (g *ProtoGenerator) queryRequestMessage(entity *parser.EntityDecl, query *parser.QueryDecl) string {
+	var sb strings.Builder
 
-		// Response message (list of entities)
-		sb.WriteString(fmt.Sprintf("message %s%sResponse {\n",
-			entity.Name, ToPascalCase(query.Name)))
-		sb.WriteString(fmt.Sprintf("    repeated %s items = 1;\n", entity.Name))
-		sb.WriteString("}\n\n")
+	sb.WriteString(fmt.Sprintf("message %s%sRequest {\n",
+		entity.Name, ToPascalCase(query.Name)))
+
+	fieldNumber := 1
+	for _, param := range query.Params {
+		typeMapping := GetTypeMappingWithMode(param.Type.Name, g.TimestampMode)
+		protoType := typeMapping.Proto
+
+		var prefix string
+		if param.Nullable() || param.HasDefault() {
+			prefix = "optional "
+		}
+
+		sb.WriteString(fmt.Sprintf("    %s%s %s = %d;\n",
+			prefix, protoType, ToSnakeCase(param.Name), fieldNumber))
+		fieldNumber++
 	}
 
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// queryResponseMessage generates the response message for a query: the list
+// of entities it returns.
+func (g *ProtoGenerator) queryResponseMessage(entity *parser.EntityDecl, query *parser.QueryDecl) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("message %s%sResponse {\n",
+		entity.Name, ToPascalCase(query.Name)))
+	sb.WriteString(fmt.Sprintf("    repeated %s items = 1;\n", entity.Name))
+	sb.WriteString("}\n")
 	return sb.String()
 }
@@ -0,0 +1,397 @@
+package codegen
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aurora/dataproto/internal/parser"
+)
+
+// generateProto runs g and returns the .proto file, leaving out the field
+// numbers sidecar.
+func generateProto(t *testing.T, g *ProtoGenerator, file *parser.File) string {
+	t.Helper()
+	out, err := g.Generate(file)
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+	for name, content := range out {
+		if strings.HasSuffix(name, ".proto") {
+			return content
+		}
+	}
+	t.Fatalf("Expected a .proto file, got %d files", len(out))
+	return ""
+}
+
+func TestProtoFileOptions(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+option optimize_for = SPEED;
+option java_multiple_files = true;
+option go_package = "example.com/test";
+option java_package = "com.example.test";
+option (custom.opt).level = 2;
+option (custom.tags) = [1, 2];
+`)
+	out := generateProto(t, NewProtoGenerator(), file)
+
+	for _, want := range []string{
+		"option optimize_for = SPEED;\n",
+		"option java_multiple_files = true;\n",
+		"option go_package = \"example.com/test\";\n",
+		"option java_package = \"com.example.test\";\n",
+		"option (custom.opt).level = 2;\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in output, got:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "custom.tags") {
+		t.Errorf("Expected list-valued option to be omitted, got:\n%s", out)
+	}
+}
+
+func TestProtoDeclaredMessages(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+entity Event {
+    @pk id: string;
+}
+
+message GetEventsRequest {
+    since: timestamp?;
+    @deprecated limit: int32;
+}
+
+service Events {
+    rpc GetEvents(GetEventsRequest) returns (stream Event);
+}
+`)
+	out := generateProto(t, NewProtoGenerator(), file)
+
+	want := "message GetEventsRequest {\n" +
+		"    optional int64 since = 1;\n" +
+		"    int32 limit = 2 [deprecated = true];\n" +
+		"}\n"
+	if !strings.Contains(out, want) {
+		t.Errorf("Expected %q in output, got:\n%s", want, out)
+	}
+	if strings.Count(out, "message GetEventsRequest {") != 1 {
+		t.Errorf("Expected the declared message to replace the inferred one, got:\n%s", out)
+	}
+}
+
+func TestProtoQueryMessages(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+entity Task {
+    @pk id: string;
+    priority: int32;
+
+    query urgent(min: int32, limit: int32 = 10) {
+        where priority >= min
+    }
+}
+
+service Tasks {
+    rpc Urgent(TaskUrgentRequest) returns (TaskUrgentResponse);
+}
+`)
+	out := generateProto(t, NewProtoGenerator(), file)
+
+	for _, want := range []string{
+		"message TaskUrgentRequest {\n" +
+			"    int32 min = 1;\n" +
+			"    optional int32 limit = 2;\n" +
+			"}\n",
+		"message TaskUrgentResponse {\n" +
+			"    repeated Task items = 1;\n" +
+			"}\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in output, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestProtoStreamingKinds(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+service Sync {
+    rpc Get(GetRequest) returns (Event);
+    rpc Watch(WatchRequest) returns (stream Event);
+    rpc Upload(stream Event) returns (Result);
+    rpc Chat(stream Event) returns (stream Event);
+}
+`)
+	out := generateProto(t, NewProtoGenerator(), file)
+
+	for _, want := range []string{
+		"rpc Get(GetRequest) returns (Event);",
+		"rpc Watch(WatchRequest) returns (stream Event);",
+		"rpc Upload(stream Event) returns (Result);",
+		"rpc Chat(stream Event) returns (stream Event);",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in output, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestProtoExplicitFieldNumbers(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+entity Event {
+    @pk @proto(number: 1) id: string;
+    notes: string?;
+    @proto(number: 2) title: string;
+    @proto(number: 10) location: string;
+    color: string;
+}
+`)
+	out := generateProto(t, NewProtoGenerator(), file)
+
+	for _, want := range []string{
+		"    string id = 1;\n",
+		"    optional string notes = 3;\n",
+		"    string title = 2;\n",
+		"    string location = 10;\n",
+		"    string color = 4;\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in output, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestAssignStableFieldNumbers(t *testing.T) {
+	// v1 emitted id = 1, title = 2, done = 3, legacy = 4; v2 inserts notes
+	// before title, moves done first and removes legacy
+	previous, err := ParseFieldNumbers([]byte(`{"Task": {"id": 1, "title": 2, "done": 3, "legacy": 4}}`))
+	if err != nil {
+		t.Fatalf("ParseFieldNumbers error: %v", err)
+	}
+	file := mustParse(t, `
+package test;
+
+entity Task {
+    done: bool;
+    @pk id: string;
+    notes: string?;
+    title: string;
+    due: timestamp?;
+}
+`)
+
+	got := AssignStableFieldNumbers(file.Entities[0], previous["Task"])
+	want := FieldNumbers{"done": 3, "id": 1, "notes": 5, "title": 2, "due": 6}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	g := NewProtoGenerator()
+	g.FieldNumbers = previous
+	out := generateProto(t, g, file)
+	if !strings.Contains(out, "    optional string notes = 5;\n") {
+		t.Errorf("Expected the inserted field to be numbered after the previous ones, got:\n%s", out)
+	}
+
+	if _, err := ParseFieldNumbers([]byte(`{"Task": [1, 2]}`)); err == nil {
+		t.Error("Expected an error for a malformed sidecar")
+	}
+}
+
+func TestProtoFieldNumbersRoundTrip(t *testing.T) {
+	v1 := mustParse(t, `
+package test;
+
+entity Task {
+    @pk id: string;
+    title: string;
+    legacy: string?;
+}
+`)
+	out, err := NewProtoGenerator().Generate(v1)
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+	sidecar, ok := out["test.fieldnumbers.json"]
+	if !ok {
+		t.Fatalf("Expected a field numbers sidecar, got %d files", len(out))
+	}
+	previous, err := ParseFieldNumbers([]byte(sidecar))
+	if err != nil {
+		t.Fatalf("ParseFieldNumbers error: %v", err)
+	}
+	if want := (FieldNumbers{"id": 1, "title": 2, "legacy": 3}); !reflect.DeepEqual(previous["Task"], want) {
+		t.Errorf("got %v, want %v", previous["Task"], want)
+	}
+
+	// v2 adds notes before title and drops legacy
+	v2 := mustParse(t, `
+package test;
+
+entity Task {
+    @pk id: string;
+    notes: string?;
+    title: string;
+}
+`)
+	g := NewProtoGenerator()
+	g.FieldNumbers = previous
+	out, err = g.Generate(v2)
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+	for _, want := range []string{
+		"    string id = 1;\n",
+		"    optional string notes = 4;\n",
+		"    string title = 2;\n",
+	} {
+		if !strings.Contains(out["test.proto"], want) {
+			t.Errorf("Expected %q in output, got:\n%s", want, out["test.proto"])
+		}
+	}
+
+	next, err := ParseFieldNumbers([]byte(out["test.fieldnumbers.json"]))
+	if err != nil {
+		t.Fatalf("ParseFieldNumbers error: %v", err)
+	}
+	want := FieldNumbers{"id": 1, "notes": 4, "title": 2, "legacy": 3}
+	if !reflect.DeepEqual(next["Task"], want) {
+		t.Errorf("Expected the removed field to stay in the sidecar, got %v, want %v", next["Task"], want)
+	}
+}
+
+func TestProtoReserved(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+entity Event {
+    reserved 2, 4 to max;
+    reserved "old_name";
+    @pk id: string;
+    title: string;
+}
+`)
+	out := generateProto(t, NewProtoGenerator(), file)
+
+	for _, want := range []string{
+		"    reserved 2, 4 to max;\n",
+		"    reserved \"old_name\";\n",
+		"    string id = 1;\n",
+		"    string title = 3;\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in output, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestProtoEnumAlias(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+enum Status {
+    option allow_alias = true;
+    ACTIVE = 0;
+    DELETED = 3;
+    REMOVED = 3;
+}
+`)
+	out := generateProto(t, NewProtoGenerator(), file)
+
+	want := "enum Status {\n    option allow_alias = true;\n    ACTIVE = 0;\n    DELETED = 3;\n    REMOVED = 3;\n}\n"
+	if !strings.Contains(out, want) {
+		t.Errorf("Expected %q in output, got:\n%s", want, out)
+	}
+}
+
+func TestProtoTimestampMode(t *testing.T) {
+	file := mustParse(t, timestampSchema)
+
+	out := generateProto(t, NewProtoGenerator(), file)
+	if !strings.Contains(out, "    int64 start_date = 2;") || strings.Contains(out, "import ") {
+		t.Errorf("Expected int64 timestamp without imports by default, got:\n%s", out)
+	}
+
+	g := NewProtoGenerator()
+	g.TimestampMode = TimestampNative
+	out = generateProto(t, g, file)
+	for _, want := range []string{
+		"import \"google/protobuf/timestamp.proto\";\n",
+		"    google.protobuf.Timestamp start_date = 2;",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in native mode, got:\n%s", want, out)
+		}
+	}
+}
+
+const docSchema = `
+package test;
+
+entity Event {
+    @pk id: string;
+
+    // The event's title, e.g. "Bob's party"
+    // shown in lists
+    title: string;
+}
+`
+
+func TestProtoFieldDoc(t *testing.T) {
+	code := generateProto(t, NewProtoGenerator(), mustParse(t, docSchema))
+	want := "    // The event's title, e.g. \"Bob's party\"\n    // shown in lists\n    string title = 2;\n"
+	if !strings.Contains(code, want) {
+		t.Errorf("Expected field doc above title, got:\n%s", code)
+	}
+}
+
+const deprecatedSchema = `
+package test;
+
+enum Priority {
+    LOW = 0;
+    @deprecated("use LOW") NONE = 1;
+}
+
+@deprecated
+entity LegacyEvent {
+    @pk id: string;
+}
+
+entity Event {
+    @pk id: string;
+    @deprecated("use title") name: string?;
+    title: string;
+}
+
+service EventService {
+    @deprecated rpc GetLegacy(LegacyEvent) returns (LegacyEvent);
+    rpc GetEvent(Event) returns (Event);
+}
+`
+
+func TestProtoDeprecated(t *testing.T) {
+	code := generateProto(t, NewProtoGenerator(), mustParse(t, deprecatedSchema))
+	for _, want := range []string{
+		"    NONE = 1 [deprecated = true];\n",
+		"message LegacyEvent {\n    option deprecated = true;\n",
+		"    optional string name = 2 [deprecated = true];\n",
+		"    string title = 3;\n",
+		"    rpc GetLegacy(LegacyEvent) returns (LegacyEvent) {\n        option deprecated = true;\n    }\n",
+		"    rpc GetEvent(Event) returns (Event);\n",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("Expected %q, got:\n%s", want, code)
+		}
+	}
+}
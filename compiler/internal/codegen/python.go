@@ -13,6 +13,7 @@ type PythonGenerator struct {
 	GenerateDataclass  bool // Use dataclasses
 	GenerateRepository bool
 	UseCertification   bool
+	QuoteIdentifiers   bool // Double-quote table and column names in repository SQL
 }
 
 // NewPythonGenerator creates a new PythonGenerator with defaults.
@@ -26,6 +27,7 @@ func NewPythonGenerator() *PythonGenerator {
 
 // Generate generates Python code from a DataProto file.
 func (g *PythonGenerator) Generate(file *parser.File) (map[string]string, error) {
+	file = concreteFile(file)
 	result := make(map[string]string)
 
 	// Generate __init__.py
@@ -88,6 +90,9 @@ func (g *PythonGenerator) generateModels(file *parser.File) string {
 	sb.WriteString("from __future__ import annotations\n")
 	sb.WriteString("from dataclasses import dataclass, field\n")
 	sb.WriteString("from enum import IntEnum\n")
+	if fields, _ := pythonNowDefaults(file); fields {
+		sb.WriteString("import time\n")
+	}
 	sb.WriteString("from typing import Optional, List\n\n")
 
 	// Generate enums
@@ -132,12 +137,16 @@ func (g *PythonGenerator) generateDataclass(entity *parser.EntityDecl) string {
 		sb.WriteString(fmt.Sprintf("    \"\"\"%s entity.\"\"\"\n\n", entity.Name))
 	}
 
-	// Fields - required fields first, then optional with defaults
+	// Fields - required fields first, then optional with defaults, then
+	// the computed ones the constructor does not take
 	var requiredFields []*parser.FieldDecl
 	var optionalFields []*parser.FieldDecl
+	var computedFields []*parser.FieldDecl
 
 	for _, f := range entity.Fields {
-		if f.Type.Optional || f.GetAnnotation("default") != nil {
+		if f.HasAnnotation("computed") {
+			computedFields = append(computedFields, f)
+		} else if f.Type.Optional || f.GetAnnotation("default") != nil {
 			optionalFields = append(optionalFields, f)
 		} else {
 			requiredFields = append(requiredFields, f)
@@ -159,20 +168,63 @@ func (g *PythonGenerator) generateDataclass(entity *parser.EntityDecl) string {
 		defaultVal := "None"
 		if def := f.GetAnnotation("default"); def != nil && len(def.Args) > 0 {
 			defaultVal = g.pythonDefaultValue(def.Args[0].Value, f.Type.Name)
+			if isNowValue(def.Args[0].Value, f.Type.Name) {
+				// A plain default would be computed once, at import
+				defaultVal = fmt.Sprintf("field(default_factory=lambda: %s)", pythonNowMillis)
+			}
 		}
 
 		sb.WriteString(fmt.Sprintf("    %s: %s = %s\n", fieldName, pythonType, defaultVal))
 	}
 
+	// Computed fields are derived by the database and read back with the
+	// row; None until then
+	for _, f := range computedFields {
+		pythonType := fmt.Sprintf("Optional[%s]", g.pythonBaseType(f.Type.Name))
+		sb.WriteString(fmt.Sprintf("    %s: %s = field(default=None, init=False)\n", ToSnakeCase(f.Name), pythonType))
+	}
+
 	return sb.String()
 }
 
+// writePythonConstructor writes the statements returning a new entity with
+// the given field values, one per field of entity. @computed fields are not
+// constructor arguments, so they are assigned once the entity is built.
+func writePythonConstructor(sb *strings.Builder, entity *parser.EntityDecl, values []string) {
+	var computed []string
+	target := "return"
+	for i, field := range entity.Fields {
+		if field.HasAnnotation("computed") {
+			computed = append(computed, fmt.Sprintf("        entity.%s = %s\n", ToSnakeCase(field.Name), values[i]))
+			target = "entity ="
+		}
+	}
+
+	sb.WriteString(fmt.Sprintf("        %s %s(\n", target, entity.Name))
+	for i, field := range entity.Fields {
+		if !field.HasAnnotation("computed") {
+			sb.WriteString(fmt.Sprintf("            %s=%s,\n", ToSnakeCase(field.Name), values[i]))
+		}
+	}
+	sb.WriteString("        )\n")
+	if len(computed) > 0 {
+		sb.WriteString(strings.Join(computed, ""))
+		sb.WriteString("        return entity\n")
+	}
+}
+
 func (g *PythonGenerator) generateRepositories(file *parser.File) string {
 	var sb strings.Builder
 
 	sb.WriteString("# Code generated by dataprotoc. DO NOT EDIT.\n\n")
 	sb.WriteString("from __future__ import annotations\n")
 	sb.WriteString("import sqlite3\n")
+	if _, params := pythonNowDefaults(file); params {
+		sb.WriteString("import time\n")
+	}
+	if hasQueryRows(file) {
+		sb.WriteString("from dataclasses import dataclass\n")
+	}
 	sb.WriteString("from typing import Optional, List\n")
 	sb.WriteString("from contextlib import contextmanager\n\n")
 	sb.WriteString("from .models import *\n\n")
@@ -193,14 +245,14 @@ func (g *PythonGenerator) generateRepositories(file *parser.File) string {
 
 	// Generate repository for each entity
 	for _, entity := range file.Entities {
-		sb.WriteString(g.generateRepository(entity))
+		sb.WriteString(g.generateRepository(file, entity))
 		sb.WriteString("\n\n")
 	}
 
 	return sb.String()
 }
 
-func (g *PythonGenerator) generateRepository(entity *parser.EntityDecl) string {
+func (g *PythonGenerator) generateRepository(file *parser.File, entity *parser.EntityDecl) string {
 	var sb strings.Builder
 
 	tableName := entity.TableName()
@@ -208,59 +260,78 @@ func (g *PythonGenerator) generateRepository(entity *parser.EntityDecl) string {
 		tableName = ToSnakeCase(entity.Name)
 	}
 
+	// The rows projecting queries return
+	for _, query := range entity.Queries {
+		if fields := queryRowFields(entity, query); fields != nil {
+			sb.WriteString(g.generatePythonQueryRow(entity, query, fields))
+			sb.WriteString("\n\n")
+		}
+	}
+
 	sb.WriteString(fmt.Sprintf("class %sRepository(BaseRepository):\n", entity.Name))
 	sb.WriteString(fmt.Sprintf("    \"\"\"Repository for %s entities.\"\"\"\n\n", entity.Name))
 
 	// Table name constant
 	sb.WriteString(fmt.Sprintf("    TABLE = \"%s\"\n\n", tableName))
 
-	// Upsert
-	sb.WriteString(g.generatePythonUpsert(entity, tableName))
+	// The table as the repository SQL names it
+	sqlTable := g.ident(tableName)
+
+	// Upsert; a view is read-only
+	readOnly := entity.View() != nil
+	if !readOnly {
+		sb.WriteString(g.generatePythonUpsert(file, entity, sqlTable))
+	}
 
 	// Find by ID
-	sb.WriteString(g.generatePythonFindById(entity, tableName))
+	sb.WriteString(g.generatePythonFindById(entity, sqlTable))
 
 	// Find all
-	sb.WriteString(g.generatePythonFindAll(entity, tableName))
+	sb.WriteString(g.generatePythonFindAll(entity, sqlTable))
 
 	// Delete
-	sb.WriteString(g.generatePythonDelete(entity, tableName))
+	if !readOnly {
+		sb.WriteString(g.generatePythonDelete(entity, sqlTable))
+	}
 
 	// Query methods
 	for _, query := range entity.Queries {
-		sb.WriteString(g.generatePythonQueryMethod(entity, query, tableName))
+		sb.WriteString(g.generatePythonQueryMethod(file, entity, query, sqlTable))
 	}
 
 	// Row mapper
-	sb.WriteString(g.generatePythonRowMapper(entity))
+	sb.WriteString(g.generatePythonRowMapper(file, entity))
+	for _, query := range entity.Queries {
+		if fields := queryRowFields(entity, query); fields != nil {
+			sb.WriteString("\n")
+			sb.WriteString(g.generatePythonQueryRowMapper(file, entity, query, fields))
+		}
+	}
 
 	return sb.String()
 }
 
-func (g *PythonGenerator) generatePythonUpsert(entity *parser.EntityDecl, tableName string) string {
+func (g *PythonGenerator) generatePythonUpsert(file *parser.File, entity *parser.EntityDecl, tableName string) string {
 	var sb strings.Builder
 
+	fields := upsertFields(entity)
 	var columns []string
 	var placeholders []string
-	for _, field := range entity.Fields {
-		columns = append(columns, ToSnakeCase(field.Name))
+	for _, field := range fields {
+		columns = append(columns, g.ident(ToSnakeCase(field.Name)))
 		placeholders = append(placeholders, "?")
 	}
 
 	sb.WriteString(fmt.Sprintf("    def upsert(self, entity: %s) -> None:\n", entity.Name))
 	sb.WriteString("        \"\"\"Insert or update an entity.\"\"\"\n")
-	sb.WriteString(fmt.Sprintf("        sql = \"INSERT OR REPLACE INTO %s (%s) VALUES (%s)\"\n",
-		tableName, strings.Join(columns, ", "), strings.Join(placeholders, ", ")))
+	sb.WriteString(fmt.Sprintf("        sql = %s\n", sqlLiteral(fmt.Sprintf("INSERT OR REPLACE INTO %s (%s) VALUES (%s)",
+		tableName, strings.Join(columns, ", "), strings.Join(placeholders, ", ")))))
 	sb.WriteString("        with self._get_connection() as conn:\n")
 	sb.WriteString("            conn.execute(sql, (\n")
 
-	for i, field := range entity.Fields {
-		fieldName := ToSnakeCase(field.Name)
-		if i < len(entity.Fields)-1 {
-			sb.WriteString(fmt.Sprintf("                entity.%s,\n", fieldName))
-		} else {
-			sb.WriteString(fmt.Sprintf("                entity.%s,\n", fieldName))
-		}
+	for _, field := range fields {
+		value := g.pythonStoredValue(file, field.Type, "entity."+ToSnakeCase(field.Name), field.Type.Optional)
+		sb.WriteString(fmt.Sprintf("                %s,\n", value))
 	}
 
 	sb.WriteString("            ))\n")
@@ -290,8 +361,7 @@ func (g *PythonGenerator) generatePythonFindById(entity *parser.EntityDecl, tabl
 	sb.WriteString(fmt.Sprintf("    def find_by_id(self, %s: %s) -> Optional[%s]:\n",
 		pkName, pkType, entity.Name))
 	sb.WriteString("        \"\"\"Find an entity by its primary key.\"\"\"\n")
-	sb.WriteString(fmt.Sprintf("        sql = \"SELECT * FROM %s WHERE %s = ?\"\n",
-		tableName, pkName))
+	sb.WriteString(fmt.Sprintf("        sql = %s\n", sqlLiteral(findByKeySQL(entity, tableName, pkName, g.ident))))
 	sb.WriteString("        with self._get_connection() as conn:\n")
 	sb.WriteString(fmt.Sprintf("            row = conn.execute(sql, (%s,)).fetchone()\n", pkName))
 	sb.WriteString("            return self._map_row(row) if row else None\n\n")
@@ -304,7 +374,7 @@ func (g *PythonGenerator) generatePythonFindAll(entity *parser.EntityDecl, table
 
 	sb.WriteString(fmt.Sprintf("    def find_all(self) -> List[%s]:\n", entity.Name))
 	sb.WriteString("        \"\"\"Find all entities.\"\"\"\n")
-	sb.WriteString(fmt.Sprintf("        sql = \"SELECT * FROM %s\"\n", tableName))
+	sb.WriteString(fmt.Sprintf("        sql = %s\n", sqlLiteral(findAllSQL(entity, tableName, g.ident))))
 	sb.WriteString("        with self._get_connection() as conn:\n")
 	sb.WriteString("            rows = conn.execute(sql).fetchall()\n")
 	sb.WriteString("            return [self._map_row(row) for row in rows]\n\n")
@@ -332,8 +402,7 @@ func (g *PythonGenerator) generatePythonDelete(entity *parser.EntityDecl, tableN
 
 	sb.WriteString(fmt.Sprintf("    def delete(self, %s: %s) -> bool:\n", pkName, pkType))
 	sb.WriteString("        \"\"\"Delete an entity by its primary key.\"\"\"\n")
-	sb.WriteString(fmt.Sprintf("        sql = \"DELETE FROM %s WHERE %s = ?\"\n",
-		tableName, pkName))
+	sb.WriteString(fmt.Sprintf("        sql = %s\n", sqlLiteral(deleteSQL(entity, tableName, pkName, g.ident, TimestampEpochMillis))))
 	sb.WriteString("        with self._get_connection() as conn:\n")
 	sb.WriteString(fmt.Sprintf("            cursor = conn.execute(sql, (%s,))\n", pkName))
 	sb.WriteString("            conn.commit()\n")
@@ -342,7 +411,7 @@ func (g *PythonGenerator) generatePythonDelete(entity *parser.EntityDecl, tableN
 	return sb.String()
 }
 
-func (g *PythonGenerator) generatePythonQueryMethod(entity *parser.EntityDecl, query *parser.QueryDecl, tableName string) string {
+func (g *PythonGenerator) generatePythonQueryMethod(file *parser.File, entity *parser.EntityDecl, query *parser.QueryDecl, tableName string) string {
 	var sb strings.Builder
 
 	// Method name and signature
@@ -350,68 +419,59 @@ func (g *PythonGenerator) generatePythonQueryMethod(entity *parser.EntityDecl, q
 	sb.WriteString(fmt.Sprintf("    def %s(self", methodName))
 
 	for _, p := range query.Params {
-		pythonType := g.pythonType(p.Type)
+		pythonType := g.pythonBaseType(p.Type.Name)
+		if p.Nullable() {
+			pythonType = fmt.Sprintf("Optional[%s]", pythonType)
+		}
 		paramName := ToSnakeCase(p.Name)
-		if p.Default != nil {
+		if isNowValue(p.Default, p.Type.Name) {
+			// A default argument would be computed once; None is replaced
+			// by the current time below
+			sb.WriteString(fmt.Sprintf(", %s: Optional[%s] = None",
+				paramName, g.pythonBaseType(p.Type.Name)))
+		} else if p.Default != nil {
 			sb.WriteString(fmt.Sprintf(", %s: %s = %s",
 				paramName, pythonType, g.pythonDefaultValue(p.Default, p.Type.Name)))
+		} else if p.DefaultExpr != nil {
+			// None lets the query substitute the default
+			sb.WriteString(fmt.Sprintf(", %s: Optional[%s] = None",
+				paramName, g.pythonBaseType(p.Type.Name)))
 		} else {
 			sb.WriteString(fmt.Sprintf(", %s: %s", paramName, pythonType))
 		}
 	}
 
-	sb.WriteString(fmt.Sprintf(") -> List[%s]:\n", entity.Name))
-	sb.WriteString(fmt.Sprintf("        \"\"\"Query: %s\"\"\"\n", query.Name))
-
-	// Build set of known parameter names
-	knownParams := make(map[string]bool)
-	for _, p := range query.Params {
-		knownParams[p.Name] = true
+	// A projecting query yields its own row type
+	rowType, mapper := entity.Name, "_map_row"
+	if queryRowFields(entity, query) != nil {
+		rowType = queryRowName(entity, query)
+		mapper = "_map_" + ToSnakeCase(rowType)
 	}
 
-	// Build SQL
-	var sqlParts []string
-	sqlParts = append(sqlParts, fmt.Sprintf("SELECT * FROM %s", tableName))
-
-	if query.Where != nil {
-		whereSQL, _ := ExprToSQLWithKnownParams(query.Where, knownParams)
-		sqlParts = append(sqlParts, "WHERE "+whereSQL)
+	if query.ReturnsOne() {
+		sb.WriteString(fmt.Sprintf(") -> Optional[%s]:\n", rowType))
+	} else {
+		sb.WriteString(fmt.Sprintf(") -> List[%s]:\n", rowType))
 	}
-
-	if len(query.OrderBy) > 0 {
-		var orderParts []string
-		for _, o := range query.OrderBy {
-			dir := "ASC"
-			if o.Descending {
-				dir = "DESC"
-			}
-			orderParts = append(orderParts, fmt.Sprintf("%s %s", ToSnakeCase(o.Field), dir))
+	sb.WriteString(fmt.Sprintf("        \"\"\"Query: %s\"\"\"\n", query.Name))
+	for _, p := range query.Params {
+		if isNowValue(p.Default, p.Type.Name) {
+			paramName := ToSnakeCase(p.Name)
+			sb.WriteString(fmt.Sprintf("        if %s is None:\n", paramName))
+			sb.WriteString(fmt.Sprintf("            %s = %s\n", paramName, pythonNowMillis))
 		}
-		sqlParts = append(sqlParts, "ORDER BY "+strings.Join(orderParts, ", "))
 	}
 
-	if query.Limit != nil {
-		if _, ok := query.Limit.(*parser.IdentExpr); ok {
-			sqlParts = append(sqlParts, "LIMIT ?")
-		} else if lit, ok := query.Limit.(*parser.LiteralExpr); ok {
-			if val, ok := lit.Value.(int64); ok {
-				sqlParts = append(sqlParts, fmt.Sprintf("LIMIT %d", val))
-			}
-		}
-	}
+	sql, names := selectSQL(DialectSQLite, entity, tableName, query, g.ident, TimestampEpochMillis)
 
-	sb.WriteString(fmt.Sprintf("        sql = \"%s\"\n", strings.Join(sqlParts, " ")))
+	sb.WriteString(fmt.Sprintf("        sql = %s\n", sqlLiteral(sql)))
 
-	// Build params tuple
+	// Build params tuple, one value per placeholder
 	sb.WriteString("        params = (")
 	var paramNames []string
-	for _, p := range query.Params {
-		paramNames = append(paramNames, ToSnakeCase(p.Name))
-	}
-	if query.Limit != nil {
-		if l, ok := query.Limit.(*parser.IdentExpr); ok {
-			paramNames = append(paramNames, ToSnakeCase(l.Name))
-		}
+	for _, name := range names {
+		p := query.Param(name)
+		paramNames = append(paramNames, g.pythonStoredValue(file, p.Type, ToSnakeCase(name), p.Nullable() || p.DefaultExpr != nil))
 	}
 	sb.WriteString(strings.Join(paramNames, ", "))
 	if len(paramNames) == 1 {
@@ -420,25 +480,59 @@ func (g *PythonGenerator) generatePythonQueryMethod(entity *parser.EntityDecl, q
 	sb.WriteString(")\n")
 
 	sb.WriteString("        with self._get_connection() as conn:\n")
-	sb.WriteString("            rows = conn.execute(sql, params).fetchall()\n")
-	sb.WriteString("            return [self._map_row(row) for row in rows]\n\n")
+	if query.ReturnsOne() {
+		sb.WriteString("            row = conn.execute(sql, params).fetchone()\n")
+		sb.WriteString(fmt.Sprintf("            return self.%s(row) if row else None\n\n", mapper))
+	} else {
+		sb.WriteString("            rows = conn.execute(sql, params).fetchall()\n")
+		sb.WriteString(fmt.Sprintf("            return [self.%s(row) for row in rows]\n\n", mapper))
+	}
 
 	return sb.String()
 }
 
-func (g *PythonGenerator) generatePythonRowMapper(entity *parser.EntityDecl) string {
+func (g *PythonGenerator) generatePythonRowMapper(file *parser.File, entity *parser.EntityDecl) string {
 	var sb strings.Builder
 
 	sb.WriteString(fmt.Sprintf("    def _map_row(self, row: sqlite3.Row) -> %s:\n", entity.Name))
 	sb.WriteString("        \"\"\"Map a database row to an entity.\"\"\"\n")
-	sb.WriteString(fmt.Sprintf("        return %s(\n", entity.Name))
 
+	var values []string
 	for _, field := range entity.Fields {
-		fieldName := ToSnakeCase(field.Name)
-		getter := g.pythonRowGetter(field)
-		sb.WriteString(fmt.Sprintf("            %s=%s,\n", fieldName, getter))
+		values = append(values, g.pythonRowGetter(file, field))
 	}
+	writePythonConstructor(&sb, entity, values)
+
+	return sb.String()
+}
+
+// generatePythonQueryRow emits the dataclass holding a row of a projecting
+// query, with one attribute per selected column.
+func (g *PythonGenerator) generatePythonQueryRow(entity *parser.EntityDecl, query *parser.QueryDecl, fields []*parser.FieldDecl) string {
+	var sb strings.Builder
 
+	sb.WriteString("@dataclass\n")
+	sb.WriteString(fmt.Sprintf("class %s:\n", queryRowName(entity, query)))
+	sb.WriteString(fmt.Sprintf("    \"\"\"Row returned by %sRepository.%s.\"\"\"\n\n", entity.Name, ToSnakeCase(query.Name)))
+	for _, field := range fields {
+		sb.WriteString(fmt.Sprintf("    %s: %s\n", ToSnakeCase(field.Name), g.pythonType(field.Type)))
+	}
+
+	return sb.String()
+}
+
+// generatePythonQueryRowMapper emits the method mapping a result row of a
+// projecting query to its row dataclass.
+func (g *PythonGenerator) generatePythonQueryRowMapper(file *parser.File, entity *parser.EntityDecl, query *parser.QueryDecl, fields []*parser.FieldDecl) string {
+	var sb strings.Builder
+	rowType := queryRowName(entity, query)
+
+	sb.WriteString(fmt.Sprintf("    def _map_%s(self, row: sqlite3.Row) -> %s:\n", ToSnakeCase(rowType), rowType))
+	sb.WriteString(fmt.Sprintf("        \"\"\"Map a database row to %s.\"\"\"\n", rowType))
+	sb.WriteString(fmt.Sprintf("        return %s(\n", rowType))
+	for _, field := range fields {
+		sb.WriteString(fmt.Sprintf("            %s=%s,\n", ToSnakeCase(field.Name), g.pythonRowGetter(file, field)))
+	}
 	sb.WriteString("        )\n")
 
 	return sb.String()
@@ -473,21 +567,19 @@ func (g *PythonGenerator) generateMapper(entity *parser.EntityDecl) string {
 	sb.WriteString("    @staticmethod\n")
 	sb.WriteString(fmt.Sprintf("    def from_proto(proto) -> %s:\n", entity.Name))
 	sb.WriteString("        \"\"\"Convert from protobuf message.\"\"\"\n")
-	sb.WriteString(fmt.Sprintf("        return %s(\n", entity.Name))
 
+	var values []string
 	for _, field := range entity.Fields {
-		fieldName := ToSnakeCase(field.Name)
 		protoName := ToCamelCase(field.Name)
 
 		if field.Type.Optional {
-			sb.WriteString(fmt.Sprintf("            %s=proto.%s if proto.HasField('%s') else None,\n",
-				fieldName, protoName, protoName))
+			values = append(values, fmt.Sprintf("proto.%s if proto.HasField('%s') else None", protoName, protoName))
 		} else {
-			sb.WriteString(fmt.Sprintf("            %s=proto.%s,\n", fieldName, protoName))
+			values = append(values, "proto."+protoName)
 		}
 	}
-
-	sb.WriteString("        )\n\n")
+	writePythonConstructor(&sb, entity, values)
+	sb.WriteString("\n")
 
 	// To proto
 	sb.WriteString("    @staticmethod\n")
@@ -501,7 +593,7 @@ func (g *PythonGenerator) generateMapper(entity *parser.EntityDecl) string {
 		fieldName := ToSnakeCase(field.Name)
 		protoName := ToCamelCase(field.Name)
 
-		if field.Type.Optional {
+		if field.Type.Optional || field.HasAnnotation("computed") {
 			sb.WriteString(fmt.Sprintf("        if entity.%s is not None:\n", fieldName))
 			sb.WriteString(fmt.Sprintf("            proto.%s = entity.%s\n", protoName, fieldName))
 		} else {
@@ -541,8 +633,15 @@ func (g *PythonGenerator) pythonBaseType(typeName string) string {
 	}
 }
 
+// pythonNowMillis is the Python expression for the current epoch
+// milliseconds, which a timestamp defaulting to now takes.
+const pythonNowMillis = "int(time.time() * 1000)"
+
 func (g *PythonGenerator) pythonDefaultValue(value interface{}, typeName string) string {
-	switch v := value.(type) {
+	if isNowValue(value, typeName) {
+		return pythonNowMillis
+	}
+	switch v := epochDefault(value, typeName).(type) {
 	case string:
 		return fmt.Sprintf("\"%s\"", v)
 	case bool:
@@ -559,9 +658,51 @@ func (g *PythonGenerator) pythonDefaultValue(value interface{}, typeName string)
 	}
 }
 
-func (g *PythonGenerator) pythonRowGetter(field *parser.FieldDecl) string {
+// pythonNowDefaults reports whether the fields and the query parameters of
+// file default to now, which needs the time module.
+func pythonNowDefaults(file *parser.File) (fields, params bool) {
+	for _, entity := range file.Entities {
+		for _, f := range entity.Fields {
+			if def := f.GetAnnotation("default"); def != nil && len(def.Args) > 0 && isNowValue(def.Args[0].Value, f.Type.Name) {
+				fields = true
+			}
+		}
+		for _, query := range entity.Queries {
+			for _, p := range query.Params {
+				if isNowValue(p.Default, p.Type.Name) {
+					params = true
+				}
+			}
+		}
+	}
+	return fields, params
+}
+
+// pythonStoredValue returns the value stored in a column of type t for the
+// Python expression value: an enum's value name, since the enum CHECK
+// constraints admit names and not numbers, or value itself.
+func (g *PythonGenerator) pythonStoredValue(file *parser.File, t *parser.TypeRef, value string, nullable bool) string {
+	if typeEnum(file, t) == nil {
+		return value
+	}
+	if nullable {
+		return fmt.Sprintf("%s.name if %s is not None else None", value, value)
+	}
+	return value + ".name"
+}
+
+func (g *PythonGenerator) pythonRowGetter(file *parser.File, field *parser.FieldDecl) string {
 	fieldName := ToSnakeCase(field.Name)
 
+	if enum := typeEnum(file, field.Type); enum != nil {
+		// Enums are stored by value name
+		if field.Type.Optional {
+			return fmt.Sprintf("%s[row['%s']] if row['%s'] is not None else None",
+				enum.Name, fieldName, fieldName)
+		}
+		return fmt.Sprintf("%s[row['%s']]", enum.Name, fieldName)
+	}
+
 	switch field.Type.Name {
 	case "bool":
 		if field.Type.Optional {
@@ -573,3 +714,12 @@ func (g *PythonGenerator) pythonRowGetter(field *parser.FieldDecl) string {
 		return fmt.Sprintf("row['%s']", fieldName)
 	}
 }
+
+// ident returns name as it appears in repository SQL, quoted when
+// QuoteIdentifiers is set.
+func (g *PythonGenerator) ident(name string) string {
+	if g.QuoteIdentifiers {
+		return quoteIdent(name)
+	}
+	return name
+}
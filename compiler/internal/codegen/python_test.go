@@ -0,0 +1,227 @@
+package codegen
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aurora/dataproto/internal/parser"
+)
+
+// TestPythonEnumRoundTrip stores and reads back a row through the generated
+// Python repository, against the generated SQLite schema and its enum CHECK
+// constraints.
+func TestPythonEnumRoundTrip(t *testing.T) {
+	file := mustParse(t, `
+package shop;
+
+enum Status {
+    ACTIVE = 0;
+    DONE = 1;
+}
+
+entity Task {
+    @pk id: string;
+    status: Status;
+    previous: Status?;
+
+    query byStatus(s: Status) {
+        where status = s
+    }
+}
+`)
+
+	runPython(t, file, `
+from shop.models import Task, Status
+from shop.repositories import TaskRepository
+repo = TaskRepository("test.db")
+repo.upsert(Task(id="a", status=Status.DONE, previous=Status.ACTIVE))
+repo.upsert(Task(id="b", status=Status.ACTIVE))
+assert repo.find_by_id("a") == Task(id="a", status=Status.DONE, previous=Status.ACTIVE)
+assert repo.find_by_id("b").previous is None
+assert [t.id for t in repo.by_status(Status.DONE)] == ["a"]
+`)
+}
+
+// TestPythonProjectionRoundTrip reads a projecting query through the
+// generated Python repository into its row type.
+func TestPythonProjectionRoundTrip(t *testing.T) {
+	file := mustParse(t, `
+package shop;
+
+entity Sale {
+    @pk id: string;
+    customer: string;
+    total: double;
+    items: int32;
+
+    query totalsByCustomer(minItems: int32) {
+        select customer, COUNT(id), SUM(total)
+        where items >= minItems
+        group_by customer
+    }
+
+    query customers() {
+        group_by customer
+        order_by customer
+    }
+}
+`)
+
+	runPython(t, file, `
+from shop.models import Sale
+from shop.repositories import SaleRepository, SaleTotalsByCustomerRow, SaleCustomersRow
+repo = SaleRepository("test.db")
+repo.upsert(Sale(id="1", customer="a", total=2.5, items=3))
+repo.upsert(Sale(id="2", customer="a", total=1.0, items=5))
+repo.upsert(Sale(id="3", customer="b", total=4.0, items=1))
+rows = repo.totals_by_customer(2)
+assert rows == [SaleTotalsByCustomerRow(customer="a", count_id=2, sum_total=3.5)], rows
+rows = repo.customers()
+assert rows == [SaleCustomersRow(customer="a"), SaleCustomersRow(customer="b")], rows
+`)
+}
+
+// TestPythonNowDefault checks that a timestamp defaulting to now takes the
+// current time for each new entity and query call.
+func TestPythonNowDefault(t *testing.T) {
+	file := mustParse(t, `
+package shop;
+
+entity Note {
+    @pk id: string;
+    @default(now) createdAt: timestamp;
+
+    query createdBefore(before: timestamp = now) {
+        where createdAt < before
+    }
+}
+`)
+
+	runPython(t, file, `
+import time
+from shop.models import Note
+from shop.repositories import NoteRepository
+start = int(time.time() * 1000)
+a = Note(id="a")
+assert start <= a.created_at <= int(time.time() * 1000), a
+time.sleep(0.01)
+assert Note(id="b").created_at > a.created_at
+repo = NoteRepository("test.db")
+repo.upsert(a)
+time.sleep(0.01)
+assert [n.id for n in repo.created_before()] == ["a"]
+`)
+}
+
+func TestPythonComputedField(t *testing.T) {
+	file := mustParse(t, `
+package shop;
+
+entity Person {
+    @pk id: string;
+    first: string;
+    last: string;
+    @computed("first || ' ' || last") fullName: string;
+}
+`)
+
+	runPython(t, file, `
+from shop.models import Person
+from shop.repositories import PersonRepository
+try:
+    Person(id="a", first="Ada", last="Lovelace", full_name="x")
+    raise AssertionError("computed field accepted by the constructor")
+except TypeError:
+    pass
+p = Person(id="a", first="Ada", last="Lovelace")
+assert p.full_name is None
+repo = PersonRepository("test.db")
+repo.upsert(p)
+assert repo.find_by_id("a").full_name == "Ada Lovelace"
+`)
+}
+
+func TestPythonQuotedIdentifiersRoundTrip(t *testing.T) {
+	file := mustParse(t, `
+package shop;
+
+@table("group")
+entity Group {
+    @pk id: string;
+    order: int32;
+    @soft_delete deletedAt: timestamp?;
+
+    query ranked(least: int32) {
+        where order >= least
+        order_by order DESC
+    }
+}
+`)
+
+	py := NewPythonGenerator()
+	py.QuoteIdentifiers = true
+	sqlite := NewSQLiteGenerator()
+	sqlite.QuoteIdentifiers = true
+	runPythonWith(t, py, sqlite, file, `
+from shop.models import Group
+from shop.repositories import GroupRepository
+repo = GroupRepository("test.db")
+repo.upsert(Group(id="a", order=1))
+repo.upsert(Group(id="b", order=2))
+assert repo.find_by_id("a").order == 1
+assert [g.id for g in repo.ranked(1)] == ["b", "a"]
+assert repo.delete("b")
+assert [g.id for g in repo.find_all()] == ["a"]
+`)
+}
+
+// runPython writes the Python package and SQLite schema generated for file
+// to a temporary directory, creates test.db from the schema and runs script
+// there. It skips the test when python3 is not installed.
+func runPython(t *testing.T, file *parser.File, script string) {
+	t.Helper()
+	runPythonWith(t, NewPythonGenerator(), NewSQLiteGenerator(), file, script)
+}
+
+// runPythonWith is runPython with the given Python and SQLite generators.
+func runPythonWith(t *testing.T, py *PythonGenerator, sqlite *SQLiteGenerator, file *parser.File, script string) {
+	t.Helper()
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 not found")
+	}
+
+	dir := t.TempDir()
+	pkg := filepath.Join(dir, "shop")
+	if err := os.Mkdir(pkg, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	out, err := py.Generate(file)
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+	for name, content := range out {
+		if err := os.WriteFile(filepath.Join(pkg, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ddl := generateOne(t, sqlite, file)
+	if err := os.WriteFile(filepath.Join(dir, "schema.sql"), []byte(ddl), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	setup := `
+import sqlite3
+conn = sqlite3.connect("test.db")
+conn.executescript(open("schema.sql").read())
+conn.close()
+`
+	cmd := exec.Command(python, "-c", setup+script)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("round trip failed: %v\n%s", err, strings.TrimSpace(string(out)))
+	}
+}
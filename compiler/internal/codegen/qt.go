@@ -13,6 +13,7 @@ type QtGenerator struct {
 	GenerateQObject    bool   // Generate Q_OBJECT classes with signals/slots
 	GenerateRepository bool   // Generate repository classes
 	GenerateQML        bool   // Generate QML-compatible types
+	QuoteIdentifiers   bool   // Double-quote table and column names in repository SQL
 }
 
 // NewQtGenerator creates a new QtGenerator with defaults.
@@ -27,6 +28,11 @@ func NewQtGenerator() *QtGenerator {
 
 // Generate generates Qt/C++ code from a DataProto file.
 func (g *QtGenerator) Generate(file *parser.File) (map[string]string, error) {
+	file = concreteFile(file)
+	// The namespace is per file; set it on a copy as g may be shared
+	cp := *g
+	g = &cp
+
 	result := make(map[string]string)
 
 	// Set namespace from package
@@ -46,7 +52,7 @@ func (g *QtGenerator) Generate(file *parser.File) (map[string]string, error) {
 			repoHeader := g.generateRepositoryHeader(entity)
 			result[ToSnakeCase(entity.Name)+"_repository.h"] = repoHeader
 
-			repoSource := g.generateRepositorySource(entity)
+			repoSource := g.generateRepositorySource(file, entity)
 			result[ToSnakeCase(entity.Name)+"_repository.cpp"] = repoSource
 		}
 	}
@@ -280,6 +286,13 @@ func (g *QtGenerator) generateRepositoryHeader(entity *parser.EntityDecl) string
 		sb.WriteString(fmt.Sprintf("namespace %s {\n\n", g.Namespace))
 	}
 
+	// The rows projecting queries return
+	for _, query := range entity.Queries {
+		if fields := queryRowFields(entity, query); fields != nil {
+			sb.WriteString(g.generateQtQueryRow(entity, query, fields))
+		}
+	}
+
 	// Class declaration
 	sb.WriteString(fmt.Sprintf("class %s : public QObject\n", className))
 	sb.WriteString("{\n")
@@ -289,8 +302,11 @@ func (g *QtGenerator) generateRepositoryHeader(entity *parser.EntityDecl) string
 	sb.WriteString(fmt.Sprintf("    explicit %s(QSqlDatabase db, QObject *parent = nullptr);\n", className))
 	sb.WriteString(fmt.Sprintf("    ~%s() override = default;\n\n", className))
 
-	// CRUD methods
-	sb.WriteString(fmt.Sprintf("    void upsert(%s *entity);\n", entityName))
+	// CRUD methods; a view is read-only
+	readOnly := entity.View() != nil
+	if !readOnly {
+		sb.WriteString(fmt.Sprintf("    void upsert(%s *entity);\n", entityName))
+	}
 
 	// Find by ID
 	var pkField *parser.FieldDecl
@@ -306,7 +322,7 @@ func (g *QtGenerator) generateRepositoryHeader(entity *parser.EntityDecl) string
 	}
 
 	sb.WriteString(fmt.Sprintf("    QList<%s*> findAll(QObject *parent = nullptr);\n", entityName))
-	if pkField != nil {
+	if pkField != nil && !readOnly {
 		pkType := g.qtType(pkField.Type)
 		sb.WriteString(fmt.Sprintf("    bool remove(%s id);\n\n", pkType))
 	}
@@ -318,6 +334,12 @@ func (g *QtGenerator) generateRepositoryHeader(entity *parser.EntityDecl) string
 
 	sb.WriteString("\nprivate:\n")
 	sb.WriteString(fmt.Sprintf("    %s* mapRow(const QSqlQuery &query, QObject *parent);\n", entityName))
+	for _, query := range entity.Queries {
+		if queryRowFields(entity, query) != nil {
+			rowType := queryRowName(entity, query)
+			sb.WriteString(fmt.Sprintf("    %s map%s(const QSqlQuery &query);\n", rowType, rowType))
+		}
+	}
 	sb.WriteString("    QSqlDatabase m_db;\n")
 
 	sb.WriteString("};\n\n")
@@ -332,7 +354,7 @@ func (g *QtGenerator) generateRepositoryHeader(entity *parser.EntityDecl) string
 	return sb.String()
 }
 
-func (g *QtGenerator) generateRepositorySource(entity *parser.EntityDecl) string {
+func (g *QtGenerator) generateRepositorySource(file *parser.File, entity *parser.EntityDecl) string {
 	var sb strings.Builder
 	className := entity.Name + "Repository"
 	headerName := ToSnakeCase(className) + ".h"
@@ -341,10 +363,12 @@ func (g *QtGenerator) generateRepositorySource(entity *parser.EntityDecl) string
 	if tableName == "" {
 		tableName = ToSnakeCase(entity.Name)
 	}
+	tableName = g.ident(tableName)
 
 	// Header
 	sb.WriteString("// Code generated by dataprotoc. DO NOT EDIT.\n\n")
 	sb.WriteString(fmt.Sprintf("#include \"%s\"\n", headerName))
+	sb.WriteString("#include <QMetaEnum>\n")
 	sb.WriteString("#include <QSqlError>\n")
 	sb.WriteString("#include <QVariant>\n\n")
 
@@ -359,8 +383,11 @@ func (g *QtGenerator) generateRepositorySource(entity *parser.EntityDecl) string
 	sb.WriteString("    , m_db(db)\n")
 	sb.WriteString("{\n}\n\n")
 
-	// Upsert
-	sb.WriteString(g.generateQtUpsert(entity, tableName))
+	// Upsert; a view is read-only
+	readOnly := entity.View() != nil
+	if !readOnly {
+		sb.WriteString(g.generateQtUpsert(file, entity, tableName))
+	}
 
 	// Find by ID
 	var pkField *parser.FieldDecl
@@ -378,17 +405,22 @@ func (g *QtGenerator) generateRepositorySource(entity *parser.EntityDecl) string
 	sb.WriteString(g.generateQtFindAll(entity, tableName))
 
 	// Delete
-	if pkField != nil {
+	if pkField != nil && !readOnly {
 		sb.WriteString(g.generateQtDelete(entity, tableName, pkField))
 	}
 
 	// Query methods
 	for _, query := range entity.Queries {
-		sb.WriteString(g.generateQtQueryMethod(entity, query, tableName))
+		sb.WriteString(g.generateQtQueryMethod(file, entity, query, tableName))
 	}
 
 	// Row mapper
-	sb.WriteString(g.generateQtRowMapper(entity))
+	sb.WriteString(g.generateQtRowMapper(file, entity))
+	for _, query := range entity.Queries {
+		if fields := queryRowFields(entity, query); fields != nil {
+			sb.WriteString(g.generateQtQueryRowMapper(file, entity, query, fields))
+		}
+	}
 
 	// Close namespace
 	if g.Namespace != "" {
@@ -398,27 +430,31 @@ func (g *QtGenerator) generateRepositorySource(entity *parser.EntityDecl) string
 	return sb.String()
 }
 
-func (g *QtGenerator) generateQtUpsert(entity *parser.EntityDecl, tableName string) string {
+func (g *QtGenerator) generateQtUpsert(file *parser.File, entity *parser.EntityDecl, tableName string) string {
 	var sb strings.Builder
 	className := entity.Name + "Repository"
 	entityName := entity.Name
 
+	fields := upsertFields(entity)
 	var columns []string
 	var placeholders []string
-	for _, field := range entity.Fields {
-		columns = append(columns, ToSnakeCase(field.Name))
+	for _, field := range fields {
+		columns = append(columns, g.ident(ToSnakeCase(field.Name)))
 		placeholders = append(placeholders, "?")
 	}
 
 	sb.WriteString(fmt.Sprintf("void %s::upsert(%s *entity)\n", className, entityName))
 	sb.WriteString("{\n")
 	sb.WriteString("    QSqlQuery query(m_db);\n")
-	sb.WriteString(fmt.Sprintf("    query.prepare(\"INSERT OR REPLACE INTO %s (%s) VALUES (%s)\");\n",
-		tableName, strings.Join(columns, ", "), strings.Join(placeholders, ", ")))
+	sb.WriteString(fmt.Sprintf("    query.prepare(%s);\n", sqlLiteral(fmt.Sprintf("INSERT OR REPLACE INTO %s (%s) VALUES (%s)",
+		tableName, strings.Join(columns, ", "), strings.Join(placeholders, ", ")))))
 
-	for _, field := range entity.Fields {
-		propName := ToCamelCase(field.Name)
-		sb.WriteString(fmt.Sprintf("    query.addBindValue(entity->%s());\n", propName))
+	for _, field := range fields {
+		value := fmt.Sprintf("entity->%s()", ToCamelCase(field.Name))
+		if enum := typeEnum(file, field.Type); enum != nil {
+			value = qtEnumKey(enum.Name, value)
+		}
+		sb.WriteString(fmt.Sprintf("    query.addBindValue(%s);\n", value))
 	}
 
 	sb.WriteString("    query.exec();\n")
@@ -437,7 +473,7 @@ func (g *QtGenerator) generateQtFindById(entity *parser.EntityDecl, tableName st
 	sb.WriteString(fmt.Sprintf("%s* %s::findById(%s id, QObject *parent)\n", entityName, className, pkType))
 	sb.WriteString("{\n")
 	sb.WriteString("    QSqlQuery query(m_db);\n")
-	sb.WriteString(fmt.Sprintf("    query.prepare(\"SELECT * FROM %s WHERE %s = ?\");\n", tableName, pkCol))
+	sb.WriteString(fmt.Sprintf("    query.prepare(%s);\n", sqlLiteral(findByKeySQL(entity, tableName, pkCol, g.ident))))
 	sb.WriteString("    query.addBindValue(id);\n")
 	sb.WriteString("    query.exec();\n\n")
 	sb.WriteString("    if (query.next()) {\n")
@@ -458,7 +494,7 @@ func (g *QtGenerator) generateQtFindAll(entity *parser.EntityDecl, tableName str
 	sb.WriteString("{\n")
 	sb.WriteString(fmt.Sprintf("    QList<%s*> results;\n", entityName))
 	sb.WriteString("    QSqlQuery query(m_db);\n")
-	sb.WriteString(fmt.Sprintf("    query.exec(\"SELECT * FROM %s\");\n\n", tableName))
+	sb.WriteString(fmt.Sprintf("    query.exec(%s);\n\n", sqlLiteral(findAllSQL(entity, tableName, g.ident))))
 	sb.WriteString("    while (query.next()) {\n")
 	sb.WriteString("        results.append(mapRow(query, parent));\n")
 	sb.WriteString("    }\n")
@@ -477,7 +513,7 @@ func (g *QtGenerator) generateQtDelete(entity *parser.EntityDecl, tableName stri
 	sb.WriteString(fmt.Sprintf("bool %s::remove(%s id)\n", className, pkType))
 	sb.WriteString("{\n")
 	sb.WriteString("    QSqlQuery query(m_db);\n")
-	sb.WriteString(fmt.Sprintf("    query.prepare(\"DELETE FROM %s WHERE %s = ?\");\n", tableName, pkCol))
+	sb.WriteString(fmt.Sprintf("    query.prepare(%s);\n", sqlLiteral(deleteSQL(entity, tableName, pkCol, g.ident, TimestampEpochMillis))))
 	sb.WriteString("    query.addBindValue(id);\n")
 	sb.WriteString("    return query.exec() && query.numRowsAffected() > 0;\n")
 	sb.WriteString("}\n\n")
@@ -487,105 +523,100 @@ func (g *QtGenerator) generateQtDelete(entity *parser.EntityDecl, tableName stri
 
 func (g *QtGenerator) generateQueryMethodDeclaration(entity *parser.EntityDecl, query *parser.QueryDecl) string {
 	var sb strings.Builder
-	entityName := entity.Name
 	methodName := ToCamelCase(query.Name)
 
-	sb.WriteString(fmt.Sprintf("    QList<%s*> %s(", entityName, methodName))
+	sb.WriteString(fmt.Sprintf("    %s %s(", g.qtQueryResultType(entity, query), methodName))
 
 	var params []string
 	for _, p := range query.Params {
-		qtType := g.qtType(p.Type)
+		qtType := g.qtParamType(p)
 		paramName := ToCamelCase(p.Name)
 		if p.Default != nil {
 			params = append(params, fmt.Sprintf("%s %s = %s",
 				qtType, paramName, g.qtLiteralValue(p.Default, p.Type.Name)))
+		} else if p.DefaultExpr != nil {
+			// An empty optional lets the query substitute the default
+			params = append(params, fmt.Sprintf("%s %s = std::nullopt", qtType, paramName))
 		} else {
 			params = append(params, fmt.Sprintf("%s %s", qtType, paramName))
 		}
 	}
-	params = append(params, "QObject *parent = nullptr")
+	// Rows of a projecting query are values, which take no parent
+	if queryRowFields(entity, query) == nil {
+		params = append(params, "QObject *parent = nullptr")
+	}
 	sb.WriteString(strings.Join(params, ", "))
 	sb.WriteString(");\n")
 
 	return sb.String()
 }
 
-func (g *QtGenerator) generateQtQueryMethod(entity *parser.EntityDecl, query *parser.QueryDecl, tableName string) string {
+func (g *QtGenerator) generateQtQueryMethod(file *parser.File, entity *parser.EntityDecl, query *parser.QueryDecl, tableName string) string {
 	var sb strings.Builder
 	className := entity.Name + "Repository"
-	entityName := entity.Name
 	methodName := ToCamelCase(query.Name)
+	resultType := g.qtQueryResultType(entity, query)
+	projects := queryRowFields(entity, query) != nil
+	mapper := "mapRow(query, parent)"
+	if projects {
+		mapper = fmt.Sprintf("map%s(query)", queryRowName(entity, query))
+	}
 
 	// Method signature
-	sb.WriteString(fmt.Sprintf("QList<%s*> %s::%s(", entityName, className, methodName))
+	sb.WriteString(fmt.Sprintf("%s %s::%s(", resultType, className, methodName))
 
 	var params []string
 	for _, p := range query.Params {
-		qtType := g.qtType(p.Type)
-		paramName := ToCamelCase(p.Name)
-		params = append(params, fmt.Sprintf("%s %s", qtType, paramName))
+		params = append(params, fmt.Sprintf("%s %s", g.qtParamType(p), ToCamelCase(p.Name)))
+	}
+	if !projects {
+		params = append(params, "QObject *parent")
 	}
-	params = append(params, "QObject *parent")
 	sb.WriteString(strings.Join(params, ", "))
 	sb.WriteString(")\n{\n")
 
-	// Build set of known parameter names
-	knownParams := make(map[string]bool)
-	for _, p := range query.Params {
-		knownParams[p.Name] = true
-	}
+	sql, names := selectSQL(DialectSQLite, entity, tableName, query, g.ident, TimestampEpochMillis)
 
-	// Build SQL
-	var sqlParts []string
-	sqlParts = append(sqlParts, fmt.Sprintf("SELECT * FROM %s", tableName))
-
-	if query.Where != nil {
-		whereSQL, _ := ExprToSQLWithKnownParams(query.Where, knownParams)
-		sqlParts = append(sqlParts, "WHERE "+whereSQL)
+	if !query.ReturnsOne() {
+		sb.WriteString(fmt.Sprintf("    %s results;\n", resultType))
 	}
-
-	if len(query.OrderBy) > 0 {
-		var orderParts []string
-		for _, o := range query.OrderBy {
-			dir := "ASC"
-			if o.Descending {
-				dir = "DESC"
-			}
-			orderParts = append(orderParts, fmt.Sprintf("%s %s", ToSnakeCase(o.Field), dir))
+	sb.WriteString("    QSqlQuery query(m_db);\n")
+	sb.WriteString(fmt.Sprintf("    query.prepare(%s);\n", sqlLiteral(sql)))
+
+	// Bind parameters, one value per placeholder
+	for _, name := range names {
+		p := query.Param(name)
+		optional := p.Nullable() || p.DefaultExpr != nil
+		value := ToCamelCase(name)
+		if optional {
+			value = "*" + value
 		}
-		sqlParts = append(sqlParts, "ORDER BY "+strings.Join(orderParts, ", "))
-	}
-
-	if query.Limit != nil {
-		if _, ok := query.Limit.(*parser.IdentExpr); ok {
-			sqlParts = append(sqlParts, "LIMIT ?")
-		} else if lit, ok := query.Limit.(*parser.LiteralExpr); ok {
-			if val, ok := lit.Value.(int64); ok {
-				sqlParts = append(sqlParts, fmt.Sprintf("LIMIT %d", val))
-			}
+		if enum := typeEnum(file, p.Type); enum != nil {
+			value = qtEnumKey(enum.Name, value)
 		}
-	}
-
-	sb.WriteString(fmt.Sprintf("    QList<%s*> results;\n", entityName))
-	sb.WriteString("    QSqlQuery query(m_db);\n")
-	sb.WriteString(fmt.Sprintf("    query.prepare(\"%s\");\n", strings.Join(sqlParts, " ")))
-
-	// Bind parameters
-	for _, p := range query.Params {
-		paramName := ToCamelCase(p.Name)
-		sb.WriteString(fmt.Sprintf("    query.addBindValue(%s);\n", paramName))
-	}
-
-	// Handle limit if it's a parameter
-	if query.Limit != nil {
-		if l, ok := query.Limit.(*parser.IdentExpr); ok {
-			sb.WriteString(fmt.Sprintf("    query.addBindValue(%s);\n", ToCamelCase(l.Name)))
+		if optional {
+			// A null QVariant binds SQL NULL, which the query reads as
+			// "no filter" or "use the default"
+			value = fmt.Sprintf("%s ? QVariant(%s) : QVariant()", ToCamelCase(name), value)
 		}
+		sb.WriteString(fmt.Sprintf("    query.addBindValue(%s);\n", value))
 	}
 
 	sb.WriteString("    query.exec();\n\n")
+	if query.ReturnsOne() {
+		empty := "nullptr"
+		if projects {
+			empty = "std::nullopt"
+		}
+		sb.WriteString("    if (!query.next()) {\n")
+		sb.WriteString(fmt.Sprintf("        return %s;\n", empty))
+		sb.WriteString("    }\n")
+		sb.WriteString(fmt.Sprintf("    return %s;\n", mapper))
+		sb.WriteString("}\n\n")
+		return sb.String()
+	}
 	sb.WriteString("    while (query.next()) {\n")
-	sb.WriteString("        results.append(mapRow(query, parent));\n")
+	sb.WriteString(fmt.Sprintf("        results.append(%s);\n", mapper))
 	sb.WriteString("    }\n")
 	sb.WriteString("    return results;\n")
 	sb.WriteString("}\n\n")
@@ -593,7 +624,23 @@ func (g *QtGenerator) generateQtQueryMethod(entity *parser.EntityDecl, query *pa
 	return sb.String()
 }
 
-func (g *QtGenerator) generateQtRowMapper(entity *parser.EntityDecl) string {
+// qtQueryResultType returns the type a query method returns: the entities,
+// or the rows of a projecting query by value. A @returns(one) query returns
+// a single entity, null when no row matches, or an optional row.
+func (g *QtGenerator) qtQueryResultType(entity *parser.EntityDecl, query *parser.QueryDecl) string {
+	if queryRowFields(entity, query) != nil {
+		if query.ReturnsOne() {
+			return fmt.Sprintf("std::optional<%s>", queryRowName(entity, query))
+		}
+		return fmt.Sprintf("QList<%s>", queryRowName(entity, query))
+	}
+	if query.ReturnsOne() {
+		return entity.Name + "*"
+	}
+	return fmt.Sprintf("QList<%s*>", entity.Name)
+}
+
+func (g *QtGenerator) generateQtRowMapper(file *parser.File, entity *parser.EntityDecl) string {
 	var sb strings.Builder
 	className := entity.Name + "Repository"
 	entityName := entity.Name
@@ -605,9 +652,7 @@ func (g *QtGenerator) generateQtRowMapper(entity *parser.EntityDecl) string {
 
 	var mapperLines []string
 	for i, field := range entity.Fields {
-		colName := ToSnakeCase(field.Name)
-		getter := g.qtQueryGetter(field, i)
-		mapperLines = append(mapperLines, fmt.Sprintf("        query.value(\"%s\")%s", colName, getter))
+		mapperLines = append(mapperLines, "        "+g.qtColumnValue(file, field, i))
 	}
 	sb.WriteString(strings.Join(mapperLines, ",\n"))
 	sb.WriteString(",\n        parent\n")
@@ -617,6 +662,55 @@ func (g *QtGenerator) generateQtRowMapper(entity *parser.EntityDecl) string {
 	return sb.String()
 }
 
+// qtColumnValue returns the expression reading field's column from the
+// current row of query.
+func (g *QtGenerator) qtColumnValue(file *parser.File, field *parser.FieldDecl, index int) string {
+	colName := ToSnakeCase(field.Name)
+	if enum := typeEnum(file, field.Type); enum != nil {
+		// Enums are stored by value name
+		return fmt.Sprintf("static_cast<%s>(QMetaEnum::fromType<%s>().keyToValue(query.value(\"%s\").toString().toLatin1()))",
+			enum.Name, enum.Name, colName)
+	}
+	return fmt.Sprintf("query.value(\"%s\")%s", colName, g.qtQueryGetter(field, index))
+}
+
+// generateQtQueryRow emits the struct holding a row of a projecting query,
+// with one member per selected column.
+func (g *QtGenerator) generateQtQueryRow(entity *parser.EntityDecl, query *parser.QueryDecl, fields []*parser.FieldDecl) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("// Row returned by %sRepository::%s.\n", entity.Name, ToCamelCase(query.Name)))
+	sb.WriteString(fmt.Sprintf("struct %s\n", queryRowName(entity, query)))
+	sb.WriteString("{\n")
+	for _, field := range fields {
+		sb.WriteString(fmt.Sprintf("    %s %s;\n", g.qtType(field.Type), ToCamelCase(field.Name)))
+	}
+	sb.WriteString("};\n\n")
+
+	return sb.String()
+}
+
+// generateQtQueryRowMapper emits the method mapping the current row of a
+// projecting query to its row struct.
+func (g *QtGenerator) generateQtQueryRowMapper(file *parser.File, entity *parser.EntityDecl, query *parser.QueryDecl, fields []*parser.FieldDecl) string {
+	var sb strings.Builder
+	rowType := queryRowName(entity, query)
+
+	sb.WriteString(fmt.Sprintf("%s %sRepository::map%s(const QSqlQuery &query)\n", rowType, entity.Name, rowType))
+	sb.WriteString("{\n")
+	sb.WriteString(fmt.Sprintf("    return %s{\n", rowType))
+
+	var mapperLines []string
+	for i, field := range fields {
+		mapperLines = append(mapperLines, "        "+g.qtColumnValue(file, field, i))
+	}
+	sb.WriteString(strings.Join(mapperLines, ",\n"))
+	sb.WriteString("\n    };\n")
+	sb.WriteString("}\n\n")
+
+	return sb.String()
+}
+
 func (g *QtGenerator) generateEnumHeader(enum *parser.EnumDecl) string {
 	var sb strings.Builder
 	guardName := strings.ToUpper(ToSnakeCase(enum.Name)) + "_H"
@@ -709,6 +803,16 @@ func (g *QtGenerator) qtType(typeRef *parser.TypeRef) string {
 	return baseType
 }
 
+// qtParamType returns the type of a query parameter, a std::optional when
+// callers may pass null, either to skip a filter or to use a default
+// expression.
+func (g *QtGenerator) qtParamType(p *parser.QueryParam) string {
+	if p.Nullable() || p.DefaultExpr != nil {
+		return fmt.Sprintf("std::optional<%s>", g.qtBaseType(p.Type.Name))
+	}
+	return g.qtBaseType(p.Type.Name)
+}
+
 func (g *QtGenerator) qtBaseType(typeName string) string {
 	switch typeName {
 	case "string":
@@ -754,7 +858,10 @@ func (g *QtGenerator) qtDefaultValue(field *parser.FieldDecl) string {
 }
 
 func (g *QtGenerator) qtLiteralValue(value interface{}, typeName string) string {
-	switch v := value.(type) {
+	if isNowValue(value, typeName) {
+		return "QDateTime::currentMSecsSinceEpoch()"
+	}
+	switch v := epochDefault(value, typeName).(type) {
 	case string:
 		return fmt.Sprintf("QStringLiteral(\"%s\")", v)
 	case bool:
@@ -771,6 +878,12 @@ func (g *QtGenerator) qtLiteralValue(value interface{}, typeName string) string
 	}
 }
 
+// qtEnumKey returns the value name of a Q_ENUM_NS value, as the enum CHECK
+// constraints expect it stored.
+func qtEnumKey(enum, value string) string {
+	return fmt.Sprintf("QString::fromLatin1(QMetaEnum::fromType<%s>().valueToKey(static_cast<int>(%s)))", enum, value)
+}
+
 func (g *QtGenerator) qtQueryGetter(field *parser.FieldDecl, index int) string {
 	switch field.Type.Name {
 	case "string":
@@ -791,3 +904,12 @@ func (g *QtGenerator) qtQueryGetter(field *parser.FieldDecl, index int) string {
 		return ".toString()"
 	}
 }
+
+// ident returns name as it appears in repository SQL, quoted when
+// QuoteIdentifiers is set.
+func (g *QtGenerator) ident(name string) string {
+	if g.QuoteIdentifiers {
+		return quoteIdent(name)
+	}
+	return name
+}
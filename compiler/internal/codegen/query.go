@@ -0,0 +1,575 @@
+package codegen
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aurora/dataproto/internal/parser"
+)
+
+// sqliteNowMillis is the SQLite expression for the current epoch milliseconds.
+const sqliteNowMillis = "(strftime('%s', 'now') * 1000)"
+
+// postgresNowMillis is the Postgres expression for the current epoch milliseconds.
+const postgresNowMillis = "(EXTRACT(EPOCH FROM now()) * 1000)::BIGINT"
+
+// mysqlNowMillis is the MySQL expression for the current epoch milliseconds.
+const mysqlNowMillis = "CAST(UNIX_TIMESTAMP(NOW(3)) * 1000 AS SIGNED)"
+
+// nowSQL returns the current time in dialect as timestamps are stored in
+// mode: CURRENT_TIMESTAMP for native date/times, or epoch milliseconds.
+func nowSQL(dialect Dialect, mode TimestampMode) string {
+	if mode == TimestampNative {
+		return "CURRENT_TIMESTAMP"
+	}
+	switch dialect {
+	case DialectPostgres:
+		return postgresNowMillis
+	case DialectMySQL:
+		return mysqlNowMillis
+	}
+	return sqliteNowMillis
+}
+
+// softDeleteColumn returns the column marking soft-deleted rows of entity, or
+// empty string when the entity does not use @soft_delete.
+func softDeleteColumn(entity *parser.EntityDecl) string {
+	if field := entity.SoftDeleteField(); field != "" {
+		return ToSnakeCase(field)
+	}
+	return ""
+}
+
+// FindByKeySQL builds the SELECT for the row with the given primary key
+// column, skipping soft-deleted rows.
+func FindByKeySQL(entity *parser.EntityDecl, tableName, pkCol string) string {
+	return findByKeySQL(entity, tableName, pkCol, plainIdent)
+}
+
+// findByKeySQL is FindByKeySQL with the columns written by ident.
+func findByKeySQL(entity *parser.EntityDecl, tableName, pkCol string, ident func(string) string) string {
+	sql := fmt.Sprintf("SELECT * FROM %s WHERE %s = ?", tableName, ident(pkCol))
+	if col := softDeleteColumn(entity); col != "" {
+		sql += fmt.Sprintf(" AND %s IS NULL", ident(col))
+	}
+	return sql
+}
+
+// sqlLiteral writes sql as a double-quoted string literal, escaping
+// backslashes and double quotes as Java, Swift, Python and C++ all read them.
+// Quoted identifiers are the double quotes generated SQL may contain.
+func sqlLiteral(sql string) string {
+	return `"` + escapeJSONString(sql) + `"`
+}
+
+// upsertFields returns the fields an upsert writes: all but the @computed
+// and @generated ones, which the database derives and rejects writes to.
+func upsertFields(entity *parser.EntityDecl) []*parser.FieldDecl {
+	var fields []*parser.FieldDecl
+	for _, field := range entity.Fields {
+		if !field.HasAnnotation("computed") && !field.HasAnnotation("generated") {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// queryRowName returns the name of the row type a projecting query returns.
+func queryRowName(entity *parser.EntityDecl, query *parser.QueryDecl) string {
+	return entity.Name + ToPascalCase(query.Name) + "Row"
+}
+
+// queryRowFields returns the fields of the row a query returns when it
+// projects the entity, in select order, or nil when it returns whole
+// entities. A query projects through its select list, or through its group
+// columns when it is grouped without one. Selected fields keep their
+// declaration; an aggregate gets a field named after it, such as countId for
+// COUNT(id), which the query selects under the same column name. COUNT is
+// never null, but the other aggregates are null over no rows.
+func queryRowFields(entity *parser.EntityDecl, query *parser.QueryDecl) []*parser.FieldDecl {
+	var fields []*parser.FieldDecl
+	if len(query.Select) == 0 {
+		for _, name := range query.GroupBy {
+			if field := entity.Field(name); field != nil {
+				fields = append(fields, field)
+			}
+		}
+		return fields
+	}
+	for _, item := range query.Select {
+		fn, name := parser.SplitSelectItem(item)
+		field := entity.Field(name)
+		if fn == "" {
+			if field != nil {
+				fields = append(fields, field)
+			}
+			continue
+		}
+
+		typ := &parser.TypeRef{Name: "int64"}
+		switch {
+		case fn == "COUNT":
+		case field == nil:
+			continue
+		case fn == "SUM" && (field.Type.Name == "int32" || field.Type.Name == "int64"):
+			typ = &parser.TypeRef{Name: "int64", Optional: true}
+		case fn == "SUM", fn == "AVG":
+			typ = &parser.TypeRef{Name: "double", Optional: true}
+		default:
+			// MIN and MAX keep the field's type
+			t := *field.Type
+			t.Optional = true
+			typ = &t
+		}
+		fields = append(fields, &parser.FieldDecl{Position: query.Position, Name: aggregateName(fn, name), Type: typ})
+	}
+	return fields
+}
+
+// hasQueryRows reports whether any query in file projects its entity into a
+// row type.
+func hasQueryRows(file *parser.File) bool {
+	for _, entity := range file.Entities {
+		for _, query := range entity.Queries {
+			if queryRowFields(entity, query) != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// aggregateName names the result of an aggregate over field: countId for
+// COUNT(id), or count for COUNT(*).
+func aggregateName(fn, field string) string {
+	if field == "*" {
+		return strings.ToLower(fn)
+	}
+	return strings.ToLower(fn) + ToPascalCase(field)
+}
+
+// FindAllSQL builds the SELECT for every row, skipping soft-deleted rows.
+func FindAllSQL(entity *parser.EntityDecl, tableName string) string {
+	return findAllSQL(entity, tableName, plainIdent)
+}
+
+// findAllSQL is FindAllSQL with the columns written by ident.
+func findAllSQL(entity *parser.EntityDecl, tableName string, ident func(string) string) string {
+	sql := "SELECT * FROM " + tableName
+	if col := softDeleteColumn(entity); col != "" {
+		sql += fmt.Sprintf(" WHERE %s IS NULL", ident(col))
+	}
+	return sql
+}
+
+// DeleteSQL builds the statement deleting the row with the given primary key
+// column. For @soft_delete entities it stamps the deletion time instead of
+// removing the row.
+func DeleteSQL(entity *parser.EntityDecl, tableName, pkCol string) string {
+	return DeleteSQLWithMode(entity, tableName, pkCol, TimestampEpochMillis)
+}
+
+// DeleteSQLWithMode is DeleteSQL for timestamps stored according to mode,
+// which decides how the soft-delete stamp is written.
+func DeleteSQLWithMode(entity *parser.EntityDecl, tableName, pkCol string, mode TimestampMode) string {
+	return deleteSQL(entity, tableName, pkCol, plainIdent, mode)
+}
+
+// deleteSQL is DeleteSQLWithMode with the columns written by ident.
+func deleteSQL(entity *parser.EntityDecl, tableName, pkCol string, ident func(string) string, mode TimestampMode) string {
+	if col := softDeleteColumn(entity); col != "" {
+		return fmt.Sprintf("UPDATE %s SET %s = %s WHERE %s = ? AND %s IS NULL",
+			tableName, ident(col), nowSQL(DialectSQLite, mode), ident(pkCol), ident(col))
+	}
+	return fmt.Sprintf("DELETE FROM %s WHERE %s = ?", tableName, ident(pkCol))
+}
+
+// SelectSQL builds the parameterized SELECT statement for a query against
+// tableName. Query parameters become ? placeholders. Without a select list
+// the query selects every column, or its group columns when grouped.
+// Soft-deleted rows of a @soft_delete entity are excluded unless the query is
+// @include_deleted or its WHERE clause refers to the deletion column itself.
+// A comparison against an optional parameter holds when the parameter is
+// null, and a null parameter with a default expression takes its default.
+func SelectSQL(entity *parser.EntityDecl, tableName string, query *parser.QueryDecl) string {
+	return DialectSelectSQL(DialectSQLite, entity, tableName, query)
+}
+
+// SelectSQLWithParams is SelectSQL that also returns the names of the
+// parameters to bind, in placeholder order. A parameter may be bound more
+// than once, or not at all, so repositories bind from these names rather
+// than from the query's parameter list.
+func SelectSQLWithParams(entity *parser.EntityDecl, tableName string, query *parser.QueryDecl) (string, []string) {
+	return DialectSelectSQLWithParams(DialectSQLite, entity, tableName, query)
+}
+
+// DialectSelectSQL is SelectSQL for a specific SQL dialect, written with the
+// dialect's placeholders.
+func DialectSelectSQL(dialect Dialect, entity *parser.EntityDecl, tableName string, query *parser.QueryDecl) string {
+	sql, _ := DialectSelectSQLWithParams(dialect, entity, tableName, query)
+	return sql
+}
+
+// DialectSelectSQLWithParams is SelectSQLWithParams for a specific SQL
+// dialect.
+func DialectSelectSQLWithParams(dialect Dialect, entity *parser.EntityDecl, tableName string, query *parser.QueryDecl) (string, []string) {
+	return selectSQL(dialect, entity, tableName, query, plainIdent, TimestampEpochMillis)
+}
+
+// selectSQL is DialectSelectSQLWithParams with the columns written by ident
+// and NOW() in the timestamp mode. tableName is used as given.
+func selectSQL(dialect Dialect, entity *parser.EntityDecl, tableName string, query *parser.QueryDecl, ident func(string) string, mode TimestampMode) (string, []string) {
+	var groupCols []string
+	for _, name := range query.GroupBy {
+		groupCols = append(groupCols, ident(ToSnakeCase(name)))
+	}
+
+	columns := "*"
+	if len(query.Select) > 0 {
+		var selected []string
+		for _, item := range query.Select {
+			fn, field := parser.SplitSelectItem(item)
+			col := field
+			if field != "*" {
+				col = ident(ToSnakeCase(field))
+			}
+			if fn != "" {
+				// Alias aggregates so row mappers read them by name
+				col = fmt.Sprintf("%s(%s) AS %s", fn, col, ident(ToSnakeCase(aggregateName(fn, field))))
+			}
+			selected = append(selected, col)
+		}
+		columns = strings.Join(selected, ", ")
+	} else if len(groupCols) > 0 {
+		columns = strings.Join(groupCols, ", ")
+	}
+
+	selectKeyword := "SELECT"
+	if query.Distinct {
+		selectKeyword = "SELECT DISTINCT"
+	}
+
+	var sqlParts []string
+	sqlParts = append(sqlParts, fmt.Sprintf("%s %s FROM %s", selectKeyword, columns, tableName))
+
+	// WHERE clause; LIMIT continues its placeholder numbering
+	ph := newPlaceholders(dialect, PlaceholderDialect)
+	ph.ident = ident
+	ph.timestamps = mode
+
+	var conditions []string
+	if query.Where != nil {
+		where := SimplifyExpr(FoldConstants(guardOptionalParams(injectParamDefaults(query.Where, query.Params), query.Params)))
+		conditions = append(conditions, exprToSQLWithParamsInternal(where, ph, paramSet(query.Params), dialect))
+	}
+	if col := softDeleteColumn(entity); col != "" && !query.IncludesDeleted() && !referencesColumn(query.Where, col) {
+		if bin, ok := query.Where.(*parser.BinaryExpr); ok && strings.EqualFold(bin.Op, "OR") {
+			conditions[0] = "(" + conditions[0] + ")"
+		}
+		conditions = append(conditions, ident(col)+" IS NULL")
+	}
+	if len(conditions) > 0 {
+		sqlParts = append(sqlParts, "WHERE "+strings.Join(conditions, " AND "))
+	}
+
+	// GROUP BY
+	if len(groupCols) > 0 {
+		sqlParts = append(sqlParts, "GROUP BY "+strings.Join(groupCols, ", "))
+	}
+
+	// HAVING shares the WHERE clause's placeholder numbering
+	if query.Having != nil {
+		having := SimplifyExpr(FoldConstants(guardOptionalParams(injectParamDefaults(query.Having, query.Params), query.Params)))
+		sqlParts = append(sqlParts, "HAVING "+exprToSQLWithParamsInternal(having, ph, paramSet(query.Params), dialect))
+	}
+
+	// ORDER BY
+	if len(query.OrderBy) > 0 {
+		var orderParts []string
+		for _, o := range query.OrderBy {
+			orderParts = append(orderParts, orderBySQL(dialect, o, ident))
+		}
+		sqlParts = append(sqlParts, "ORDER BY "+strings.Join(orderParts, ", "))
+	}
+
+	// LIMIT
+	if query.Limit != nil {
+		switch l := query.Limit.(type) {
+		case *parser.LiteralExpr:
+			if val, ok := l.Value.(int64); ok {
+				sqlParts = append(sqlParts, fmt.Sprintf("LIMIT %d", val))
+			}
+		case *parser.IdentExpr:
+			sqlParts = append(sqlParts, "LIMIT "+limitPlaceholder(dialect, ph, l.Name, query.Param(l.Name), query.Params))
+		}
+	} else if query.ReturnsOne() {
+		// @returns(one) reads a single row
+		sqlParts = append(sqlParts, "LIMIT 1")
+	}
+
+	return strings.Join(sqlParts, " "), ph.names
+}
+
+// injectParamDefaults replaces each reference to a parameter with a default
+// expression by COALESCE(param, default), so passing null uses the default.
+// A default may itself use the parameters declared before it, whose own
+// defaults apply in turn.
+func injectParamDefaults(expr parser.Expr, params []*parser.QueryParam) parser.Expr {
+	defaults := make(map[string]parser.Expr)
+	for _, p := range params {
+		if p.DefaultExpr == nil {
+			continue
+		}
+		defaults[p.Name] = &parser.CallExpr{Position: p.Position, Name: "COALESCE", Args: []parser.Expr{
+			&parser.IdentExpr{Position: p.Position, Name: p.Name},
+			substituteParams(p.DefaultExpr, defaults),
+		}}
+	}
+	if len(defaults) == 0 {
+		return expr
+	}
+	return substituteParams(expr, defaults)
+}
+
+// substituteParams replaces the identifiers named in exprs with their
+// expressions.
+func substituteParams(expr parser.Expr, exprs map[string]parser.Expr) parser.Expr {
+	sub := func(e parser.Expr) parser.Expr { return substituteParams(e, exprs) }
+	switch e := expr.(type) {
+	case *parser.IdentExpr:
+		if replacement, ok := exprs[e.Name]; ok {
+			return replacement
+		}
+		return e
+	case *parser.BinaryExpr:
+		return &parser.BinaryExpr{Position: e.Position, Left: sub(e.Left), Op: e.Op, Right: sub(e.Right)}
+	case *parser.UnaryExpr:
+		return &parser.UnaryExpr{Position: e.Position, Op: e.Op, Operand: sub(e.Operand)}
+	case *parser.IsNullExpr:
+		return &parser.IsNullExpr{Position: e.Position, Operand: sub(e.Operand), Not: e.Not}
+	case *parser.ParenExpr:
+		return &parser.ParenExpr{Position: e.Position, Inner: sub(e.Inner)}
+	case *parser.CallExpr:
+		args, _ := mapExprs(e.Args, sub)
+		return &parser.CallExpr{Position: e.Position, Name: e.Name, Args: args}
+	case *parser.TupleExpr:
+		elems, _ := mapExprs(e.Elements, sub)
+		return &parser.TupleExpr{Position: e.Position, Elements: elems}
+	case *parser.CaseExpr:
+		out := &parser.CaseExpr{Position: e.Position}
+		for _, when := range e.Whens {
+			out.Whens = append(out.Whens, &parser.CaseWhen{Position: when.Position, Cond: sub(when.Cond), Result: sub(when.Result)})
+		}
+		if e.Else != nil {
+			out.Else = sub(e.Else)
+		}
+		return out
+	default:
+		return expr
+	}
+}
+
+// comparisonOps are the binary operators guardOptionalParams guards.
+var comparisonOps = map[string]bool{
+	"=": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true,
+	"LIKE": true, "ILIKE": true, "IN": true,
+}
+
+// guardOptionalParams rewrites each comparison against a nullable parameter
+// into (param IS NULL OR comparison), so passing null skips that filter.
+// A guard inside NOT would make the negation false instead, so a negation
+// using nullable parameters is guarded as a whole. With ? placeholders the
+// parameter is then bound twice.
+func guardOptionalParams(expr parser.Expr, params []*parser.QueryParam) parser.Expr {
+	nullable := make(map[string]bool)
+	for _, p := range params {
+		if p.Nullable() {
+			nullable[p.Name] = true
+		}
+	}
+	if len(nullable) == 0 {
+		return expr
+	}
+	return guardComparisons(expr, nullable)
+}
+
+func guardComparisons(expr parser.Expr, nullable map[string]bool) parser.Expr {
+	switch e := expr.(type) {
+	case *parser.BinaryExpr:
+		if comparisonOps[strings.ToUpper(e.Op)] {
+			for _, operand := range rowOperands(e) {
+				if id, ok := operand.(*parser.IdentExpr); ok && nullable[id.Name] {
+					return &parser.ParenExpr{Position: e.Position, Inner: &parser.BinaryExpr{
+						Position: e.Position,
+						Left:     &parser.IsNullExpr{Position: id.Position, Operand: &parser.IdentExpr{Position: id.Position, Name: id.Name}},
+						Op:       "OR",
+						Right:    e,
+					}}
+				}
+			}
+			return e
+		}
+		return &parser.BinaryExpr{Position: e.Position, Left: guardComparisons(e.Left, nullable), Op: e.Op, Right: guardComparisons(e.Right, nullable)}
+	case *parser.UnaryExpr:
+		if e.Op == "NOT" {
+			return guardNegation(e, nullable)
+		}
+		return &parser.UnaryExpr{Position: e.Position, Op: e.Op, Operand: guardComparisons(e.Operand, nullable)}
+	case *parser.ParenExpr:
+		return &parser.ParenExpr{Position: e.Position, Inner: guardComparisons(e.Inner, nullable)}
+	default:
+		return expr
+	}
+}
+
+// guardNegation rewrites a NOT using nullable parameters into (param IS NULL
+// OR ... OR NOT ...), so passing null for any of them skips the negated
+// filter rather than matching nothing.
+func guardNegation(e *parser.UnaryExpr, nullable map[string]bool) parser.Expr {
+	var guarded parser.Expr = e
+	seen := make(map[string]bool)
+	var names []*parser.IdentExpr
+	parser.WalkExpr(e.Operand, func(sub parser.Expr) {
+		if id, ok := sub.(*parser.IdentExpr); ok && nullable[id.Name] && !seen[id.Name] {
+			seen[id.Name] = true
+			names = append(names, id)
+		}
+	})
+	if len(names) == 0 {
+		return e
+	}
+	for i := len(names) - 1; i >= 0; i-- {
+		id := names[i]
+		guarded = &parser.BinaryExpr{
+			Position: e.Position,
+			Left:     &parser.IsNullExpr{Position: id.Position, Operand: &parser.IdentExpr{Position: id.Position, Name: id.Name}},
+			Op:       "OR",
+			Right:    guarded,
+		}
+	}
+	return &parser.ParenExpr{Position: e.Position, Inner: guarded}
+}
+
+// rowOperands returns the operands of a comparison, with a row value
+// standing for each of its elements.
+func rowOperands(e *parser.BinaryExpr) []parser.Expr {
+	var operands []parser.Expr
+	for _, operand := range []parser.Expr{e.Left, e.Right} {
+		if tuple, ok := operand.(*parser.TupleExpr); ok {
+			operands = append(operands, tuple.Elements...)
+		} else {
+			operands = append(operands, operand)
+		}
+	}
+	return operands
+}
+
+// limitPlaceholder renders a LIMIT bound to param, capped at the parameter's
+// @max so callers cannot request unbounded pages. SQLite and Postgres also
+// clamp it at 0, since SQLite grants every row for a negative LIMIT. A
+// default expression applies when the caller passes null.
+func limitPlaceholder(dialect Dialect, ph *placeholders, name string, param *parser.QueryParam, params []*parser.QueryParam) string {
+	placeholder := ph.next(name)
+	if param == nil {
+		return placeholder
+	}
+	if param.DefaultExpr != nil {
+		def := SimplifyExpr(FoldConstants(injectParamDefaults(param.DefaultExpr, params)))
+		placeholder = fmt.Sprintf("COALESCE(%s, %s)", placeholder, exprToSQLWithParamsInternal(def, ph, paramSet(params), dialect))
+	}
+	max, ok := param.MaxValue()
+	if !ok {
+		return placeholder
+	}
+	switch dialect {
+	case DialectPostgres:
+		return fmt.Sprintf("GREATEST(0, LEAST(%s, %d))", placeholder, max)
+	case DialectMySQL:
+		return fmt.Sprintf("LEAST(%s, %d)", placeholder, max)
+	}
+	return fmt.Sprintf("MAX(0, MIN(%s, %d))", placeholder, max)
+}
+
+// orderBySQL renders one ORDER BY key, its column written by ident. SQLite
+// before 3.30 and MySQL have no NULLS FIRST/LAST, so there the placement is
+// simulated with a leading IS NULL key.
+func orderBySQL(dialect Dialect, o *parser.OrderByField, ident func(string) string) string {
+	col := ident(ToSnakeCase(o.Field))
+	dir := "ASC"
+	if o.Descending {
+		dir = "DESC"
+	}
+	if o.Nulls == parser.NullsDefault {
+		return col + " " + dir
+	}
+	if dialect == DialectPostgres {
+		return fmt.Sprintf("%s %s %s", col, dir, o.Nulls)
+	}
+	nullsDir := "ASC"
+	if o.Nulls == parser.NullsFirst {
+		nullsDir = "DESC"
+	}
+	return fmt.Sprintf("%s IS NULL %s, %s %s", col, nullsDir, col, dir)
+}
+
+// referencesColumn reports whether expr refers to the column col.
+func referencesColumn(expr parser.Expr, col string) bool {
+	switch e := expr.(type) {
+	case *parser.IdentExpr:
+		return ToSnakeCase(e.Name) == col
+	case *parser.BinaryExpr:
+		return referencesColumn(e.Left, col) || referencesColumn(e.Right, col)
+	case *parser.UnaryExpr:
+		return referencesColumn(e.Operand, col)
+	case *parser.IsNullExpr:
+		return referencesColumn(e.Operand, col)
+	case *parser.ParenExpr:
+		return referencesColumn(e.Inner, col)
+	case *parser.TupleExpr:
+		for _, elem := range e.Elements {
+			if referencesColumn(elem, col) {
+				return true
+			}
+		}
+	case *parser.FieldAccessExpr:
+		return referencesColumn(e.Base, col)
+	case *parser.CallExpr:
+		for _, arg := range e.Args {
+			if referencesColumn(arg, col) {
+				return true
+			}
+		}
+	case *parser.CaseExpr:
+		for _, when := range e.Whens {
+			if referencesColumn(when.Cond, col) || referencesColumn(when.Result, col) {
+				return true
+			}
+		}
+		return referencesColumn(e.Else, col)
+	}
+	return false
+}
+
+// StatementNames returns a stable prepared-statement name for every query in
+// file, formed from the entity and query names (CalendarEventEventsByDateRange).
+// A name that would collide with an earlier one gets a numeric suffix, so names
+// are unique within the file and stable as long as declaration order is.
+func StatementNames(file *parser.File) map[*parser.QueryDecl]string {
+	file = concreteFile(file)
+	names := make(map[*parser.QueryDecl]string)
+	used := make(map[string]bool)
+
+	for _, entity := range file.Entities {
+		for _, query := range entity.Queries {
+			base := entity.Name + ToPascalCase(query.Name)
+			name := base
+			for n := 2; used[name]; n++ {
+				name = fmt.Sprintf("%s%d", base, n)
+			}
+			used[name] = true
+			names[query] = name
+		}
+	}
+
+	return names
+}
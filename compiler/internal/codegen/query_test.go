@@ -0,0 +1,725 @@
+package codegen
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStatementNamesAreUnique(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+entity A {
+    @pk id: string;
+    query bC() { where id = "x" }
+}
+
+entity AB {
+    @pk id: string;
+    query c() { where id = "x" }
+}
+`)
+
+	names := StatementNames(file)
+	first := names[file.Entities[0].Queries[0]]
+	second := names[file.Entities[1].Queries[0]]
+
+	if first != "ABC" {
+		t.Errorf("Expected ABC, got %s", first)
+	}
+	if second != "ABC2" {
+		t.Errorf("Expected colliding name to be suffixed as ABC2, got %s", second)
+	}
+}
+
+func TestSelectSQLGroupBy(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+entity Event {
+    @pk id: string;
+    calendar_name: string;
+    location: string?;
+
+    query byCalendar(place: string) {
+        where location = place
+        group_by calendar_name
+        order_by calendar_name ASC
+    }
+}
+`)
+
+	got := SelectSQL(file.Entities[0], "events", file.Entities[0].Queries[0])
+	want := "SELECT calendar_name FROM events WHERE location = ? GROUP BY calendar_name ORDER BY calendar_name ASC"
+	if got != want {
+		t.Errorf("SelectSQL = %q, want %q", got, want)
+	}
+}
+
+func TestSelectSQLProjection(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+entity Event {
+    @pk id: string;
+    title: string;
+    calendarName: string;
+
+    query titles() {
+        select id, title
+    }
+
+    query counts() {
+        select calendarName, COUNT(id)
+        group_by calendarName
+    }
+
+    query calendars() {
+        select distinct calendarName
+    }
+}
+`)
+
+	queries := file.Entities[0].Queries
+	tests := []struct {
+		got, want string
+	}{
+		{SelectSQL(file.Entities[0], "events", queries[0]), "SELECT id, title FROM events"},
+		{SelectSQL(file.Entities[0], "events", queries[1]), "SELECT calendar_name, COUNT(id) AS count_id FROM events GROUP BY calendar_name"},
+		{SelectSQL(file.Entities[0], "events", queries[2]), "SELECT DISTINCT calendar_name FROM events"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("SelectSQL = %q, want %q", tt.got, tt.want)
+		}
+	}
+}
+
+func TestSoftDeleteSQL(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+@soft_delete
+entity Note {
+    @pk id: string;
+    title: string;
+    deleted_at: timestamp?;
+
+    query byTitle(term: string) {
+        where title = term OR title LIKE term
+    }
+
+    query trash() {
+        where deleted_at IS NOT NULL
+    }
+
+    query all() {
+        order_by title ASC
+    }
+}
+`)
+	entity := file.Entities[0]
+	tests := []struct {
+		got, want string
+	}{
+		{SelectSQL(entity, "notes", entity.Queries[0]), "SELECT * FROM notes WHERE (title = ? OR title LIKE ?) AND deleted_at IS NULL"},
+		{SelectSQL(entity, "notes", entity.Queries[1]), "SELECT * FROM notes WHERE deleted_at IS NOT NULL"},
+		{SelectSQL(entity, "notes", entity.Queries[2]), "SELECT * FROM notes WHERE deleted_at IS NULL ORDER BY title ASC"},
+		{FindByKeySQL(entity, "notes", "id"), "SELECT * FROM notes WHERE id = ? AND deleted_at IS NULL"},
+		{FindAllSQL(entity, "notes"), "SELECT * FROM notes WHERE deleted_at IS NULL"},
+		{DeleteSQL(entity, "notes", "id"), "UPDATE notes SET deleted_at = (strftime('%s', 'now') * 1000) WHERE id = ? AND deleted_at IS NULL"},
+		{DeleteSQLWithMode(entity, "notes", "id", TimestampNative), "UPDATE notes SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND deleted_at IS NULL"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("got %q, want %q", tt.got, tt.want)
+		}
+	}
+}
+
+func TestSoftDeleteIncludeDeleted(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+@softdelete(field: "removed_at")
+entity Note {
+    @pk id: string;
+    title: string;
+    removed_at: timestamp?;
+
+    query byTitle(term: string) {
+        where title = term
+    }
+
+    @include_deleted
+    query history(term: string) {
+        where title = term
+    }
+}
+`)
+	entity := file.Entities[0]
+	tests := []struct {
+		got, want string
+	}{
+		{SelectSQL(entity, "notes", entity.Queries[0]), "SELECT * FROM notes WHERE title = ? AND removed_at IS NULL"},
+		{SelectSQL(entity, "notes", entity.Queries[1]), "SELECT * FROM notes WHERE title = ?"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("got %q, want %q", tt.got, tt.want)
+		}
+	}
+}
+
+func TestSelectSQLILike(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+entity Event {
+    @pk id: string;
+    title: string;
+
+    query search(term: string) {
+        where title ILIKE "%" || term || "%"
+    }
+}
+`)
+
+	entity := file.Entities[0]
+	tests := []struct {
+		dialect Dialect
+		want    string
+	}{
+		{DialectSQLite, "SELECT * FROM events WHERE title LIKE '%' || ? || '%'"},
+		{DialectPostgres, "SELECT * FROM events WHERE title ILIKE '%' || $1 || '%'"},
+		{DialectMySQL, "SELECT * FROM events WHERE title LIKE CONCAT('%', ?, '%')"},
+	}
+	for _, tt := range tests {
+		got := DialectSelectSQL(tt.dialect, entity, "events", entity.Queries[0])
+		if got != tt.want {
+			t.Errorf("%s: DialectSelectSQL = %q, want %q", tt.dialect, got, tt.want)
+		}
+	}
+}
+
+func TestSelectSQLConcat(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+entity Person {
+    @pk id: string;
+    first: string;
+    last: string;
+    age: int32;
+
+    query byName(name: string) {
+        where first || " " || last = name AND (age > 0 OR last = name)
+    }
+}
+`)
+
+	entity := file.Entities[0]
+	tests := []struct {
+		dialect Dialect
+		want    string
+	}{
+		{DialectSQLite, "SELECT * FROM people WHERE first || ' ' || last = ? AND (age > 0 OR last = ?)"},
+		{DialectPostgres, "SELECT * FROM people WHERE first || ' ' || last = $1 AND (age > 0 OR last = $1)"},
+		{DialectMySQL, "SELECT * FROM people WHERE CONCAT(first, ' ', last) = ? AND (age > 0 OR last = ?)"},
+	}
+	for _, tt := range tests {
+		got := DialectSelectSQL(tt.dialect, entity, "people", entity.Queries[0])
+		if got != tt.want {
+			t.Errorf("%s: DialectSelectSQL = %q, want %q", tt.dialect, got, tt.want)
+		}
+	}
+}
+
+func TestSelectSQLRowComparison(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+entity Event {
+    @pk id: string;
+    start_date: int64;
+
+    query after(after_date: int64, after_id: string) {
+        where (start_date, id) > (after_date, after_id)
+        order_by start_date ASC
+    }
+}
+`)
+
+	entity := file.Entities[0]
+	tests := []struct {
+		dialect Dialect
+		want    string
+	}{
+		{DialectSQLite, "SELECT * FROM events WHERE (start_date, id) > (?, ?) ORDER BY start_date ASC"},
+		{DialectPostgres, "SELECT * FROM events WHERE (start_date, id) > ($1, $2) ORDER BY start_date ASC"},
+	}
+	for _, tt := range tests {
+		got := DialectSelectSQL(tt.dialect, entity, "events", entity.Queries[0])
+		if got != tt.want {
+			t.Errorf("%s: DialectSelectSQL = %q, want %q", tt.dialect, got, tt.want)
+		}
+	}
+}
+
+func TestSelectSQLParamDefaults(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+entity Event {
+    @pk id: string;
+    start_date: timestamp;
+
+    query upcoming(after: timestamp = NOW(), before: timestamp = after + 86400000) {
+        where start_date >= after AND start_date < before
+    }
+}
+`)
+
+	entity := file.Entities[0]
+	tests := []struct {
+		dialect Dialect
+		want    string
+	}{
+		{DialectSQLite, "SELECT * FROM events WHERE start_date >= COALESCE(?, " + sqliteNowMillis + ") AND " +
+			"start_date < COALESCE(?, COALESCE(?, " + sqliteNowMillis + ") + 86400000)"},
+		{DialectPostgres, "SELECT * FROM events WHERE start_date >= COALESCE($1, " + postgresNowMillis + ") AND " +
+			"start_date < COALESCE($2, COALESCE($1, " + postgresNowMillis + ") + 86400000)"},
+	}
+	for _, tt := range tests {
+		got := DialectSelectSQL(tt.dialect, entity, "events", entity.Queries[0])
+		if got != tt.want {
+			t.Errorf("%s: DialectSelectSQL = %q, want %q", tt.dialect, got, tt.want)
+		}
+	}
+
+	_, names := ExprToSQLWithParams(entity.Queries[0].Where, entity.Queries[0].Params, DialectSQLite, PlaceholderDialect)
+	if want := []string{"after", "before", "after"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("bound params = %v, want %v", names, want)
+	}
+}
+
+func TestSelectSQLCaseExpr(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+entity Event {
+    @pk id: string;
+    is_all_day: bool;
+    priority: int32;
+
+    query ranked(min: int32) {
+        where CASE WHEN is_all_day THEN 1 WHEN priority > min THEN 1 + 1 ELSE 0 END > 0
+    }
+}
+`)
+
+	got := SelectSQL(file.Entities[0], "events", file.Entities[0].Queries[0])
+	want := "SELECT * FROM events WHERE CASE WHEN is_all_day THEN 1 WHEN priority > ? THEN 2 ELSE 0 END > 0"
+	if got != want {
+		t.Errorf("SelectSQL = %q, want %q", got, want)
+	}
+}
+
+func TestSelectSQLOrderByNulls(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+entity Event {
+    @pk id: string;
+    start_date: timestamp?;
+
+    query upcoming() {
+        order_by start_date DESC NULLS LAST, id ASC
+    }
+}
+`)
+	entity := file.Entities[0]
+	query := entity.Queries[0]
+
+	got := DialectSelectSQL(DialectPostgres, entity, "events", query)
+	want := "SELECT * FROM events ORDER BY start_date DESC NULLS LAST, id ASC"
+	if got != want {
+		t.Errorf("postgres SelectSQL = %q, want %q", got, want)
+	}
+
+	got = SelectSQL(entity, "events", query)
+	want = "SELECT * FROM events ORDER BY start_date IS NULL ASC, start_date DESC, id ASC"
+	if got != want {
+		t.Errorf("sqlite SelectSQL = %q, want %q", got, want)
+	}
+}
+
+func TestSelectSQLFieldAccess(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+entity Event {
+    @pk id: string;
+    calendar: Calendar;
+
+    query inCalendar(name: string) {
+        where event.calendar.displayName = name
+    }
+}
+`)
+
+	got := SelectSQL(file.Entities[0], "event", file.Entities[0].Queries[0])
+	want := "SELECT * FROM event WHERE event.calendar.display_name = ?"
+	if got != want {
+		t.Errorf("SelectSQL = %q, want %q", got, want)
+	}
+	if sql := ExprToSQL(file.Entities[0].Queries[0].Where); sql != "event.calendar.displayName = name" {
+		t.Errorf("ExprToSQL = %q", sql)
+	}
+}
+
+func TestSelectSQLCappedLimit(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+entity Event {
+    @pk id: string;
+
+    query page(@max(100) pageSize: int32 = 20) {
+        limit pageSize
+    }
+
+    query all(count: int32) {
+        limit count
+    }
+}
+`)
+	entity := file.Entities[0]
+
+	if got, want := SelectSQL(entity, "events", entity.Queries[0]), "SELECT * FROM events LIMIT MAX(0, MIN(?, 100))"; got != want {
+		t.Errorf("sqlite SelectSQL = %q, want %q", got, want)
+	}
+	if got, want := DialectSelectSQL(DialectPostgres, entity, "events", entity.Queries[0]), "SELECT * FROM events LIMIT GREATEST(0, LEAST($1, 100))"; got != want {
+		t.Errorf("postgres SelectSQL = %q, want %q", got, want)
+	}
+	if got, want := DialectSelectSQL(DialectMySQL, entity, "events", entity.Queries[0]), "SELECT * FROM events LIMIT LEAST(?, 100)"; got != want {
+		t.Errorf("mysql SelectSQL = %q, want %q", got, want)
+	}
+	if got, want := SelectSQL(entity, "events", entity.Queries[1]), "SELECT * FROM events LIMIT ?"; got != want {
+		t.Errorf("uncapped SelectSQL = %q, want %q", got, want)
+	}
+}
+
+func TestSelectSQLLimitDefaultExpr(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+entity Event {
+    @pk id: string;
+
+    query page(@max(100) pageSize: int32 = 10 * 5) {
+        limit pageSize
+    }
+}
+`)
+	entity := file.Entities[0]
+
+	tests := []struct {
+		dialect Dialect
+		want    string
+	}{
+		{DialectSQLite, "SELECT * FROM events LIMIT MAX(0, MIN(COALESCE(?, 50), 100))"},
+		{DialectPostgres, "SELECT * FROM events LIMIT GREATEST(0, LEAST(COALESCE($1, 50), 100))"},
+		{DialectMySQL, "SELECT * FROM events LIMIT LEAST(COALESCE(?, 50), 100)"},
+	}
+	for _, tt := range tests {
+		if got := DialectSelectSQL(tt.dialect, entity, "events", entity.Queries[0]); got != tt.want {
+			t.Errorf("%s: DialectSelectSQL = %q, want %q", tt.dialect, got, tt.want)
+		}
+	}
+}
+
+func TestSelectSQLParamShadowsField(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+entity Article {
+    @pk id: string;
+    title: string;
+    authorId: string;
+
+    query byTitle(title: string) {
+        where title = title
+    }
+
+    query byAuthor(author: string) {
+        where authorId = author
+    }
+}
+`)
+	entity := file.Entities[0]
+
+	// The parameter wins on both sides; only membership decides
+	if got, want := SelectSQL(entity, "articles", entity.Queries[0]), "SELECT * FROM articles WHERE ? = ?"; got != want {
+		t.Errorf("shadowed SelectSQL = %q, want %q", got, want)
+	}
+	if got, want := SelectSQL(entity, "articles", entity.Queries[1]), "SELECT * FROM articles WHERE author_id = ?"; got != want {
+		t.Errorf("SelectSQL = %q, want %q", got, want)
+	}
+}
+
+func TestExprToSQLWithParams(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+entity Booking {
+    @pk id: string;
+    start_date: timestamp;
+
+    query since(after: timestamp) {
+        where start_date > after
+    }
+}
+`)
+	query := file.Entities[0].Queries[0]
+
+	sql, params := ExprToSQLWithParams(query.Where, query.Params, DialectSQLite, PlaceholderDialect)
+	if sql != "start_date > ?" {
+		t.Errorf("sql = %q, want %q", sql, "start_date > ?")
+	}
+	if !reflect.DeepEqual(params, []string{"after"}) {
+		t.Errorf("params = %v, want [after]", params)
+	}
+}
+
+func TestExprToSQLWithParamsPlaceholders(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+entity Booking {
+    @pk id: string;
+    start_date: timestamp;
+
+    query between(after: timestamp, before: timestamp) {
+        where start_date > after AND start_date < before
+    }
+}
+`)
+	query := file.Entities[0].Queries[0]
+
+	tests := []struct {
+		dialect Dialect
+		style   PlaceholderStyle
+		want    string
+	}{
+		{DialectSQLite, PlaceholderDialect, "start_date > ? AND start_date < ?"},
+		{DialectPostgres, PlaceholderDialect, "start_date > $1 AND start_date < $2"},
+		{DialectSQLite, PlaceholderDollar, "start_date > $1 AND start_date < $2"},
+		{DialectPostgres, PlaceholderQuestion, "start_date > ? AND start_date < ?"},
+		{DialectSQLite, PlaceholderNamed, "start_date > :after AND start_date < :before"},
+	}
+	for _, tt := range tests {
+		sql, params := ExprToSQLWithParams(query.Where, query.Params, tt.dialect, tt.style)
+		if sql != tt.want {
+			t.Errorf("%s/%d: sql = %q, want %q", tt.dialect, tt.style, sql, tt.want)
+		}
+		if !reflect.DeepEqual(params, []string{"after", "before"}) {
+			t.Errorf("%s/%d: params = %v, want [after before]", tt.dialect, tt.style, params)
+		}
+	}
+}
+
+func TestPostgresSelectSQLNumbersPlaceholders(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+entity Event {
+    @pk id: string;
+    title: string;
+
+    query page(term: string, @max(50) size: int32) {
+        where title = term
+        limit size
+    }
+}
+`)
+	entity := file.Entities[0]
+
+	if got, want := DialectSelectSQL(DialectPostgres, entity, "events", entity.Queries[0]), "SELECT * FROM events WHERE title = $1 LIMIT GREATEST(0, LEAST($2, 50))"; got != want {
+		t.Errorf("postgres SelectSQL = %q, want %q", got, want)
+	}
+}
+
+func TestSelectSQLHaving(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+entity Event {
+    @pk id: string;
+    calendarName: string;
+    title: string;
+
+    query busy(term: string, min: int32, n: int32) {
+        select calendarName, COUNT(*)
+        where title LIKE term
+        group_by calendarName
+        having COUNT(id) >= min
+        limit n
+    }
+}
+`)
+
+	entity := file.Entities[0]
+	query := entity.Queries[0]
+	tests := []struct {
+		dialect Dialect
+		want    string
+	}{
+		{DialectSQLite, "SELECT calendar_name, COUNT(*) AS count FROM events WHERE title LIKE ? GROUP BY calendar_name HAVING COUNT(id) >= ? LIMIT ?"},
+		{DialectPostgres, "SELECT calendar_name, COUNT(*) AS count FROM events WHERE title LIKE $1 GROUP BY calendar_name HAVING COUNT(id) >= $2 LIMIT $3"},
+	}
+	for _, tt := range tests {
+		if got := DialectSelectSQL(tt.dialect, entity, "events", query); got != tt.want {
+			t.Errorf("DialectSelectSQL(%v) =\n%s\nwant\n%s", tt.dialect, got, tt.want)
+		}
+	}
+}
+
+func TestSelectSQLReturnsOne(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+entity Task {
+    @pk id: string;
+    slug: string;
+
+    @returns(one)
+    query bySlug(s: string) {
+        where slug = s
+    }
+
+    @returns(one)
+    query first() {
+        limit 5
+    }
+
+    @returns(many)
+    query all() {
+        where slug != ""
+    }
+}
+`)
+
+	entity := file.Entities[0]
+	tests := []string{
+		"SELECT * FROM tasks WHERE slug = ? LIMIT 1",
+		"SELECT * FROM tasks LIMIT 5",
+		"SELECT * FROM tasks WHERE slug != ''",
+	}
+	for i, want := range tests {
+		if got := SelectSQL(entity, "tasks", entity.Queries[i]); got != want {
+			t.Errorf("SelectSQL(%s) =\n%s\nwant\n%s", entity.Queries[i].Name, got, want)
+		}
+	}
+}
+
+func TestSelectSQLOptionalParamGuard(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+entity Task {
+    @pk id: string;
+    state: string;
+    title: string;
+
+    query search(status?: string, term: string) {
+        where state = status AND title LIKE term
+    }
+}
+`)
+
+	entity := file.Entities[0]
+	query := entity.Queries[0]
+	tests := []struct {
+		dialect Dialect
+		want    string
+	}{
+		{DialectSQLite, "SELECT * FROM tasks WHERE (? IS NULL OR state = ?) AND title LIKE ?"},
+		{DialectPostgres, "SELECT * FROM tasks WHERE ($1 IS NULL OR state = $1) AND title LIKE $2"},
+	}
+	for _, tt := range tests {
+		if got := DialectSelectSQL(tt.dialect, entity, "tasks", query); got != tt.want {
+			t.Errorf("DialectSelectSQL(%v) =\n%s\nwant\n%s", tt.dialect, got, tt.want)
+		}
+	}
+
+	sql, names := ExprToSQLWithParams(query.Where, query.Params, DialectSQLite, PlaceholderNamed)
+	if want := "(:status IS NULL OR state = :status) AND title LIKE :term"; sql != want {
+		t.Errorf("ExprToSQLWithParams = %q, want %q", sql, want)
+	}
+	if want := []string{"status", "term"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("names = %v, want %v", names, want)
+	}
+}
+
+func TestSelectSQLOptionalParamUnderNot(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+@table("tasks")
+entity Task {
+    @pk id: string;
+    state: string;
+    title: string;
+
+    query excluding(status?: string, term: string) {
+        where NOT (state = status OR title = term)
+    }
+}
+`)
+
+	entity := file.Entities[0]
+	sql, names := SelectSQLWithParams(entity, "tasks", entity.Queries[0])
+	if want := "SELECT * FROM tasks WHERE (? IS NULL OR NOT (state = ? OR title = ?))"; sql != want {
+		t.Errorf("SelectSQLWithParams = %q, want %q", sql, want)
+	}
+	if want := []string{"status", "status", "term"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("names = %v, want %v", names, want)
+	}
+}
+
+func TestSimplifiedAwayParamsAreNotBound(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+@table("tasks")
+entity Task {
+    @pk id: string;
+    done: bool;
+    priority: int32;
+
+    query pending(least: int32, flag: bool) {
+        where (priority >= least OR true) AND done = flag
+    }
+}
+`)
+
+	entity := file.Entities[0]
+	sql, names := SelectSQLWithParams(entity, "tasks", entity.Queries[0])
+	if want := "SELECT * FROM tasks WHERE done = ?"; sql != want {
+		t.Errorf("SelectSQLWithParams = %q, want %q", sql, want)
+	}
+	if want := []string{"flag"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("names = %v, want %v", names, want)
+	}
+
+	out, err := NewJavaGenerator().Generate(file)
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+	java := out["TaskRepository.java"]
+	if !strings.Contains(java, "stmt.setBoolean(1, flag);") || strings.Contains(java, "least);") {
+		t.Errorf("Expected only flag to be bound, got:\n%s", java)
+	}
+}
@@ -0,0 +1,70 @@
+package codegen
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Generator)
+)
+
+func init() {
+	Register("proto", NewProtoGenerator())
+	Register("sqlite", NewSQLiteGenerator())
+	Register("postgres", NewPostgresGenerator())
+	Register("java", NewJavaGenerator())
+	Register("kotlin", NewKotlinGenerator())
+	Register("swift", NewSwiftGenerator())
+	Register("python", NewPythonGenerator())
+	Register("qt", NewQtGenerator())
+	Register("mongodb", NewMongoDBGenerator())
+	Register("rust", NewRustGenerator())
+	Register("go", NewGoGenerator())
+	Register("go-grpc", NewGoGRPCGenerator())
+	Register("jsonschema", NewJSONSchemaGenerator())
+	Register("graphql", NewGraphQLGenerator())
+	Register("mermaid", NewMermaidGenerator())
+	Register("openapi", NewOpenAPIGenerator())
+}
+
+// Register makes a generator available under name. Like database/sql's
+// Register it panics if g is nil or name is already taken, since both are
+// programming errors best caught at init time. The one instance serves every
+// compilation, possibly concurrently, so its Generate must not modify it.
+func Register(name string, g Generator) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if g == nil {
+		panic("codegen: Register generator is nil")
+	}
+	if _, dup := registry[name]; dup {
+		panic(fmt.Sprintf("codegen: Register called twice for generator %q", name))
+	}
+	registry[name] = g
+}
+
+// Get returns the generator registered under name.
+func Get(name string) (Generator, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	g, ok := registry[name]
+	return g, ok
+}
+
+// Names returns the names of all registered generators, sorted.
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,49 @@
+package codegen
+
+import (
+	"testing"
+
+	"github.com/aurora/dataproto/internal/parser"
+)
+
+type fakeGenerator struct{}
+
+func (fakeGenerator) Generate(file *parser.File) (map[string]string, error) {
+	return map[string]string{"fake.txt": "fake"}, nil
+}
+
+func TestRegistry(t *testing.T) {
+	Register("fake-registry-test", fakeGenerator{})
+
+	g, ok := Get("fake-registry-test")
+	if !ok {
+		t.Fatal("Expected the fake generator to be registered")
+	}
+	if _, isFake := g.(fakeGenerator); !isFake {
+		t.Errorf("Expected fakeGenerator, got %T", g)
+	}
+
+	if _, ok := Get("no-such-generator"); ok {
+		t.Error("Expected no generator for an unregistered name")
+	}
+
+	for _, name := range []string{"proto", "sqlite", "postgres", "go", "openapi"} {
+		if _, ok := Get(name); !ok {
+			t.Errorf("Expected built-in generator %s to be registered", name)
+		}
+	}
+
+	names := Names()
+	for i := 1; i < len(names); i++ {
+		if names[i-1] >= names[i] {
+			t.Fatalf("Expected sorted names, got %v", names)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected registering a duplicate name to panic")
+		}
+	}()
+	Register("proto", fakeGenerator{})
+}
@@ -0,0 +1,155 @@
+package codegen
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aurora/dataproto/internal/parser"
+)
+
+// RustGenerator generates Rust models with serde derives from DataProto schemas.
+type RustGenerator struct{}
+
+// NewRustGenerator creates a new RustGenerator.
+func NewRustGenerator() *RustGenerator {
+	return &RustGenerator{}
+}
+
+// rustKeywords are identifiers that cannot name a field or variant. They
+// get a trailing underscore rather than the r# prefix, which crate, self,
+// super and Self do not accept.
+var rustKeywords = map[string]bool{
+	"as": true, "async": true, "await": true, "break": true, "const": true,
+	"continue": true, "crate": true, "dyn": true, "else": true, "enum": true,
+	"extern": true, "false": true, "fn": true, "for": true, "if": true,
+	"impl": true, "in": true, "let": true, "loop": true, "match": true,
+	"mod": true, "move": true, "mut": true, "pub": true, "ref": true,
+	"return": true, "self": true, "Self": true, "static": true, "struct": true,
+	"super": true, "trait": true, "true": true, "type": true, "unsafe": true,
+	"use": true, "where": true, "while": true, "abstract": true, "become": true,
+	"box": true, "do": true, "final": true, "macro": true, "override": true,
+	"priv": true, "try": true, "typeof": true, "unsized": true, "virtual": true,
+	"yield": true,
+}
+
+// Generate generates a single Rust source file from a DataProto file.
+func (g *RustGenerator) Generate(file *parser.File) (map[string]string, error) {
+	file = concreteFile(file)
+	result := make(map[string]string)
+
+	var sb strings.Builder
+
+	// Header
+	sb.WriteString("// Code generated by dataprotoc. DO NOT EDIT.\n")
+	sb.WriteString("// source: ")
+	if file.Package != nil {
+		sb.WriteString(file.Package.Name)
+	}
+	sb.WriteString(".dataproto\n\n")
+	sb.WriteString("use serde::{Deserialize, Serialize};\n")
+
+	for _, enum := range file.Enums {
+		sb.WriteString("\n")
+		sb.WriteString(g.generateEnum(enum))
+	}
+
+	for _, entity := range file.Entities {
+		sb.WriteString("\n")
+		sb.WriteString(g.generateStruct(entity))
+	}
+
+	// Generate filename
+	filename := "models.rs"
+	if file.Package != nil {
+		parts := strings.Split(file.Package.Name, ".")
+		filename = parts[len(parts)-1] + ".rs"
+	}
+
+	result[filename] = sb.String()
+	return result, nil
+}
+
+func (g *RustGenerator) generateEnum(enum *parser.EnumDecl) string {
+	var sb strings.Builder
+
+	sb.WriteString("#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash, Serialize, Deserialize)]\n")
+	sb.WriteString(fmt.Sprintf("pub enum %s {\n", enum.Name))
+
+	for _, val := range enum.Values {
+		if enum.AliasOf(val) != nil {
+			continue
+		}
+		variant := rustVariant(val.Name)
+		// Keep the schema's value name on the wire, and read aliases too
+		var attrs []string
+		if variant != val.Name {
+			attrs = append(attrs, fmt.Sprintf("rename = \"%s\"", val.Name))
+		}
+		for _, other := range enum.Values {
+			if enum.AliasOf(other) == val {
+				attrs = append(attrs, fmt.Sprintf("alias = \"%s\"", other.Name))
+			}
+		}
+		if len(attrs) > 0 {
+			sb.WriteString(fmt.Sprintf("    #[serde(%s)]\n", strings.Join(attrs, ", ")))
+		}
+		sb.WriteString(fmt.Sprintf("    %s = %d,\n", variant, val.Number))
+	}
+
+	sb.WriteString("}\n")
+
+	// Discriminants must be unique, so an alias is a constant naming the
+	// variant it aliases
+	var aliases []string
+	for _, val := range enum.Values {
+		if alias := enum.AliasOf(val); alias != nil {
+			aliases = append(aliases, fmt.Sprintf("    pub const %s: %s = %s::%s;\n",
+				ToScreamingSnakeCase(ToCamelCase(val.Name)), enum.Name, enum.Name, rustVariant(alias.Name)))
+		}
+	}
+	if len(aliases) > 0 {
+		sb.WriteString(fmt.Sprintf("\nimpl %s {\n", enum.Name))
+		sb.WriteString(strings.Join(aliases, ""))
+		sb.WriteString("}\n")
+	}
+	return sb.String()
+}
+
+// rustVariant returns the variant name for an enum value.
+func rustVariant(name string) string {
+	variant := ToPascalCase(name)
+	if rustKeywords[variant] {
+		variant += "_"
+	}
+	return variant
+}
+
+func (g *RustGenerator) generateStruct(entity *parser.EntityDecl) string {
+	var sb strings.Builder
+
+	sb.WriteString("#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]\n")
+	sb.WriteString(fmt.Sprintf("pub struct %s {\n", entity.Name))
+
+	for _, field := range entity.Fields {
+		// snake_case is both the Rust convention and the wire name
+		name := ToSnakeCase(field.Name)
+		if rustKeywords[name] {
+			name += "_"
+		}
+		if wireName := JSONName(field); wireName != name {
+			sb.WriteString(fmt.Sprintf("    #[serde(rename = \"%s\")]\n", wireName))
+		}
+		sb.WriteString(fmt.Sprintf("    pub %s: %s,\n", name, g.rustType(field.Type)))
+	}
+
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+func (g *RustGenerator) rustType(typeRef *parser.TypeRef) string {
+	baseType := GetTypeMapping(typeRef.Name).Rust
+	if typeRef.Optional {
+		return fmt.Sprintf("Option<%s>", baseType)
+	}
+	return baseType
+}
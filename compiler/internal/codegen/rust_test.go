@@ -0,0 +1,92 @@
+package codegen
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRustGolden(t *testing.T) {
+	file := mustParse(t, `
+package aurora.tasks;
+
+enum TaskStatus {
+    PENDING = 0;
+    IN_PROGRESS = 1;
+}
+
+entity Task {
+    @pk id: string;
+    title: string;
+    type: string;
+    status: TaskStatus;
+    due_date: timestamp?;
+    attachment: bytes?;
+    retryCount: int32;
+    score: double;
+}
+`)
+
+	code := generateOne(t, NewRustGenerator(), file)
+	assertGolden(t, "rust/tasks.rs.golden", code)
+}
+
+func TestRustRenamesKeywords(t *testing.T) {
+	file := mustParse(t, `
+package aurora.links;
+
+enum Target {
+    SELF = 0;
+    OTHER = 1;
+}
+
+entity Link {
+    @pk id: string;
+    self: string;
+    crate: string;
+    target: Target;
+}
+`)
+
+	code := generateOne(t, NewRustGenerator(), file)
+	for _, want := range []string{
+		"#[serde(rename = \"self\")]\n    pub self_: String,",
+		"#[serde(rename = \"crate\")]\n    pub crate_: String,",
+		"#[serde(rename = \"SELF\")]\n    Self_ = 0,",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("Expected %q in:\n%s", want, code)
+		}
+	}
+	if strings.Contains(code, "r#") {
+		t.Errorf("Expected no raw identifiers in:\n%s", code)
+	}
+}
+
+func TestRustEnumAlias(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+enum Status {
+    option allow_alias = true;
+    ACTIVE = 0;
+    DELETED = 3;
+    REMOVED = 3;
+}
+`)
+
+	code := generateOne(t, NewRustGenerator(), file)
+	want := `pub enum Status {
+    #[serde(rename = "ACTIVE")]
+    Active = 0,
+    #[serde(rename = "DELETED", alias = "REMOVED")]
+    Deleted = 3,
+}
+
+impl Status {
+    pub const REMOVED: Status = Status::Deleted;
+}
+`
+	if !strings.Contains(code, want) {
+		t.Errorf("Expected %q in output, got:\n%s", want, code)
+	}
+}
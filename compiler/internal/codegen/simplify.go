@@ -0,0 +1,158 @@
+package codegen
+
+import "github.com/aurora/dataproto/internal/parser"
+
+// SimplifyExpr returns an equivalent expression with trivial boolean
+// structure removed:
+//
+//	NOT NOT x    -> x
+//	x AND true   -> x        x OR false  -> x
+//	x AND false  -> false    x OR true   -> true
+//
+// The short-circuit rules never drop an operand that contains a function
+// call, since calls like NOW() are not guaranteed to be pure. All rules hold
+// under SQL three-valued logic. The input tree is not modified.
+func SimplifyExpr(expr parser.Expr) parser.Expr {
+	switch e := expr.(type) {
+	case *parser.UnaryExpr:
+		operand := SimplifyExpr(e.Operand)
+		if e.Op == "NOT" {
+			if inner, ok := unparen(operand).(*parser.UnaryExpr); ok && inner.Op == "NOT" {
+				// The inner operand sat in unary position, so it is
+				// self-delimiting wherever it ends up.
+				return inner.Operand
+			}
+		}
+		if operand == e.Operand {
+			return e
+		}
+		return &parser.UnaryExpr{Position: e.Position, Op: e.Op, Operand: operand}
+
+	case *parser.BinaryExpr:
+		left := SimplifyExpr(e.Left)
+		right := SimplifyExpr(e.Right)
+
+		switch e.Op {
+		case "AND":
+			if b, ok := boolLiteral(right); ok {
+				if b {
+					return left
+				}
+				if !containsCall(left) {
+					return right
+				}
+			}
+			if b, ok := boolLiteral(left); ok {
+				if b {
+					return right
+				}
+				if !containsCall(right) {
+					return left
+				}
+			}
+		case "OR":
+			if b, ok := boolLiteral(right); ok {
+				if !b {
+					return left
+				}
+				if !containsCall(left) {
+					return right
+				}
+			}
+			if b, ok := boolLiteral(left); ok {
+				if !b {
+					return right
+				}
+				if !containsCall(right) {
+					return left
+				}
+			}
+		}
+
+		if left == e.Left && right == e.Right {
+			return e
+		}
+		return &parser.BinaryExpr{Position: e.Position, Left: left, Op: e.Op, Right: right}
+
+	case *parser.ParenExpr:
+		inner := SimplifyExpr(e.Inner)
+		switch inner.(type) {
+		case *parser.IdentExpr, *parser.FieldAccessExpr, *parser.LiteralExpr, *parser.CallExpr, *parser.ParenExpr,
+			*parser.TupleExpr, *parser.CaseExpr:
+			// Parentheses around an atomic expression are redundant
+			return inner
+		}
+		if inner == e.Inner {
+			return e
+		}
+		return &parser.ParenExpr{Position: e.Position, Inner: inner}
+
+	case *parser.TupleExpr:
+		if elems, changed := mapExprs(e.Elements, SimplifyExpr); changed {
+			return &parser.TupleExpr{Position: e.Position, Elements: elems}
+		}
+		return e
+
+	case *parser.IsNullExpr:
+		operand := SimplifyExpr(e.Operand)
+		if operand == e.Operand {
+			return e
+		}
+		return &parser.IsNullExpr{Position: e.Position, Operand: operand, Not: e.Not}
+
+	default:
+		return expr
+	}
+}
+
+// unparen strips any enclosing parentheses from expr.
+func unparen(expr parser.Expr) parser.Expr {
+	for {
+		p, ok := expr.(*parser.ParenExpr)
+		if !ok {
+			return expr
+		}
+		expr = p.Inner
+	}
+}
+
+// boolLiteral reports whether expr is a boolean literal, and its value.
+func boolLiteral(expr parser.Expr) (bool, bool) {
+	if lit, ok := unparen(expr).(*parser.LiteralExpr); ok {
+		b, ok := lit.Value.(bool)
+		return b, ok
+	}
+	return false, false
+}
+
+// containsCall reports whether expr contains a function call.
+func containsCall(expr parser.Expr) bool {
+	switch e := expr.(type) {
+	case *parser.CallExpr:
+		return true
+	case *parser.BinaryExpr:
+		return containsCall(e.Left) || containsCall(e.Right)
+	case *parser.UnaryExpr:
+		return containsCall(e.Operand)
+	case *parser.IsNullExpr:
+		return containsCall(e.Operand)
+	case *parser.ParenExpr:
+		return containsCall(e.Inner)
+	case *parser.TupleExpr:
+		for _, elem := range e.Elements {
+			if containsCall(elem) {
+				return true
+			}
+		}
+		return false
+	case *parser.CaseExpr:
+		for _, when := range e.Whens {
+			if containsCall(when.Cond) || containsCall(when.Result) {
+				return true
+			}
+		}
+		return e.Else != nil && containsCall(e.Else)
+	default:
+		return false
+	}
+}
@@ -0,0 +1,67 @@
+package codegen
+
+import (
+	"testing"
+
+	"github.com/aurora/dataproto/internal/parser"
+)
+
+// parseWhere parses where as the filter of a query and returns its expression.
+func parseWhere(t *testing.T, where string) parser.Expr {
+	t.Helper()
+	file := mustParse(t, `
+package test;
+
+entity Task {
+    @pk id: string;
+    done: bool;
+    priority: int32;
+    query Filter() {
+        where `+where+`
+    }
+}
+`)
+	return file.Entities[0].Queries[0].Where
+}
+
+func TestSimplifyExpr(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"double negation", "NOT (NOT done)", "done"},
+		{"double negation unparenthesized", "NOT NOT done", "done"},
+		{"double negation keeps grouping", "NOT (NOT (done OR priority > 1))", "(done OR priority > 1)"},
+		{"and true", "priority > 1 AND true", "priority > 1"},
+		{"true and", "true AND priority > 1", "priority > 1"},
+		{"or false", "priority > 1 OR false", "priority > 1"},
+		{"false or", "false OR priority > 1", "priority > 1"},
+		{"and false", "priority > 1 AND false", "0"},
+		{"or true", "priority > 1 OR true", "1"},
+		{"nested identity", "done AND (priority > 1 OR false)", "done AND (priority > 1)"},
+		{"redundant parens", "NOT (done AND true)", "NOT done"},
+		{"call kept in and false", "COALESCE(done, false) AND false", "COALESCE(done, 0) AND 0"},
+		{"call kept in or true", "priority > COUNT(id) OR true", "priority > COUNT(id) OR 1"},
+		{"call with identity", "NOW() > priority AND true", "NOW() > priority"},
+		{"unchanged", "done = false", "done = 0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ExprToSQL(SimplifyExpr(parseWhere(t, tt.input)))
+			if got != tt.want {
+				t.Errorf("SimplifyExpr(%s) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSimplifyExprDoesNotModifyInput(t *testing.T) {
+	expr := parseWhere(t, "NOT (NOT done) AND true")
+	before := ExprToSQL(expr)
+	SimplifyExpr(expr)
+	if after := ExprToSQL(expr); after != before {
+		t.Errorf("Input modified: %q became %q", before, after)
+	}
+}
@@ -0,0 +1,415 @@
+package codegen
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aurora/dataproto/internal/parser"
+)
+
+// Dialect selects the SQL flavor query statements are generated for.
+type Dialect int
+
+const (
+	DialectSQLite Dialect = iota
+	DialectPostgres
+	DialectMySQL
+)
+
+func (d Dialect) String() string {
+	switch d {
+	case DialectSQLite:
+		return "sqlite"
+	case DialectPostgres:
+		return "postgres"
+	case DialectMySQL:
+		return "mysql"
+	default:
+		return "unknown"
+	}
+}
+
+// PlaceholderStyle selects how bind parameters are written in generated SQL.
+type PlaceholderStyle int
+
+const (
+	// PlaceholderDialect uses the dialect's own style: ? for SQLite and
+	// MySQL, and $1, $2, ... for Postgres.
+	PlaceholderDialect PlaceholderStyle = iota
+	// PlaceholderQuestion writes every parameter as ?.
+	PlaceholderQuestion
+	// PlaceholderDollar numbers parameters $1, $2, ... in order of appearance.
+	PlaceholderDollar
+	// PlaceholderNamed writes a parameter as :name, as sqlx expects.
+	PlaceholderNamed
+)
+
+// placeholders renders the placeholders of one statement, recording the
+// bound parameter names in order. Numbered and named placeholders are
+// reused when a parameter appears again, so each is bound once; a ? is
+// bound once per occurrence. Columns the statement refers to are written
+// by ident, and NOW() as timestamps are stored.
+type placeholders struct {
+	style      PlaceholderStyle
+	names      []string
+	ident      func(string) string
+	timestamps TimestampMode
+}
+
+func newPlaceholders(dialect Dialect, style PlaceholderStyle) *placeholders {
+	if style == PlaceholderDialect {
+		style = PlaceholderQuestion
+		if dialect == DialectPostgres {
+			style = PlaceholderDollar
+		}
+	}
+	return &placeholders{style: style, ident: plainIdent}
+}
+
+// next returns the placeholder binding the parameter name.
+func (p *placeholders) next(name string) string {
+	if p.style == PlaceholderDollar || p.style == PlaceholderNamed {
+		for i, bound := range p.names {
+			if bound == name {
+				return p.render(i+1, name)
+			}
+		}
+	}
+	p.names = append(p.names, name)
+	return p.render(len(p.names), name)
+}
+
+// render writes the placeholder for the n-th bound parameter, name.
+func (p *placeholders) render(n int, name string) string {
+	switch p.style {
+	case PlaceholderDollar:
+		return fmt.Sprintf("$%d", n)
+	case PlaceholderNamed:
+		return ":" + name
+	default:
+		return "?"
+	}
+}
+
+// onDeleteAction maps an @ondelete action to its SQL referential action.
+func onDeleteAction(action string) string {
+	switch strings.ToLower(action) {
+	case "cascade":
+		return "CASCADE"
+	case "setnull":
+		return "SET NULL"
+	default:
+		return "RESTRICT"
+	}
+}
+
+// referencedTable returns the table name of the named entity, honoring its
+// @table annotation when the entity is declared in file.
+func referencedTable(file *parser.File, entityName string) string {
+	if target := file.Entity(entityName); target != nil && target.TableName() != "" {
+		return target.TableName()
+	}
+	return ToSnakeCase(entityName)
+}
+
+// referencedSchema returns the schema of the named entity's table when the
+// entity is declared in file, or empty string.
+func referencedSchema(file *parser.File, entityName string) string {
+	if target := file.Entity(entityName); target != nil {
+		return target.SchemaName()
+	}
+	return ""
+}
+
+// schemaTable writes table with ident, qualified by schema in dialects that
+// have schemas. SQLite has none, so it ignores the schema of @table.
+func schemaTable(dialect Dialect, schema, table string, ident func(string) string) string {
+	if schema == "" || dialect == DialectSQLite {
+		return ident(table)
+	}
+	return ident(schema) + "." + ident(table)
+}
+
+// sqlForeignKey is a foreign key resolved to SQL names.
+type sqlForeignKey struct {
+	Columns    []string
+	RefSchema  string
+	RefTable   string
+	RefColumns []string
+	OnDelete   string
+}
+
+// fieldForeignKey resolves a field's single-column @fk("Entity.field")
+// annotation, with its @ondelete action; ON DELETE defaults to RESTRICT.
+func fieldForeignKey(file *parser.File, field *parser.FieldDecl) (sqlForeignKey, bool) {
+	fk := field.GetAnnotation("fk")
+	if fk == nil || len(fk.Args) == 0 {
+		return sqlForeignKey{}, false
+	}
+	ref, ok := fk.Args[0].Value.(string)
+	if !ok {
+		return sqlForeignKey{}, false
+	}
+	parts := strings.Split(ref, ".")
+	if len(parts) != 2 {
+		return sqlForeignKey{}, false
+	}
+
+	onDelete := "RESTRICT"
+	if od := field.GetAnnotation("ondelete"); od != nil && len(od.Args) > 0 {
+		if action, ok := od.Args[0].Value.(string); ok {
+			onDelete = onDeleteAction(action)
+		}
+	}
+	return sqlForeignKey{
+		Columns:    []string{ToSnakeCase(field.Name)},
+		RefSchema:  referencedSchema(file, parts[0]),
+		RefTable:   referencedTable(file, parts[0]),
+		RefColumns: []string{ToSnakeCase(parts[1])},
+		OnDelete:   onDelete,
+	}, true
+}
+
+// compositeForeignKeys resolves an entity's multi-column @fk annotations
+// against the target entities' primary keys. Keys whose target is missing or
+// whose arity does not match are skipped; the checker reports those.
+func compositeForeignKeys(file *parser.File, entity *parser.EntityDecl) []sqlForeignKey {
+	var fks []sqlForeignKey
+	for _, fk := range entity.ForeignKeys() {
+		target := file.Entity(fk.References)
+		if target == nil {
+			continue
+		}
+		pks := target.PrimaryKeyFields()
+		if len(pks) != len(fk.Fields) {
+			continue
+		}
+
+		resolved := sqlForeignKey{
+			RefSchema: target.SchemaName(),
+			RefTable:  referencedTable(file, target.Name),
+			OnDelete:  onDeleteAction(fk.OnDelete),
+		}
+		for i, name := range fk.Fields {
+			resolved.Columns = append(resolved.Columns, ToSnakeCase(name))
+			resolved.RefColumns = append(resolved.RefColumns, ToSnakeCase(pks[i].Name))
+		}
+		fks = append(fks, resolved)
+	}
+	return fks
+}
+
+// typeEnum returns the enum t refers to, inline enums included, or nil when
+// t is not an enum.
+func typeEnum(file *parser.File, t *parser.TypeRef) *parser.EnumDecl {
+	if t.Enum != nil {
+		return t.Enum
+	}
+	return file.Enum(t.Name)
+}
+
+// enumCheck returns the CHECK condition limiting an enum-typed column col to
+// the enum's value names, which is how the repositories store an enum. A
+// column whose type @column overrides for backend may hold a native enum or
+// the numbers instead, so it gets no check.
+func enumCheck(file *parser.File, field *parser.FieldDecl, backend, col string) (string, bool) {
+	if field.ColumnType(backend) != "" {
+		return "", false
+	}
+	enum := typeEnum(file, field.Type)
+	if enum == nil || len(enum.Values) == 0 {
+		return "", false
+	}
+	var names []string
+	for _, val := range enum.Values {
+		names = append(names, sqlString(val.Name))
+	}
+	return fmt.Sprintf("%s IN (%s)", col, strings.Join(names, ", ")), true
+}
+
+// boundsCheck returns the CHECK condition enforcing a field's @range, @min
+// and @max bounds on column col.
+func boundsCheck(field *parser.FieldDecl, col string) (string, bool) {
+	min, max := field.NumericBounds()
+	var conds []string
+	if min != nil {
+		conds = append(conds, fmt.Sprintf("%s >= %s", col, formatBound(*min)))
+	}
+	if max != nil {
+		conds = append(conds, fmt.Sprintf("%s <= %s", col, formatBound(*max)))
+	}
+	return strings.Join(conds, " AND "), len(conds) > 0
+}
+
+// formatBound formats a range bound without a trailing fraction for integers.
+func formatBound(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// fieldPattern returns the regex of a @pattern annotation on a string field.
+func fieldPattern(field *parser.FieldDecl) (string, bool) {
+	if field.Type.Name != "string" {
+		return "", false
+	}
+	pattern := field.Pattern()
+	return pattern, pattern != ""
+}
+
+// notNullColumn reports whether a column is declared NOT NULL: non-optional
+// fields and optional fields marked @required. Primary key columns carry the
+// constraint through their key instead.
+func notNullColumn(field *parser.FieldDecl) bool {
+	return !field.IsPrimaryKey() && !nullableField(field)
+}
+
+// isNowDefault reports whether a timestamp @default means the current time,
+// written @default(now) or @default("now").
+func isNowDefault(value string) bool {
+	return strings.EqualFold(value, "now")
+}
+
+// timestampLiteral parses a timestamp @default written as an RFC 3339 time,
+// such as "2024-01-01T00:00:00Z".
+func timestampLiteral(value string) (time.Time, bool) {
+	t, err := time.Parse(time.RFC3339, value)
+	return t, err == nil
+}
+
+// isNowValue reports whether value, the default of a timestamp field or
+// parameter, is now. The language generators compute it as the current
+// epoch milliseconds whenever a default is needed.
+func isNowValue(value interface{}, typeName string) bool {
+	s, ok := value.(string)
+	return ok && typeName == "timestamp" && isNowDefault(s)
+}
+
+// epochDefault converts an RFC 3339 @default of a timestamp field to epoch
+// milliseconds, the int64 the language generators map timestamp to. Other
+// values are returned unchanged.
+func epochDefault(value interface{}, typeName string) interface{} {
+	if s, ok := value.(string); ok && typeName == "timestamp" {
+		if t, ok := timestampLiteral(s); ok {
+			return t.UnixMilli()
+		}
+	}
+	return value
+}
+
+// inlineComment flattens a doc comment onto one line for a trailing -- SQL
+// comment.
+func inlineComment(doc string) string {
+	return strings.Join(strings.Fields(doc), " ")
+}
+
+// sqlString quotes s as an SQL string literal.
+func sqlString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// indexColumns returns the column list of an entity-level @index, each
+// column quoted with ident and followed by DESC when so declared.
+func indexColumns(idx *parser.Index, ident func(string) string) string {
+	var defs []string
+	for i, f := range idx.Fields {
+		if idx.Descending[i] {
+			defs = append(defs, ident(ToSnakeCase(f))+" DESC")
+		} else {
+			defs = append(defs, ident(ToSnakeCase(f)))
+		}
+	}
+	return strings.Join(defs, ", ")
+}
+
+// indexName returns the name of an entity-level @index: the table and its
+// columns, with _desc after a descending column and _partial for an index
+// with a predicate. A name already in taken gets a numeric suffix, so
+// indexes differing only in their predicate do not share a name and have
+// one skipped by IF NOT EXISTS. The name is added to taken.
+func indexName(tableName string, idx *parser.Index, taken map[string]bool) string {
+	parts := []string{"idx", tableName}
+	for i, f := range idx.Fields {
+		parts = append(parts, ToSnakeCase(f))
+		if idx.Descending[i] {
+			parts = append(parts, "desc")
+		}
+	}
+	if idx.Where != "" {
+		parts = append(parts, "partial")
+	}
+	name := strings.Join(parts, "_")
+	for n := 2; taken[name]; n++ {
+		name = fmt.Sprintf("%s_%d", strings.Join(parts, "_"), n)
+	}
+	taken[name] = true
+	return name
+}
+
+// indexWhere returns the WHERE clause of a partial @index in dialect, or ""
+// when the index covers every row. NOW() follows the timestamp mode here and
+// in the other DDL expressions below.
+func indexWhere(idx *parser.Index, dialect Dialect, ident func(string) string, mode TimestampMode) (string, error) {
+	if idx.Where == "" {
+		return "", nil
+	}
+	if idx.WhereExpr == nil {
+		return "", fmt.Errorf("invalid @index where condition %q", idx.Where)
+	}
+	sql, _ := exprToDialectSQL(idx.WhereExpr, nil, dialect, ident, mode)
+	return " WHERE " + sql, nil
+}
+
+// checkSQL returns the condition of a @check constraint in dialect, with the
+// fields it uses as columns.
+func checkSQL(check *parser.Check, dialect Dialect, ident func(string) string, mode TimestampMode) string {
+	sql, _ := exprToDialectSQL(check.Expr, nil, dialect, ident, mode)
+	return sql
+}
+
+// computedSQL returns the expression of a @computed field in dialect, with
+// the fields it uses as columns.
+func computedSQL(field *parser.FieldDecl, dialect Dialect, ident func(string) string, mode TimestampMode) (string, bool) {
+	_, expr, ok := field.Computed()
+	if !ok || expr == nil {
+		return "", false
+	}
+	sql, _ := exprToDialectSQL(expr, nil, dialect, ident, mode)
+	return sql, true
+}
+
+// withComputedColumns extends the SELECT defining a view with a column for
+// each of the view entity's @computed fields, evaluated over its rows.
+func withComputedColumns(dialect Dialect, entity *parser.EntityDecl, query string, ident func(string) string, mode TimestampMode) string {
+	var cols []string
+	for _, field := range entity.Fields {
+		if sql, ok := computedSQL(field, dialect, ident, mode); ok {
+			cols = append(cols, fmt.Sprintf("%s AS %s", sql, ident(ToSnakeCase(field.Name))))
+		}
+	}
+	if len(cols) == 0 {
+		return query
+	}
+	return fmt.Sprintf("SELECT *, %s FROM (%s) AS base", strings.Join(cols, ", "), query)
+}
+
+// viewSelect returns the SELECT defining a @view entity in dialect: the
+// embedded statement, or the referenced query compiled against its entity's
+// table. ok is false when the definition does not resolve; the checker
+// reports those.
+func viewSelect(dialect Dialect, file *parser.File, view *parser.View, ident func(string) string, mode TimestampMode) (string, bool) {
+	if view.Select != "" {
+		return strings.TrimSuffix(strings.TrimSpace(view.Select), ";"), true
+	}
+	target := file.Entity(view.Entity)
+	if target == nil {
+		return "", false
+	}
+	query := target.Query(view.Query)
+	if query == nil || len(query.Params) > 0 {
+		return "", false
+	}
+	table := schemaTable(dialect, target.SchemaName(), referencedTable(file, target.Name), ident)
+	sql, _ := selectSQL(dialect, target, table, query, ident, mode)
+	return sql, true
+}
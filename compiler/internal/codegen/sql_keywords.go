@@ -0,0 +1,33 @@
+package codegen
+
+import (
+	"strings"
+
+	"github.com/aurora/dataproto/internal/naming"
+)
+
+// IsReservedSQLKeyword reports whether name must be quoted to be used as a
+// table or column name in dialect. The comparison ignores case.
+func IsReservedSQLKeyword(name string, dialect Dialect) bool {
+	return naming.IsReservedSQLKeyword(name, dialect.String())
+}
+
+// quoteIdent quotes name as an SQL identifier. Both dialects use double
+// quotes, which also makes the name case-sensitive.
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// plainIdent writes an identifier as is.
+func plainIdent(name string) string {
+	return name
+}
+
+// joinIdents renders names through ident as a comma-separated column list.
+func joinIdents(names []string, ident func(string) string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = ident(name)
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -2,7 +2,9 @@ package codegen
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/aurora/dataproto/internal/parser"
 )
@@ -11,8 +13,15 @@ import (
 type PostgresGenerator struct {
 	// IncludeDropStatements adds DROP TABLE IF EXISTS before CREATE
 	IncludeDropStatements bool
-	// UseSerial uses SERIAL instead of GENERATED for auto-increment
+	// UseSerial emits @autoincrement keys as SERIAL or BIGSERIAL instead of
+	// identity columns
 	UseSerial bool
+	// TimestampMode selects BIGINT epoch milliseconds or TIMESTAMPTZ for
+	// timestamp columns
+	TimestampMode TimestampMode
+	// QuoteIdentifiers double-quotes table and column names so reserved
+	// keywords such as order can be used as names
+	QuoteIdentifiers bool
 }
 
 // NewPostgresGenerator creates a new PostgresGenerator.
@@ -22,6 +31,7 @@ func NewPostgresGenerator() *PostgresGenerator {
 
 // Generate generates PostgreSQL DDL from a DataProto file.
 func (g *PostgresGenerator) Generate(file *parser.File) (map[string]string, error) {
+	file = concreteFile(file)
 	result := make(map[string]string)
 
 	var sb strings.Builder
@@ -36,6 +46,7 @@ func (g *PostgresGenerator) Generate(file *parser.File) (map[string]string, erro
 	sb.WriteString("-- target: PostgreSQL\n\n")
 
 	// Generate tables for each entity
+	var views []*parser.EntityDecl
 	for _, entity := range file.Entities {
 		// Check if postgres is a supported backend
 		backends := entity.Backends()
@@ -52,7 +63,13 @@ func (g *PostgresGenerator) Generate(file *parser.File) (map[string]string, erro
 			}
 		}
 
-		tableDDL, err := g.generateTable(entity)
+		// Views are created once every table they may select from exists
+		if entity.View() != nil {
+			views = append(views, entity)
+			continue
+		}
+
+		tableDDL, err := g.generateTable(file, entity)
 		if err != nil {
 			return nil, err
 		}
@@ -60,13 +77,25 @@ func (g *PostgresGenerator) Generate(file *parser.File) (map[string]string, erro
 		sb.WriteString("\n")
 
 		// Generate indexes
-		indexes := g.generateIndexes(entity)
+		indexes, err := g.generateIndexes(entity)
+		if err != nil {
+			return nil, err
+		}
 		sb.WriteString(indexes)
 		if indexes != "" {
 			sb.WriteString("\n")
 		}
 	}
 
+	for _, entity := range views {
+		viewDDL, err := g.generateView(file, entity)
+		if err != nil {
+			return nil, err
+		}
+		sb.WriteString(viewDDL)
+		sb.WriteString("\n")
+	}
+
 	// Generate filename
 	filename := "schema.sql"
 	if file.Package != nil {
@@ -78,7 +107,7 @@ func (g *PostgresGenerator) Generate(file *parser.File) (map[string]string, erro
 	return result, nil
 }
 
-func (g *PostgresGenerator) generateTable(entity *parser.EntityDecl) (string, error) {
+func (g *PostgresGenerator) generateTable(file *parser.File, entity *parser.EntityDecl) (string, error) {
 	var sb strings.Builder
 
 	tableName := entity.TableName()
@@ -86,82 +115,151 @@ func (g *PostgresGenerator) generateTable(entity *parser.EntityDecl) (string, er
 		tableName = ToSnakeCase(entity.Name)
 	}
 
+	table := g.table(entity.SchemaName(), tableName)
 	if g.IncludeDropStatements {
-		sb.WriteString(fmt.Sprintf("DROP TABLE IF EXISTS %s CASCADE;\n\n", tableName))
+		sb.WriteString(fmt.Sprintf("DROP TABLE IF EXISTS %s CASCADE;\n\n", table))
 	}
 
-	sb.WriteString(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n", tableName))
+	sb.WriteString(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n", table))
 
 	var columns []string
 	var constraints []string
 
+	// A single @pk is declared inline; several form a table-level composite key
+	pkFields := entity.PrimaryKeyFields()
+	compositePK := len(pkFields) > 1
+	if compositePK {
+		var pkCols []string
+		for _, f := range pkFields {
+			pkCols = append(pkCols, ToSnakeCase(f.Name))
+		}
+		constraints = append(constraints,
+			fmt.Sprintf("    CONSTRAINT pk_%s PRIMARY KEY (%s)", tableName, joinIdents(pkCols, g.ident)))
+	}
+
 	for _, field := range entity.Fields {
-		colDef := g.generateColumn(field)
+		colDef := g.generateColumn(field, compositePK)
 		columns = append(columns, "    "+colDef)
 
 		// Unique constraint (separate from column for Postgres)
 		if field.IsUnique() && !field.IsPrimaryKey() {
 			constraints = append(constraints,
 				fmt.Sprintf("    CONSTRAINT uq_%s_%s UNIQUE (%s)",
-					tableName, ToSnakeCase(field.Name), ToSnakeCase(field.Name)))
+					tableName, ToSnakeCase(field.Name), g.ident(ToSnakeCase(field.Name))))
+		}
+
+		// Validation constraints from @range, @min, @max, @pattern and enums
+		for _, cond := range g.columnChecks(file, field) {
+			constraints = append(constraints,
+				fmt.Sprintf("    CONSTRAINT ck_%s_%s CHECK (%s)", tableName, ToSnakeCase(field.Name), cond))
 		}
 
 		// Foreign key constraint
-		if fk := field.GetAnnotation("fk"); fk != nil && len(fk.Args) > 0 {
-			if ref, ok := fk.Args[0].Value.(string); ok {
-				parts := strings.Split(ref, ".")
-				if len(parts) == 2 {
-					refTable := ToSnakeCase(parts[0])
-					refColumn := ToSnakeCase(parts[1])
-
-					onDelete := "RESTRICT"
-					if od := field.GetAnnotation("ondelete"); od != nil && len(od.Args) > 0 {
-						if action, ok := od.Args[0].Value.(string); ok {
-							switch strings.ToLower(action) {
-							case "cascade":
-								onDelete = "CASCADE"
-							case "setnull":
-								onDelete = "SET NULL"
-							case "restrict":
-								onDelete = "RESTRICT"
-							}
-						}
-					}
-
-					constraints = append(constraints,
-						fmt.Sprintf("    CONSTRAINT fk_%s_%s FOREIGN KEY (%s) REFERENCES %s(%s) ON DELETE %s",
-							tableName, ToSnakeCase(field.Name), ToSnakeCase(field.Name),
-							refTable, refColumn, onDelete))
-				}
-			}
+		if fk, ok := fieldForeignKey(file, field); ok {
+			constraints = append(constraints,
+				fmt.Sprintf("    CONSTRAINT fk_%s_%s FOREIGN KEY (%s) REFERENCES %s(%s) ON DELETE %s",
+					tableName, ToSnakeCase(field.Name), g.ident(ToSnakeCase(field.Name)),
+					g.table(fk.RefSchema, fk.RefTable), joinIdents(fk.RefColumns, g.ident), fk.OnDelete))
+		}
+	}
+
+	// @check constraints are numbered, as a condition may span several fields
+	for i, check := range entity.Checks() {
+		if check.Expr != nil {
+			constraints = append(constraints,
+				fmt.Sprintf("    CONSTRAINT ck_%s_%d CHECK (%s)", tableName, i+1, checkSQL(check, DialectPostgres, g.ident, g.TimestampMode)))
 		}
 	}
 
+	for _, fk := range compositeForeignKeys(file, entity) {
+		constraints = append(constraints,
+			fmt.Sprintf("    CONSTRAINT fk_%s_%s FOREIGN KEY (%s) REFERENCES %s(%s) ON DELETE %s",
+				tableName, strings.Join(fk.Columns, "_"), joinIdents(fk.Columns, g.ident),
+				g.table(fk.RefSchema, fk.RefTable), joinIdents(fk.RefColumns, g.ident), fk.OnDelete))
+	}
+
 	// Combine columns and constraints
 	allDefs := append(columns, constraints...)
 	sb.WriteString(strings.Join(allDefs, ",\n"))
 	sb.WriteString("\n);\n")
 
+	// Field docs become column comments
+	for _, field := range entity.Fields {
+		if field.Doc != "" {
+			sb.WriteString(fmt.Sprintf("COMMENT ON COLUMN %s.%s IS %s;\n",
+				table, g.ident(ToSnakeCase(field.Name)), sqlString(field.Doc)))
+		}
+	}
+
 	return sb.String(), nil
 }
 
-func (g *PostgresGenerator) generateColumn(field *parser.FieldDecl) string {
-	colName := ToSnakeCase(field.Name)
-	sqlType := g.postgresType(field.Type.Name)
+// generateView emits CREATE VIEW for a read-only @view entity. Postgres has
+// no IF NOT EXISTS for views; OR REPLACE keeps the script rerunnable.
+func (g *PostgresGenerator) generateView(file *parser.File, entity *parser.EntityDecl) (string, error) {
+	var sb strings.Builder
+
+	viewName := entity.TableName()
+	if viewName == "" {
+		viewName = ToSnakeCase(entity.Name)
+	}
+
+	query, ok := viewSelect(DialectPostgres, file, entity.View(), g.ident, g.TimestampMode)
+	if !ok {
+		return "", fmt.Errorf("view %s: cannot resolve its query", entity.Name)
+	}
+	query = withComputedColumns(DialectPostgres, entity, query, g.ident, g.TimestampMode)
+
+	view := g.table(entity.SchemaName(), viewName)
+	if g.IncludeDropStatements {
+		sb.WriteString(fmt.Sprintf("DROP VIEW IF EXISTS %s CASCADE;\n\n", view))
+	}
+	sb.WriteString(fmt.Sprintf("CREATE OR REPLACE VIEW %s AS\n    %s;\n", view, query))
+
+	return sb.String(), nil
+}
+
+func (g *PostgresGenerator) generateColumn(field *parser.FieldDecl, compositePK bool) string {
+	colName := g.ident(ToSnakeCase(field.Name))
+	sqlType := g.columnType(field)
 
 	var parts []string
 	parts = append(parts, colName, sqlType)
 
-	// Primary key
-	if field.IsPrimaryKey() {
+	// @autoincrement keys are identity columns or, with UseSerial, serials.
+	// Identities are BY DEFAULT so that repository upserts, which write the
+	// key, can still replace an existing row.
+	autoIncrement := field.IsAutoIncrement() && field.IsPrimaryKey() && !compositePK
+	if autoIncrement && g.UseSerial {
+		if field.Type.Name == "int64" {
+			parts[1] = "BIGSERIAL"
+		} else {
+			parts[1] = "SERIAL"
+		}
+	}
+
+	// Primary key (composite keys are emitted as a table constraint)
+	if field.IsPrimaryKey() && !compositePK {
 		parts = append(parts, "PRIMARY KEY")
 	}
+	if autoIncrement && !g.UseSerial {
+		parts = append(parts, "GENERATED BY DEFAULT AS IDENTITY")
+	}
 
-	// NOT NULL
-	if !field.Type.Optional && !field.IsPrimaryKey() {
-		if field.IsRequired() || field.GetAnnotation("default") == nil {
-			parts = append(parts, "NOT NULL")
-		}
+	// Generated column; Postgres only supports STORED
+	if expr, _, ok := field.Generated(); ok {
+		parts = append(parts, fmt.Sprintf("GENERATED ALWAYS AS (%s) STORED", expr))
+	}
+
+	// Postgres has no virtual columns, so a computed field is stored, but
+	// it is still only ever written by the database
+	if expr, ok := computedSQL(field, DialectPostgres, g.ident, g.TimestampMode); ok {
+		parts = append(parts, fmt.Sprintf("GENERATED ALWAYS AS (%s) STORED", expr))
+	}
+
+	// NOT NULL, alongside any default
+	if notNullColumn(field) {
+		parts = append(parts, "NOT NULL")
 	}
 
 	// Default value
@@ -173,6 +271,31 @@ func (g *PostgresGenerator) generateColumn(field *parser.FieldDecl) string {
 	return strings.Join(parts, " ")
 }
 
+// columnType returns the SQL type of field's column, honoring @column.
+// columnChecks returns the CHECK conditions validating field's column: its
+// @range, @min and @max bounds, its @pattern and its enum's value names.
+func (g *PostgresGenerator) columnChecks(file *parser.File, field *parser.FieldDecl) []string {
+	col := g.ident(ToSnakeCase(field.Name))
+	var conds []string
+	if cond, ok := boundsCheck(field, col); ok {
+		conds = append(conds, cond)
+	}
+	if pattern, ok := fieldPattern(field); ok {
+		conds = append(conds, fmt.Sprintf("%s ~ %s", col, sqlString(pattern)))
+	}
+	if cond, ok := enumCheck(file, field, "postgres", col); ok {
+		conds = append(conds, cond)
+	}
+	return conds
+}
+
+func (g *PostgresGenerator) columnType(field *parser.FieldDecl) string {
+	if override := field.ColumnType("postgres"); override != "" {
+		return override
+	}
+	return g.postgresType(field.Type.Name)
+}
+
 func (g *PostgresGenerator) postgresType(typeName string) string {
 	switch typeName {
 	case "string":
@@ -190,16 +313,26 @@ func (g *PostgresGenerator) postgresType(typeName string) string {
 	case "bytes":
 		return "BYTEA"
 	case "timestamp":
-		return "BIGINT" // epoch milliseconds
+		return GetTypeMappingWithMode(typeName, g.TimestampMode).Postgres
 	default:
 		return "TEXT"
 	}
 }
 
+// formatDefaultValue renders a @default value for a column of typeName.
+// BOOLEAN columns do not accept integers, so 0 and 1 become FALSE and TRUE.
 func (g *PostgresGenerator) formatDefaultValue(value interface{}, typeName string) string {
+	switch typeName {
+	case "timestamp":
+		return g.timestampDefault(value)
+	case "bool":
+		if n, ok := value.(int64); ok {
+			value = n != 0
+		}
+	}
 	switch v := value.(type) {
 	case string:
-		return fmt.Sprintf("'%s'", strings.ReplaceAll(v, "'", "''"))
+		return sqlString(v)
 	case bool:
 		if v {
 			return "TRUE"
@@ -208,31 +341,75 @@ func (g *PostgresGenerator) formatDefaultValue(value interface{}, typeName strin
 	case int64:
 		return fmt.Sprintf("%d", v)
 	case float64:
-		return fmt.Sprintf("%f", v)
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	default:
+		return "NULL"
+	}
+}
+
+// timestampDefault renders a timestamp @default, either now, an RFC 3339
+// time or epoch milliseconds, in the column's TimestampMode.
+func (g *PostgresGenerator) timestampDefault(value interface{}) string {
+	native := g.TimestampMode == TimestampNative
+	switch v := value.(type) {
+	case string:
+		if isNowDefault(v) {
+			return nowSQL(DialectPostgres, g.TimestampMode)
+		}
+		if t, ok := timestampLiteral(v); ok {
+			if native {
+				return sqlString(t.UTC().Format(time.RFC3339Nano))
+			}
+			return fmt.Sprintf("%d", t.UnixMilli())
+		}
+		return sqlString(v)
+	case int64:
+		if native {
+			return fmt.Sprintf("to_timestamp(%d / 1000.0)", v)
+		}
+		return fmt.Sprintf("%d", v)
 	default:
 		return "NULL"
 	}
 }
 
-func (g *PostgresGenerator) generateIndexes(entity *parser.EntityDecl) string {
+func (g *PostgresGenerator) generateIndexes(entity *parser.EntityDecl) (string, error) {
 	var sb strings.Builder
 
 	tableName := entity.TableName()
 	if tableName == "" {
 		tableName = ToSnakeCase(entity.Name)
 	}
+	table := g.table(entity.SchemaName(), tableName)
 
+	taken := make(map[string]bool)
 	for _, field := range entity.Fields {
 		if field.IsIndexed() && !field.IsPrimaryKey() {
 			colName := ToSnakeCase(field.Name)
-			indexName := fmt.Sprintf("idx_%s_%s", tableName, colName)
+			name := fmt.Sprintf("idx_%s_%s", tableName, colName)
+			taken[name] = true
 
 			sb.WriteString(fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (%s);\n",
-				indexName, tableName, colName))
+				name, table, g.ident(colName)))
 		}
 	}
 
-	return sb.String()
+	// Entity-level composite indexes
+	for _, idx := range entity.Indexes() {
+		where, err := indexWhere(idx, DialectPostgres, g.ident, g.TimestampMode)
+		if err != nil {
+			return "", fmt.Errorf("entity %s: %w", entity.Name, err)
+		}
+		unique := ""
+		if idx.Unique {
+			unique = "UNIQUE "
+		}
+
+		sb.WriteString(fmt.Sprintf("CREATE %sINDEX IF NOT EXISTS %s ON %s (%s)%s;\n",
+			unique, indexName(tableName, idx, taken), table, indexColumns(idx, g.ident), where))
+	}
+
+	return sb.String(), nil
 }
 
 // GenerateMigration generates an additive-only migration.
@@ -261,38 +438,57 @@ func (g *PostgresGenerator) GenerateMigration(from, to *parser.EntityDecl) (stri
 	sb.WriteString(fmt.Sprintf("-- Migration for table: %s\n", tableName))
 	sb.WriteString("-- ADDITIVE ONLY: Foundation columns cannot be removed or changed.\n\n")
 
+	// Fields are walked in declaration order so the output is stable
+	var fromDecls []*parser.FieldDecl
+	if from != nil {
+		fromDecls = from.Fields
+	}
+
 	// Find added columns (allowed)
-	for name, field := range toFields {
-		if _, exists := fromFields[name]; !exists {
+	for _, field := range to.Fields {
+		if _, exists := fromFields[field.Name]; !exists {
 			// New column - must be optional or have default
 			if !field.Type.Optional && field.GetAnnotation("default") == nil {
 				blocked = append(blocked,
-					fmt.Sprintf("Cannot add required column '%s' without default value", name))
+					fmt.Sprintf("Cannot add required column '%s' without default value", field.Name))
 				continue
 			}
-			colDef := g.generateColumn(field)
-			sb.WriteString(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s;\n", tableName, colDef))
+			colDef := g.generateColumn(field, false)
+			sb.WriteString(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s;\n", g.table(to.SchemaName(), tableName), colDef))
 		}
 	}
 
 	// Find removed columns (blocked)
-	for name := range fromFields {
-		if _, exists := toFields[name]; !exists {
+	for _, fromField := range fromDecls {
+		if _, exists := toFields[fromField.Name]; !exists {
 			blocked = append(blocked,
-				fmt.Sprintf("Cannot remove foundation column '%s'", name))
+				fmt.Sprintf("Cannot remove foundation column '%s'", fromField.Name))
 		}
 	}
 
 	// Find changed types (blocked)
-	for name, fromField := range fromFields {
-		if toField, exists := toFields[name]; exists {
+	for _, fromField := range fromDecls {
+		if toField, exists := toFields[fromField.Name]; exists {
 			if fromField.Type.Name != toField.Type.Name {
 				blocked = append(blocked,
 					fmt.Sprintf("Cannot change type of '%s' from %s to %s",
-						name, fromField.Type.Name, toField.Type.Name))
+						fromField.Name, fromField.Type.Name, toField.Type.Name))
 			}
 		}
 	}
 
 	return sb.String(), blocked, nil
 }
+
+// ident returns name as it appears in DDL, quoted when QuoteIdentifiers is set.
+func (g *PostgresGenerator) ident(name string) string {
+	if g.QuoteIdentifiers {
+		return quoteIdent(name)
+	}
+	return name
+}
+
+// table writes a table or view name, qualified by its schema if it has one.
+func (g *PostgresGenerator) table(schema, name string) string {
+	return schemaTable(DialectPostgres, schema, name, g.ident)
+}
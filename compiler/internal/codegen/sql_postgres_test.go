@@ -0,0 +1,446 @@
+package codegen
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPostgresCompositePrimaryKey(t *testing.T) {
+	file := mustParse(t, compositeKeySchema)
+	ddl := generateOne(t, NewPostgresGenerator(), file)
+
+	if !strings.Contains(ddl, "CONSTRAINT pk_event_tags PRIMARY KEY (event_id, tag_id)") {
+		t.Errorf("Expected composite PRIMARY KEY constraint, got:\n%s", ddl)
+	}
+	if strings.Contains(ddl, "event_id TEXT PRIMARY KEY") {
+		t.Errorf("Expected no inline PRIMARY KEY on composite key column, got:\n%s", ddl)
+	}
+}
+
+func TestPostgresCompositeForeignKey(t *testing.T) {
+	file := mustParse(t, compositeForeignKeySchema)
+	ddl := generateOne(t, NewPostgresGenerator(), file)
+
+	want := "CONSTRAINT fk_shipment_order_id_line_no FOREIGN KEY (order_id, line_no) " +
+		"REFERENCES order_lines(order_id, line_no) ON DELETE CASCADE"
+	if !strings.Contains(ddl, want) {
+		t.Errorf("Expected multi-column FOREIGN KEY constraint, got:\n%s", ddl)
+	}
+}
+
+func TestPostgresIndexes(t *testing.T) {
+	file := mustParse(t, indexSchema)
+	ddl := generateOne(t, NewPostgresGenerator(), file)
+
+	for _, want := range []string{
+		"CREATE INDEX IF NOT EXISTS idx_events_start_date ON events (start_date);",
+		"CREATE INDEX IF NOT EXISTS idx_events_calendar_id_start_date ON events (calendar_id, start_date);",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_events_calendar_id_external_id ON events (calendar_id, external_id);",
+	} {
+		if !strings.Contains(ddl, want) {
+			t.Errorf("Expected %q in DDL, got:\n%s", want, ddl)
+		}
+	}
+}
+
+func TestPostgresPartialIndex(t *testing.T) {
+	file := mustParse(t, partialIndexSchema)
+	ddl := generateOne(t, NewPostgresGenerator(), file)
+
+	want := "CREATE INDEX IF NOT EXISTS idx_events_calendar_id_start_date_desc_partial " +
+		"ON events (calendar_id, start_date DESC) WHERE deleted_at IS NULL AND active = TRUE;"
+	if !strings.Contains(ddl, want) {
+		t.Errorf("Expected %q in DDL, got:\n%s", want, ddl)
+	}
+}
+
+func TestPostgresForeignKeys(t *testing.T) {
+	file := mustParse(t, foreignKeySchema)
+	ddl := generateOne(t, NewPostgresGenerator(), file)
+
+	want := "CONSTRAINT fk_event_attachment_event_id FOREIGN KEY (event_id) " +
+		"REFERENCES calendar_events(id) ON DELETE CASCADE"
+	if !strings.Contains(ddl, want) {
+		t.Errorf("Expected %q in DDL, got:\n%s", want, ddl)
+	}
+}
+
+func TestPostgresGeneratedColumns(t *testing.T) {
+	file := mustParse(t, generatedSchema)
+	ddl := generateOne(t, NewPostgresGenerator(), file)
+
+	want := "total DOUBLE PRECISION GENERATED ALWAYS AS (price * quantity) STORED"
+	if !strings.Contains(ddl, want) {
+		t.Errorf("Expected %q in DDL, got:\n%s", want, ddl)
+	}
+	if strings.Contains(ddl, "VIRTUAL") {
+		t.Errorf("Expected no VIRTUAL columns for Postgres, got:\n%s", ddl)
+	}
+}
+
+func TestPostgresCheckConstraints(t *testing.T) {
+	file := mustParse(t, checkSchema)
+	ddl := generateOne(t, NewPostgresGenerator(), file)
+
+	for _, want := range []string{
+		"CONSTRAINT ck_reminders_priority CHECK (priority >= 0 AND priority <= 3)",
+		"CONSTRAINT ck_reminders_code CHECK (code ~ '^[a-z]+$')",
+	} {
+		if !strings.Contains(ddl, want) {
+			t.Errorf("Expected %q in DDL, got:\n%s", want, ddl)
+		}
+	}
+}
+
+func TestPostgresCheckColumnNames(t *testing.T) {
+	file := mustParse(t, camelCheckSchema)
+	ddl := generateOne(t, NewPostgresGenerator(), file)
+
+	if want := "CONSTRAINT ck_slots_1 CHECK (end_at > start_at OR active = FALSE)"; !strings.Contains(ddl, want) {
+		t.Errorf("Expected %q in DDL, got:\n%s", want, ddl)
+	}
+}
+
+func TestPostgresEnumCheck(t *testing.T) {
+	file := mustParse(t, enumCheckSchema)
+	ddl := generateOne(t, NewPostgresGenerator(), file)
+
+	if !strings.Contains(ddl, "CONSTRAINT ck_tasks_status CHECK (status IN ('ACTIVE', 'DONE'))") {
+		t.Errorf("Expected enum CHECK constraint, got:\n%s", ddl)
+	}
+	if strings.Contains(ddl, "ck_tasks_state") {
+		t.Errorf("Expected no CHECK on a column typed as a native enum, got:\n%s", ddl)
+	}
+}
+
+const computedSchema = `
+package test;
+
+@table("people")
+entity Person {
+    @pk id: string;
+    firstName: string;
+    lastName: string;
+    @computed("firstName || ' ' || lastName") fullName: string;
+
+    query named() {
+        where lastName != ""
+    }
+}
+
+@view(query: "Person.named")
+entity Directory {
+    id: string;
+    lastName: string;
+    @computed("lastName || '!'") shout: string;
+}
+`
+
+func TestPostgresComputedColumn(t *testing.T) {
+	file := mustParse(t, computedSchema)
+	ddl := generateOne(t, NewPostgresGenerator(), file)
+
+	for _, want := range []string{
+		"full_name TEXT GENERATED ALWAYS AS (first_name || ' ' || last_name) STORED NOT NULL",
+		"SELECT *, last_name || '!' AS shout FROM (SELECT * FROM people WHERE last_name != '') AS base",
+	} {
+		if !strings.Contains(ddl, want) {
+			t.Errorf("Expected %q in DDL, got:\n%s", want, ddl)
+		}
+	}
+
+	sqlite := generateOne(t, NewSQLiteGenerator(), file)
+	if !strings.Contains(sqlite, "full_name TEXT GENERATED ALWAYS AS (first_name || ' ' || last_name) VIRTUAL NOT NULL") {
+		t.Errorf("Expected a virtual column in SQLite, got:\n%s", sqlite)
+	}
+
+	out, err := NewJavaGenerator().Generate(file)
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+	if java := out["PersonRepository.java"]; !strings.Contains(java, "INSERT OR REPLACE INTO people (id, first_name, last_name) VALUES (?, ?, ?)") {
+		t.Errorf("Expected the upsert to skip the computed column, got:\n%s", java)
+	}
+}
+
+func TestPostgresTimestampMode(t *testing.T) {
+	file := mustParse(t, timestampSchema)
+
+	ddl := generateOne(t, NewPostgresGenerator(), file)
+	if !strings.Contains(ddl, "start_date BIGINT NOT NULL") {
+		t.Errorf("Expected BIGINT epoch column by default, got:\n%s", ddl)
+	}
+
+	g := NewPostgresGenerator()
+	g.TimestampMode = TimestampNative
+	ddl = generateOne(t, g, file)
+	if !strings.Contains(ddl, "start_date TIMESTAMPTZ NOT NULL") {
+		t.Errorf("Expected TIMESTAMPTZ column in native mode, got:\n%s", ddl)
+	}
+}
+
+func TestPostgresNativeNow(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+@table("events")
+entity Event {
+    @pk id: string;
+    start_date: timestamp;
+
+    query upcoming() {
+        where start_date > NOW()
+    }
+}
+
+@view(query: "Event.upcoming")
+entity UpcomingEvent {
+    id: string;
+    start_date: timestamp;
+}
+`)
+
+	ddl := generateOne(t, NewPostgresGenerator(), file)
+	if !strings.Contains(ddl, "WHERE start_date > (EXTRACT(EPOCH FROM now()) * 1000)::BIGINT") {
+		t.Errorf("Expected NOW() as epoch millis by default, got:\n%s", ddl)
+	}
+
+	g := NewPostgresGenerator()
+	g.TimestampMode = TimestampNative
+	ddl = generateOne(t, g, file)
+	if !strings.Contains(ddl, "WHERE start_date > CURRENT_TIMESTAMP") {
+		t.Errorf("Expected NOW() as CURRENT_TIMESTAMP in native mode, got:\n%s", ddl)
+	}
+}
+
+func TestPostgresQuoteIdentifiers(t *testing.T) {
+	if !IsReservedSQLKeyword("order", DialectPostgres) {
+		t.Error("Expected order to be reserved in Postgres")
+	}
+	if !IsReservedSQLKeyword("user", DialectPostgres) || IsReservedSQLKeyword("user", DialectSQLite) {
+		t.Error("Expected user to be reserved in Postgres only")
+	}
+
+	file := mustParse(t, reservedNameSchema)
+
+	g := NewPostgresGenerator()
+	g.QuoteIdentifiers = true
+	ddl := generateOne(t, g, file)
+	for _, want := range []string{
+		`CREATE TABLE IF NOT EXISTS "group" (`,
+		`    "order" INTEGER NOT NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_group_order ON "group" ("order");`,
+	} {
+		if !strings.Contains(ddl, want) {
+			t.Errorf("Expected %q, got:\n%s", want, ddl)
+		}
+	}
+}
+
+func TestPostgresMigrationOrder(t *testing.T) {
+	from := mustParse(t, `
+package test;
+
+entity Event {
+    @pk id: string;
+    a: string;
+    b: int32;
+    c: string;
+    d: string;
+}
+`).Entities[0]
+	to := mustParse(t, `
+package test;
+
+entity Event {
+    @pk id: string;
+    b: string;
+    d: int32;
+    w: string?;
+    x: string;
+    y: string?;
+    z: string;
+}
+`).Entities[0]
+
+	g := NewPostgresGenerator()
+	for i := 0; i < 10; i++ {
+		ddl, blocked, err := g.GenerateMigration(from, to)
+		if err != nil {
+			t.Fatalf("GenerateMigration: %v", err)
+		}
+		if !strings.Contains(ddl, "ADD COLUMN w TEXT;\nALTER TABLE event ADD COLUMN y TEXT;\n") {
+			t.Fatalf("Expected added columns in declaration order, got:\n%s", ddl)
+		}
+		want := []string{
+			"Cannot add required column 'x' without default value",
+			"Cannot add required column 'z' without default value",
+			"Cannot remove foundation column 'a'",
+			"Cannot remove foundation column 'c'",
+			"Cannot change type of 'b' from int32 to string",
+			"Cannot change type of 'd' from string to int32",
+		}
+		if strings.Join(blocked, "\n") != strings.Join(want, "\n") {
+			t.Fatalf("Blocked changes out of order:\n%s", strings.Join(blocked, "\n"))
+		}
+	}
+}
+
+func TestPostgresColumnDoc(t *testing.T) {
+	ddl := generateOne(t, NewPostgresGenerator(), mustParse(t, docSchema))
+	want := "COMMENT ON COLUMN event.title IS 'The event''s title, e.g. \"Bob''s party\"\nshown in lists';\n"
+	if !strings.Contains(ddl, want) {
+		t.Errorf("Expected COMMENT ON COLUMN, got:\n%s", ddl)
+	}
+	if strings.Contains(ddl, "COMMENT ON COLUMN event.id") {
+		t.Errorf("Expected no comment for undocumented id, got:\n%s", ddl)
+	}
+}
+
+func TestPostgresView(t *testing.T) {
+	g := NewPostgresGenerator()
+	g.IncludeDropStatements = true
+	ddl := generateOne(t, g, mustParse(t, viewSchema))
+
+	want := "DROP VIEW IF EXISTS upcoming_event CASCADE;\n\n" +
+		"CREATE OR REPLACE VIEW upcoming_event AS\n    SELECT * FROM event WHERE start_time > 0 ORDER BY start_time ASC;\n"
+	if !strings.Contains(ddl, want) {
+		t.Errorf("Expected %q, got:\n%s", want, ddl)
+	}
+	if strings.Contains(ddl, "TABLE IF EXISTS upcoming_event") {
+		t.Errorf("Expected no table for a view, got:\n%s", ddl)
+	}
+}
+
+func TestPostgresDefaults(t *testing.T) {
+	ddl := generateOne(t, NewPostgresGenerator(), mustParse(t, defaultSchema))
+	for _, want := range []string{
+		"    done BOOLEAN NOT NULL DEFAULT FALSE,\n",
+		"    priority INTEGER NOT NULL DEFAULT 0,\n",
+		"    pinned BOOLEAN NOT NULL DEFAULT TRUE,\n",
+		"    weight DOUBLE PRECISION NOT NULL DEFAULT 0.5,\n",
+		"    created_at BIGINT NOT NULL DEFAULT (EXTRACT(EPOCH FROM now()) * 1000)::BIGINT,\n",
+		"    starts_at BIGINT NOT NULL DEFAULT 1704067200000,\n",
+		"    note TEXT NOT NULL DEFAULT '',\n",
+		"    title TEXT NOT NULL,\n",
+		"    due_at BIGINT\n",
+	} {
+		if !strings.Contains(ddl, want) {
+			t.Errorf("Expected %q, got:\n%s", want, ddl)
+		}
+	}
+
+	g := NewPostgresGenerator()
+	g.TimestampMode = TimestampNative
+	ddl = generateOne(t, g, mustParse(t, defaultSchema))
+	for _, want := range []string{
+		"    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,\n",
+		"    starts_at TIMESTAMPTZ NOT NULL DEFAULT '2024-01-01T00:00:00Z',\n",
+	} {
+		if !strings.Contains(ddl, want) {
+			t.Errorf("Expected %q, got:\n%s", want, ddl)
+		}
+	}
+}
+
+func TestPostgresCheckAnnotations(t *testing.T) {
+	file := mustParse(t, checkAnnotationSchema)
+	ddl := generateOne(t, NewPostgresGenerator(), file)
+
+	for _, want := range []string{
+		"CONSTRAINT ck_bookings_1 CHECK (end_date >= start_date)",
+		"CONSTRAINT ck_bookings_2 CHECK (guests > 0 AND guests <= 8)",
+	} {
+		if !strings.Contains(ddl, want) {
+			t.Errorf("Expected %q in DDL, got:\n%s", want, ddl)
+		}
+	}
+}
+
+func TestPostgresAutoIncrement(t *testing.T) {
+	file := mustParse(t, autoIncrementSchema)
+
+	ddl := generateOne(t, NewPostgresGenerator(), file)
+	for _, want := range []string{
+		"    id BIGINT PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY",
+		"    id INTEGER PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY",
+	} {
+		if !strings.Contains(ddl, want) {
+			t.Errorf("Expected %q in DDL, got:\n%s", want, ddl)
+		}
+	}
+
+	g := NewPostgresGenerator()
+	g.UseSerial = true
+	ddl = generateOne(t, g, file)
+	for _, want := range []string{"    id BIGSERIAL PRIMARY KEY", "    id SERIAL PRIMARY KEY"} {
+		if !strings.Contains(ddl, want) {
+			t.Errorf("Expected %q with UseSerial, got:\n%s", want, ddl)
+		}
+	}
+	if strings.Contains(ddl, "IDENTITY") {
+		t.Errorf("Expected no identity columns with UseSerial, got:\n%s", ddl)
+	}
+}
+
+const columnOverrideSchema = `
+package test;
+
+entity Product {
+    @pk id: string;
+    @column(postgres: "NUMERIC(10,2)") price: double;
+    @column(sqlite: "NUMERIC") weight: double?;
+}
+`
+
+func TestPostgresColumnOverride(t *testing.T) {
+	file := mustParse(t, columnOverrideSchema)
+	ddl := generateOne(t, NewPostgresGenerator(), file)
+
+	for _, want := range []string{
+		"    price NUMERIC(10,2) NOT NULL",
+		"    weight DOUBLE PRECISION",
+	} {
+		if !strings.Contains(ddl, want) {
+			t.Errorf("Expected %q in DDL, got:\n%s", want, ddl)
+		}
+	}
+}
+
+const schemaTableSchema = `
+package test;
+
+@table(schema: "app", name: "calendar_events")
+entity Event {
+    @pk id: string;
+    @indexed title: string;
+}
+
+@table("attendees")
+entity Attendee {
+    @pk id: string;
+    @fk(Event.id) event_id: string;
+}
+`
+
+func TestPostgresSchemaTable(t *testing.T) {
+	file := mustParse(t, schemaTableSchema)
+	ddl := generateOne(t, NewPostgresGenerator(), file)
+
+	for _, want := range []string{
+		"CREATE TABLE IF NOT EXISTS app.calendar_events (",
+		"CREATE INDEX IF NOT EXISTS idx_calendar_events_title ON app.calendar_events (title);",
+		"CREATE TABLE IF NOT EXISTS attendees (",
+		"REFERENCES app.calendar_events(id)",
+	} {
+		if !strings.Contains(ddl, want) {
+			t.Errorf("Expected %q in DDL, got:\n%s", want, ddl)
+		}
+	}
+
+	// SQLite has no schemas and keeps the bare name
+	ddl = generateOne(t, NewSQLiteGenerator(), file)
+	if !strings.Contains(ddl, "CREATE TABLE IF NOT EXISTS calendar_events (") {
+		t.Errorf("Expected an unqualified table in SQLite DDL, got:\n%s", ddl)
+	}
+}
@@ -2,6 +2,7 @@ package codegen
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/aurora/dataproto/internal/parser"
@@ -11,6 +12,15 @@ import (
 type SQLiteGenerator struct {
 	// IncludeDropStatements adds DROP TABLE IF EXISTS before CREATE
 	IncludeDropStatements bool
+	// PatternChecks emits CHECK constraints for @pattern using REGEXP, which
+	// SQLite only supports when the application registers a regexp function
+	PatternChecks bool
+	// TimestampMode selects INTEGER epoch milliseconds or DATETIME for
+	// timestamp columns
+	TimestampMode TimestampMode
+	// QuoteIdentifiers double-quotes table and column names so reserved
+	// keywords such as order can be used as names
+	QuoteIdentifiers bool
 }
 
 // NewSQLiteGenerator creates a new SQLiteGenerator.
@@ -20,6 +30,7 @@ func NewSQLiteGenerator() *SQLiteGenerator {
 
 // Generate generates SQLite DDL from a DataProto file.
 func (g *SQLiteGenerator) Generate(file *parser.File) (map[string]string, error) {
+	file = concreteFile(file)
 	result := make(map[string]string)
 
 	var sb strings.Builder
@@ -33,6 +44,7 @@ func (g *SQLiteGenerator) Generate(file *parser.File) (map[string]string, error)
 	sb.WriteString(".dataproto\n\n")
 
 	// Generate tables for each entity
+	var views []*parser.EntityDecl
 	for _, entity := range file.Entities {
 		// Check if sqlite is a supported backend
 		backends := entity.Backends()
@@ -49,7 +61,13 @@ func (g *SQLiteGenerator) Generate(file *parser.File) (map[string]string, error)
 			}
 		}
 
-		tableDDL, err := g.generateTable(entity)
+		// Views are created once every table they may select from exists
+		if entity.View() != nil {
+			views = append(views, entity)
+			continue
+		}
+
+		tableDDL, err := g.generateTable(file, entity)
 		if err != nil {
 			return nil, err
 		}
@@ -57,13 +75,25 @@ func (g *SQLiteGenerator) Generate(file *parser.File) (map[string]string, error)
 		sb.WriteString("\n")
 
 		// Generate indexes
-		indexes := g.generateIndexes(entity)
+		indexes, err := g.generateIndexes(entity)
+		if err != nil {
+			return nil, err
+		}
 		sb.WriteString(indexes)
 		if indexes != "" {
 			sb.WriteString("\n")
 		}
 	}
 
+	for _, entity := range views {
+		viewDDL, err := g.generateView(file, entity)
+		if err != nil {
+			return nil, err
+		}
+		sb.WriteString(viewDDL)
+		sb.WriteString("\n")
+	}
+
 	// Generate filename
 	filename := "schema.sql"
 	if file.Package != nil {
@@ -75,7 +105,7 @@ func (g *SQLiteGenerator) Generate(file *parser.File) (map[string]string, error)
 	return result, nil
 }
 
-func (g *SQLiteGenerator) generateTable(entity *parser.EntityDecl) (string, error) {
+func (g *SQLiteGenerator) generateTable(file *parser.File, entity *parser.EntityDecl) (string, error) {
 	var sb strings.Builder
 
 	tableName := entity.TableName()
@@ -84,84 +114,147 @@ func (g *SQLiteGenerator) generateTable(entity *parser.EntityDecl) (string, erro
 	}
 
 	if g.IncludeDropStatements {
-		sb.WriteString(fmt.Sprintf("DROP TABLE IF EXISTS %s;\n\n", tableName))
+		sb.WriteString(fmt.Sprintf("DROP TABLE IF EXISTS %s;\n\n", g.ident(tableName)))
 	}
 
-	sb.WriteString(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n", tableName))
+	sb.WriteString(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n", g.ident(tableName)))
 
 	var columns []string
+	var columnDocs []string
 	var uniqueConstraints []string
+	var checks []string
 	var foreignKeys []string
 
+	// A single @pk is declared inline; several form a table-level composite key
+	pkFields := entity.PrimaryKeyFields()
+	compositePK := len(pkFields) > 1
+
 	for _, field := range entity.Fields {
-		colDef := g.generateColumn(field)
+		colDef := g.generateColumn(field, compositePK)
 		columns = append(columns, "    "+colDef)
+		columnDocs = append(columnDocs, inlineComment(field.Doc))
 
 		if field.IsUnique() && !field.IsPrimaryKey() {
 			uniqueConstraints = append(uniqueConstraints,
-				fmt.Sprintf("    UNIQUE (%s)", ToSnakeCase(field.Name)))
+				fmt.Sprintf("    UNIQUE (%s)", g.ident(ToSnakeCase(field.Name))))
+		}
+
+		// Validation constraints from @range, @min, @max, @pattern and enums
+		for _, cond := range g.columnChecks(file, field) {
+			checks = append(checks, fmt.Sprintf("    CHECK (%s)", cond))
 		}
 
 		// Check for foreign key
-		if fk := field.GetAnnotation("fk"); fk != nil && len(fk.Args) > 0 {
-			if ref, ok := fk.Args[0].Value.(string); ok {
-				// Parse Entity.field format
-				parts := strings.Split(ref, ".")
-				if len(parts) == 2 {
-					refTable := ToSnakeCase(parts[0])
-					refColumn := ToSnakeCase(parts[1])
-
-					onDelete := "RESTRICT"
-					if od := field.GetAnnotation("ondelete"); od != nil && len(od.Args) > 0 {
-						if action, ok := od.Args[0].Value.(string); ok {
-							switch strings.ToLower(action) {
-							case "cascade":
-								onDelete = "CASCADE"
-							case "setnull":
-								onDelete = "SET NULL"
-							case "restrict":
-								onDelete = "RESTRICT"
-							}
-						}
-					}
-
-					foreignKeys = append(foreignKeys,
-						fmt.Sprintf("    FOREIGN KEY (%s) REFERENCES %s(%s) ON DELETE %s",
-							ToSnakeCase(field.Name), refTable, refColumn, onDelete))
-				}
-			}
+		if fk, ok := fieldForeignKey(file, field); ok {
+			foreignKeys = append(foreignKeys,
+				fmt.Sprintf("    FOREIGN KEY (%s) REFERENCES %s(%s) ON DELETE %s",
+					g.ident(ToSnakeCase(field.Name)), g.ident(fk.RefTable), joinIdents(fk.RefColumns, g.ident), fk.OnDelete))
 		}
 	}
 
+	if compositePK {
+		var pkCols []string
+		for _, f := range pkFields {
+			pkCols = append(pkCols, ToSnakeCase(f.Name))
+		}
+		columns = append(columns, fmt.Sprintf("    PRIMARY KEY (%s)", joinIdents(pkCols, g.ident)))
+	}
+
+	for _, check := range entity.Checks() {
+		if check.Expr != nil {
+			checks = append(checks, fmt.Sprintf("    CHECK (%s)", checkSQL(check, DialectSQLite, g.ident, g.TimestampMode)))
+		}
+	}
+
+	for _, fk := range compositeForeignKeys(file, entity) {
+		foreignKeys = append(foreignKeys,
+			fmt.Sprintf("    FOREIGN KEY (%s) REFERENCES %s(%s) ON DELETE %s",
+				joinIdents(fk.Columns, g.ident), g.ident(fk.RefTable), joinIdents(fk.RefColumns, g.ident), fk.OnDelete))
+	}
+
 	// Build full DDL
 	allConstraints := append(columns, uniqueConstraints...)
+	allConstraints = append(allConstraints, checks...)
 	allConstraints = append(allConstraints, foreignKeys...)
 
-	sb.WriteString(strings.Join(allConstraints, ",\n"))
-	sb.WriteString("\n);\n")
+	// Field docs trail their column definitions, after the separating comma
+	for i, def := range allConstraints {
+		sb.WriteString(def)
+		if i < len(allConstraints)-1 {
+			sb.WriteString(",")
+		}
+		if i < len(columnDocs) && columnDocs[i] != "" {
+			sb.WriteString(" -- " + columnDocs[i])
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString(");\n")
+
+	return sb.String(), nil
+}
+
+// generateView emits CREATE VIEW for a read-only @view entity.
+func (g *SQLiteGenerator) generateView(file *parser.File, entity *parser.EntityDecl) (string, error) {
+	var sb strings.Builder
+
+	viewName := entity.TableName()
+	if viewName == "" {
+		viewName = ToSnakeCase(entity.Name)
+	}
+
+	query, ok := viewSelect(DialectSQLite, file, entity.View(), g.ident, g.TimestampMode)
+	if !ok {
+		return "", fmt.Errorf("view %s: cannot resolve its query", entity.Name)
+	}
+	query = withComputedColumns(DialectSQLite, entity, query, g.ident, g.TimestampMode)
+
+	if g.IncludeDropStatements {
+		sb.WriteString(fmt.Sprintf("DROP VIEW IF EXISTS %s;\n\n", g.ident(viewName)))
+	}
+	sb.WriteString(fmt.Sprintf("CREATE VIEW IF NOT EXISTS %s AS\n    %s;\n", g.ident(viewName), query))
 
 	return sb.String(), nil
 }
 
-func (g *SQLiteGenerator) generateColumn(field *parser.FieldDecl) string {
-	colName := ToSnakeCase(field.Name)
-	typeMapping := GetTypeMapping(field.Type.Name)
-	sqlType := typeMapping.SQLite
+func (g *SQLiteGenerator) generateColumn(field *parser.FieldDecl, compositePK bool) string {
+	colName := g.ident(ToSnakeCase(field.Name))
+	sqlType := g.columnType(field)
 
 	var constraints []string
 
-	// Primary key
-	if field.IsPrimaryKey() {
-		constraints = append(constraints, "PRIMARY KEY")
+	// Generated column
+	if expr, stored, ok := field.Generated(); ok {
+		kind := "VIRTUAL"
+		if stored {
+			kind = "STORED"
+		}
+		constraints = append(constraints, fmt.Sprintf("GENERATED ALWAYS AS (%s) %s", expr, kind))
+	}
+
+	// A computed field is derived when read, never stored
+	if expr, ok := computedSQL(field, DialectSQLite, g.ident, g.TimestampMode); ok {
+		constraints = append(constraints, fmt.Sprintf("GENERATED ALWAYS AS (%s) VIRTUAL", expr))
 	}
 
-	// NOT NULL (unless optional or has default)
-	if !field.Type.Optional && field.GetAnnotation("default") == nil && !field.IsPrimaryKey() {
-		if field.IsRequired() {
+	// Primary key (composite keys are emitted as a table constraint).
+	// SQLite allows NULLs in composite key columns, so forbid them explicitly.
+	if field.IsPrimaryKey() {
+		if compositePK {
 			constraints = append(constraints, "NOT NULL")
+		} else if field.IsAutoIncrement() {
+			// Only an INTEGER PRIMARY KEY aliases the rowid
+			sqlType = "INTEGER"
+			constraints = append(constraints, "PRIMARY KEY AUTOINCREMENT")
+		} else {
+			constraints = append(constraints, "PRIMARY KEY")
 		}
 	}
 
+	// NOT NULL, alongside any default
+	if notNullColumn(field) {
+		constraints = append(constraints, "NOT NULL")
+	}
+
 	// Default value
 	if def := field.GetAnnotation("default"); def != nil && len(def.Args) > 0 {
 		defaultVal := g.formatDefaultValue(def.Args[0].Value, field.Type.Name)
@@ -174,10 +267,41 @@ func (g *SQLiteGenerator) generateColumn(field *parser.FieldDecl) string {
 	return fmt.Sprintf("%s %s", colName, sqlType)
 }
 
+// columnType returns the SQL type of field's column, honoring @column.
+// columnChecks returns the CHECK conditions validating field's column: its
+// @range, @min and @max bounds, its @pattern when PatternChecks is set, and
+// its enum's value names.
+func (g *SQLiteGenerator) columnChecks(file *parser.File, field *parser.FieldDecl) []string {
+	col := g.ident(ToSnakeCase(field.Name))
+	var conds []string
+	if cond, ok := boundsCheck(field, col); ok {
+		conds = append(conds, cond)
+	}
+	if pattern, ok := fieldPattern(field); ok && g.PatternChecks {
+		conds = append(conds, fmt.Sprintf("%s REGEXP %s", col, sqlString(pattern)))
+	}
+	if cond, ok := enumCheck(file, field, "sqlite", col); ok {
+		conds = append(conds, cond)
+	}
+	return conds
+}
+
+func (g *SQLiteGenerator) columnType(field *parser.FieldDecl) string {
+	if override := field.ColumnType("sqlite"); override != "" {
+		return override
+	}
+	return GetTypeMappingWithMode(field.Type.Name, g.TimestampMode).SQLite
+}
+
+// formatDefaultValue renders a @default value for a column of typeName.
+// SQLite has no boolean type, so booleans are stored as 1 and 0.
 func (g *SQLiteGenerator) formatDefaultValue(value interface{}, typeName string) string {
+	if typeName == "timestamp" {
+		return g.timestampDefault(value)
+	}
 	switch v := value.(type) {
 	case string:
-		return fmt.Sprintf("'%s'", strings.ReplaceAll(v, "'", "''"))
+		return sqlString(v)
 	case bool:
 		if v {
 			return "1"
@@ -186,13 +310,39 @@ func (g *SQLiteGenerator) formatDefaultValue(value interface{}, typeName string)
 	case int64:
 		return fmt.Sprintf("%d", v)
 	case float64:
-		return fmt.Sprintf("%f", v)
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	default:
+		return "NULL"
+	}
+}
+
+// timestampDefault renders a timestamp @default, either now, an RFC 3339
+// time or epoch milliseconds, in the column's TimestampMode.
+func (g *SQLiteGenerator) timestampDefault(value interface{}) string {
+	native := g.TimestampMode == TimestampNative
+	switch v := value.(type) {
+	case string:
+		if isNowDefault(v) {
+			return nowSQL(DialectSQLite, g.TimestampMode)
+		}
+		if t, ok := timestampLiteral(v); ok {
+			if native {
+				return sqlString(t.UTC().Format("2006-01-02 15:04:05"))
+			}
+			return fmt.Sprintf("%d", t.UnixMilli())
+		}
+		return sqlString(v)
+	case int64:
+		if native {
+			return fmt.Sprintf("(datetime(%d / 1000, 'unixepoch'))", v)
+		}
+		return fmt.Sprintf("%d", v)
 	default:
 		return "NULL"
 	}
 }
 
-func (g *SQLiteGenerator) generateIndexes(entity *parser.EntityDecl) string {
+func (g *SQLiteGenerator) generateIndexes(entity *parser.EntityDecl) (string, error) {
 	var sb strings.Builder
 
 	tableName := entity.TableName()
@@ -200,17 +350,34 @@ func (g *SQLiteGenerator) generateIndexes(entity *parser.EntityDecl) string {
 		tableName = ToSnakeCase(entity.Name)
 	}
 
+	taken := make(map[string]bool)
 	for _, field := range entity.Fields {
 		if field.IsIndexed() && !field.IsPrimaryKey() {
 			colName := ToSnakeCase(field.Name)
-			indexName := fmt.Sprintf("idx_%s_%s", tableName, colName)
+			name := fmt.Sprintf("idx_%s_%s", tableName, colName)
+			taken[name] = true
 
 			sb.WriteString(fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s\n    ON %s(%s);\n",
-				indexName, tableName, colName))
+				name, g.ident(tableName), g.ident(colName)))
+		}
+	}
+
+	// Entity-level composite indexes
+	for _, idx := range entity.Indexes() {
+		where, err := indexWhere(idx, DialectSQLite, g.ident, g.TimestampMode)
+		if err != nil {
+			return "", fmt.Errorf("entity %s: %w", entity.Name, err)
+		}
+		unique := ""
+		if idx.Unique {
+			unique = "UNIQUE "
 		}
+
+		sb.WriteString(fmt.Sprintf("CREATE %sINDEX IF NOT EXISTS %s\n    ON %s(%s)%s;\n",
+			unique, indexName(tableName, idx, taken), g.ident(tableName), indexColumns(idx, g.ident), where))
 	}
 
-	return sb.String()
+	return sb.String(), nil
 }
 
 // GenerateMigration generates a migration from one schema version to another.
@@ -237,20 +404,22 @@ func (g *SQLiteGenerator) GenerateMigration(from, to *parser.EntityDecl) (string
 
 	sb.WriteString(fmt.Sprintf("-- Migration for table: %s\n\n", tableName))
 
-	// Find added columns
-	for name, field := range toFields {
-		if _, exists := fromFields[name]; !exists {
-			colDef := g.generateColumn(field)
+	// Find added columns, in declaration order
+	for _, field := range to.Fields {
+		if _, exists := fromFields[field.Name]; !exists {
+			colDef := g.generateColumn(field, false)
 			sb.WriteString(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s;\n",
-				tableName, colDef))
+				g.ident(tableName), colDef))
 		}
 	}
 
 	// Find removed columns (SQLite doesn't support DROP COLUMN in older versions)
 	var droppedCols []string
-	for name := range fromFields {
-		if _, exists := toFields[name]; !exists {
-			droppedCols = append(droppedCols, name)
+	if from != nil {
+		for _, field := range from.Fields {
+			if _, exists := toFields[field.Name]; !exists {
+				droppedCols = append(droppedCols, field.Name)
+			}
 		}
 	}
 
@@ -265,3 +434,11 @@ func (g *SQLiteGenerator) GenerateMigration(from, to *parser.EntityDecl) (string
 
 	return sb.String(), nil
 }
+
+// ident returns name as it appears in DDL, quoted when QuoteIdentifiers is set.
+func (g *SQLiteGenerator) ident(name string) string {
+	if g.QuoteIdentifiers {
+		return quoteIdent(name)
+	}
+	return name
+}
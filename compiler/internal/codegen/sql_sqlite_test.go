@@ -0,0 +1,631 @@
+package codegen
+
+import (
+	"strings"
+	"testing"
+)
+
+const compositeKeySchema = `
+package test;
+
+@table("event_tags")
+entity EventTag {
+    @pk event_id: string;
+    @pk tag_id: string;
+    note: string?;
+}
+`
+
+func TestSQLiteCompositePrimaryKey(t *testing.T) {
+	file := mustParse(t, compositeKeySchema)
+	ddl := generateOne(t, NewSQLiteGenerator(), file)
+
+	if !strings.Contains(ddl, "    PRIMARY KEY (event_id, tag_id)") {
+		t.Errorf("Expected composite PRIMARY KEY constraint, got:\n%s", ddl)
+	}
+	if !strings.Contains(ddl, "event_id TEXT NOT NULL,") {
+		t.Errorf("Expected key column without inline PRIMARY KEY, got:\n%s", ddl)
+	}
+}
+
+const compositeForeignKeySchema = `
+package test;
+
+@table("order_lines")
+entity OrderLine {
+    @pk order_id: string;
+    @pk line_no: int32;
+}
+
+@fk(fields: ["order_id", "line_no"], references: "OrderLine", ondelete: "cascade")
+entity Shipment {
+    @pk id: string;
+    order_id: string;
+    line_no: int32;
+}
+`
+
+func TestSQLiteCompositeForeignKey(t *testing.T) {
+	file := mustParse(t, compositeForeignKeySchema)
+	ddl := generateOne(t, NewSQLiteGenerator(), file)
+
+	want := "FOREIGN KEY (order_id, line_no) REFERENCES order_lines(order_id, line_no) ON DELETE CASCADE"
+	if !strings.Contains(ddl, want) {
+		t.Errorf("Expected multi-column FOREIGN KEY, got:\n%s", ddl)
+	}
+}
+
+const indexSchema = `
+package test;
+
+@table("events")
+@index(fields: ["calendar_id", "start_date"])
+@index(fields: ["calendar_id", "external_id"], unique: true)
+entity Event {
+    @pk id: string;
+    calendar_id: string;
+    external_id: string;
+    @indexed start_date: timestamp;
+}
+`
+
+func TestSQLiteIndexes(t *testing.T) {
+	file := mustParse(t, indexSchema)
+	ddl := generateOne(t, NewSQLiteGenerator(), file)
+
+	for _, want := range []string{
+		"CREATE INDEX IF NOT EXISTS idx_events_start_date\n    ON events(start_date);",
+		"CREATE INDEX IF NOT EXISTS idx_events_calendar_id_start_date\n    ON events(calendar_id, start_date);",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_events_calendar_id_external_id\n    ON events(calendar_id, external_id);",
+	} {
+		if !strings.Contains(ddl, want) {
+			t.Errorf("Expected %q in DDL, got:\n%s", want, ddl)
+		}
+	}
+}
+
+const partialIndexSchema = `
+package test;
+
+@table("events")
+@index(fields: ["calendar_id", "start_date DESC"], where: "deletedAt IS NULL AND active = true")
+entity Event {
+    @pk id: string;
+    calendar_id: string;
+    start_date: timestamp;
+    deletedAt: timestamp?;
+    active: bool;
+}
+`
+
+func TestSQLitePartialIndex(t *testing.T) {
+	file := mustParse(t, partialIndexSchema)
+	ddl := generateOne(t, NewSQLiteGenerator(), file)
+
+	want := "CREATE INDEX IF NOT EXISTS idx_events_calendar_id_start_date_desc_partial\n" +
+		"    ON events(calendar_id, start_date DESC) WHERE deleted_at IS NULL AND active = 1;"
+	if !strings.Contains(ddl, want) {
+		t.Errorf("Expected %q in DDL, got:\n%s", want, ddl)
+	}
+}
+
+func TestIndexNamesAreUnique(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+@table("events")
+@index(fields: ["calendarId", "startDate DESC"], where: "deletedAt IS NULL")
+@index(fields: ["calendarId", "startDate"])
+@index(fields: ["calendarId", "startDate"], where: "order > 0")
+@index(fields: ["calendarId", "startDate"], where: "order < 0")
+entity Event {
+    @pk id: string;
+    calendarId: string;
+    startDate: timestamp;
+    deletedAt: timestamp?;
+    order: int32;
+}
+`)
+	sqlite := NewSQLiteGenerator()
+	sqlite.QuoteIdentifiers = true
+	postgres := NewPostgresGenerator()
+	postgres.QuoteIdentifiers = true
+
+	for _, tt := range []struct {
+		name string
+		gen  Generator
+		want []string
+	}{
+		{"sqlite", sqlite, []string{
+			`idx_events_calendar_id_start_date_desc_partial` + "\n" +
+				`    ON "events"("calendar_id", "start_date" DESC) WHERE "deleted_at" IS NULL;`,
+			`idx_events_calendar_id_start_date` + "\n" + `    ON "events"("calendar_id", "start_date");`,
+			`idx_events_calendar_id_start_date_partial` + "\n" +
+				`    ON "events"("calendar_id", "start_date") WHERE "order" > 0;`,
+			`idx_events_calendar_id_start_date_partial_2` + "\n" +
+				`    ON "events"("calendar_id", "start_date") WHERE "order" < 0;`,
+		}},
+		{"postgres", postgres, []string{
+			`idx_events_calendar_id_start_date_desc_partial ON "events" ("calendar_id", "start_date" DESC) WHERE "deleted_at" IS NULL;`,
+			`idx_events_calendar_id_start_date ON "events" ("calendar_id", "start_date");`,
+			`idx_events_calendar_id_start_date_partial ON "events" ("calendar_id", "start_date") WHERE "order" > 0;`,
+			`idx_events_calendar_id_start_date_partial_2 ON "events" ("calendar_id", "start_date") WHERE "order" < 0;`,
+		}},
+	} {
+		ddl := generateOne(t, tt.gen, file)
+		for _, want := range tt.want {
+			if !strings.Contains(ddl, "CREATE INDEX IF NOT EXISTS "+want) {
+				t.Errorf("%s: expected %q in DDL, got:\n%s", tt.name, want, ddl)
+			}
+		}
+	}
+}
+
+func TestInvalidIndexWhere(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+@index(fields: ["title"], where: "title =")
+entity Task {
+    @pk id: string;
+    title: string;
+}
+`)
+	for _, gen := range []Generator{NewSQLiteGenerator(), NewPostgresGenerator()} {
+		if _, err := gen.Generate(file); err == nil || !strings.Contains(err.Error(), `invalid @index where condition "title ="`) {
+			t.Errorf("Expected invalid where error, got %v", err)
+		}
+	}
+}
+
+const foreignKeySchema = `
+package test;
+
+@table("calendar_events")
+entity CalendarEvent {
+    @pk id: string;
+}
+
+entity EventAttachment {
+    @pk id: string;
+    @fk(CalendarEvent.id) @ondelete(cascade) event_id: string;
+    @fk("CalendarEvent.id") @ondelete(setnull) source_event_id: string?;
+}
+`
+
+func TestSQLiteForeignKeys(t *testing.T) {
+	file := mustParse(t, foreignKeySchema)
+	ddl := generateOne(t, NewSQLiteGenerator(), file)
+
+	for _, want := range []string{
+		"FOREIGN KEY (event_id) REFERENCES calendar_events(id) ON DELETE CASCADE",
+		"FOREIGN KEY (source_event_id) REFERENCES calendar_events(id) ON DELETE SET NULL",
+	} {
+		if !strings.Contains(ddl, want) {
+			t.Errorf("Expected %q in DDL, got:\n%s", want, ddl)
+		}
+	}
+}
+
+const generatedSchema = `
+package test;
+
+@table("lines")
+entity Line {
+    @pk id: string;
+    price: double;
+    quantity: int32;
+    @generated("price * quantity") total: double;
+    @generated("price * 2", stored: false) doubled: double;
+}
+`
+
+func TestSQLiteGeneratedColumns(t *testing.T) {
+	file := mustParse(t, generatedSchema)
+	ddl := generateOne(t, NewSQLiteGenerator(), file)
+
+	for _, want := range []string{
+		"total REAL GENERATED ALWAYS AS (price * quantity) STORED",
+		"doubled REAL GENERATED ALWAYS AS (price * 2) VIRTUAL",
+	} {
+		if !strings.Contains(ddl, want) {
+			t.Errorf("Expected %q in DDL, got:\n%s", want, ddl)
+		}
+	}
+
+	out, err := NewJavaGenerator().Generate(file)
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+	if java := out["LineRepository.java"]; !strings.Contains(java, "INSERT OR REPLACE INTO lines (id, price, quantity) VALUES (?, ?, ?)") {
+		t.Errorf("Expected the upsert to skip the generated columns, got:\n%s", java)
+	}
+}
+
+const checkSchema = `
+package test;
+
+@table("reminders")
+entity Reminder {
+    @pk id: string;
+    @range(0, 3) priority: int32;
+    @pattern("^[a-z]+$") code: string;
+}
+`
+
+func TestSQLiteCheckConstraints(t *testing.T) {
+	file := mustParse(t, checkSchema)
+	ddl := generateOne(t, NewSQLiteGenerator(), file)
+
+	if !strings.Contains(ddl, "    CHECK (priority >= 0 AND priority <= 3)") {
+		t.Errorf("Expected range CHECK constraint, got:\n%s", ddl)
+	}
+	if strings.Contains(ddl, "REGEXP") {
+		t.Errorf("Expected no REGEXP check by default, got:\n%s", ddl)
+	}
+
+	g := NewSQLiteGenerator()
+	g.PatternChecks = true
+	ddl = generateOne(t, g, file)
+	if !strings.Contains(ddl, "    CHECK (code REGEXP '^[a-z]+$')") {
+		t.Errorf("Expected pattern CHECK constraint, got:\n%s", ddl)
+	}
+}
+
+func TestSQLiteMinCheck(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+@table("items")
+entity Item {
+    @pk id: string;
+    @min(0) stock: int32;
+    @max(9.5) score: double;
+}
+`)
+	ddl := generateOne(t, NewSQLiteGenerator(), file)
+
+	for _, want := range []string{"    CHECK (stock >= 0)", "    CHECK (score <= 9.5)"} {
+		if !strings.Contains(ddl, want) {
+			t.Errorf("Expected %q in DDL, got:\n%s", want, ddl)
+		}
+	}
+}
+
+const enumCheckSchema = `
+package test;
+
+enum Status {
+    ACTIVE = 0;
+    DONE = 1;
+}
+
+@table("tasks")
+entity Task {
+    @pk id: string;
+    status: Status;
+    @column(postgres: "task_status") state: Status;
+}
+`
+
+func TestSQLiteEnumCheck(t *testing.T) {
+	file := mustParse(t, enumCheckSchema)
+	ddl := generateOne(t, NewSQLiteGenerator(), file)
+
+	if !strings.Contains(ddl, "    CHECK (status IN ('ACTIVE', 'DONE'))") {
+		t.Errorf("Expected enum CHECK constraint, got:\n%s", ddl)
+	}
+	if !strings.Contains(ddl, "    CHECK (state IN ('ACTIVE', 'DONE'))") {
+		t.Errorf("Expected enum CHECK constraint on column without SQLite override, got:\n%s", ddl)
+	}
+}
+
+const checkAnnotationSchema = `
+package test;
+
+@table("bookings")
+@check("end_date >= start_date")
+entity Booking {
+    @pk id: string;
+    start_date: timestamp;
+    end_date: timestamp;
+    @check("guests > 0 AND guests <= 8") guests: int32;
+}
+`
+
+func TestSQLiteCheckAnnotations(t *testing.T) {
+	file := mustParse(t, checkAnnotationSchema)
+	ddl := generateOne(t, NewSQLiteGenerator(), file)
+
+	for _, want := range []string{
+		"    CHECK (end_date >= start_date)",
+		"    CHECK (guests > 0 AND guests <= 8)",
+	} {
+		if !strings.Contains(ddl, want) {
+			t.Errorf("Expected %q in DDL, got:\n%s", want, ddl)
+		}
+	}
+}
+
+const camelCheckSchema = `
+package test;
+
+@table("slots")
+@check("endAt > startAt OR active = false")
+entity Slot {
+    @pk id: string;
+    startAt: timestamp;
+    endAt: timestamp;
+    active: bool;
+}
+`
+
+func TestSQLiteCheckColumnNames(t *testing.T) {
+	file := mustParse(t, camelCheckSchema)
+	ddl := generateOne(t, NewSQLiteGenerator(), file)
+
+	if want := "    CHECK (end_at > start_at OR active = 0)"; !strings.Contains(ddl, want) {
+		t.Errorf("Expected %q in DDL, got:\n%s", want, ddl)
+	}
+}
+
+const autoIncrementSchema = `
+package test;
+
+entity Ticket {
+    @pk @autoincrement id: int64;
+    title: string;
+}
+
+entity Seat {
+    @pk @autoincrement id: int32;
+}
+`
+
+func TestSQLiteAutoIncrement(t *testing.T) {
+	file := mustParse(t, autoIncrementSchema)
+	ddl := generateOne(t, NewSQLiteGenerator(), file)
+
+	if n := strings.Count(ddl, "    id INTEGER PRIMARY KEY AUTOINCREMENT"); n != 2 {
+		t.Errorf("Expected 2 AUTOINCREMENT keys, got %d:\n%s", n, ddl)
+	}
+}
+
+func TestSQLiteColumnOverride(t *testing.T) {
+	file := mustParse(t, columnOverrideSchema)
+	ddl := generateOne(t, NewSQLiteGenerator(), file)
+
+	for _, want := range []string{"    price REAL NOT NULL", "    weight NUMERIC"} {
+		if !strings.Contains(ddl, want) {
+			t.Errorf("Expected %q in DDL, got:\n%s", want, ddl)
+		}
+	}
+}
+
+func TestSQLiteInheritedFields(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+entity Audited {
+    @pk id: string;
+    created_at: timestamp;
+}
+
+@table("notes")
+entity Note extends Audited {
+    body: string;
+}
+`)
+	ddl := generateOne(t, NewSQLiteGenerator(), file)
+
+	want := "CREATE TABLE IF NOT EXISTS notes (\n    id TEXT PRIMARY KEY,\n    created_at"
+	if !strings.Contains(ddl, want) {
+		t.Errorf("Expected inherited columns first in notes, got:\n%s", ddl)
+	}
+}
+
+const timestampSchema = `
+package test;
+
+@table("events")
+entity Event {
+    @pk id: string;
+    @required start_date: timestamp;
+}
+`
+
+func TestSQLiteTimestampMode(t *testing.T) {
+	file := mustParse(t, timestampSchema)
+
+	ddl := generateOne(t, NewSQLiteGenerator(), file)
+	if !strings.Contains(ddl, "start_date INTEGER NOT NULL") {
+		t.Errorf("Expected INTEGER epoch column by default, got:\n%s", ddl)
+	}
+
+	g := NewSQLiteGenerator()
+	g.TimestampMode = TimestampNative
+	ddl = generateOne(t, g, file)
+	if !strings.Contains(ddl, "start_date DATETIME NOT NULL") {
+		t.Errorf("Expected DATETIME column in native mode, got:\n%s", ddl)
+	}
+}
+
+const reservedNameSchema = `
+package test;
+
+@table("group")
+entity Group {
+    @pk id: string;
+    @indexed order: int32;
+}
+`
+
+func TestSQLiteQuoteIdentifiers(t *testing.T) {
+	if !IsReservedSQLKeyword("order", DialectSQLite) || !IsReservedSQLKeyword("ORDER", DialectSQLite) {
+		t.Error("Expected order to be reserved in SQLite")
+	}
+	if IsReservedSQLKeyword("title", DialectSQLite) {
+		t.Error("Expected title not to be reserved in SQLite")
+	}
+
+	file := mustParse(t, reservedNameSchema)
+
+	ddl := generateOne(t, NewSQLiteGenerator(), file)
+	if !strings.Contains(ddl, "    order INTEGER") {
+		t.Errorf("Expected unquoted names by default, got:\n%s", ddl)
+	}
+
+	g := NewSQLiteGenerator()
+	g.QuoteIdentifiers = true
+	ddl = generateOne(t, g, file)
+	for _, want := range []string{
+		`CREATE TABLE IF NOT EXISTS "group" (`,
+		`    "order" INTEGER`,
+		`ON "group"("order");`,
+	} {
+		if !strings.Contains(ddl, want) {
+			t.Errorf("Expected %q, got:\n%s", want, ddl)
+		}
+	}
+}
+
+func TestRepositoryQuoteIdentifiers(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+@table("group")
+entity Group {
+    @pk id: string;
+    order: int32;
+
+    query ranked(least: int32) {
+        where order >= least
+        order_by order DESC
+    }
+}
+`)
+
+	g := NewJavaGenerator()
+	g.QuoteIdentifiers = true
+	out, err := g.Generate(file)
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+	java := out["GroupRepository.java"]
+	for _, want := range []string{
+		`SQL_RANKED = "SELECT * FROM \"group\" WHERE \"order\" >= ? ORDER BY \"order\" DESC";`,
+		`String sql = "INSERT OR REPLACE INTO \"group\" (\"id\", \"order\") VALUES (?, ?)";`,
+		`String sql = "DELETE FROM \"group\" WHERE \"id\" = ?";`,
+	} {
+		if !strings.Contains(java, want) {
+			t.Errorf("Expected %q, got:\n%s", want, java)
+		}
+	}
+}
+
+func TestSQLiteColumnDoc(t *testing.T) {
+	ddl := generateOne(t, NewSQLiteGenerator(), mustParse(t, docSchema))
+	want := "    title TEXT NOT NULL -- The event's title, e.g. \"Bob's party\" shown in lists\n);"
+	if !strings.Contains(ddl, want) {
+		t.Errorf("Expected inline column comment, got:\n%s", ddl)
+	}
+	if !strings.Contains(ddl, "    id TEXT PRIMARY KEY,\n") {
+		t.Errorf("Expected undocumented column without comment, got:\n%s", ddl)
+	}
+}
+
+const viewSchema = `
+package test;
+
+@view(query: "Event.upcoming")
+entity UpcomingEvent {
+    id: string;
+    title: string;
+}
+
+@table("event_counts")
+@view("SELECT owner_id, COUNT(*) AS total FROM event GROUP BY owner_id;")
+entity EventCount {
+    ownerId: string;
+    total: int64;
+}
+
+entity Event {
+    @pk id: string;
+    @indexed title: string;
+    ownerId: string;
+    startTime: timestamp;
+
+    query upcoming() {
+        where startTime > 0
+        order_by startTime ASC
+    }
+}
+`
+
+func TestSQLiteView(t *testing.T) {
+	ddl := generateOne(t, NewSQLiteGenerator(), mustParse(t, viewSchema))
+
+	for _, want := range []string{
+		"CREATE VIEW IF NOT EXISTS upcoming_event AS\n    SELECT * FROM event WHERE start_time > 0 ORDER BY start_time ASC;\n",
+		"CREATE VIEW IF NOT EXISTS event_counts AS\n    SELECT owner_id, COUNT(*) AS total FROM event GROUP BY owner_id;\n",
+	} {
+		if !strings.Contains(ddl, want) {
+			t.Errorf("Expected %q, got:\n%s", want, ddl)
+		}
+	}
+	if strings.Contains(ddl, "CREATE TABLE IF NOT EXISTS upcoming_event") || strings.Contains(ddl, "event_counts (") {
+		t.Errorf("Expected no tables for views, got:\n%s", ddl)
+	}
+	if strings.Index(ddl, "CREATE TABLE IF NOT EXISTS event (") > strings.Index(ddl, "CREATE VIEW") {
+		t.Errorf("Expected views after the tables they select from, got:\n%s", ddl)
+	}
+}
+
+const defaultSchema = `
+package test;
+
+entity Task {
+    @pk id: string;
+    @default(false) done: bool;
+    @default(0) priority: int32;
+    @default(1) pinned: bool;
+    @default(0.5) weight: double;
+    @default(now) createdAt: timestamp;
+    @default("2024-01-01T00:00:00Z") startsAt: timestamp;
+    @required @default("") note: string?;
+    title: string;
+    dueAt: timestamp?;
+}
+`
+
+func TestSQLiteDefaults(t *testing.T) {
+	ddl := generateOne(t, NewSQLiteGenerator(), mustParse(t, defaultSchema))
+	for _, want := range []string{
+		"    done INTEGER NOT NULL DEFAULT 0,\n",
+		"    priority INTEGER NOT NULL DEFAULT 0,\n",
+		"    pinned INTEGER NOT NULL DEFAULT 1,\n",
+		"    weight REAL NOT NULL DEFAULT 0.5,\n",
+		"    created_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now') * 1000),\n",
+		"    starts_at INTEGER NOT NULL DEFAULT 1704067200000,\n",
+		"    note TEXT NOT NULL DEFAULT '',\n",
+		"    title TEXT NOT NULL,\n",
+		"    due_at INTEGER\n",
+	} {
+		if !strings.Contains(ddl, want) {
+			t.Errorf("Expected %q, got:\n%s", want, ddl)
+		}
+	}
+
+	g := NewSQLiteGenerator()
+	g.TimestampMode = TimestampNative
+	ddl = generateOne(t, g, mustParse(t, defaultSchema))
+	for _, want := range []string{
+		"    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,\n",
+		"    starts_at DATETIME NOT NULL DEFAULT '2024-01-01 00:00:00',\n",
+	} {
+		if !strings.Contains(ddl, want) {
+			t.Errorf("Expected %q, got:\n%s", want, ddl)
+		}
+	}
+}
@@ -13,6 +13,7 @@ type SwiftGenerator struct {
 	GenerateMappers    bool   // Generate iOS native type mappers (EKEvent, etc.)
 	GenerateRepository bool   // Generate local storage repository
 	UseCertification   bool   // Include certification check
+	QuoteIdentifiers   bool   // Double-quote table and column names in repository SQL
 }
 
 // NewSwiftGenerator creates a new SwiftGenerator with defaults.
@@ -27,8 +28,13 @@ func NewSwiftGenerator() *SwiftGenerator {
 
 // Generate generates Swift code from a DataProto file.
 func (g *SwiftGenerator) Generate(file *parser.File) (map[string]string, error) {
+	file = concreteFile(file)
 	result := make(map[string]string)
 
+	for _, enum := range file.Enums {
+		result[enum.Name+".swift"] = g.generateEnum(enum)
+	}
+
 	for _, entity := range file.Entities {
 		// Entity struct
 		entityCode := g.generateEntity(entity)
@@ -42,7 +48,7 @@ func (g *SwiftGenerator) Generate(file *parser.File) (map[string]string, error)
 
 		// Repository
 		if g.GenerateRepository {
-			repoCode := g.generateRepository(entity)
+			repoCode := g.generateRepository(file, entity)
 			result[entity.Name+"Repository.swift"] = repoCode
 		}
 	}
@@ -74,6 +80,16 @@ func (g *SwiftGenerator) generateEntity(entity *parser.EntityDecl) string {
 			sb.WriteString("    /// Primary key\n")
 		}
 
+		if field.HasAnnotation("computed") {
+			// Derived by the database and read back with the row
+			initial := "nil"
+			if !field.Type.Optional {
+				initial = g.swiftDefaultForType(field.Type.Name)
+			}
+			sb.WriteString("    /// Computed by the database\n")
+			sb.WriteString(fmt.Sprintf("    public private(set) var %s: %s = %s\n", propertyName, swiftType, initial))
+			continue
+		}
 		sb.WriteString(fmt.Sprintf("    public var %s: %s\n", propertyName, swiftType))
 	}
 
@@ -92,10 +108,15 @@ func (g *SwiftGenerator) generateEntity(entity *parser.EntityDecl) string {
 		}
 	}
 
-	// Memberwise initializer
+	// Memberwise initializer; computed fields are not the caller's to set
 	sb.WriteString("    public init(\n")
 	var initParams []string
+	var computed []*parser.FieldDecl
 	for _, field := range entity.Fields {
+		if field.HasAnnotation("computed") {
+			computed = append(computed, field)
+			continue
+		}
 		swiftType := g.swiftType(field.Type)
 		propertyName := ToCamelCase(field.Name)
 
@@ -113,9 +134,47 @@ func (g *SwiftGenerator) generateEntity(entity *parser.EntityDecl) string {
 	sb.WriteString("\n    ) {\n")
 
 	for _, field := range entity.Fields {
+		if field.HasAnnotation("computed") {
+			continue
+		}
 		propertyName := ToCamelCase(field.Name)
 		sb.WriteString(fmt.Sprintf("        self.%s = %s\n", propertyName, propertyName))
 	}
+	sb.WriteString("    }\n\n")
+
+	// Rows and protos read back carry the computed values too; the module
+	// builds those through an internal initializer taking every field
+	if len(computed) > 0 {
+		sb.WriteString("    init(\n")
+		var params, args []string
+		for _, field := range entity.Fields {
+			propertyName := ToCamelCase(field.Name)
+			params = append(params, fmt.Sprintf("        %s: %s", propertyName, g.swiftType(field.Type)))
+			if !field.HasAnnotation("computed") {
+				args = append(args, fmt.Sprintf("%s: %s", propertyName, propertyName))
+			}
+		}
+		sb.WriteString(strings.Join(params, ",\n"))
+		sb.WriteString("\n    ) {\n")
+		sb.WriteString(fmt.Sprintf("        self.init(%s)\n", strings.Join(args, ", ")))
+		for _, field := range computed {
+			propertyName := ToCamelCase(field.Name)
+			sb.WriteString(fmt.Sprintf("        self.%s = %s\n", propertyName, propertyName))
+		}
+		sb.WriteString("    }\n\n")
+	}
+
+	// CodingKeys map camelCase properties to snake_case or @json wire names
+	sb.WriteString("    enum CodingKeys: String, CodingKey {\n")
+	for _, field := range entity.Fields {
+		propertyName := ToCamelCase(field.Name)
+		wireName := JSONName(field)
+		if propertyName == wireName {
+			sb.WriteString(fmt.Sprintf("        case %s\n", propertyName))
+		} else {
+			sb.WriteString(fmt.Sprintf("        case %s = \"%s\"\n", propertyName, wireName))
+		}
+	}
 	sb.WriteString("    }\n")
 
 	sb.WriteString("}\n")
@@ -123,6 +182,56 @@ func (g *SwiftGenerator) generateEntity(entity *parser.EntityDecl) string {
 	return sb.String()
 }
 
+func (g *SwiftGenerator) generateEnum(enum *parser.EnumDecl) string {
+	var sb strings.Builder
+
+	sb.WriteString("// Code generated by dataprotoc. DO NOT EDIT.\n\n")
+	sb.WriteString("import Foundation\n\n")
+
+	sb.WriteString(fmt.Sprintf("public enum %s: Int, Codable, Sendable {\n", enum.Name))
+	for _, val := range enum.Values {
+		if enum.AliasOf(val) == nil {
+			sb.WriteString(fmt.Sprintf("    case %s = %d\n", ToCamelCase(val.Name), val.Number))
+		}
+	}
+
+	// Raw values must be unique, so an alias names the case it aliases
+	for _, val := range enum.Values {
+		if alias := enum.AliasOf(val); alias != nil {
+			sb.WriteString(fmt.Sprintf("    public static let %s = %s.%s\n",
+				ToCamelCase(val.Name), enum.Name, ToCamelCase(alias.Name)))
+		}
+	}
+
+	// Repositories store the schema's value names
+	sb.WriteString("\n    /// The value's name in the schema, as stored in SQL columns.\n")
+	sb.WriteString("    public var name: String {\n")
+	sb.WriteString("        switch self {\n")
+	for _, val := range enum.Values {
+		if enum.AliasOf(val) == nil {
+			sb.WriteString(fmt.Sprintf("        case .%s: return \"%s\"\n", ToCamelCase(val.Name), val.Name))
+		}
+	}
+	sb.WriteString("        }\n")
+	sb.WriteString("    }\n\n")
+	sb.WriteString("    /// Creates the value with the given schema name.\n")
+	sb.WriteString("    public init?(name: String) {\n")
+	sb.WriteString("        switch name {\n")
+	for _, val := range enum.Values {
+		target := val
+		if alias := enum.AliasOf(val); alias != nil {
+			target = alias
+		}
+		sb.WriteString(fmt.Sprintf("        case \"%s\": self = .%s\n", val.Name, ToCamelCase(target.Name)))
+	}
+	sb.WriteString("        default: return nil\n")
+	sb.WriteString("        }\n")
+	sb.WriteString("    }\n")
+	sb.WriteString("}\n")
+
+	return sb.String()
+}
+
 func (g *SwiftGenerator) generateMapper(entity *parser.EntityDecl) string {
 	var sb strings.Builder
 
@@ -217,6 +326,9 @@ func (g *SwiftGenerator) generateEKEventMapper(entity *parser.EntityDecl) string
 
 	var initParams []string
 	for _, field := range entity.Fields {
+		if field.HasAnnotation("computed") {
+			continue
+		}
 		propertyName := ToCamelCase(field.Name)
 		if mapping, ok := mappings[propertyName]; ok {
 			initParams = append(initParams, fmt.Sprintf("            %s: %s", propertyName, mapping))
@@ -258,6 +370,9 @@ func (g *SwiftGenerator) generateEKReminderMapper(entity *parser.EntityDecl) str
 
 	var initParams []string
 	for _, field := range entity.Fields {
+		if field.HasAnnotation("computed") {
+			continue
+		}
 		propertyName := ToCamelCase(field.Name)
 		if mapping, ok := mappings[propertyName]; ok {
 			initParams = append(initParams, fmt.Sprintf("            %s: %s", propertyName, mapping))
@@ -299,6 +414,9 @@ func (g *SwiftGenerator) generatePHAssetMapper(entity *parser.EntityDecl) string
 
 	var initParams []string
 	for _, field := range entity.Fields {
+		if field.HasAnnotation("computed") {
+			continue
+		}
 		propertyName := ToCamelCase(field.Name)
 		if mapping, ok := mappings[propertyName]; ok {
 			initParams = append(initParams, fmt.Sprintf("            %s: %s", propertyName, mapping))
@@ -319,13 +437,14 @@ func (g *SwiftGenerator) generatePHAssetMapper(entity *parser.EntityDecl) string
 	return sb.String()
 }
 
-func (g *SwiftGenerator) generateRepository(entity *parser.EntityDecl) string {
+func (g *SwiftGenerator) generateRepository(file *parser.File, entity *parser.EntityDecl) string {
 	var sb strings.Builder
 
 	tableName := entity.TableName()
 	if tableName == "" {
 		tableName = ToSnakeCase(entity.Name)
 	}
+	tableName = g.ident(tableName)
 
 	// Header
 	sb.WriteString("// Code generated by dataprotoc. DO NOT EDIT.\n\n")
@@ -351,22 +470,41 @@ func (g *SwiftGenerator) generateRepository(entity *parser.EntityDecl) string {
 	sb.WriteString("        sqlite3_close(db)\n")
 	sb.WriteString("    }\n\n")
 
-	// CRUD methods
-	sb.WriteString(g.generateSwiftUpsert(entity, tableName))
+	// CRUD methods; a view is read-only
+	readOnly := entity.View() != nil
+	if !readOnly {
+		sb.WriteString(g.generateSwiftUpsert(file, entity, tableName))
+	}
 	sb.WriteString(g.generateSwiftFindById(entity, tableName))
 	sb.WriteString(g.generateSwiftFindAll(entity, tableName))
-	sb.WriteString(g.generateSwiftDelete(entity, tableName))
+	if !readOnly {
+		sb.WriteString(g.generateSwiftDelete(entity, tableName))
+	}
 
 	// Query methods
 	for _, query := range entity.Queries {
-		sb.WriteString(g.generateSwiftQueryMethod(entity, query, tableName))
+		sb.WriteString(g.generateSwiftQueryMethod(file, entity, query, tableName))
 	}
 
 	// Row mapper
-	sb.WriteString(g.generateSwiftRowMapper(entity))
+	sb.WriteString(g.generateSwiftRowMapper(file, entity))
+	for _, query := range entity.Queries {
+		if fields := queryRowFields(entity, query); fields != nil {
+			sb.WriteString("\n")
+			sb.WriteString(g.generateSwiftQueryRowMapper(file, entity, query, fields))
+		}
+	}
 
 	sb.WriteString("}\n\n")
 
+	// The rows projecting queries return
+	for _, query := range entity.Queries {
+		if fields := queryRowFields(entity, query); fields != nil {
+			sb.WriteString(g.generateSwiftQueryRow(entity, query, fields))
+			sb.WriteString("\n")
+		}
+	}
+
 	// Error enum
 	sb.WriteString("public enum DataProtoError: Error {\n")
 	sb.WriteString("    case databaseError(String)\n")
@@ -376,28 +514,29 @@ func (g *SwiftGenerator) generateRepository(entity *parser.EntityDecl) string {
 	return sb.String()
 }
 
-func (g *SwiftGenerator) generateSwiftUpsert(entity *parser.EntityDecl, tableName string) string {
+func (g *SwiftGenerator) generateSwiftUpsert(file *parser.File, entity *parser.EntityDecl, tableName string) string {
 	var sb strings.Builder
 
+	fields := upsertFields(entity)
 	var columns []string
 	var placeholders []string
-	for _, field := range entity.Fields {
-		columns = append(columns, ToSnakeCase(field.Name))
+	for _, field := range fields {
+		columns = append(columns, g.ident(ToSnakeCase(field.Name)))
 		placeholders = append(placeholders, "?")
 	}
 
 	sb.WriteString(fmt.Sprintf("    public func upsert(_ entity: %s) throws {\n", entity.Name))
-	sb.WriteString(fmt.Sprintf("        let sql = \"INSERT OR REPLACE INTO %s (%s) VALUES (%s)\"\n",
-		tableName, strings.Join(columns, ", "), strings.Join(placeholders, ", ")))
+	sb.WriteString(fmt.Sprintf("        let sql = %s\n", sqlLiteral(fmt.Sprintf("INSERT OR REPLACE INTO %s (%s) VALUES (%s)",
+		tableName, strings.Join(columns, ", "), strings.Join(placeholders, ", ")))))
 	sb.WriteString("        var stmt: OpaquePointer?\n")
 	sb.WriteString("        guard sqlite3_prepare_v2(db, sql, -1, &stmt, nil) == SQLITE_OK else {\n")
 	sb.WriteString("            throw DataProtoError.databaseError(String(cString: sqlite3_errmsg(db)))\n")
 	sb.WriteString("        }\n")
 	sb.WriteString("        defer { sqlite3_finalize(stmt) }\n\n")
 
-	for i, field := range entity.Fields {
+	for i, field := range fields {
 		propertyName := ToCamelCase(field.Name)
-		binding := g.swiftSQLiteBinding(field, i+1, "entity."+propertyName)
+		binding := g.swiftSQLiteBinding(file, field, i+1, "entity."+propertyName)
 		sb.WriteString(fmt.Sprintf("        %s\n", binding))
 	}
 
@@ -430,8 +569,7 @@ func (g *SwiftGenerator) generateSwiftFindById(entity *parser.EntityDecl, tableN
 
 	sb.WriteString(fmt.Sprintf("    public func findById(_ %s: %s) throws -> %s? {\n",
 		pkName, pkType, entity.Name))
-	sb.WriteString(fmt.Sprintf("        let sql = \"SELECT * FROM %s WHERE %s = ?\"\n",
-		tableName, pkCol))
+	sb.WriteString(fmt.Sprintf("        let sql = %s\n", sqlLiteral(findByKeySQL(entity, tableName, pkCol, g.ident))))
 	sb.WriteString("        var stmt: OpaquePointer?\n")
 	sb.WriteString("        guard sqlite3_prepare_v2(db, sql, -1, &stmt, nil) == SQLITE_OK else {\n")
 	sb.WriteString("            throw DataProtoError.databaseError(String(cString: sqlite3_errmsg(db)))\n")
@@ -451,7 +589,7 @@ func (g *SwiftGenerator) generateSwiftFindAll(entity *parser.EntityDecl, tableNa
 	var sb strings.Builder
 
 	sb.WriteString(fmt.Sprintf("    public func findAll() throws -> [%s] {\n", entity.Name))
-	sb.WriteString(fmt.Sprintf("        let sql = \"SELECT * FROM %s\"\n", tableName))
+	sb.WriteString(fmt.Sprintf("        let sql = %s\n", sqlLiteral(findAllSQL(entity, tableName, g.ident))))
 	sb.WriteString("        var stmt: OpaquePointer?\n")
 	sb.WriteString("        guard sqlite3_prepare_v2(db, sql, -1, &stmt, nil) == SQLITE_OK else {\n")
 	sb.WriteString("            throw DataProtoError.databaseError(String(cString: sqlite3_errmsg(db)))\n")
@@ -488,8 +626,7 @@ func (g *SwiftGenerator) generateSwiftDelete(entity *parser.EntityDecl, tableNam
 
 	sb.WriteString(fmt.Sprintf("    public func delete(_ %s: %s) throws -> Bool {\n",
 		pkName, pkType))
-	sb.WriteString(fmt.Sprintf("        let sql = \"DELETE FROM %s WHERE %s = ?\"\n",
-		tableName, pkCol))
+	sb.WriteString(fmt.Sprintf("        let sql = %s\n", sqlLiteral(deleteSQL(entity, tableName, pkCol, g.ident, TimestampEpochMillis))))
 	sb.WriteString("        var stmt: OpaquePointer?\n")
 	sb.WriteString("        guard sqlite3_prepare_v2(db, sql, -1, &stmt, nil) == SQLITE_OK else {\n")
 	sb.WriteString("            throw DataProtoError.databaseError(String(cString: sqlite3_errmsg(db)))\n")
@@ -502,7 +639,7 @@ func (g *SwiftGenerator) generateSwiftDelete(entity *parser.EntityDecl, tableNam
 	return sb.String()
 }
 
-func (g *SwiftGenerator) generateSwiftQueryMethod(entity *parser.EntityDecl, query *parser.QueryDecl, tableName string) string {
+func (g *SwiftGenerator) generateSwiftQueryMethod(file *parser.File, entity *parser.EntityDecl, query *parser.QueryDecl, tableName string) string {
 	var sb strings.Builder
 
 	// Method signature
@@ -510,73 +647,75 @@ func (g *SwiftGenerator) generateSwiftQueryMethod(entity *parser.EntityDecl, que
 
 	var params []string
 	for _, p := range query.Params {
-		swiftType := g.swiftType(p.Type)
+		swiftType := g.swiftBaseType(p.Type.Name)
+		if p.Nullable() {
+			swiftType += "?"
+		}
 		paramName := ToCamelCase(p.Name)
 		if p.Default != nil {
 			params = append(params, fmt.Sprintf("%s: %s = %s",
 				paramName, swiftType, g.swiftDefaultValue(p.Default, p.Type.Name)))
+		} else if p.DefaultExpr != nil {
+			// nil lets the query substitute the default
+			params = append(params, fmt.Sprintf("%s: %s? = nil",
+				paramName, g.swiftBaseType(p.Type.Name)))
 		} else {
 			params = append(params, fmt.Sprintf("%s: %s", paramName, swiftType))
 		}
 	}
-	sb.WriteString(strings.Join(params, ", "))
-	sb.WriteString(fmt.Sprintf(") throws -> [%s] {\n", entity.Name))
-
-	// Build set of known parameter names
-	knownParams := make(map[string]bool)
-	for _, p := range query.Params {
-		knownParams[p.Name] = true
+	// A projecting query yields its own row type
+	rowType, mapper := entity.Name, "mapRow"
+	if queryRowFields(entity, query) != nil {
+		rowType = queryRowName(entity, query)
+		mapper = "map" + rowType
 	}
 
-	// Build SQL
-	var sqlParts []string
-	sqlParts = append(sqlParts, fmt.Sprintf("SELECT * FROM %s", tableName))
-
-	if query.Where != nil {
-		whereSQL, _ := ExprToSQLWithKnownParams(query.Where, knownParams)
-		sqlParts = append(sqlParts, "WHERE "+whereSQL)
+	sb.WriteString(strings.Join(params, ", "))
+	if query.ReturnsOne() {
+		// @returns(one) queries yield the first row, if any
+		sb.WriteString(fmt.Sprintf(") throws -> %s? {\n", rowType))
+	} else {
+		sb.WriteString(fmt.Sprintf(") throws -> [%s] {\n", rowType))
 	}
 
-	if len(query.OrderBy) > 0 {
-		var orderParts []string
-		for _, o := range query.OrderBy {
-			dir := "ASC"
-			if o.Descending {
-				dir = "DESC"
-			}
-			orderParts = append(orderParts, fmt.Sprintf("%s %s", ToSnakeCase(o.Field), dir))
-		}
-		sqlParts = append(sqlParts, "ORDER BY "+strings.Join(orderParts, ", "))
-	}
+	sql, names := selectSQL(DialectSQLite, entity, tableName, query, g.ident, TimestampEpochMillis)
 
-	if query.Limit != nil {
-		if _, ok := query.Limit.(*parser.IdentExpr); ok {
-			sqlParts = append(sqlParts, "LIMIT ?")
-		} else if lit, ok := query.Limit.(*parser.LiteralExpr); ok {
-			if val, ok := lit.Value.(int64); ok {
-				sqlParts = append(sqlParts, fmt.Sprintf("LIMIT %d", val))
-			}
-		}
-	}
-
-	sb.WriteString(fmt.Sprintf("        let sql = \"%s\"\n", strings.Join(sqlParts, " ")))
+	sb.WriteString(fmt.Sprintf("        let sql = %s\n", sqlLiteral(sql)))
 	sb.WriteString("        var stmt: OpaquePointer?\n")
 	sb.WriteString("        guard sqlite3_prepare_v2(db, sql, -1, &stmt, nil) == SQLITE_OK else {\n")
 	sb.WriteString("            throw DataProtoError.databaseError(String(cString: sqlite3_errmsg(db)))\n")
 	sb.WriteString("        }\n")
 	sb.WriteString("        defer { sqlite3_finalize(stmt) }\n\n")
 
-	// Bind parameters
-	paramIdx := 1
-	for _, p := range query.Params {
-		binding := g.swiftSQLiteBindingByType(p.Type.Name, paramIdx, ToCamelCase(p.Name))
+	// Bind parameters, one value per placeholder
+	for i, name := range names {
+		p := query.Param(name)
+		typeName, suffix := p.Type.Name, ""
+		if typeEnum(file, p.Type) != nil {
+			// Enums are stored by value name
+			typeName, suffix = "string", ".name"
+		}
+		if p.Nullable() || p.DefaultExpr != nil {
+			// Bind SQL NULL for nil, which the query reads as "no filter" or
+			// "use the default"
+			binding := g.swiftSQLiteBindingByType(typeName, i+1, "v"+suffix)
+			sb.WriteString(fmt.Sprintf("        if let v = %s { %s } else { sqlite3_bind_null(stmt, %d) }\n",
+				ToCamelCase(name), binding, i+1))
+			continue
+		}
+		binding := g.swiftSQLiteBindingByType(typeName, i+1, ToCamelCase(name)+suffix)
 		sb.WriteString(fmt.Sprintf("        %s\n", binding))
-		paramIdx++
 	}
 
-	sb.WriteString(fmt.Sprintf("\n        var results: [%s] = []\n", entity.Name))
+	if query.ReturnsOne() {
+		sb.WriteString("\n        guard sqlite3_step(stmt) == SQLITE_ROW else { return nil }\n")
+		sb.WriteString(fmt.Sprintf("        return %s(stmt)\n", mapper))
+		sb.WriteString("    }\n\n")
+		return sb.String()
+	}
+	sb.WriteString(fmt.Sprintf("\n        var results: [%s] = []\n", rowType))
 	sb.WriteString("        while sqlite3_step(stmt) == SQLITE_ROW {\n")
-	sb.WriteString("            results.append(mapRow(stmt))\n")
+	sb.WriteString(fmt.Sprintf("            results.append(%s(stmt))\n", mapper))
 	sb.WriteString("        }\n")
 	sb.WriteString("        return results\n")
 	sb.WriteString("    }\n\n")
@@ -584,7 +723,7 @@ func (g *SwiftGenerator) generateSwiftQueryMethod(entity *parser.EntityDecl, que
 	return sb.String()
 }
 
-func (g *SwiftGenerator) generateSwiftRowMapper(entity *parser.EntityDecl) string {
+func (g *SwiftGenerator) generateSwiftRowMapper(file *parser.File, entity *parser.EntityDecl) string {
 	var sb strings.Builder
 
 	sb.WriteString(fmt.Sprintf("    private func mapRow(_ stmt: OpaquePointer?) -> %s {\n", entity.Name))
@@ -593,7 +732,7 @@ func (g *SwiftGenerator) generateSwiftRowMapper(entity *parser.EntityDecl) strin
 	var mapperLines []string
 	for i, field := range entity.Fields {
 		propertyName := ToCamelCase(field.Name)
-		getter := g.swiftSQLiteGetter(field, i)
+		getter := g.swiftSQLiteGetter(file, field, i)
 		mapperLines = append(mapperLines, fmt.Sprintf("            %s: %s", propertyName, getter))
 	}
 	sb.WriteString(strings.Join(mapperLines, ",\n"))
@@ -603,6 +742,42 @@ func (g *SwiftGenerator) generateSwiftRowMapper(entity *parser.EntityDecl) strin
 	return sb.String()
 }
 
+// generateSwiftQueryRow emits the struct holding a row of a projecting
+// query, with one property per selected column.
+func (g *SwiftGenerator) generateSwiftQueryRow(entity *parser.EntityDecl, query *parser.QueryDecl, fields []*parser.FieldDecl) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("/// Row returned by `%sRepository.%s`.\n", entity.Name, ToCamelCase(query.Name)))
+	sb.WriteString(fmt.Sprintf("public struct %s: Sendable {\n", queryRowName(entity, query)))
+	for _, field := range fields {
+		sb.WriteString(fmt.Sprintf("    public let %s: %s\n", ToCamelCase(field.Name), g.swiftType(field.Type)))
+	}
+	sb.WriteString("}\n")
+
+	return sb.String()
+}
+
+// generateSwiftQueryRowMapper emits the method mapping a result row of a
+// projecting query, whose columns are in select order, to its row struct.
+func (g *SwiftGenerator) generateSwiftQueryRowMapper(file *parser.File, entity *parser.EntityDecl, query *parser.QueryDecl, fields []*parser.FieldDecl) string {
+	var sb strings.Builder
+	rowType := queryRowName(entity, query)
+
+	sb.WriteString(fmt.Sprintf("    private func map%s(_ stmt: OpaquePointer?) -> %s {\n", rowType, rowType))
+	sb.WriteString(fmt.Sprintf("        %s(\n", rowType))
+
+	var mapperLines []string
+	for i, field := range fields {
+		getter := g.swiftSQLiteGetter(file, field, i)
+		mapperLines = append(mapperLines, fmt.Sprintf("            %s: %s", ToCamelCase(field.Name), getter))
+	}
+	sb.WriteString(strings.Join(mapperLines, ",\n"))
+	sb.WriteString("\n        )\n")
+	sb.WriteString("    }\n")
+
+	return sb.String()
+}
+
 func (g *SwiftGenerator) generateQueryBuilder(file *parser.File) string {
 	var sb strings.Builder
 
@@ -687,7 +862,10 @@ func (g *SwiftGenerator) swiftBaseType(typeName string) string {
 }
 
 func (g *SwiftGenerator) swiftDefaultValue(value interface{}, typeName string) string {
-	switch v := value.(type) {
+	if isNowValue(value, typeName) {
+		return "Int64(Date().timeIntervalSince1970 * 1000)"
+	}
+	switch v := epochDefault(value, typeName).(type) {
 	case string:
 		return fmt.Sprintf("\"%s\"", v)
 	case bool:
@@ -736,7 +914,15 @@ func (g *SwiftGenerator) protoSetter(field *parser.FieldDecl, propertyName strin
 	return fmt.Sprintf("proto.%s = %s", propertyName, propertyName)
 }
 
-func (g *SwiftGenerator) swiftSQLiteBinding(field *parser.FieldDecl, index int, value string) string {
+func (g *SwiftGenerator) swiftSQLiteBinding(file *parser.File, field *parser.FieldDecl, index int, value string) string {
+	if typeEnum(file, field.Type) != nil {
+		// Enums are stored by value name
+		if field.Type.Optional {
+			return fmt.Sprintf("if let v = %s { sqlite3_bind_text(stmt, %d, v.name, -1, nil) } else { sqlite3_bind_null(stmt, %d) }",
+				value, index, index)
+		}
+		return fmt.Sprintf("sqlite3_bind_text(stmt, %d, %s.name, -1, nil)", index, value)
+	}
 	switch field.Type.Name {
 	case "string":
 		if field.Type.Optional {
@@ -786,7 +972,14 @@ func (g *SwiftGenerator) swiftSQLiteBindingByType(typeName string, index int, va
 	}
 }
 
-func (g *SwiftGenerator) swiftSQLiteGetter(field *parser.FieldDecl, index int) string {
+func (g *SwiftGenerator) swiftSQLiteGetter(file *parser.File, field *parser.FieldDecl, index int) string {
+	if enum := typeEnum(file, field.Type); enum != nil {
+		base := fmt.Sprintf("%s(name: String(cString: sqlite3_column_text(stmt, %d)))!", enum.Name, index)
+		if field.Type.Optional {
+			return fmt.Sprintf("sqlite3_column_type(stmt, %d) != SQLITE_NULL ? %s : nil", index, base)
+		}
+		return base
+	}
 	switch field.Type.Name {
 	case "string":
 		base := fmt.Sprintf("String(cString: sqlite3_column_text(stmt, %d))", index)
@@ -824,3 +1017,12 @@ func (g *SwiftGenerator) swiftSQLiteGetter(field *parser.FieldDecl, index int) s
 		return fmt.Sprintf("String(cString: sqlite3_column_text(stmt, %d))", index)
 	}
 }
+
+// ident returns name as it appears in repository SQL, quoted when
+// QuoteIdentifiers is set.
+func (g *SwiftGenerator) ident(name string) string {
+	if g.QuoteIdentifiers {
+		return quoteIdent(name)
+	}
+	return name
+}
@@ -0,0 +1,120 @@
+package codegen
+
+import (
+	"strings"
+	"testing"
+)
+
+const calendarSchemaPath = "../../../examples/aurora/calendar.dataproto"
+
+func TestSwiftCalendarEventGolden(t *testing.T) {
+	file := mustParseFile(t, calendarSchemaPath)
+
+	out, err := NewSwiftGenerator().Generate(file)
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+
+	code, ok := out["CalendarEvent.swift"]
+	if !ok {
+		t.Fatal("Expected CalendarEvent.swift to be generated")
+	}
+	assertGolden(t, "swift/CalendarEvent.swift.golden", code)
+}
+
+func TestSwiftEnum(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+enum TaskStatus {
+    PENDING = 0;
+    IN_PROGRESS = 1;
+}
+`)
+
+	out, err := NewSwiftGenerator().Generate(file)
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+
+	code := out["TaskStatus.swift"]
+	for _, want := range []string{
+		"public enum TaskStatus: Int, Codable, Sendable {",
+		"    case pending = 0",
+		"    case inProgress = 1",
+		`        case .inProgress: return "IN_PROGRESS"`,
+		`        case "IN_PROGRESS": self = .inProgress`,
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("Expected %q in output, got:\n%s", want, code)
+		}
+	}
+}
+
+func TestSwiftEnumAlias(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+enum Status {
+    option allow_alias = true;
+    ACTIVE = 0;
+    DELETED = 3;
+    REMOVED = 3;
+}
+`)
+
+	out, err := NewSwiftGenerator().Generate(file)
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+
+	code := out["Status.swift"]
+	for _, want := range []string{
+		"    case deleted = 3\n",
+		"    public static let removed = Status.deleted\n",
+		`        case "REMOVED": self = .deleted`,
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("Expected %q in output, got:\n%s", want, code)
+		}
+	}
+	for _, unwanted := range []string{"case removed", "case .removed"} {
+		if strings.Contains(code, unwanted) {
+			t.Errorf("Expected no %q in output, got:\n%s", unwanted, code)
+		}
+	}
+}
+
+func TestSwiftComputedField(t *testing.T) {
+	file := mustParse(t, `
+package test;
+
+entity Person {
+    @pk id: string;
+    first: string;
+    last: string;
+    @computed("first || ' ' || last") fullName: string;
+}
+`)
+
+	out, err := NewSwiftGenerator().Generate(file)
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+
+	code := out["Person.swift"]
+	for _, want := range []string{
+		"    public private(set) var fullName: String = \"\"\n",
+		"    public init(\n        id: String,\n        first: String,\n        last: String\n    ) {\n",
+		"        self.init(id: id, first: first, last: last)\n        self.fullName = fullName\n",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("Expected %q in output, got:\n%s", want, code)
+		}
+	}
+
+	repo := out["PersonRepository.swift"]
+	if !strings.Contains(repo, "INSERT OR REPLACE INTO person (id, first, last) VALUES (?, ?, ?)") {
+		t.Errorf("Expected the upsert to leave out the computed field, got:\n%s", repo)
+	}
+}
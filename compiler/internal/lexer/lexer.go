@@ -17,6 +17,13 @@ type Lexer struct {
 	line     int  // current line number (1-indexed)
 	column   int  // current column number (1-indexed)
 	lineStart int // position of current line start
+	lineStarts []int // start of every line seen so far, by line number - 1
+	indexed    int   // position up to which lineStarts is complete
+
+	doc      []string       // line comments pending attachment to the next token
+	docLine  int            // line of the last pending doc comment
+	prevLine int            // line of the last token returned
+	docs     map[[2]int]string // doc comments by the line and column of their token
 }
 
 // New creates a new Lexer for the given input.
@@ -26,6 +33,7 @@ func New(input string) *Lexer {
 		line:      1,
 		column:    1,
 		lineStart: 0,
+		lineStarts: []int{0},
 	}
 	l.readChar()
 	return l
@@ -47,10 +55,43 @@ func (l *Lexer) readChar() {
 		r, width := utf8.DecodeRuneInString(l.input[l.readPos:])
 		l.ch = r
 		l.readPos += width
+		if l.readPos > l.indexed {
+			if r == '\n' {
+				l.lineStarts = append(l.lineStarts, l.readPos)
+			}
+			l.indexed = l.readPos
+		}
 	}
 	l.column = l.pos - l.lineStart + 1
 }
 
+// LineText returns the text of the 1-indexed line, without its line ending,
+// or "" if the input has no such line. Lines past the scanned part of the
+// input are indexed on demand.
+func (l *Lexer) LineText(line int) string {
+	for len(l.lineStarts) <= line && l.indexed < len(l.input) {
+		i := strings.IndexByte(l.input[l.indexed:], '\n')
+		if i < 0 {
+			l.indexed = len(l.input)
+			break
+		}
+		l.indexed += i + 1
+		l.lineStarts = append(l.lineStarts, l.indexed)
+	}
+	if line < 1 || line > len(l.lineStarts) {
+		return ""
+	}
+	start := l.lineStarts[line-1]
+	if start == len(l.input) && line > 1 {
+		return "" // after a trailing newline
+	}
+	end := len(l.input)
+	if line < len(l.lineStarts) {
+		end = l.lineStarts[line] - 1
+	}
+	return strings.TrimSuffix(l.input[start:end], "\r")
+}
+
 // peekChar returns the next character without advancing.
 func (l *Lexer) peekChar() rune {
 	if l.readPos >= len(l.input) {
@@ -62,7 +103,30 @@ func (l *Lexer) peekChar() rune {
 
 // NextToken returns the next token from the input.
 func (l *Lexer) NextToken() Token {
-	l.skipWhitespaceAndComments()
+	tok := l.nextToken()
+	if len(l.doc) > 0 && l.docLine == tok.Line-1 {
+		if l.docs == nil {
+			l.docs = make(map[[2]int]string)
+		}
+		l.docs[[2]int{tok.Line, tok.Column}] = strings.Join(l.doc, "\n")
+	}
+	l.doc = nil
+	l.prevLine = tok.Line
+	return tok
+}
+
+// Doc returns the doc comment of the token at line and column: the run of
+// // comment lines directly above it, without their comment markers. Only the
+// first token of a line has one; a blank line, a block comment or code in
+// between detaches the comments. Only tokens already returned are known.
+func (l *Lexer) Doc(line, column int) string {
+	return l.docs[[2]int{line, column}]
+}
+
+func (l *Lexer) nextToken() Token {
+	if illegal, ok := l.skipWhitespaceAndComments(); !ok {
+		return illegal
+	}
 
 	tok := Token{
 		Line:   l.line,
@@ -101,7 +165,7 @@ func (l *Lexer) NextToken() Token {
 		tok = l.newToken(PLUS, "+")
 	case '-':
 		if isDigit(l.peekChar()) {
-			tok = l.readNumber()
+			return l.readNumber() // readNumber already advanced
 		} else {
 			tok = l.newToken(MINUS, "-")
 		}
@@ -141,8 +205,8 @@ func (l *Lexer) NextToken() Token {
 		} else {
 			tok = l.newToken(ILLEGAL, string(l.ch))
 		}
-	case '"':
-		tok = l.readString()
+	case '"', '\'':
+		return l.readString() // readString already advanced
 	default:
 		if isLetter(l.ch) {
 			tok = l.readIdentifier()
@@ -169,8 +233,9 @@ func (l *Lexer) newToken(tokenType TokenType, literal string) Token {
 	}
 }
 
-// skipWhitespaceAndComments skips whitespace and comments.
-func (l *Lexer) skipWhitespaceAndComments() {
+// skipWhitespaceAndComments skips whitespace and comments. It returns an
+// ILLEGAL token and false when a block comment is not terminated.
+func (l *Lexer) skipWhitespaceAndComments() (Token, bool) {
 	for {
 		// Skip whitespace
 		for l.ch == ' ' || l.ch == '\t' || l.ch == '\r' || l.ch == '\n' {
@@ -184,49 +249,94 @@ func (l *Lexer) skipWhitespaceAndComments() {
 		// Check for comments
 		if l.ch == '/' {
 			if l.peekChar() == '/' {
-				// Line comment
-				l.skipLineComment()
+				// Line comment; one trailing code on the same line is not doc
+				line := l.line
+				text := l.skipLineComment()
+				if line != l.prevLine {
+					if len(l.doc) > 0 && l.docLine != line-1 {
+						l.doc = nil
+					}
+					l.doc = append(l.doc, text)
+					l.docLine = line
+				}
 				continue
 			} else if l.peekChar() == '*' {
 				// Block comment
-				l.skipBlockComment()
+				l.doc = nil
+				start := l.newToken(ILLEGAL, "unterminated block comment")
+				if !l.skipBlockComment() {
+					return start, false
+				}
 				continue
 			}
 		}
 
-		break
+		return Token{}, true
 	}
 }
 
-// skipLineComment skips a // comment.
-func (l *Lexer) skipLineComment() {
+// skipLineComment skips a // comment and returns its text, without the
+// leading slashes and one following space.
+func (l *Lexer) skipLineComment() string {
+	start := l.pos + 2
 	for l.ch != '\n' && l.ch != 0 {
 		l.readChar()
 	}
+	text := strings.TrimRight(l.input[start:l.pos], " \t\r")
+	return strings.TrimPrefix(text, " ")
 }
 
-// skipBlockComment skips a /* */ comment.
-func (l *Lexer) skipBlockComment() {
+// skipBlockComment skips a /* */ comment. Block comments nest, so
+// /* a /* b */ c */ is a single comment. It reports false if the input ends
+// before the comment is closed.
+func (l *Lexer) skipBlockComment() bool {
 	l.readChar() // skip '/'
 	l.readChar() // skip '*'
 
+	depth := 1
 	for {
 		if l.ch == 0 {
-			return // EOF
+			return false // EOF
 		}
 		if l.ch == '\n' {
 			l.line++
 			l.lineStart = l.readPos
 		}
+		if l.ch == '/' && l.peekChar() == '*' {
+			l.readChar() // skip '/'
+			l.readChar() // skip '*'
+			depth++
+			continue
+		}
 		if l.ch == '*' && l.peekChar() == '/' {
 			l.readChar() // skip '*'
 			l.readChar() // skip '/'
-			return
+			depth--
+			if depth == 0 {
+				return true
+			}
+			continue
 		}
 		l.readChar()
 	}
 }
 
+// maxTokenLength bounds identifiers and numbers, in bytes. A longer one is
+// still consumed whole, so lexing resumes after it, but becomes ILLEGAL.
+const maxTokenLength = 1024
+
+// tooLong returns the ILLEGAL token for a kind of token, starting at
+// startCol, that ran past maxTokenLength.
+func (l *Lexer) tooLong(kind string, startCol int) Token {
+	return Token{
+		Type: ILLEGAL,
+		Literal: fmt.Sprintf("%s at line %d, column %d is longer than %d bytes",
+			kind, l.line, startCol, maxTokenLength),
+		Line:   l.line,
+		Column: startCol,
+	}
+}
+
 // readIdentifier reads an identifier or keyword.
 func (l *Lexer) readIdentifier() Token {
 	startCol := l.column
@@ -235,6 +345,9 @@ func (l *Lexer) readIdentifier() Token {
 	for isLetter(l.ch) || isDigit(l.ch) || l.ch == '_' {
 		l.readChar()
 	}
+	if l.pos-startPos > maxTokenLength {
+		return l.tooLong("identifier", startCol)
+	}
 
 	literal := l.input[startPos:l.pos]
 	tokenType := LookupIdent(literal)
@@ -284,6 +397,10 @@ func (l *Lexer) readNumber() Token {
 		}
 	}
 
+	if l.pos-startPos > maxTokenLength {
+		return l.tooLong("number", startCol)
+	}
+
 	literal := l.input[startPos:l.pos]
 	tokenType := INT
 	if isFloat {
@@ -298,15 +415,26 @@ func (l *Lexer) readNumber() Token {
 	}
 }
 
-// readString reads a string literal.
+// maxPartialString is how much of an unterminated string its error quotes.
+const maxPartialString = 20
+
+// readString reads a string literal in double or single quotes, the latter
+// for SQL-style literals inside expressions. A string must close on the line
+// it opens; otherwise the ILLEGAL token points at the opening quote and
+// quotes the start of the string. Escapes are \" \' \\ \n \r \t \0 \a \b \f
+// \v and \xHH; an \x without two hex digits makes the whole string ILLEGAL.
 func (l *Lexer) readString() Token {
+	startLine := l.line
 	startCol := l.column
 	var sb strings.Builder
+	var badEscape string // the first invalid escape, reported once the string ends
 
+	quote := l.ch
 	l.readChar() // skip opening quote
 
-	for l.ch != '"' && l.ch != 0 {
+	for l.ch != quote && l.ch != 0 && l.ch != '\n' {
 		if l.ch == '\\' {
+			escCol := l.column
 			l.readChar()
 			switch l.ch {
 			case '"':
@@ -319,40 +447,63 @@ func (l *Lexer) readString() Token {
 				sb.WriteRune('\r')
 			case 't':
 				sb.WriteRune('\t')
+			case '0':
+				sb.WriteRune(0)
+			case 'a':
+				sb.WriteRune('\a')
+			case 'b':
+				sb.WriteRune('\b')
+			case 'f':
+				sb.WriteRune('\f')
+			case 'v':
+				sb.WriteRune('\v')
 			case 'x':
-				// Hex escape: \xHH
-				l.readChar()
-				hex1 := l.ch
-				l.readChar()
-				hex2 := l.ch
-				val := hexValue(hex1)*16 + hexValue(hex2)
-				sb.WriteRune(rune(val))
+				// Hex escape: \xHH. Digits are only consumed while valid, so
+				// a bad escape cannot swallow the closing quote.
+				val, digits := 0, 0
+				for ; digits < 2 && isHexDigit(l.peekChar()); digits++ {
+					l.readChar()
+					val = val*16 + hexValue(l.ch)
+				}
+				if digits == 2 {
+					sb.WriteRune(rune(val))
+				} else if badEscape == "" {
+					badEscape = fmt.Sprintf("invalid escape at line %d, column %d: \\x needs two hex digits", l.line, escCol)
+				}
 			default:
 				sb.WriteRune(l.ch)
 			}
 		} else {
-			if l.ch == '\n' {
-				l.line++
-				l.lineStart = l.readPos
-			}
 			sb.WriteRune(l.ch)
 		}
 		l.readChar()
 	}
 
-	if l.ch != '"' {
+	if l.ch != quote {
+		partial := []rune(sb.String())
+		quoted := string(partial)
+		if len(partial) > maxPartialString {
+			quoted = string(partial[:maxPartialString]) + "..."
+		}
+		// The newline is left for skipWhitespaceAndComments to count
 		return Token{
-			Type:    ILLEGAL,
-			Literal: "unterminated string",
-			Line:    l.line,
-			Column:  startCol,
+			Type: ILLEGAL,
+			Literal: fmt.Sprintf("unterminated string starting at line %d, column %d: %c%s",
+				startLine, startCol, quote, quoted),
+			Line:   startLine,
+			Column: startCol,
 		}
 	}
+	l.readChar() // skip closing quote
+
+	if badEscape != "" {
+		return Token{Type: ILLEGAL, Literal: badEscape, Line: startLine, Column: startCol}
+	}
 
 	return Token{
 		Type:    STRING,
 		Literal: sb.String(),
-		Line:    l.line,
+		Line:    startLine,
 		Column:  startCol,
 	}
 }
@@ -374,6 +525,23 @@ func (l *Lexer) Tokenize() ([]Token, error) {
 	return tokens, nil
 }
 
+// TokenizeAll returns all tokens from the input like Tokenize, but sets
+// ILLEGAL tokens aside in illegals and keeps lexing after them, so every
+// lexical error in the input can be reported at once.
+func (l *Lexer) TokenizeAll() (tokens, illegals []Token) {
+	for {
+		tok := l.NextToken()
+		if tok.Type == ILLEGAL {
+			illegals = append(illegals, tok)
+			continue
+		}
+		tokens = append(tokens, tok)
+		if tok.Type == EOF {
+			return tokens, illegals
+		}
+	}
+}
+
 // Helper functions
 
 func isLetter(ch rune) bool {
@@ -384,6 +552,10 @@ func isDigit(ch rune) bool {
 	return ch >= '0' && ch <= '9'
 }
 
+func isHexDigit(ch rune) bool {
+	return ch >= '0' && ch <= '9' || ch >= 'a' && ch <= 'f' || ch >= 'A' && ch <= 'F'
+}
+
 func hexValue(ch rune) int {
 	if ch >= '0' && ch <= '9' {
 		return int(ch - '0')
@@ -1,6 +1,8 @@
 package lexer
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -183,6 +185,15 @@ func TestNumberLiterals(t *testing.T) {
 	}
 }
 
+func TestNegativeNumberFollowedByToken(t *testing.T) {
+	l := New("(-1)")
+	for _, want := range []TokenType{LPAREN, INT, RPAREN, EOF} {
+		if tok := l.NextToken(); tok.Type != want {
+			t.Fatalf("expected %q, got %q (%q)", want, tok.Type, tok.Literal)
+		}
+	}
+}
+
 func TestStringLiterals(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -193,6 +204,9 @@ func TestStringLiterals(t *testing.T) {
 		{`"with \"quotes\""`, `with "quotes"`},
 		{`"with\nnewline"`, "with\nnewline"},
 		{`"with\ttab"`, "with\ttab"},
+		{`"\x41\x62"`, "Ab"},
+		{`"nul\0byte"`, "nul\x00byte"},
+		{`"\a\b\f\v"`, "\a\b\f\v"},
 	}
 
 	for _, tt := range tests {
@@ -276,3 +290,248 @@ acos
 		t.Errorf("; - expected line 3, got %d", tok.Line)
 	}
 }
+
+func TestNestedBlockComment(t *testing.T) {
+	l := New("/* outer /* inner */ still comment */ entity")
+
+	tok := l.NextToken()
+	if tok.Type != ENTITY {
+		t.Fatalf("expected ENTITY after nested comment, got %q (%q)", tok.Type, tok.Literal)
+	}
+	if tok := l.NextToken(); tok.Type != EOF {
+		t.Errorf("expected EOF, got %q", tok.Type)
+	}
+}
+
+func TestUnterminatedBlockComment(t *testing.T) {
+	l := New("package acos;\n  /* never /* closed */\nentity")
+
+	for _, want := range []TokenType{PACKAGE, IDENT, SEMICOLON} {
+		if tok := l.NextToken(); tok.Type != want {
+			t.Fatalf("expected %q, got %q", want, tok.Type)
+		}
+	}
+
+	tok := l.NextToken()
+	if tok.Type != ILLEGAL || tok.Literal != "unterminated block comment" {
+		t.Fatalf("expected unterminated comment error, got %q (%q)", tok.Type, tok.Literal)
+	}
+	if tok.Line != 2 || tok.Column != 3 {
+		t.Errorf("expected error at the comment start 2:3, got %d:%d", tok.Line, tok.Column)
+	}
+	if tok := l.NextToken(); tok.Type != EOF {
+		t.Errorf("expected EOF after the error, got %q", tok.Type)
+	}
+}
+
+func TestSingleQuotedString(t *testing.T) {
+	l := New(`first || ' ' || 'it\'s "fine"' 'open`)
+
+	var got []Token
+	for tok := l.NextToken(); tok.Type != EOF; tok = l.NextToken() {
+		got = append(got, tok)
+	}
+	if len(got) != 6 {
+		t.Fatalf("expected 6 tokens, got %v", got)
+	}
+	if got[2].Type != STRING || got[2].Literal != " " {
+		t.Errorf("expected string ' ', got %q (%q)", got[2].Type, got[2].Literal)
+	}
+	if got[4].Type != STRING || got[4].Literal != `it's "fine"` {
+		t.Errorf("expected string with escaped quote, got %q (%q)", got[4].Type, got[4].Literal)
+	}
+	want := "unterminated string starting at line 1, column 32: 'open"
+	if got[5].Type != ILLEGAL || got[5].Literal != want {
+		t.Errorf("expected %q, got %q (%q)", want, got[5].Type, got[5].Literal)
+	}
+}
+
+func TestUnterminatedString(t *testing.T) {
+	l := New("@table(\"calendar_events\n)\nentity")
+
+	for _, want := range []TokenType{AT, IDENT, LPAREN} {
+		if tok := l.NextToken(); tok.Type != want {
+			t.Fatalf("expected %q, got %q", want, tok.Type)
+		}
+	}
+
+	tok := l.NextToken()
+	want := `unterminated string starting at line 1, column 8: "calendar_events`
+	if tok.Type != ILLEGAL || tok.Literal != want {
+		t.Fatalf("expected %q, got %q (%q)", want, tok.Type, tok.Literal)
+	}
+	if tok.Line != 1 || tok.Column != 8 {
+		t.Errorf("expected error at the opening quote 1:8, got %d:%d", tok.Line, tok.Column)
+	}
+
+	// Lexing resumes on the next line
+	tok = l.NextToken()
+	if tok.Type != RPAREN || tok.Line != 2 {
+		t.Errorf("expected ) on line 2, got %q on line %d", tok.Type, tok.Line)
+	}
+	if tok := l.NextToken(); tok.Type != ENTITY || tok.Line != 3 {
+		t.Errorf("expected entity on line 3, got %q on line %d", tok.Type, tok.Line)
+	}
+}
+
+func TestInvalidHexEscape(t *testing.T) {
+	l := New(`"ok" "a\xZZ" "\x4" entity`)
+
+	if tok := l.NextToken(); tok.Type != STRING {
+		t.Fatalf("expected STRING, got %q", tok.Type)
+	}
+
+	// The error names the escape; the token sits at the opening quote
+	for _, tt := range []struct{ quote, escape int }{{6, 8}, {14, 15}} {
+		tok := l.NextToken()
+		want := fmt.Sprintf("invalid escape at line 1, column %d: \\x needs two hex digits", tt.escape)
+		if tok.Type != ILLEGAL || tok.Literal != want {
+			t.Errorf("expected %q, got %q (%q)", want, tok.Type, tok.Literal)
+		}
+		if tok.Column != tt.quote {
+			t.Errorf("expected error at the opening quote column %d, got %d", tt.quote, tok.Column)
+		}
+	}
+
+	// The bad escape does not swallow the closing quote
+	if tok := l.NextToken(); tok.Type != ENTITY {
+		t.Errorf("expected entity after the strings, got %q", tok.Type)
+	}
+}
+
+func TestTokenizeAllCollectsIllegals(t *testing.T) {
+	l := New("entity Task {\n    a ! b;\n    c | d;\n}")
+
+	tokens, illegals := l.TokenizeAll()
+	if len(illegals) != 2 {
+		t.Fatalf("expected 2 illegal tokens, got %v", illegals)
+	}
+	for i, want := range []struct {
+		literal      string
+		line, column int
+	}{{"!", 2, 7}, {"|", 3, 7}} {
+		tok := illegals[i]
+		if tok.Literal != want.literal || tok.Line != want.line || tok.Column != want.column {
+			t.Errorf("illegal %d: expected %q at %d:%d, got %q at %d:%d",
+				i, want.literal, want.line, want.column, tok.Literal, tok.Line, tok.Column)
+		}
+	}
+
+	// Lexing carries on past both, and the illegals are left out of tokens
+	if len(tokens) != 11 || tokens[len(tokens)-1].Type != EOF {
+		t.Errorf("expected 11 tokens ending in EOF, got %v", tokens)
+	}
+}
+
+func TestTokenTooLong(t *testing.T) {
+	long := strings.Repeat("a", maxTokenLength+1)
+	l := New(long + " " + strings.Repeat("9", maxTokenLength+1) + " entity")
+
+	want := fmt.Sprintf("identifier at line 1, column 1 is longer than %d bytes", maxTokenLength)
+	if tok := l.NextToken(); tok.Type != ILLEGAL || tok.Literal != want {
+		t.Errorf("expected %q, got %q (%q)", want, tok.Type, tok.Literal)
+	}
+	want = fmt.Sprintf("number at line 1, column %d is longer than %d bytes", maxTokenLength+3, maxTokenLength)
+	if tok := l.NextToken(); tok.Type != ILLEGAL || tok.Literal != want {
+		t.Errorf("expected %q, got %q (%q)", want, tok.Type, tok.Literal)
+	}
+	if tok := l.NextToken(); tok.Type != ENTITY {
+		t.Errorf("expected entity after the long tokens, got %q", tok.Type)
+	}
+
+	// At the limit a token is still fine
+	if tok := New(long[1:]).NextToken(); tok.Type != IDENT {
+		t.Errorf("expected IDENT at the limit, got %q", tok.Type)
+	}
+}
+
+func TestUnterminatedStringAtEOF(t *testing.T) {
+	l := New(`"a very long string that never ends`)
+
+	tok := l.NextToken()
+	want := `unterminated string starting at line 1, column 1: "a very long string t...`
+	if tok.Type != ILLEGAL || tok.Literal != want {
+		t.Fatalf("expected %q, got %q (%q)", want, tok.Type, tok.Literal)
+	}
+	if tok := l.NextToken(); tok.Type != EOF {
+		t.Errorf("expected EOF, got %q", tok.Type)
+	}
+}
+
+func TestDocComments(t *testing.T) {
+	input := `// Package doc
+package test;
+
+// Detached by the blank line
+
+// First line
+//   indented second line
+id: string; // trailing, not doc
+/* block */
+name: string;
+`
+	l := New(input)
+	var toks []Token
+	for tok := l.NextToken(); tok.Type != EOF; tok = l.NextToken() {
+		toks = append(toks, tok)
+	}
+
+	tests := []struct {
+		literal string
+		doc     string
+	}{
+		{"package", "Package doc"},
+		{"id", "First line\n  indented second line"},
+		{"name", ""},
+	}
+	for _, tt := range tests {
+		for _, tok := range toks {
+			if tok.Literal != tt.literal {
+				continue
+			}
+			if got := l.Doc(tok.Line, tok.Column); got != tt.doc {
+				t.Errorf("Doc of %s = %q, want %q", tt.literal, got, tt.doc)
+			}
+		}
+	}
+	if semi := toks[2]; l.Doc(semi.Line, semi.Column) != "" {
+		t.Errorf("Expected no doc on %q", semi.Literal)
+	}
+}
+
+func TestLineText(t *testing.T) {
+	input := "entity A {\r\n\tid: int;\n}"
+	l := New(input)
+	tok := l.NextToken()
+	if got := l.LineText(tok.Line); got != "entity A {" {
+		t.Errorf("LineText(%d) = %q, want %q", tok.Line, got, "entity A {")
+	}
+
+	// Lines the lexer has not reached yet are indexed on demand.
+	tests := []struct {
+		line int
+		want string
+	}{
+		{0, ""},
+		{1, "entity A {"},
+		{2, "\tid: int;"},
+		{3, "}"},
+		{4, ""},
+	}
+	for _, tt := range tests {
+		if got := l.LineText(tt.line); got != tt.want {
+			t.Errorf("LineText(%d) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+
+	// Scanning past lines indexed on demand does not record them twice.
+	for tok.Type != EOF {
+		tok = l.NextToken()
+	}
+	if got := l.LineText(3); got != "}" {
+		t.Errorf("LineText(3) after scanning = %q, want %q", got, "}")
+	}
+	if got := New("a\n").LineText(2); got != "" {
+		t.Errorf("LineText(2) after a trailing newline = %q, want empty", got)
+	}
+}
@@ -48,13 +48,19 @@ const (
 	OPTION
 	ENUM
 	ENTITY
+	EXTENDS
+	MESSAGE
 	QUERY
 	SERVICE
 	RPC
 	RETURNS
 	STREAM
+	SELECT
+	DISTINCT
 	WHERE
 	ORDER_BY
+	GROUP_BY
+	HAVING
 	LIMIT
 
 	// SQL operators (keywords)
@@ -63,12 +69,21 @@ const (
 	NOT
 	IN
 	LIKE
+	ILIKE
 	IS
 	NULL
+	CASE
+	WHEN
+	THEN
+	ELSE
+	END
 
 	// Direction
 	ASC
 	DESC
+	NULLS
+	FIRST
+	LAST
 
 	// Types
 	TYPE_STRING
@@ -122,23 +137,38 @@ var tokenNames = map[TokenType]string{
 	OPTION:    "option",
 	ENUM:      "enum",
 	ENTITY:    "entity",
+	EXTENDS:   "extends",
+	MESSAGE:   "message",
 	QUERY:     "query",
 	SERVICE:   "service",
 	RPC:       "rpc",
 	RETURNS:   "returns",
 	STREAM:    "stream",
+	SELECT:    "select",
+	DISTINCT:  "distinct",
 	WHERE:     "where",
 	ORDER_BY:  "order_by",
+	GROUP_BY:  "group_by",
+	HAVING:    "having",
 	LIMIT:     "limit",
 	AND:       "AND",
 	OR:        "OR",
 	NOT:       "NOT",
 	IN:        "IN",
 	LIKE:      "LIKE",
+	ILIKE:     "ILIKE",
 	IS:        "IS",
 	NULL:      "NULL",
+	CASE:      "CASE",
+	WHEN:      "WHEN",
+	THEN:      "THEN",
+	ELSE:      "ELSE",
+	END:       "END",
 	ASC:       "ASC",
 	DESC:      "DESC",
+	NULLS:     "NULLS",
+	FIRST:     "FIRST",
+	LAST:      "LAST",
 	TYPE_STRING:    "string",
 	TYPE_INT32:     "int32",
 	TYPE_INT64:     "int64",
@@ -165,23 +195,38 @@ var keywords = map[string]TokenType{
 	"option":    OPTION,
 	"enum":      ENUM,
 	"entity":    ENTITY,
+	"extends":   EXTENDS,
+	"message":   MESSAGE,
 	"query":     QUERY,
 	"service":   SERVICE,
 	"rpc":       RPC,
 	"returns":   RETURNS,
 	"stream":    STREAM,
+	"select":    SELECT,
+	"distinct":  DISTINCT,
 	"where":     WHERE,
 	"order_by":  ORDER_BY,
+	"group_by":  GROUP_BY,
+	"having":    HAVING,
 	"limit":     LIMIT,
 	"AND":       AND,
 	"OR":        OR,
 	"NOT":       NOT,
 	"IN":        IN,
 	"LIKE":      LIKE,
+	"ILIKE":     ILIKE,
 	"IS":        IS,
 	"NULL":      NULL,
+	"CASE":      CASE,
+	"WHEN":      WHEN,
+	"THEN":      THEN,
+	"ELSE":      ELSE,
+	"END":       END,
 	"ASC":       ASC,
 	"DESC":      DESC,
+	"NULLS":     NULLS,
+	"FIRST":     FIRST,
+	"LAST":      LAST,
 	"string":    TYPE_STRING,
 	"int32":     TYPE_INT32,
 	"int64":     TYPE_INT64,
@@ -0,0 +1,84 @@
+// Package naming holds the identifier conventions shared by the checker and
+// the code generators: case conversion and the SQL keywords that cannot be
+// used as unquoted names.
+package naming
+
+import (
+	"strings"
+	"unicode"
+)
+
+// ToPascalCase converts a string to PascalCase.
+func ToPascalCase(s string) string {
+	words := SplitWords(s)
+	for i, word := range words {
+		if len(word) > 0 {
+			words[i] = strings.ToUpper(string(word[0])) + strings.ToLower(word[1:])
+		}
+	}
+	return strings.Join(words, "")
+}
+
+// ToCamelCase converts a string to camelCase.
+func ToCamelCase(s string) string {
+	pascal := ToPascalCase(s)
+	if len(pascal) == 0 {
+		return pascal
+	}
+	return strings.ToLower(string(pascal[0])) + pascal[1:]
+}
+
+// ToSnakeCase converts a string to snake_case.
+func ToSnakeCase(s string) string {
+	var result strings.Builder
+	for i, r := range s {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				result.WriteRune('_')
+			}
+			result.WriteRune(unicode.ToLower(r))
+		} else {
+			result.WriteRune(r)
+		}
+	}
+	return result.String()
+}
+
+// ToScreamingSnakeCase converts a string to SCREAMING_SNAKE_CASE.
+func ToScreamingSnakeCase(s string) string {
+	return strings.ToUpper(ToSnakeCase(s))
+}
+
+// SplitWords splits a string into words based on underscores and case changes.
+func SplitWords(s string) []string {
+	var words []string
+	var current strings.Builder
+
+	for i, r := range s {
+		if r == '_' {
+			if current.Len() > 0 {
+				words = append(words, current.String())
+				current.Reset()
+			}
+			continue
+		}
+
+		if unicode.IsUpper(r) && i > 0 {
+			prev := rune(s[i-1])
+			if !unicode.IsUpper(prev) && prev != '_' {
+				if current.Len() > 0 {
+					words = append(words, current.String())
+					current.Reset()
+				}
+			}
+		}
+
+		current.WriteRune(r)
+	}
+
+	if current.Len() > 0 {
+		words = append(words, current.String())
+	}
+
+	return words
+}
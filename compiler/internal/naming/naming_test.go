@@ -0,0 +1,42 @@
+package naming
+
+import "testing"
+
+func TestCaseConversions(t *testing.T) {
+	tests := []struct {
+		input                           string
+		pascal, camel, snake, screaming string
+	}{
+		{"dueDate", "DueDate", "dueDate", "due_date", "DUE_DATE"},
+		{"created_at", "CreatedAt", "createdAt", "created_at", "CREATED_AT"},
+		{"ID", "Id", "id", "i_d", "I_D"},
+		{"", "", "", "", ""},
+	}
+
+	for _, tt := range tests {
+		if got := ToPascalCase(tt.input); got != tt.pascal {
+			t.Errorf("ToPascalCase(%q) = %q, want %q", tt.input, got, tt.pascal)
+		}
+		if got := ToCamelCase(tt.input); got != tt.camel {
+			t.Errorf("ToCamelCase(%q) = %q, want %q", tt.input, got, tt.camel)
+		}
+		if got := ToSnakeCase(tt.input); got != tt.snake {
+			t.Errorf("ToSnakeCase(%q) = %q, want %q", tt.input, got, tt.snake)
+		}
+		if got := ToScreamingSnakeCase(tt.input); got != tt.screaming {
+			t.Errorf("ToScreamingSnakeCase(%q) = %q, want %q", tt.input, got, tt.screaming)
+		}
+	}
+}
+
+func TestIsReservedSQLKeyword(t *testing.T) {
+	if !IsReservedSQLKeyword("ORDER", "sqlite") || !IsReservedSQLKeyword("order", "postgres") {
+		t.Error("Expected order to be reserved in both dialects")
+	}
+	if IsReservedSQLKeyword("user", "sqlite") || !IsReservedSQLKeyword("user", "postgres") {
+		t.Error("Expected user to be reserved only in Postgres")
+	}
+	if IsReservedSQLKeyword("order", "mysql") {
+		t.Error("Expected no keywords for an unknown dialect")
+	}
+}
@@ -0,0 +1,51 @@
+package naming
+
+import "strings"
+
+// sqlKeywords holds, per dialect name, the keywords that cannot be used as
+// unquoted table or column names. SQLite accepts most of its keywords as
+// identifiers, so only those its parser never falls back on are listed.
+var sqlKeywords = map[string]map[string]bool{
+	"sqlite": keywordSet(
+		"add", "all", "alter", "and", "as", "autoincrement", "between", "case",
+		"check", "collate", "commit", "constraint", "create", "default",
+		"deferrable", "delete", "distinct", "drop", "else", "escape", "except",
+		"exists", "foreign", "from", "group", "having", "in", "index", "insert",
+		"intersect", "into", "is", "isnull", "join", "limit", "not", "nothing",
+		"notnull", "null", "on", "or", "order", "primary", "references",
+		"returning", "select", "set", "table", "then", "to", "transaction",
+		"union", "unique", "update", "using", "values", "when", "where", "window",
+	),
+	"postgres": keywordSet(
+		"all", "analyse", "analyze", "and", "any", "array", "as", "asc",
+		"asymmetric", "authorization", "binary", "both", "case", "cast", "check",
+		"collate", "collation", "column", "concurrently", "constraint", "create",
+		"cross", "current_catalog", "current_date", "current_role",
+		"current_schema", "current_time", "current_timestamp", "current_user",
+		"default", "deferrable", "desc", "distinct", "do", "else", "end",
+		"except", "false", "fetch", "for", "foreign", "freeze", "from", "full",
+		"grant", "group", "having", "ilike", "in", "initially", "inner",
+		"intersect", "into", "is", "isnull", "join", "lateral", "leading",
+		"left", "like", "limit", "localtime", "localtimestamp", "natural", "not",
+		"notnull", "null", "offset", "on", "only", "or", "order", "outer",
+		"overlaps", "placing", "primary", "references", "returning", "right",
+		"select", "session_user", "similar", "some", "symmetric", "table",
+		"tablesample", "then", "to", "trailing", "true", "union", "unique",
+		"user", "using", "variadic", "verbose", "when", "where", "window", "with",
+	),
+}
+
+func keywordSet(words ...string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		set[w] = true
+	}
+	return set
+}
+
+// IsReservedSQLKeyword reports whether name must be quoted to be used as a
+// table or column name in the named dialect, "sqlite" or "postgres". The
+// comparison ignores case.
+func IsReservedSQLKeyword(name, dialect string) bool {
+	return sqlKeywords[dialect][strings.ToLower(name)]
+}
@@ -1,7 +1,12 @@
 // Package parser provides parsing for DataProto schema files.
 package parser
 
-import "github.com/aurora/dataproto/internal/lexer"
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aurora/dataproto/internal/lexer"
+)
 
 // Node is the base interface for all AST nodes.
 type Node interface {
@@ -17,6 +22,7 @@ type File struct {
 	Options    []*OptionDecl
 	Enums      []*EnumDecl
 	Entities   []*EntityDecl
+	Messages   []*MessageDecl
 	Services   []*ServiceDecl
 }
 
@@ -43,9 +49,24 @@ func (i *ImportDecl) Pos() lexer.Position { return i.Position }
 
 // OptionDecl represents a file-level option.
 type OptionDecl struct {
-	Position lexer.Position
-	Name     string
-	Value    interface{} // string, int, float, bool, or identifier
+	Position  lexer.Position
+	Name      string           // full name as written, e.g. (custom.opt).field
+	NameParts []OptionNamePart // Name split at the dots outside parentheses
+	Value     interface{}      // string, int, float, bool, identifier or []interface{}
+	Ident     bool             // Value is a bare identifier such as SPEED
+}
+
+// OptionNamePart is one component of an option name. An extension part is
+// written in parentheses and names a custom option, as in (custom.opt).field.
+type OptionNamePart struct {
+	Name      string
+	Extension bool
+}
+
+// IsExtension reports whether the option sets a custom (extension) option
+// rather than a built-in one.
+func (o *OptionDecl) IsExtension() bool {
+	return len(o.NameParts) > 0 && o.NameParts[0].Extension
 }
 
 func (o *OptionDecl) node() {}
@@ -55,7 +76,9 @@ func (o *OptionDecl) Pos() lexer.Position { return o.Position }
 type EnumDecl struct {
 	Position lexer.Position
 	Name     string
+	Options  []*OptionDecl
 	Values   []*EnumValue
+	Inline   bool // declared by a field type rather than at top level
 }
 
 func (e *EnumDecl) node() {}
@@ -63,26 +86,84 @@ func (e *EnumDecl) Pos() lexer.Position { return e.Position }
 
 // EnumValue represents a single enum value.
 type EnumValue struct {
-	Position lexer.Position
-	Name     string
-	Number   int
+	Position    lexer.Position
+	Annotations []*Annotation
+	Name        string
+	Number      int
 }
 
 func (e *EnumValue) node() {}
 func (e *EnumValue) Pos() lexer.Position { return e.Position }
 
+// GetAnnotation returns the first annotation with the given name, or nil.
+func (e *EnumValue) GetAnnotation(name string) *Annotation {
+	for _, a := range e.Annotations {
+		if a.Name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+// Deprecated returns the message of a @deprecated annotation, and whether
+// the value is deprecated.
+func (e *EnumValue) Deprecated() (string, bool) {
+	return deprecation(e.GetAnnotation("deprecated"))
+}
+
 // EntityDecl represents an entity declaration (maps to table + proto message).
 type EntityDecl struct {
 	Position    lexer.Position
 	Annotations []*Annotation
 	Name        string
+	Extends     string // base entity whose fields come first, if any
 	Fields      []*FieldDecl
 	Queries     []*QueryDecl
+	Reserved    []*ReservedDecl
 }
 
 func (e *EntityDecl) node() {}
 func (e *EntityDecl) Pos() lexer.Position { return e.Position }
 
+// MessageDecl represents a standalone message type, such as an rpc request.
+// Unlike an entity it is never stored, so it has no queries and its fields
+// take no storage annotations.
+type MessageDecl struct {
+	Position    lexer.Position
+	Annotations []*Annotation
+	Name        string
+	Fields      []*FieldDecl
+	Reserved    []*ReservedDecl
+}
+
+func (m *MessageDecl) node() {}
+func (m *MessageDecl) Pos() lexer.Position { return m.Position }
+
+// ReservedMax is the field number "max" stands for in a reserved range.
+const ReservedMax = 1<<29 - 1
+
+// ReservedDecl represents: reserved 3, 5 to 9; or reserved "old_name";
+type ReservedDecl struct {
+	Position lexer.Position
+	Ranges   []ReservedRange
+	Names    []string
+}
+
+func (r *ReservedDecl) node() {}
+func (r *ReservedDecl) Pos() lexer.Position { return r.Position }
+
+// ReservedRange is an inclusive range of field numbers. A single reserved
+// number has Start == End.
+type ReservedRange struct {
+	Start int64
+	End   int64
+}
+
+// Contains reports whether n lies in the range.
+func (r ReservedRange) Contains(n int64) bool {
+	return n >= r.Start && n <= r.End
+}
+
 // Annotation represents an annotation like @table("name").
 type Annotation struct {
 	Position lexer.Position
@@ -97,7 +178,7 @@ func (a *Annotation) Pos() lexer.Position { return a.Position }
 type AnnotationArg struct {
 	Position lexer.Position
 	Name     string      // optional, for named args like max: 100
-	Value    interface{} // string, int, float, bool, identifier, or []interface{}
+	Value    interface{} // string, int, float, bool, identifier, []interface{}, or map[string]interface{}
 }
 
 func (a *AnnotationArg) node() {}
@@ -109,6 +190,11 @@ type FieldDecl struct {
 	Annotations []*Annotation
 	Name        string
 	Type        *TypeRef
+	Doc         string // comment lines directly above the field, if any
+
+	// Inherited names the entity that declared the field when it was
+	// copied from a base entity; it is empty for the entity's own fields
+	Inherited string
 }
 
 func (f *FieldDecl) node() {}
@@ -119,6 +205,10 @@ type TypeRef struct {
 	Position lexer.Position
 	Name     string // base type name (string, int32, etc. or custom type)
 	Optional bool   // true if followed by ?
+
+	// Enum is the enum declared inline by this type, if any. Name is then
+	// the enum's synthesized <Entity><Field> name.
+	Enum *EnumDecl
 }
 
 func (t *TypeRef) node() {}
@@ -126,12 +216,17 @@ func (t *TypeRef) Pos() lexer.Position { return t.Position }
 
 // QueryDecl represents a named query within an entity.
 type QueryDecl struct {
-	Position lexer.Position
-	Name     string
-	Params   []*QueryParam
-	Where    Expr
-	OrderBy  []*OrderByField
-	Limit    Expr // can be nil, int literal, or parameter reference
+	Position    lexer.Position
+	Annotations []*Annotation
+	Name        string
+	Params      []*QueryParam
+	Select      []string // selected fields or aggregates like COUNT(id); empty selects all
+	Distinct    bool     // select distinct: drop duplicate rows of the select list
+	Where       Expr
+	GroupBy     []string
+	Having      Expr // filter on groups; may use aggregates like COUNT(id)
+	OrderBy     []*OrderByField
+	Limit       Expr // can be nil, int literal, or parameter reference
 }
 
 func (q *QueryDecl) node() {}
@@ -139,28 +234,86 @@ func (q *QueryDecl) Pos() lexer.Position { return q.Position }
 
 // QueryParam represents a parameter to a query.
 type QueryParam struct {
-	Position lexer.Position
-	Name     string
-	Type     *TypeRef
-	Default  interface{} // optional default value
+	Position    lexer.Position
+	Annotations []*Annotation
+	Name        string
+	Type        *TypeRef
+	Default     interface{} // optional default value
+	DefaultExpr Expr        // default computed when the caller passes null, such as NOW(); nil for a literal Default
+	Optional    bool        // true if the name is followed by ?; callers may pass null
 }
 
 func (q *QueryParam) node() {}
 func (q *QueryParam) Pos() lexer.Position { return q.Position }
 
+// Nullable reports whether callers may pass null for the parameter: it is
+// marked optional, by name or by type.
+func (q *QueryParam) Nullable() bool {
+	return q.Optional || (q.Type != nil && q.Type.Optional)
+}
+
+// HasDefault reports whether the parameter has a default, either a literal
+// or an expression.
+func (q *QueryParam) HasDefault() bool {
+	return q.Default != nil || q.DefaultExpr != nil
+}
+
+// GetAnnotation returns the first annotation with the given name, or nil.
+func (q *QueryParam) GetAnnotation(name string) *Annotation {
+	for _, a := range q.Annotations {
+		if a.Name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+// MaxValue returns the cap from a @max(n) annotation on an integer
+// parameter, such as a page size used as the query's limit.
+func (q *QueryParam) MaxValue() (int64, bool) {
+	if a := q.GetAnnotation("max"); a != nil && len(a.Args) > 0 {
+		n, ok := a.Args[0].Value.(int64)
+		return n, ok
+	}
+	return 0, false
+}
+
 // OrderByField represents a field in ORDER BY clause.
 type OrderByField struct {
 	Position   lexer.Position
 	Field      string
 	Descending bool
+	Nulls      NullsOrder
 }
 
 func (o *OrderByField) node() {}
 func (o *OrderByField) Pos() lexer.Position { return o.Position }
 
+// NullsOrder is where NULLs sort in an ORDER BY field.
+type NullsOrder int
+
+const (
+	// NullsDefault leaves NULL placement to the database.
+	NullsDefault NullsOrder = iota
+	NullsFirst
+	NullsLast
+)
+
+func (n NullsOrder) String() string {
+	switch n {
+	case NullsFirst:
+		return "NULLS FIRST"
+	case NullsLast:
+		return "NULLS LAST"
+	default:
+		return ""
+	}
+}
+
 // Expr is the interface for all expression types.
 type Expr interface {
 	Node
+	fmt.Stringer
 	expr()
 }
 
@@ -168,7 +321,7 @@ type Expr interface {
 type BinaryExpr struct {
 	Position lexer.Position
 	Left     Expr
-	Op       string // AND, OR, =, !=, <, <=, >, >=, LIKE, IN, +, -, *, /, %, ||
+	Op       string // AND, OR, =, !=, <, <=, >, >=, LIKE, ILIKE, IN, +, -, *, /, %, ||
 	Right    Expr
 }
 
@@ -208,6 +361,35 @@ func (i *IdentExpr) node() {}
 func (i *IdentExpr) expr() {}
 func (i *IdentExpr) Pos() lexer.Position { return i.Position }
 
+// FieldAccessExpr represents a dotted reference to a field of Base, such as
+// calendar.name; longer paths nest, so event.calendar.name has the access
+// event.calendar as its base.
+type FieldAccessExpr struct {
+	Position lexer.Position
+	Base     Expr
+	Field    string
+}
+
+func (f *FieldAccessExpr) node() {}
+func (f *FieldAccessExpr) expr() {}
+func (f *FieldAccessExpr) Pos() lexer.Position { return f.Position }
+
+// Path returns the identifier the access starts from and the field names
+// below it, in order. root is nil when the base is not an identifier.
+func (f *FieldAccessExpr) Path() (root *IdentExpr, path []string) {
+	var expr Expr = f
+	for {
+		access, ok := expr.(*FieldAccessExpr)
+		if !ok {
+			break
+		}
+		path = append([]string{access.Field}, path...)
+		expr = access.Base
+	}
+	root, _ = expr.(*IdentExpr)
+	return root, path
+}
+
 // LiteralExpr represents a literal value.
 type LiteralExpr struct {
 	Position lexer.Position
@@ -239,6 +421,38 @@ func (p *ParenExpr) node() {}
 func (p *ParenExpr) expr() {}
 func (p *ParenExpr) Pos() lexer.Position { return p.Position }
 
+// TupleExpr represents a parenthesized list of two or more expressions, a
+// row value as in where (start_date, id) > (after_date, after_id).
+type TupleExpr struct {
+	Position lexer.Position
+	Elements []Expr
+}
+
+func (t *TupleExpr) node() {}
+func (t *TupleExpr) expr() {}
+func (t *TupleExpr) Pos() lexer.Position { return t.Position }
+
+// CaseExpr represents CASE WHEN cond THEN result ... [ELSE result] END.
+type CaseExpr struct {
+	Position lexer.Position
+	Whens    []*CaseWhen
+	Else     Expr // nil without an ELSE branch
+}
+
+func (c *CaseExpr) node() {}
+func (c *CaseExpr) expr() {}
+func (c *CaseExpr) Pos() lexer.Position { return c.Position }
+
+// CaseWhen is a single WHEN cond THEN result branch of a CaseExpr.
+type CaseWhen struct {
+	Position lexer.Position
+	Cond     Expr
+	Result   Expr
+}
+
+func (w *CaseWhen) node() {}
+func (w *CaseWhen) Pos() lexer.Position { return w.Position }
+
 // ServiceDecl represents a gRPC service declaration.
 type ServiceDecl struct {
 	Position lexer.Position
@@ -252,6 +466,7 @@ func (s *ServiceDecl) Pos() lexer.Position { return s.Position }
 // RpcDecl represents an RPC method declaration.
 type RpcDecl struct {
 	Position       lexer.Position
+	Annotations    []*Annotation
 	Name           string
 	RequestType    *RpcType
 	ResponseType   *RpcType
@@ -260,6 +475,62 @@ type RpcDecl struct {
 func (r *RpcDecl) node() {}
 func (r *RpcDecl) Pos() lexer.Position { return r.Position }
 
+// GetAnnotation returns the first annotation with the given name, or nil.
+func (r *RpcDecl) GetAnnotation(name string) *Annotation {
+	for _, a := range r.Annotations {
+		if a.Name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+// Deprecated returns the message of a @deprecated annotation, and whether
+// the rpc is deprecated.
+func (r *RpcDecl) Deprecated() (string, bool) {
+	return deprecation(r.GetAnnotation("deprecated"))
+}
+
+// StreamingKind classifies an RPC by which of its sides stream.
+type StreamingKind int
+
+const (
+	Unary StreamingKind = iota
+	ServerStreaming
+	ClientStreaming
+	BidiStreaming
+)
+
+func (k StreamingKind) String() string {
+	switch k {
+	case ServerStreaming:
+		return "server-streaming"
+	case ClientStreaming:
+		return "client-streaming"
+	case BidiStreaming:
+		return "bidirectional-streaming"
+	default:
+		return "unary"
+	}
+}
+
+// StreamingKind returns whether the RPC is unary, server-streaming,
+// client-streaming or bidirectional.
+func (r *RpcDecl) StreamingKind() StreamingKind {
+	clientStream := r.RequestType != nil && r.RequestType.Stream
+	serverStream := r.ResponseType != nil && r.ResponseType.Stream
+	switch {
+	case clientStream && serverStream:
+		return BidiStreaming
+	case clientStream:
+		return ClientStreaming
+	case serverStream:
+		return ServerStreaming
+	default:
+		return Unary
+	}
+}
+
 // RpcType represents a request or response type in an RPC.
 type RpcType struct {
 	Position lexer.Position
@@ -292,6 +563,31 @@ func (f *FieldDecl) GetAnnotation(name string) *Annotation {
 	return nil
 }
 
+// Deprecated returns the message of a @deprecated annotation, and whether
+// the field is deprecated.
+func (f *FieldDecl) Deprecated() (string, bool) {
+	return deprecation(f.GetAnnotation("deprecated"))
+}
+
+// Deprecated returns the message of a @deprecated annotation, and whether
+// the entity is deprecated.
+func (e *EntityDecl) Deprecated() (string, bool) {
+	return deprecation(e.GetAnnotation("deprecated"))
+}
+
+// deprecation reads a @deprecated or @deprecated("message") annotation.
+func deprecation(a *Annotation) (string, bool) {
+	if a == nil {
+		return "", false
+	}
+	if len(a.Args) > 0 {
+		if s, ok := a.Args[0].Value.(string); ok {
+			return s, true
+		}
+	}
+	return "", true
+}
+
 // HasAnnotation returns true if the field has the given annotation.
 func (f *FieldDecl) HasAnnotation(name string) bool {
 	return f.GetAnnotation(name) != nil
@@ -302,6 +598,11 @@ func (f *FieldDecl) IsPrimaryKey() bool {
 	return f.HasAnnotation("pk")
 }
 
+// IsAutoIncrement returns true if the field has the @autoincrement annotation.
+func (f *FieldDecl) IsAutoIncrement() bool {
+	return f.HasAnnotation("autoincrement")
+}
+
 // IsRequired returns true if the field has the @required annotation.
 func (f *FieldDecl) IsRequired() bool {
 	return f.HasAnnotation("required")
@@ -317,9 +618,105 @@ func (f *FieldDecl) IsUnique() bool {
 	return f.HasAnnotation("unique")
 }
 
-// TableName returns the SQL table name from @table annotation, or empty string.
-func (e *EntityDecl) TableName() string {
-	if a := e.GetAnnotation("table"); a != nil && len(a.Args) > 0 {
+// Generated returns the SQL expression of a @generated("expr") column and
+// whether it is stored (the default) rather than virtual.
+func (f *FieldDecl) Generated() (expr string, stored bool, ok bool) {
+	a := f.GetAnnotation("generated")
+	if a == nil || len(a.Args) == 0 {
+		return "", false, false
+	}
+	expr, ok = a.Args[0].Value.(string)
+	stored = true
+	if v, isBool := a.NamedArg("stored").(bool); isBool {
+		stored = v
+	}
+	return expr, stored, ok
+}
+
+// Computed returns the expression of a @computed("first || ' ' || last")
+// annotation, both as written and parsed. expr is nil when src does not
+// parse, and ok is false when the annotation is absent or its argument is
+// not a string.
+func (f *FieldDecl) Computed() (src string, expr Expr, ok bool) {
+	a := f.GetAnnotation("computed")
+	if a == nil || len(a.Args) == 0 {
+		return "", nil, false
+	}
+	if src, ok = a.Args[0].Value.(string); !ok {
+		return "", nil, false
+	}
+	expr, _ = ParseExpr(src)
+	return src, expr, true
+}
+
+// Range returns the bounds of a @range(min, max) annotation, given either
+// positionally or as min:/max: named arguments. ok is false when the
+// annotation is absent or either bound is not a number.
+func (f *FieldDecl) Range() (min, max float64, ok bool) {
+	a := f.GetAnnotation("range")
+	if a == nil {
+		return 0, 0, false
+	}
+	min, minOK := number(bound(a, "min", 0))
+	max, maxOK := number(bound(a, "max", 1))
+	return min, max, minOK && maxOK
+}
+
+// NumericBounds returns the lower and upper bounds declared by @range, @min
+// and @max, merged into one constraint. @min and @max take precedence over
+// @range, though the checker rejects one that disagrees with it. A nil bound
+// means the field is unconstrained on that side.
+func (f *FieldDecl) NumericBounds() (min, max *float64) {
+	if lo, hi, ok := f.Range(); ok {
+		min, max = &lo, &hi
+	}
+	if a := f.GetAnnotation("min"); a != nil && len(a.Args) > 0 {
+		if v, ok := number(a.Args[0].Value); ok {
+			min = &v
+		}
+	}
+	if a := f.GetAnnotation("max"); a != nil && len(a.Args) > 0 {
+		if v, ok := number(a.Args[0].Value); ok {
+			max = &v
+		}
+	}
+	return min, max
+}
+
+// LengthConstraint holds the bounds of a @length annotation. A nil bound
+// means the field is unconstrained on that side.
+type LengthConstraint struct {
+	Min *int64
+	Max *int64
+}
+
+// LengthConstraint returns the bounds of the field's @length annotation and
+// whether it has one. A single positional argument is the maximum, as in
+// @length(5); two are min then max. Named min: and max: arguments may be
+// given alone or together. Bounds that are not integers are left nil.
+func (f *FieldDecl) LengthConstraint() (LengthConstraint, bool) {
+	a := f.GetAnnotation("length")
+	if a == nil {
+		return LengthConstraint{}, false
+	}
+	minIndex, maxIndex := 0, 1
+	if len(a.Args) == 1 {
+		minIndex, maxIndex = -1, 0
+	}
+
+	var lc LengthConstraint
+	if v, ok := bound(a, "min", minIndex).(int64); ok {
+		lc.Min = &v
+	}
+	if v, ok := bound(a, "max", maxIndex).(int64); ok {
+		lc.Max = &v
+	}
+	return lc, true
+}
+
+// Format returns the name of a @format("email") annotation, or empty string.
+func (f *FieldDecl) Format() string {
+	if a := f.GetAnnotation("format"); a != nil && len(a.Args) > 0 {
 		if s, ok := a.Args[0].Value.(string); ok {
 			return s
 		}
@@ -327,6 +724,138 @@ func (e *EntityDecl) TableName() string {
 	return ""
 }
 
+// RenamedFrom returns the previous name given with @renamed_from("old"),
+// which lets a migration rename the column instead of replacing it, or empty
+// string.
+func (f *FieldDecl) RenamedFrom() string {
+	if a := f.GetAnnotation("renamed_from"); a != nil && len(a.Args) > 0 {
+		if s, ok := a.Args[0].Value.(string); ok {
+			return s
+		}
+	}
+	return ""
+}
+
+// ColumnTypes returns the per-backend column types set with
+// @column(postgres: "NUMERIC(10,2)", ...), keyed by backend, or nil.
+func (f *FieldDecl) ColumnTypes() map[string]string {
+	a := f.GetAnnotation("column")
+	if a == nil {
+		return nil
+	}
+	types := make(map[string]string)
+	for _, arg := range a.Args {
+		if s, ok := arg.Value.(string); ok && arg.Name != "" && s != "" {
+			types[arg.Name] = s
+		}
+	}
+	return types
+}
+
+// ColumnType returns the column type @column sets for backend, or empty
+// string to use the default mapping.
+func (f *FieldDecl) ColumnType(backend string) string {
+	return f.ColumnTypes()[backend]
+}
+
+// JSONName returns the wire name set with @json, or empty string when the
+// field uses its derived name.
+func (f *FieldDecl) JSONName() string {
+	if a := f.GetAnnotation("json"); a != nil && len(a.Args) > 0 {
+		if s, ok := a.Args[0].Value.(string); ok {
+			return s
+		}
+	}
+	return ""
+}
+
+// Pattern returns the regex of a @pattern annotation, or empty string.
+func (f *FieldDecl) Pattern() string {
+	if a := f.GetAnnotation("pattern"); a != nil && len(a.Args) > 0 {
+		if s, ok := a.Args[0].Value.(string); ok {
+			return s
+		}
+	}
+	return ""
+}
+
+// bound returns the named argument if present, else the positional one. A
+// negative index means the bound has no positional form.
+func bound(a *Annotation, name string, index int) interface{} {
+	if v := a.NamedArg(name); v != nil {
+		return v
+	}
+	if index >= 0 && index < len(a.Args) && a.Args[index].Name == "" {
+		return a.Args[index].Value
+	}
+	return nil
+}
+
+// number converts an int or float literal value to float64.
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// ProtoNumber returns the explicit proto field number from
+// @proto(number: N), if any.
+func (f *FieldDecl) ProtoNumber() (int64, bool) {
+	if a := f.GetAnnotation("proto"); a != nil {
+		n, ok := a.NamedArg("number").(int64)
+		return n, ok
+	}
+	return 0, false
+}
+
+// PrimaryKeyFields returns all fields marked @pk, in declaration order.
+// More than one field means the entity has a composite primary key.
+func (e *EntityDecl) PrimaryKeyFields() []*FieldDecl {
+	var pks []*FieldDecl
+	for _, f := range e.Fields {
+		if f.IsPrimaryKey() {
+			pks = append(pks, f)
+		}
+	}
+	return pks
+}
+
+// TableName returns the SQL table name from @table("name") or
+// @table(name: "name"), or empty string. The positional name may come after
+// named arguments, as in @table(schema: "app", "events"). The name is
+// unqualified; see SchemaName.
+func (e *EntityDecl) TableName() string {
+	a := e.GetAnnotation("table")
+	if a == nil {
+		return ""
+	}
+	if s, ok := a.NamedArg("name").(string); ok {
+		return s
+	}
+	for _, arg := range a.Args {
+		if arg.Name == "" {
+			s, _ := arg.Value.(string)
+			return s
+		}
+	}
+	return ""
+}
+
+// SchemaName returns the schema of the entity's table from
+// @table(schema: "app", name: "..."), or empty string.
+func (e *EntityDecl) SchemaName() string {
+	if a := e.GetAnnotation("table"); a != nil {
+		if s, ok := a.NamedArg("schema").(string); ok {
+			return s
+		}
+	}
+	return ""
+}
+
 // Backends returns the list of backends from @backends annotation.
 func (e *EntityDecl) Backends() []string {
 	if a := e.GetAnnotation("backends"); a != nil {
@@ -340,3 +869,449 @@ func (e *EntityDecl) Backends() []string {
 	}
 	return nil
 }
+
+// SoftDeleteAnnotation returns the entity's @soft_delete annotation, or its
+// alias @softdelete, or nil.
+func (e *EntityDecl) SoftDeleteAnnotation() *Annotation {
+	if a := e.GetAnnotation("soft_delete"); a != nil {
+		return a
+	}
+	return e.GetAnnotation("softdelete")
+}
+
+// SoftDeleteField returns the name of the timestamp field marking rows as
+// deleted, from @soft_delete, @soft_delete("field") or
+// @soft_delete(field: "field"). The default field is deleted_at. It returns
+// empty string when the entity has no @soft_delete.
+func (e *EntityDecl) SoftDeleteField() string {
+	a := e.SoftDeleteAnnotation()
+	if a == nil {
+		return ""
+	}
+	if s, ok := a.NamedArg("field").(string); ok {
+		return s
+	}
+	if len(a.Args) > 0 && a.Args[0].Name == "" {
+		if s, ok := a.Args[0].Value.(string); ok {
+			return s
+		}
+	}
+	return "deleted_at"
+}
+
+// IsReservedNumber reports whether a reserved statement covers field number n.
+func (e *EntityDecl) IsReservedNumber(n int64) bool {
+	for _, decl := range e.Reserved {
+		for _, r := range decl.Ranges {
+			if r.Contains(n) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsReservedName reports whether a reserved statement lists the field name.
+func (e *EntityDecl) IsReservedName(name string) bool {
+	for _, decl := range e.Reserved {
+		for _, n := range decl.Names {
+			if n == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// AllowAlias reports whether the enum sets option allow_alias = true, which
+// lets several values share a number.
+func (e *EnumDecl) AllowAlias() bool {
+	for _, opt := range e.Options {
+		if opt.Name == "allow_alias" {
+			v, _ := opt.Value.(bool)
+			return v
+		}
+	}
+	return false
+}
+
+// AliasOf returns the earlier value that val shares its number with, or nil
+// when val is the first value with that number.
+func (e *EnumDecl) AliasOf(val *EnumValue) *EnumValue {
+	for _, other := range e.Values {
+		if other == val {
+			return nil
+		}
+		if other.Number == val.Number {
+			return other
+		}
+	}
+	return nil
+}
+
+// Entity returns the entity with the given name, or nil.
+func (f *File) Entity(name string) *EntityDecl {
+	for _, e := range f.Entities {
+		if e.Name == name {
+			return e
+		}
+	}
+	return nil
+}
+
+// Message returns the message with the given name, or nil.
+func (f *File) Message(name string) *MessageDecl {
+	for _, m := range f.Messages {
+		if m.Name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+// Enum returns the enum with the given name, inline enums included, or nil.
+func (f *File) Enum(name string) *EnumDecl {
+	for _, e := range f.Enums {
+		if e.Name == name {
+			return e
+		}
+	}
+	return nil
+}
+
+// NamedArg returns the value of the named argument, or nil if absent.
+func (a *Annotation) NamedArg(name string) interface{} {
+	for _, arg := range a.Args {
+		if arg.Name == name {
+			return arg.Value
+		}
+	}
+	return nil
+}
+
+// ForeignKey is a multi-column foreign key declared on an entity with
+// @fk(fields: [...], references: "Entity"). The fields reference the
+// target entity's primary key columns in declaration order.
+type ForeignKey struct {
+	Annotation *Annotation
+	Fields     []string
+	References string
+	OnDelete   string
+}
+
+// ForeignKeys returns the entity-level multi-column foreign keys.
+func (e *EntityDecl) ForeignKeys() []*ForeignKey {
+	var fks []*ForeignKey
+	for _, a := range e.Annotations {
+		if a.Name != "fk" {
+			continue
+		}
+		fk := &ForeignKey{Annotation: a, Fields: stringList(a.NamedArg("fields"))}
+		fk.References, _ = a.NamedArg("references").(string)
+		fk.OnDelete, _ = a.NamedArg("ondelete").(string)
+		fks = append(fks, fk)
+	}
+	return fks
+}
+
+// Index is a multi-column index declared on an entity with
+// @index(fields: [...]), an optional unique: true and an optional partial
+// predicate, where: "condition". A field may be followed by ASC or DESC.
+type Index struct {
+	Annotation *Annotation
+	Fields     []string // field names, without their sort direction
+	Descending []bool   // parallel to Fields
+	Unique     bool
+	Where      string
+	WhereExpr  Expr // nil when Where is empty or does not parse
+}
+
+// Indexes returns the entity-level @index declarations.
+func (e *EntityDecl) Indexes() []*Index {
+	var indexes []*Index
+	for _, a := range e.Annotations {
+		if a.Name != "index" {
+			continue
+		}
+		idx := &Index{Annotation: a}
+		for _, f := range stringList(a.NamedArg("fields")) {
+			name, desc, _ := ParseIndexField(f)
+			idx.Fields = append(idx.Fields, name)
+			idx.Descending = append(idx.Descending, desc)
+		}
+		idx.Unique, _ = a.NamedArg("unique").(bool)
+		idx.Where, _ = a.NamedArg("where").(string)
+		if idx.Where != "" {
+			idx.WhereExpr, _ = ParseExpr(idx.Where)
+		}
+		indexes = append(indexes, idx)
+	}
+	return indexes
+}
+
+// ParseIndexField splits an @index field, "name" or "name ASC|DESC", into
+// the field name and its direction. ok is false when anything other than
+// a direction follows the name.
+func ParseIndexField(s string) (name string, desc bool, ok bool) {
+	words := strings.Fields(s)
+	switch {
+	case len(words) == 1:
+		return words[0], false, true
+	case len(words) == 2 && strings.EqualFold(words[1], "asc"):
+		return words[0], false, true
+	case len(words) == 2 && strings.EqualFold(words[1], "desc"):
+		return words[0], true, true
+	}
+	return strings.TrimSpace(s), false, false
+}
+
+// View is the definition of a read-only entity declared with @view: either
+// an embedded SELECT, @view("SELECT ..."), or a parameterless query of
+// another entity, @view(query: "Entity.query").
+type View struct {
+	Annotation *Annotation
+	Select     string
+	Entity     string
+	Query      string
+}
+
+// View returns the entity's @view definition, or nil when the entity is a
+// table.
+func (e *EntityDecl) View() *View {
+	a := e.GetAnnotation("view")
+	if a == nil {
+		return nil
+	}
+	v := &View{Annotation: a}
+	if len(a.Args) > 0 && a.Args[0].Name == "" {
+		v.Select, _ = a.Args[0].Value.(string)
+	}
+	if ref, ok := a.NamedArg("query").(string); ok {
+		if dot := strings.Index(ref, "."); dot >= 0 {
+			v.Entity, v.Query = ref[:dot], ref[dot+1:]
+		} else {
+			v.Query = ref
+		}
+	}
+	return v
+}
+
+// IsAbstract reports whether the entity is declared @abstract: it only lends
+// its fields to the entities that extend it, and has no table or generated
+// code of its own.
+func (e *EntityDecl) IsAbstract() bool {
+	return e.GetAnnotation("abstract") != nil
+}
+
+// Check is a CHECK constraint declared with @check("expr") on an entity or
+// one of its fields.
+type Check struct {
+	Annotation *Annotation
+	Field      *FieldDecl // nil for an entity-level check
+	Condition  string
+	Expr       Expr // nil when Condition does not parse
+}
+
+// Checks returns the entity's @check constraints, entity-level ones first
+// and then those of each field in declaration order.
+func (e *EntityDecl) Checks() []*Check {
+	var checks []*Check
+	add := func(a *Annotation, field *FieldDecl) {
+		c := &Check{Annotation: a, Field: field}
+		if len(a.Args) > 0 {
+			c.Condition, _ = a.Args[0].Value.(string)
+		}
+		if c.Condition != "" {
+			c.Expr, _ = ParseExpr(c.Condition)
+		}
+		checks = append(checks, c)
+	}
+	for _, a := range e.Annotations {
+		if a.Name == "check" {
+			add(a, nil)
+		}
+	}
+	for _, f := range e.Fields {
+		for _, a := range f.Annotations {
+			if a.Name == "check" {
+				add(a, f)
+			}
+		}
+	}
+	return checks
+}
+
+// GetAnnotation returns the first annotation with the given name, or nil.
+func (q *QueryDecl) GetAnnotation(name string) *Annotation {
+	for _, a := range q.Annotations {
+		if a.Name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+// ReturnsOne reports whether the query is declared @returns(one): it yields
+// at most one row, and generated methods return a single optional result
+// rather than a list. Queries return many rows by default.
+func (q *QueryDecl) ReturnsOne() bool {
+	if a := q.GetAnnotation("returns"); a != nil && len(a.Args) > 0 {
+		return a.Args[0].Value == "one"
+	}
+	return false
+}
+
+// IncludesDeleted reports whether the query is declared @include_deleted,
+// opting out of the filter that hides soft-deleted rows.
+func (q *QueryDecl) IncludesDeleted() bool {
+	return q.GetAnnotation("include_deleted") != nil
+}
+
+// Param returns the query's parameter with the given name, or nil.
+func (q *QueryDecl) Param(name string) *QueryParam {
+	for _, p := range q.Params {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// Field returns the entity's field with the given name, or nil.
+func (e *EntityDecl) Field(name string) *FieldDecl {
+	for _, f := range e.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// Query returns the entity's query with the given name, or nil.
+func (e *EntityDecl) Query(name string) *QueryDecl {
+	for _, q := range e.Queries {
+		if q.Name == name {
+			return q
+		}
+	}
+	return nil
+}
+
+// stringList returns the string elements of an annotation list value.
+func stringList(v interface{}) []string {
+	list, _ := v.([]interface{})
+	var out []string
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// SplitSelectItem splits a select item into its aggregate function and
+// argument. A plain field returns an empty function name.
+func SplitSelectItem(item string) (fn, field string) {
+	open := strings.Index(item, "(")
+	if open < 0 || !strings.HasSuffix(item, ")") {
+		return "", item
+	}
+	return item[:open], item[open+1 : len(item)-1]
+}
+
+// ReferencedParams returns the names of the query's parameters referenced by
+// its WHERE, HAVING and LIMIT expressions, deduplicated in order of first use.
+func ReferencedParams(query *QueryDecl) []string {
+	declared := make(map[string]bool)
+	for _, p := range query.Params {
+		declared[p.Name] = true
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	visit := func(e Expr) {
+		if id, ok := e.(*IdentExpr); ok && declared[id.Name] && !seen[id.Name] {
+			seen[id.Name] = true
+			names = append(names, id.Name)
+		}
+	}
+
+	WalkExpr(query.Where, visit)
+	WalkExpr(query.Having, visit)
+	WalkExpr(query.Limit, visit)
+	return names
+}
+
+// FieldReferences maps each field of entity to the entity's queries that
+// refer to it in their select list, WHERE, GROUP BY, HAVING, ORDER BY or
+// LIMIT, in declaration order. A parameter shadows the field it is named
+// after, so references to it do not count. Unreferenced fields have no entry.
+func FieldReferences(entity *EntityDecl) map[string][]*QueryDecl {
+	refs := make(map[string][]*QueryDecl)
+	for _, query := range entity.Queries {
+		seen := make(map[string]bool)
+		ref := func(name string) {
+			if seen[name] || entity.Field(name) == nil || query.Param(name) != nil {
+				return
+			}
+			seen[name] = true
+			refs[name] = append(refs[name], query)
+		}
+		visit := func(e Expr) {
+			if id, ok := e.(*IdentExpr); ok {
+				ref(id.Name)
+			}
+		}
+
+		for _, item := range query.Select {
+			_, field := SplitSelectItem(item)
+			ref(field)
+		}
+		WalkExpr(query.Where, visit)
+		for _, name := range query.GroupBy {
+			ref(name)
+		}
+		WalkExpr(query.Having, visit)
+		for _, o := range query.OrderBy {
+			ref(o.Field)
+		}
+		WalkExpr(query.Limit, visit)
+	}
+	return refs
+}
+
+// WalkExpr calls fn for expr and each of its sub-expressions, left to right.
+// A nil expr is not visited.
+func WalkExpr(expr Expr, fn func(Expr)) {
+	if expr == nil {
+		return
+	}
+	fn(expr)
+	switch e := expr.(type) {
+	case *BinaryExpr:
+		WalkExpr(e.Left, fn)
+		WalkExpr(e.Right, fn)
+	case *UnaryExpr:
+		WalkExpr(e.Operand, fn)
+	case *IsNullExpr:
+		WalkExpr(e.Operand, fn)
+	case *CallExpr:
+		for _, arg := range e.Args {
+			WalkExpr(arg, fn)
+		}
+	case *ParenExpr:
+		WalkExpr(e.Inner, fn)
+	case *TupleExpr:
+		for _, elem := range e.Elements {
+			WalkExpr(elem, fn)
+		}
+	case *FieldAccessExpr:
+		WalkExpr(e.Base, fn)
+	case *CaseExpr:
+		for _, when := range e.Whens {
+			WalkExpr(when.Cond, fn)
+			WalkExpr(when.Result, fn)
+		}
+		WalkExpr(e.Else, fn)
+	}
+}
@@ -0,0 +1,322 @@
+package parser
+
+// Clone returns a deep copy of file: every declaration, expression tree and
+// annotation argument is copied, so the copy can be rewritten without
+// touching the original. An inline enum stays shared between its field's
+// type and the copy's Enums, as it is in a parsed file.
+func Clone(file *File) *File {
+	if file == nil {
+		return nil
+	}
+	c := &cloner{enums: make(map[*EnumDecl]*EnumDecl)}
+
+	out := &File{Position: file.Position}
+	if file.Package != nil {
+		pkg := *file.Package
+		out.Package = &pkg
+	}
+	for _, imp := range file.Imports {
+		out.Imports = append(out.Imports, cloneImport(imp))
+	}
+	out.Options = cloneOptions(file.Options)
+	for _, enum := range file.Enums {
+		out.Enums = append(out.Enums, c.enum(enum))
+	}
+	for _, entity := range file.Entities {
+		out.Entities = append(out.Entities, c.entity(entity))
+	}
+	for _, msg := range file.Messages {
+		out.Messages = append(out.Messages, c.message(msg))
+	}
+	for _, svc := range file.Services {
+		out.Services = append(out.Services, cloneService(svc))
+	}
+	return out
+}
+
+// cloner remembers the enums it has copied, so an inline enum reached from
+// both File.Enums and a TypeRef is copied once.
+type cloner struct {
+	enums map[*EnumDecl]*EnumDecl
+}
+
+func (c *cloner) enum(enum *EnumDecl) *EnumDecl {
+	if enum == nil {
+		return nil
+	}
+	if out, ok := c.enums[enum]; ok {
+		return out
+	}
+	out := &EnumDecl{
+		Position: enum.Position,
+		Name:     enum.Name,
+		Options:  cloneOptions(enum.Options),
+		Inline:   enum.Inline,
+	}
+	c.enums[enum] = out
+	for _, val := range enum.Values {
+		if val == nil {
+			out.Values = append(out.Values, nil)
+			continue
+		}
+		v := *val
+		v.Annotations = cloneAnnotations(val.Annotations)
+		out.Values = append(out.Values, &v)
+	}
+	return out
+}
+
+func (c *cloner) entity(entity *EntityDecl) *EntityDecl {
+	if entity == nil {
+		return nil
+	}
+	out := &EntityDecl{
+		Position:    entity.Position,
+		Annotations: cloneAnnotations(entity.Annotations),
+		Name:        entity.Name,
+		Extends:     entity.Extends,
+		Fields:      c.fields(entity.Fields),
+	}
+	for _, query := range entity.Queries {
+		out.Queries = append(out.Queries, c.query(query))
+	}
+	out.Reserved = cloneReserved(entity.Reserved)
+	return out
+}
+
+func cloneReserved(reserved []*ReservedDecl) []*ReservedDecl {
+	var out []*ReservedDecl
+	for _, r := range reserved {
+		if r == nil {
+			out = append(out, nil)
+			continue
+		}
+		out = append(out, &ReservedDecl{
+			Position: r.Position,
+			Ranges:   append([]ReservedRange(nil), r.Ranges...),
+			Names:    append([]string(nil), r.Names...),
+		})
+	}
+	return out
+}
+
+func (c *cloner) message(msg *MessageDecl) *MessageDecl {
+	if msg == nil {
+		return nil
+	}
+	return &MessageDecl{
+		Position:    msg.Position,
+		Annotations: cloneAnnotations(msg.Annotations),
+		Name:        msg.Name,
+		Fields:      c.fields(msg.Fields),
+		Reserved:    cloneReserved(msg.Reserved),
+	}
+}
+
+func (c *cloner) fields(fields []*FieldDecl) []*FieldDecl {
+	var out []*FieldDecl
+	for _, field := range fields {
+		if field == nil {
+			out = append(out, nil)
+			continue
+		}
+		out = append(out, &FieldDecl{
+			Position:    field.Position,
+			Annotations: cloneAnnotations(field.Annotations),
+			Name:        field.Name,
+			Type:        c.typeRef(field.Type),
+			Doc:         field.Doc,
+			Inherited:   field.Inherited,
+		})
+	}
+	return out
+}
+
+func (c *cloner) typeRef(t *TypeRef) *TypeRef {
+	if t == nil {
+		return nil
+	}
+	out := *t
+	out.Enum = c.enum(t.Enum)
+	return &out
+}
+
+func (c *cloner) query(query *QueryDecl) *QueryDecl {
+	if query == nil {
+		return nil
+	}
+	out := &QueryDecl{
+		Position:    query.Position,
+		Annotations: cloneAnnotations(query.Annotations),
+		Name:        query.Name,
+		Select:      append([]string(nil), query.Select...),
+		Distinct:    query.Distinct,
+		Where:       cloneExpr(query.Where),
+		GroupBy:     append([]string(nil), query.GroupBy...),
+		Having:      cloneExpr(query.Having),
+		Limit:       cloneExpr(query.Limit),
+	}
+	for _, param := range query.Params {
+		if param == nil {
+			out.Params = append(out.Params, nil)
+			continue
+		}
+		out.Params = append(out.Params, &QueryParam{
+			Position:    param.Position,
+			Annotations: cloneAnnotations(param.Annotations),
+			Name:        param.Name,
+			Type:        c.typeRef(param.Type),
+			Default:     cloneValue(param.Default),
+			DefaultExpr: cloneExpr(param.DefaultExpr),
+			Optional:    param.Optional,
+		})
+	}
+	for _, o := range query.OrderBy {
+		if o == nil {
+			out.OrderBy = append(out.OrderBy, nil)
+			continue
+		}
+		ob := *o
+		out.OrderBy = append(out.OrderBy, &ob)
+	}
+	return out
+}
+
+func cloneImport(imp *ImportDecl) *ImportDecl {
+	if imp == nil {
+		return nil
+	}
+	out := *imp
+	return &out
+}
+
+func cloneService(svc *ServiceDecl) *ServiceDecl {
+	if svc == nil {
+		return nil
+	}
+	out := &ServiceDecl{Position: svc.Position, Name: svc.Name}
+	for _, method := range svc.Methods {
+		if method == nil {
+			out.Methods = append(out.Methods, nil)
+			continue
+		}
+		out.Methods = append(out.Methods, &RpcDecl{
+			Position:     method.Position,
+			Annotations:  cloneAnnotations(method.Annotations),
+			Name:         method.Name,
+			RequestType:  cloneRpcType(method.RequestType),
+			ResponseType: cloneRpcType(method.ResponseType),
+		})
+	}
+	return out
+}
+
+func cloneRpcType(t *RpcType) *RpcType {
+	if t == nil {
+		return nil
+	}
+	out := *t
+	return &out
+}
+
+func cloneOptions(opts []*OptionDecl) []*OptionDecl {
+	var out []*OptionDecl
+	for _, opt := range opts {
+		if opt == nil {
+			out = append(out, nil)
+			continue
+		}
+		out = append(out, &OptionDecl{
+			Position:  opt.Position,
+			Name:      opt.Name,
+			NameParts: append([]OptionNamePart(nil), opt.NameParts...),
+			Value:     cloneValue(opt.Value),
+			Ident:     opt.Ident,
+		})
+	}
+	return out
+}
+
+func cloneAnnotations(anns []*Annotation) []*Annotation {
+	var out []*Annotation
+	for _, ann := range anns {
+		if ann == nil {
+			out = append(out, nil)
+			continue
+		}
+		a := &Annotation{Position: ann.Position, Name: ann.Name}
+		for _, arg := range ann.Args {
+			a.Args = append(a.Args, AnnotationArg{Position: arg.Position, Name: arg.Name, Value: cloneValue(arg.Value)})
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+// cloneValue copies the lists and maps of an option or annotation value.
+// Scalars are immutable and returned as is.
+func cloneValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = cloneValue(item)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			out[k] = cloneValue(item)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// cloneExpr deep-copies an expression tree.
+func cloneExpr(expr Expr) Expr {
+	switch e := expr.(type) {
+	case *BinaryExpr:
+		return &BinaryExpr{Position: e.Position, Left: cloneExpr(e.Left), Op: e.Op, Right: cloneExpr(e.Right)}
+	case *UnaryExpr:
+		return &UnaryExpr{Position: e.Position, Op: e.Op, Operand: cloneExpr(e.Operand)}
+	case *IsNullExpr:
+		return &IsNullExpr{Position: e.Position, Operand: cloneExpr(e.Operand), Not: e.Not}
+	case *IdentExpr:
+		out := *e
+		return &out
+	case *FieldAccessExpr:
+		return &FieldAccessExpr{Position: e.Position, Base: cloneExpr(e.Base), Field: e.Field}
+	case *LiteralExpr:
+		out := *e
+		return &out
+	case *CallExpr:
+		out := &CallExpr{Position: e.Position, Name: e.Name}
+		for _, arg := range e.Args {
+			out.Args = append(out.Args, cloneExpr(arg))
+		}
+		return out
+	case *ParenExpr:
+		return &ParenExpr{Position: e.Position, Inner: cloneExpr(e.Inner)}
+	case *TupleExpr:
+		out := &TupleExpr{Position: e.Position}
+		for _, elem := range e.Elements {
+			out.Elements = append(out.Elements, cloneExpr(elem))
+		}
+		return out
+	case *CaseExpr:
+		out := &CaseExpr{Position: e.Position, Else: cloneExpr(e.Else)}
+		for _, when := range e.Whens {
+			if when == nil {
+				out.Whens = append(out.Whens, nil)
+				continue
+			}
+			out.Whens = append(out.Whens, &CaseWhen{Position: when.Position, Cond: cloneExpr(when.Cond), Result: cloneExpr(when.Result)})
+		}
+		return out
+	default:
+		// nil, or an Expr implemented outside this package
+		return expr
+	}
+}
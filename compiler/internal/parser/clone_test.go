@@ -0,0 +1,77 @@
+package parser
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestClone(t *testing.T) {
+	source, err := os.ReadFile("../../../examples/aurora/calendar.dataproto")
+	if err != nil {
+		t.Fatalf("reading calendar schema: %v", err)
+	}
+	file, err := Parse(string(source))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	before := file.String()
+
+	clone := Clone(file)
+	if !reflect.DeepEqual(clone, file) {
+		t.Fatal("Expected the clone to equal the original")
+	}
+
+	entity := clone.Entities[0]
+	entity.Name = "Renamed"
+	entity.Annotations[0].Args[0].Value = "renamed"
+	entity.Fields[0].Type.Name = "int64"
+	where := entity.Queries[0].Where.(*BinaryExpr)
+	where.Op = "OR"
+	where.Left.(*BinaryExpr).Left.(*IdentExpr).Name = "renamed"
+
+	if got := file.String(); got != before {
+		t.Errorf("Mutating the clone changed the original:\n%s\nwant\n%s", got, before)
+	}
+	if file.Entities[0].Name != "CalendarEvent" {
+		t.Errorf("Expected original entity CalendarEvent, got %s", file.Entities[0].Name)
+	}
+}
+
+func TestCloneSharesInlineEnum(t *testing.T) {
+	file, err := Parse(`entity Task { @pk id: string; status: enum { OPEN = 0; DONE = 1; }; }`)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	clone := Clone(file)
+	if clone.Entities[0].Fields[1].Type.Enum != clone.Enums[0] {
+		t.Error("Expected the inline enum to stay shared between the field and Enums")
+	}
+	if clone.Enums[0] == file.Enums[0] {
+		t.Error("Expected the inline enum to be copied")
+	}
+}
+
+func TestCloneParamDefaultExpr(t *testing.T) {
+	file, err := Parse(`entity Event {
+    @pk id: string;
+    at: timestamp;
+
+    query window(since: timestamp = NOW(), until: timestamp = since + 86400000) {
+        where at >= since AND at < until
+    }
+}`)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	clone := Clone(file)
+	if !reflect.DeepEqual(clone, file) {
+		t.Fatal("Expected the clone to equal the original")
+	}
+	param := clone.Entities[0].Queries[0].Params[1]
+	if param.DefaultExpr == nil || param.DefaultExpr == file.Entities[0].Queries[0].Params[1].DefaultExpr {
+		t.Errorf("Expected a copied default expression, got %v", param.DefaultExpr)
+	}
+}
@@ -0,0 +1,264 @@
+package parser
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/aurora/dataproto/internal/lexer"
+)
+
+// jsonNode is one node of the JSON form of the AST. encoding/json sorts map
+// keys, which keeps the output stable.
+type jsonNode map[string]interface{}
+
+// MarshalJSON encodes file as an indented JSON tree for tools outside Go.
+// Every node is an object with a "kind" discriminator, such as "entity" or
+// "binary", and a "pos" holding its line and column (and file, when known).
+// Lists are always arrays, never null, and absent optional nodes such as a
+// query without a where clause are null.
+func MarshalJSON(file *File) ([]byte, error) {
+	return json.MarshalIndent(fileJSON(file), "", "  ")
+}
+
+func newJSONNode(kind string, pos lexer.Position) jsonNode {
+	p := jsonNode{"line": pos.Line, "column": pos.Column}
+	if pos.Filename != "" {
+		p["file"] = pos.Filename
+	}
+	return jsonNode{"kind": kind, "pos": p}
+}
+
+// jsonList converts items with fn, yielding [] rather than null when empty.
+func jsonList[T any](items []T, fn func(T) interface{}) []interface{} {
+	out := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		out = append(out, fn(item))
+	}
+	return out
+}
+
+func jsonStrings(items []string) []interface{} {
+	return jsonList(items, func(s string) interface{} { return s })
+}
+
+func fileJSON(file *File) interface{} {
+	if file == nil {
+		return nil
+	}
+	n := newJSONNode("file", file.Position)
+	n["package"] = nil
+	if file.Package != nil {
+		pkg := newJSONNode("package", file.Package.Position)
+		pkg["name"] = file.Package.Name
+		n["package"] = pkg
+	}
+	n["imports"] = jsonList(file.Imports, func(imp *ImportDecl) interface{} {
+		node := newJSONNode("import", imp.Position)
+		node["path"] = imp.Path
+		return node
+	})
+	n["options"] = jsonList(file.Options, optionJSON)
+	n["enums"] = jsonList(file.Enums, enumJSON)
+	n["entities"] = jsonList(file.Entities, entityJSON)
+	n["messages"] = jsonList(file.Messages, func(msg *MessageDecl) interface{} {
+		node := newJSONNode("message", msg.Position)
+		node["name"] = msg.Name
+		node["annotations"] = jsonList(msg.Annotations, annotationJSON)
+		node["fields"] = jsonList(msg.Fields, fieldJSON)
+		node["reserved"] = jsonList(msg.Reserved, reservedJSON)
+		return node
+	})
+	n["services"] = jsonList(file.Services, serviceJSON)
+	return n
+}
+
+func optionJSON(opt *OptionDecl) interface{} {
+	n := newJSONNode("option", opt.Position)
+	n["name"] = opt.Name
+	n["extension"] = opt.IsExtension()
+	n["value"] = opt.Value
+	n["ident"] = opt.Ident
+	return n
+}
+
+func enumJSON(enum *EnumDecl) interface{} {
+	n := newJSONNode("enum", enum.Position)
+	n["name"] = enum.Name
+	n["inline"] = enum.Inline
+	n["options"] = jsonList(enum.Options, optionJSON)
+	n["values"] = jsonList(enum.Values, func(val *EnumValue) interface{} {
+		node := newJSONNode("enum_value", val.Position)
+		node["name"] = val.Name
+		node["number"] = val.Number
+		node["annotations"] = jsonList(val.Annotations, annotationJSON)
+		return node
+	})
+	return n
+}
+
+func entityJSON(entity *EntityDecl) interface{} {
+	n := newJSONNode("entity", entity.Position)
+	n["name"] = entity.Name
+	n["extends"] = entity.Extends
+	n["annotations"] = jsonList(entity.Annotations, annotationJSON)
+	n["fields"] = jsonList(entity.Fields, fieldJSON)
+	n["queries"] = jsonList(entity.Queries, queryJSON)
+	n["reserved"] = jsonList(entity.Reserved, reservedJSON)
+	return n
+}
+
+func reservedJSON(r *ReservedDecl) interface{} {
+	n := newJSONNode("reserved", r.Position)
+	n["ranges"] = jsonList(r.Ranges, func(rr ReservedRange) interface{} {
+		return jsonNode{"start": rr.Start, "end": rr.End}
+	})
+	n["names"] = jsonStrings(r.Names)
+	return n
+}
+
+func fieldJSON(field *FieldDecl) interface{} {
+	n := newJSONNode("field", field.Position)
+	n["name"] = field.Name
+	n["type"] = typeJSON(field.Type)
+	n["annotations"] = jsonList(field.Annotations, annotationJSON)
+	n["doc"] = field.Doc
+	n["inherited"] = field.Inherited
+	return n
+}
+
+// typeJSON encodes a type reference. An inline enum is listed with the
+// file's enums; the type refers to it by its synthesized name.
+func typeJSON(t *TypeRef) interface{} {
+	if t == nil {
+		return nil
+	}
+	n := newJSONNode("type", t.Position)
+	n["name"] = t.Name
+	n["optional"] = t.Optional
+	return n
+}
+
+func annotationJSON(ann *Annotation) interface{} {
+	n := newJSONNode("annotation", ann.Position)
+	n["name"] = ann.Name
+	n["args"] = jsonList(ann.Args, func(arg AnnotationArg) interface{} {
+		node := newJSONNode("arg", arg.Position)
+		node["name"] = arg.Name
+		node["value"] = arg.Value
+		return node
+	})
+	return n
+}
+
+func queryJSON(query *QueryDecl) interface{} {
+	n := newJSONNode("query", query.Position)
+	n["name"] = query.Name
+	n["annotations"] = jsonList(query.Annotations, annotationJSON)
+	n["params"] = jsonList(query.Params, func(param *QueryParam) interface{} {
+		node := newJSONNode("param", param.Position)
+		node["name"] = param.Name
+		node["type"] = typeJSON(param.Type)
+		node["optional"] = param.Optional
+		node["default"] = param.Default
+		node["default_expr"] = exprJSON(param.DefaultExpr)
+		node["annotations"] = jsonList(param.Annotations, annotationJSON)
+		return node
+	})
+	n["select"] = jsonStrings(query.Select)
+	n["distinct"] = query.Distinct
+	n["where"] = exprJSON(query.Where)
+	n["group_by"] = jsonStrings(query.GroupBy)
+	n["having"] = exprJSON(query.Having)
+	n["order_by"] = jsonList(query.OrderBy, func(o *OrderByField) interface{} {
+		node := newJSONNode("order_by", o.Position)
+		node["field"] = o.Field
+		node["descending"] = o.Descending
+		node["nulls"] = strings.ToLower(strings.TrimPrefix(o.Nulls.String(), "NULLS "))
+		return node
+	})
+	n["limit"] = exprJSON(query.Limit)
+	return n
+}
+
+func exprJSON(expr Expr) interface{} {
+	switch e := expr.(type) {
+	case *BinaryExpr:
+		n := newJSONNode("binary", e.Position)
+		n["op"] = e.Op
+		n["left"] = exprJSON(e.Left)
+		n["right"] = exprJSON(e.Right)
+		return n
+	case *UnaryExpr:
+		n := newJSONNode("unary", e.Position)
+		n["op"] = e.Op
+		n["operand"] = exprJSON(e.Operand)
+		return n
+	case *IsNullExpr:
+		n := newJSONNode("is_null", e.Position)
+		n["operand"] = exprJSON(e.Operand)
+		n["not"] = e.Not
+		return n
+	case *IdentExpr:
+		n := newJSONNode("ident", e.Position)
+		n["name"] = e.Name
+		return n
+	case *FieldAccessExpr:
+		n := newJSONNode("field_access", e.Position)
+		n["base"] = exprJSON(e.Base)
+		n["field"] = e.Field
+		return n
+	case *LiteralExpr:
+		n := newJSONNode("literal", e.Position)
+		n["value"] = e.Value
+		return n
+	case *CallExpr:
+		n := newJSONNode("call", e.Position)
+		n["name"] = e.Name
+		n["args"] = jsonList(e.Args, exprJSON)
+		return n
+	case *ParenExpr:
+		n := newJSONNode("paren", e.Position)
+		n["inner"] = exprJSON(e.Inner)
+		return n
+	case *TupleExpr:
+		n := newJSONNode("tuple", e.Position)
+		n["elements"] = jsonList(e.Elements, exprJSON)
+		return n
+	case *CaseExpr:
+		n := newJSONNode("case", e.Position)
+		n["whens"] = jsonList(e.Whens, func(when *CaseWhen) interface{} {
+			node := newJSONNode("when", when.Position)
+			node["cond"] = exprJSON(when.Cond)
+			node["result"] = exprJSON(when.Result)
+			return node
+		})
+		n["else"] = exprJSON(e.Else)
+		return n
+	default:
+		return nil
+	}
+}
+
+func serviceJSON(svc *ServiceDecl) interface{} {
+	n := newJSONNode("service", svc.Position)
+	n["name"] = svc.Name
+	n["methods"] = jsonList(svc.Methods, func(method *RpcDecl) interface{} {
+		node := newJSONNode("rpc", method.Position)
+		node["name"] = method.Name
+		node["annotations"] = jsonList(method.Annotations, annotationJSON)
+		node["request"] = rpcTypeJSON(method.RequestType)
+		node["response"] = rpcTypeJSON(method.ResponseType)
+		return node
+	})
+	return n
+}
+
+func rpcTypeJSON(t *RpcType) interface{} {
+	if t == nil {
+		return nil
+	}
+	n := newJSONNode("rpc_type", t.Position)
+	n["name"] = t.Name
+	n["stream"] = t.Stream
+	return n
+}
@@ -0,0 +1,124 @@
+package parser
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// decodeAST marshals file and decodes the JSON generically, dropping the
+// keys in strip wherever they occur.
+func decodeAST(t *testing.T, file *File, strip ...string) map[string]interface{} {
+	t.Helper()
+	data, err := MarshalJSON(file)
+	if err != nil {
+		t.Fatalf("MarshalJSON error: %v", err)
+	}
+	var tree map[string]interface{}
+	if err := json.Unmarshal(data, &tree); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	var drop func(v interface{})
+	drop = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for _, key := range strip {
+				delete(v, key)
+			}
+			for _, child := range v {
+				drop(child)
+			}
+		case []interface{}:
+			for _, child := range v {
+				drop(child)
+			}
+		}
+	}
+	drop(tree)
+	return tree
+}
+
+func TestMarshalJSON(t *testing.T) {
+	source, err := os.ReadFile("../../../examples/aurora/calendar.dataproto")
+	if err != nil {
+		t.Fatalf("reading calendar schema: %v", err)
+	}
+	file, err := Parse(string(source))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	tree := decodeAST(t, file)
+	if tree["kind"] != "file" || tree["package"].(map[string]interface{})["name"] != "acos" {
+		t.Errorf("Expected file in package acos, got %v %v", tree["kind"], tree["package"])
+	}
+
+	entity := tree["entities"].([]interface{})[0].(map[string]interface{})
+	if entity["kind"] != "entity" || entity["name"] != "CalendarEvent" {
+		t.Errorf("Expected entity CalendarEvent, got %v %v", entity["kind"], entity["name"])
+	}
+	if pos := entity["pos"].(map[string]interface{}); pos["line"] != float64(10) || pos["column"] != float64(1) {
+		t.Errorf("Expected CalendarEvent at 10:1, got %v", pos)
+	}
+
+	id := entity["fields"].([]interface{})[0].(map[string]interface{})
+	if id["name"] != "id" || id["type"].(map[string]interface{})["name"] != "string" {
+		t.Errorf("Expected id: string, got %v", id)
+	}
+	if ann := id["annotations"].([]interface{})[0].(map[string]interface{}); ann["kind"] != "annotation" || ann["name"] != "pk" {
+		t.Errorf("Expected @pk, got %v", ann)
+	}
+
+	query := entity["queries"].([]interface{})[0].(map[string]interface{})
+	where := query["where"].(map[string]interface{})
+	if where["kind"] != "binary" || where["op"] != "AND" {
+		t.Errorf("Expected an AND where clause, got %v %v", where["kind"], where["op"])
+	}
+	if left := where["left"].(map[string]interface{}); left["kind"] != "binary" ||
+		left["left"].(map[string]interface{})["kind"] != "ident" {
+		t.Errorf("Expected start_date >= after, got %v", left)
+	}
+	if query["having"] != nil || len(query["group_by"].([]interface{})) != 0 {
+		t.Errorf("Expected no having and an empty group_by, got %v %v", query["having"], query["group_by"])
+	}
+
+	svc := tree["services"].([]interface{})[0].(map[string]interface{})
+	push := svc["methods"].([]interface{})[0].(map[string]interface{})
+	if req := push["request"].(map[string]interface{}); req["kind"] != "rpc_type" || req["stream"] != true {
+		t.Errorf("Expected a streamed request, got %v", req)
+	}
+
+	// Reparsing the printed file yields the same tree, positions and field
+	// docs aside
+	reparsed, err := Parse(file.String())
+	if err != nil {
+		t.Fatalf("reparse error: %v", err)
+	}
+	if got, want := decodeAST(t, reparsed, "pos", "doc"), decodeAST(t, file, "pos", "doc"); !reflect.DeepEqual(got, want) {
+		t.Error("Expected the reparsed file to marshal to the same tree")
+	}
+}
+
+func TestMarshalJSONParamDefaultExpr(t *testing.T) {
+	file, err := Parse(`entity Event {
+    @pk id: string;
+    at: timestamp;
+
+    query since(t: timestamp = NOW()) {
+        where at >= t
+    }
+}`)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	tree := decodeAST(t, file)
+	entity := tree["entities"].([]interface{})[0].(map[string]interface{})
+	query := entity["queries"].([]interface{})[0].(map[string]interface{})
+	param := query["params"].([]interface{})[0].(map[string]interface{})
+	def, ok := param["default_expr"].(map[string]interface{})
+	if !ok || def["kind"] != "call" || def["name"] != "NOW" {
+		t.Errorf("Expected default_expr NOW(), got %v", param["default_expr"])
+	}
+}
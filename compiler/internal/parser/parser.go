@@ -3,8 +3,11 @@ package parser
 import (
 	"fmt"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/aurora/dataproto/internal/lexer"
+	"github.com/aurora/dataproto/internal/naming"
 )
 
 // Parser parses DataProto source code into an AST.
@@ -12,13 +15,52 @@ type Parser struct {
 	l         *lexer.Lexer
 	curToken  lexer.Token
 	peekToken lexer.Token
-	errors    []string
+	errors    []ParseError
 	filename  string
+	exprDepth int
+
+	// inlineEnums collects the enums declared inline by field types
+	inlineEnums []*EnumDecl
+
+	// MaxExprDepth bounds how deeply expressions may nest before the parser
+	// reports an error instead of recursing further. New sets it to
+	// DefaultMaxExprDepth.
+	MaxExprDepth int
+}
+
+// DefaultMaxExprDepth is the default expression nesting limit. It is far
+// beyond anything a real query needs but keeps recursion bounded.
+const DefaultMaxExprDepth = 1000
+
+// ParseError is a syntax error with the source position it was reported at.
+type ParseError struct {
+	Position lexer.Position
+	Message  string
+}
+
+func (e ParseError) Error() string {
+	return fmt.Sprintf("line %d:%d: %s", e.Position.Line, e.Position.Column, e.Message)
+}
+
+// ErrorList is the error returned by Parse and ParseFile when parsing fails.
+// It exposes every reported error with its position.
+type ErrorList []ParseError
+
+func (l ErrorList) Error() string {
+	return fmt.Sprintf("parse errors: %v", l.strings())
+}
+
+func (l ErrorList) strings() []string {
+	msgs := make([]string, len(l))
+	for i, e := range l {
+		msgs[i] = e.Error()
+	}
+	return msgs
 }
 
 // New creates a new Parser for the given lexer.
 func New(l *lexer.Lexer) *Parser {
-	p := &Parser{l: l}
+	p := &Parser{l: l, MaxExprDepth: DefaultMaxExprDepth}
 	// Read two tokens to populate curToken and peekToken
 	p.nextToken()
 	p.nextToken()
@@ -37,8 +79,13 @@ func NewFromStringWithFilename(input, filename string) *Parser {
 	return p
 }
 
-// Errors returns all parsing errors.
+// Errors returns all parsing errors as "line L:C: message" strings.
 func (p *Parser) Errors() []string {
+	return ErrorList(p.errors).strings()
+}
+
+// DetailedErrors returns all parsing errors with their positions.
+func (p *Parser) DetailedErrors() []ParseError {
 	return p.errors
 }
 
@@ -68,35 +115,80 @@ func (p *Parser) expectPeek(t lexer.TokenType) bool {
 	return false
 }
 
+// addError records an error at the given position.
+func (p *Parser) addError(pos lexer.Position, format string, args ...interface{}) {
+	p.errors = append(p.errors, ParseError{
+		Position: pos,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
 // peekError adds an error for unexpected peek token.
 func (p *Parser) peekError(t lexer.TokenType) {
-	msg := fmt.Sprintf("line %d:%d: expected %s, got %s",
-		p.peekToken.Line, p.peekToken.Column, t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	if p.peekToken.Type == lexer.ILLEGAL {
+		p.addError(p.tokenPos(p.peekToken), "%s", illegalMessage(p.peekToken))
+		return
+	}
+	p.addError(p.tokenPos(p.peekToken), "expected %s, got %s", t, p.peekToken.Type)
 }
 
-// curError adds an error for unexpected current token.
+// curError adds an error for unexpected current token. An ILLEGAL token
+// carries the lexer's own description of the problem, which is reported
+// instead.
 func (p *Parser) curError(expected string) {
-	msg := fmt.Sprintf("line %d:%d: expected %s, got %s",
-		p.curToken.Line, p.curToken.Column, expected, p.curToken.Type)
-	p.errors = append(p.errors, msg)
+	if p.curToken.Type == lexer.ILLEGAL {
+		p.addError(p.curPos(), "%s", illegalMessage(p.curToken))
+		return
+	}
+	p.addError(p.curPos(), "expected %s, got %s", expected, p.curToken.Type)
+}
+
+// illegalMessage describes an ILLEGAL token. Single characters the lexer
+// does not recognize carry just that character; other ILLEGAL tokens, like
+// an unterminated string, carry a full description.
+func illegalMessage(tok lexer.Token) string {
+	if utf8.RuneCountInString(tok.Literal) == 1 {
+		return fmt.Sprintf("unexpected character %q", tok.Literal)
+	}
+	return tok.Literal
 }
 
 // curPos returns the current token position.
 func (p *Parser) curPos() lexer.Position {
+	return p.tokenPos(p.curToken)
+}
+
+// tokenPos returns the source position of tok.
+func (p *Parser) tokenPos(tok lexer.Token) lexer.Position {
 	return lexer.Position{
 		Filename: p.filename,
-		Line:     p.curToken.Line,
-		Column:   p.curToken.Column,
+		Line:     tok.Line,
+		Column:   tok.Column,
 	}
 }
 
+// isFieldStart reports whether the current token begins a field declaration.
+// A keyword only names a field when followed by ':', so that query
+// declarations in an entity body still parse as such.
+func (p *Parser) isFieldStart() bool {
+	return (p.curTokenIs(lexer.IDENT) && !p.isReservedStart()) ||
+		(p.isKeywordAsIdent() && p.peekTokenIs(lexer.COLON))
+}
+
+// isReservedStart reports whether the current token begins a reserved
+// declaration. "reserved" is only a keyword at the start of a statement in
+// an entity or message body, so it is still free to name fields and params.
+func (p *Parser) isReservedStart() bool {
+	return p.curTokenIs(lexer.IDENT) && p.curToken.Literal == "reserved" && !p.peekTokenIs(lexer.COLON)
+}
+
 // isKeywordAsIdent returns true if current token is a keyword that can be used as identifier.
 func (p *Parser) isKeywordAsIdent() bool {
 	switch p.curToken.Type {
-	case lexer.LIMIT, lexer.WHERE, lexer.ORDER_BY, lexer.QUERY,
-		lexer.ASC, lexer.DESC, lexer.AND, lexer.OR, lexer.NOT,
-		lexer.IN, lexer.LIKE, lexer.IS, lexer.NULL:
+	case lexer.LIMIT, lexer.SELECT, lexer.DISTINCT, lexer.WHERE, lexer.ORDER_BY, lexer.GROUP_BY, lexer.HAVING, lexer.QUERY,
+		lexer.ASC, lexer.DESC, lexer.NULLS, lexer.FIRST, lexer.LAST, lexer.AND, lexer.OR, lexer.NOT,
+		lexer.IN, lexer.LIKE, lexer.ILIKE, lexer.IS, lexer.NULL,
+		lexer.CASE, lexer.WHEN, lexer.THEN, lexer.ELSE, lexer.END, lexer.MESSAGE, lexer.EXTENDS:
 		return true
 	default:
 		return false
@@ -124,23 +216,112 @@ func (p *Parser) ParseFile() *File {
 				entity := p.parseEntityDecl()
 				entity.Annotations = annotations
 				file.Entities = append(file.Entities, entity)
+			} else if p.curTokenIs(lexer.MESSAGE) {
+				msg := p.parseMessageDecl()
+				msg.Annotations = annotations
+				file.Messages = append(file.Messages, msg)
 			} else {
-				p.curError("entity after annotations")
+				p.curError("entity or message after annotations")
 				p.nextToken()
 			}
 		case lexer.ENTITY:
 			file.Entities = append(file.Entities, p.parseEntityDecl())
+		case lexer.MESSAGE:
+			file.Messages = append(file.Messages, p.parseMessageDecl())
 		case lexer.SERVICE:
 			file.Services = append(file.Services, p.parseServiceDecl())
 		default:
-			p.curError("package, import, option, enum, entity, or service")
+			p.curError("package, import, option, enum, entity, message, or service")
 			p.nextToken()
 		}
 	}
 
+	file.Enums = append(file.Enums, p.inlineEnums...)
+	inheritFields(file.Entities, nil)
 	return file
 }
 
+// inheritFields copies the fields of each entity's base, and of the base's
+// own bases, ahead of the entity's own fields. Bases are looked up among
+// entities, then among imported, whose inherited fields are already in place.
+// With imported entities, only the entities whose chain of bases leaves the
+// file are completed; the others were when the file was parsed. Entities
+// whose chain of bases names an unknown entity or loops back on itself are
+// left as written; the checker reports both.
+func inheritFields(entities, imported []*EntityDecl) {
+	byName := make(map[string]*EntityDecl)
+	for _, entity := range imported {
+		byName[entity.Name] = entity
+	}
+	local := make(map[string]*EntityDecl)
+	for _, entity := range entities {
+		byName[entity.Name] = entity
+		local[entity.Name] = entity
+	}
+
+	done := make(map[*EntityDecl]bool)
+	for _, entity := range imported {
+		done[entity] = true
+	}
+	if len(imported) > 0 {
+		for _, entity := range entities {
+			done[entity] = resolvesBase(entity, local, nil)
+		}
+	}
+
+	var inherit func(entity *EntityDecl)
+	inherit = func(entity *EntityDecl) {
+		if done[entity] {
+			return
+		}
+		done[entity] = true
+		base := byName[entity.Extends]
+		if base == nil {
+			return
+		}
+		inherit(base)
+
+		var fields []*FieldDecl
+		for _, field := range base.Fields {
+			copied := *field
+			copied.Annotations = cloneAnnotations(field.Annotations)
+			if field.Type != nil {
+				// An inline enum stays shared with the base's field
+				typ := *field.Type
+				copied.Type = &typ
+			}
+			if copied.Inherited == "" {
+				copied.Inherited = base.Name
+			}
+			fields = append(fields, &copied)
+		}
+		entity.Fields = append(fields, entity.Fields...)
+	}
+
+	for _, entity := range entities {
+		if entity.Extends != "" && resolvesBase(entity, byName, done) {
+			inherit(entity)
+		}
+	}
+}
+
+// resolvesBase reports whether entity's chain of bases ends at an entity
+// that extends nothing or is already complete, without naming an unknown
+// entity or looping.
+func resolvesBase(entity *EntityDecl, byName map[string]*EntityDecl, complete map[*EntityDecl]bool) bool {
+	seen := make(map[*EntityDecl]bool)
+	for entity.Extends != "" && !complete[entity] {
+		if seen[entity] {
+			return false
+		}
+		seen[entity] = true
+		if entity = byName[entity.Extends]; entity == nil {
+			return false
+		}
+	}
+	return true
+}
+
 // parsePackageDecl parses: package name.space;
 func (p *Parser) parsePackageDecl() *PackageDecl {
 	decl := &PackageDecl{Position: p.curPos()}
@@ -197,22 +378,18 @@ func (p *Parser) parseOptionDecl() *OptionDecl {
 	decl := &OptionDecl{Position: p.curPos()}
 	p.nextToken() // consume 'option'
 
-	if !p.curTokenIs(lexer.IDENT) {
-		p.curError("option name")
+	if !p.parseOptionName(decl) {
 		return decl
 	}
 
-	decl.Name = p.curToken.Literal
-	p.nextToken()
-
 	if !p.curTokenIs(lexer.EQUALS) {
 		p.curError("'='")
 		return decl
 	}
 	p.nextToken()
 
+	decl.Ident = p.curTokenIs(lexer.IDENT)
 	decl.Value = p.parseValue()
-	p.nextToken()
 
 	if p.curTokenIs(lexer.SEMICOLON) {
 		p.nextToken()
@@ -221,6 +398,55 @@ func (p *Parser) parseOptionDecl() *OptionDecl {
 	return decl
 }
 
+// parseOptionName parses a dotted option name whose parts may be
+// parenthesized extension names: go_package, (custom.opt).field.
+func (p *Parser) parseOptionName(decl *OptionDecl) bool {
+	var names []string
+	for {
+		var part OptionNamePart
+		if p.curTokenIs(lexer.LPAREN) {
+			p.nextToken() // consume '('
+			part.Extension = true
+			if !p.curTokenIs(lexer.IDENT) {
+				p.curError("option extension name")
+				return false
+			}
+			part.Name = p.curToken.Literal
+			p.nextToken()
+			for p.curTokenIs(lexer.DOT) {
+				p.nextToken() // consume '.'
+				if !p.curTokenIs(lexer.IDENT) {
+					p.curError("identifier after '.'")
+					return false
+				}
+				part.Name += "." + p.curToken.Literal
+				p.nextToken()
+			}
+			if !p.curTokenIs(lexer.RPAREN) {
+				p.curError("')'")
+				return false
+			}
+			p.nextToken() // consume ')'
+			names = append(names, "("+part.Name+")")
+		} else if p.curTokenIs(lexer.IDENT) || p.isKeywordAsIdent() {
+			part.Name = p.curToken.Literal
+			p.nextToken()
+			names = append(names, part.Name)
+		} else {
+			p.curError("option name")
+			return false
+		}
+		decl.NameParts = append(decl.NameParts, part)
+
+		if !p.curTokenIs(lexer.DOT) {
+			break
+		}
+		p.nextToken() // consume '.'
+	}
+	decl.Name = strings.Join(names, ".")
+	return true
+}
+
 // parseEnumDecl parses: enum Name { VALUE = 0; ... }
 func (p *Parser) parseEnumDecl() *EnumDecl {
 	decl := &EnumDecl{Position: p.curPos()}
@@ -234,15 +460,28 @@ func (p *Parser) parseEnumDecl() *EnumDecl {
 	decl.Name = p.curToken.Literal
 	p.nextToken()
 
+	p.parseEnumBody(decl)
+	return decl
+}
+
+// parseEnumBody parses the braced value list of an enum, top-level or inline.
+func (p *Parser) parseEnumBody(decl *EnumDecl) {
 	if !p.curTokenIs(lexer.LBRACE) {
 		p.curError("'{'")
-		return decl
+		return
 	}
 	p.nextToken()
 
 	for !p.curTokenIs(lexer.RBRACE) && !p.curTokenIs(lexer.EOF) {
-		if p.curTokenIs(lexer.IDENT) {
-			value := &EnumValue{Position: p.curPos(), Name: p.curToken.Literal}
+		if p.curTokenIs(lexer.OPTION) {
+			decl.Options = append(decl.Options, p.parseOptionDecl())
+		} else if p.curTokenIs(lexer.IDENT) || p.curTokenIs(lexer.AT) {
+			annotations := p.parseAnnotations()
+			if !p.curTokenIs(lexer.IDENT) {
+				p.curError("enum value name")
+				continue
+			}
+			value := &EnumValue{Position: p.curPos(), Annotations: annotations, Name: p.curToken.Literal}
 			p.nextToken()
 
 			if p.curTokenIs(lexer.EQUALS) {
@@ -268,11 +507,23 @@ func (p *Parser) parseEnumDecl() *EnumDecl {
 	if p.curTokenIs(lexer.RBRACE) {
 		p.nextToken()
 	}
+}
 
-	return decl
+// nameInlineEnums names the inline enums of owner's fields <Owner><Field>,
+// points the fields' types at them and queues them for ParseFile to add to
+// the file's enums.
+func (p *Parser) nameInlineEnums(owner string, fields []*FieldDecl) {
+	for _, field := range fields {
+		if field.Type == nil || field.Type.Enum == nil {
+			continue
+		}
+		field.Type.Enum.Name = owner + naming.ToPascalCase(field.Name)
+		field.Type.Name = field.Type.Enum.Name
+		p.inlineEnums = append(p.inlineEnums, field.Type.Enum)
+	}
 }
 
-// parseEntityDecl parses: entity Name { fields... queries... }
+// parseEntityDecl parses: entity Name [extends Base] { fields... queries... }
 func (p *Parser) parseEntityDecl() *EntityDecl {
 	decl := &EntityDecl{Position: p.curPos()}
 	p.nextToken() // consume 'entity'
@@ -285,6 +536,16 @@ func (p *Parser) parseEntityDecl() *EntityDecl {
 	decl.Name = p.curToken.Literal
 	p.nextToken()
 
+	if p.curTokenIs(lexer.EXTENDS) {
+		p.nextToken()
+		if !p.curTokenIs(lexer.IDENT) {
+			p.curError("base entity name")
+			return decl
+		}
+		decl.Extends = p.curToken.Literal
+		p.nextToken()
+	}
+
 	if !p.curTokenIs(lexer.LBRACE) {
 		p.curError("'{'")
 		return decl
@@ -292,21 +553,33 @@ func (p *Parser) parseEntityDecl() *EntityDecl {
 	p.nextToken()
 
 	for !p.curTokenIs(lexer.RBRACE) && !p.curTokenIs(lexer.EOF) {
+		// A field's doc comment sits above its first annotation, if any
+		doc := p.l.Doc(p.curToken.Line, p.curToken.Column)
+
 		switch {
 		case p.curTokenIs(lexer.AT):
 			// Annotated field
 			annotations := p.parseAnnotations()
-			if p.curTokenIs(lexer.IDENT) {
+			if p.isFieldStart() {
 				field := p.parseFieldDecl()
 				field.Annotations = annotations
+				field.Doc = doc
 				decl.Fields = append(decl.Fields, field)
+			} else if p.curTokenIs(lexer.QUERY) {
+				query := p.parseQueryDecl()
+				query.Annotations = annotations
+				decl.Queries = append(decl.Queries, query)
 			}
-		case p.curTokenIs(lexer.IDENT):
-			decl.Fields = append(decl.Fields, p.parseFieldDecl())
+		case p.isFieldStart():
+			field := p.parseFieldDecl()
+			field.Doc = doc
+			decl.Fields = append(decl.Fields, field)
 		case p.curTokenIs(lexer.QUERY):
 			decl.Queries = append(decl.Queries, p.parseQueryDecl())
+		case p.isReservedStart():
+			decl.Reserved = append(decl.Reserved, p.parseReservedDecl())
 		default:
-			p.curError("field, query, or '}'")
+			p.curError("field, query, reserved, or '}'")
 			p.nextToken()
 		}
 	}
@@ -315,6 +588,102 @@ func (p *Parser) parseEntityDecl() *EntityDecl {
 		p.nextToken()
 	}
 
+	p.nameInlineEnums(decl.Name, decl.Fields)
+	return decl
+}
+
+// parseMessageDecl parses: message Name { field: Type; reserved ...; ... }
+func (p *Parser) parseMessageDecl() *MessageDecl {
+	decl := &MessageDecl{Position: p.curPos()}
+	p.nextToken() // consume 'message'
+
+	if !p.curTokenIs(lexer.IDENT) {
+		p.curError("message name")
+		return decl
+	}
+
+	decl.Name = p.curToken.Literal
+	p.nextToken()
+
+	if !p.curTokenIs(lexer.LBRACE) {
+		p.curError("'{'")
+		return decl
+	}
+	p.nextToken()
+
+	for !p.curTokenIs(lexer.RBRACE) && !p.curTokenIs(lexer.EOF) {
+		doc := p.l.Doc(p.curToken.Line, p.curToken.Column)
+
+		if p.isReservedStart() {
+			decl.Reserved = append(decl.Reserved, p.parseReservedDecl())
+			continue
+		}
+
+		var annotations []*Annotation
+		if p.curTokenIs(lexer.AT) {
+			annotations = p.parseAnnotations()
+		}
+		if !p.isFieldStart() {
+			p.curError("field, reserved, or '}'")
+			p.nextToken()
+			continue
+		}
+		field := p.parseFieldDecl()
+		field.Annotations = annotations
+		field.Doc = doc
+		decl.Fields = append(decl.Fields, field)
+	}
+
+	if p.curTokenIs(lexer.RBRACE) {
+		p.nextToken()
+	}
+
+	p.nameInlineEnums(decl.Name, decl.Fields)
+	return decl
+}
+
+// parseReservedDecl parses: reserved 3, 5 to 9, 20 to max; or reserved "name", ...;
+func (p *Parser) parseReservedDecl() *ReservedDecl {
+	decl := &ReservedDecl{Position: p.curPos()}
+	p.nextToken() // consume 'reserved'
+
+	for {
+		switch {
+		case p.curTokenIs(lexer.STRING):
+			decl.Names = append(decl.Names, p.curToken.Literal)
+			p.nextToken()
+		case p.curTokenIs(lexer.INT):
+			start, _ := strconv.ParseInt(p.curToken.Literal, 10, 64)
+			r := ReservedRange{Start: start, End: start}
+			p.nextToken()
+			if p.curTokenIs(lexer.IDENT) && p.curToken.Literal == "to" {
+				p.nextToken()
+				switch {
+				case p.curTokenIs(lexer.INT):
+					r.End, _ = strconv.ParseInt(p.curToken.Literal, 10, 64)
+				case p.curTokenIs(lexer.IDENT) && p.curToken.Literal == "max":
+					r.End = ReservedMax
+				default:
+					p.curError("field number or max")
+				}
+				p.nextToken()
+			}
+			decl.Ranges = append(decl.Ranges, r)
+		default:
+			p.curError("field number or name")
+			p.nextToken()
+			return decl
+		}
+
+		if !p.curTokenIs(lexer.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if p.curTokenIs(lexer.SEMICOLON) {
+		p.nextToken()
+	}
 	return decl
 }
 
@@ -334,7 +703,8 @@ func (p *Parser) parseAnnotation() *Annotation {
 	ann := &Annotation{Position: p.curPos()}
 	p.nextToken() // consume '@'
 
-	if !p.curTokenIs(lexer.IDENT) {
+	// Keywords name annotations too, as in @returns(one)
+	if !p.curTokenIs(lexer.IDENT) && lexer.LookupIdent(p.curToken.Literal) == lexer.IDENT {
 		p.curError("annotation name")
 		return ann
 	}
@@ -369,8 +739,9 @@ func (p *Parser) parseAnnotation() *Annotation {
 func (p *Parser) parseAnnotationArg() AnnotationArg {
 	arg := AnnotationArg{Position: p.curPos()}
 
-	// Check for named argument: name = value or name: value
-	if p.curTokenIs(lexer.IDENT) && (p.peekTokenIs(lexer.EQUALS) || p.peekTokenIs(lexer.COLON)) {
+	// Check for named argument: name = value or name: value. Query keywords
+	// may name arguments, as in @view(query: "Entity.query")
+	if (p.curTokenIs(lexer.IDENT) || p.isKeywordAsIdent()) && (p.peekTokenIs(lexer.EQUALS) || p.peekTokenIs(lexer.COLON)) {
 		arg.Name = p.curToken.Literal
 		p.nextToken() // consume name
 		p.nextToken() // consume = or :
@@ -380,36 +751,26 @@ func (p *Parser) parseAnnotationArg() AnnotationArg {
 	return arg
 }
 
-// parseAnnotationValue parses an annotation value.
+// parseAnnotationValue parses an annotation value: a literal, a dotted
+// identifier, a list, or an object.
 func (p *Parser) parseAnnotationValue() interface{} {
 	switch p.curToken.Type {
-	case lexer.STRING:
-		val := p.curToken.Literal
-		p.nextToken()
-		return val
-	case lexer.INT:
-		val, _ := strconv.ParseInt(p.curToken.Literal, 10, 64)
-		p.nextToken()
-		return val
-	case lexer.FLOAT:
-		val, _ := strconv.ParseFloat(p.curToken.Literal, 64)
-		p.nextToken()
-		return val
-	case lexer.TRUE:
-		p.nextToken()
-		return true
-	case lexer.FALSE:
-		p.nextToken()
-		return false
 	case lexer.IDENT:
 		val := p.curToken.Literal
 		p.nextToken()
+		// Dotted references such as @fk(Entity.field)
+		for p.curTokenIs(lexer.DOT) && p.peekTokenIs(lexer.IDENT) {
+			p.nextToken()
+			val += "." + p.curToken.Literal
+			p.nextToken()
+		}
 		return val
 	case lexer.LBRACKET:
 		return p.parseAnnotationList()
+	case lexer.LBRACE:
+		return p.parseAnnotationObject()
 	default:
-		p.nextToken()
-		return nil
+		return p.parseValue()
 	}
 }
 
@@ -432,11 +793,45 @@ func (p *Parser) parseAnnotationList() []interface{} {
 	return values
 }
 
+// parseAnnotationObject parses: { key: value, key = value, ... }
+func (p *Parser) parseAnnotationObject() map[string]interface{} {
+	p.nextToken() // consume '{'
+	obj := make(map[string]interface{})
+
+	for !p.curTokenIs(lexer.RBRACE) && !p.curTokenIs(lexer.EOF) {
+		if !p.curTokenIs(lexer.IDENT) && !p.curTokenIs(lexer.STRING) && !p.isKeywordAsIdent() {
+			p.curError("object key")
+			p.nextToken()
+			continue
+		}
+		key := p.curToken.Literal
+		p.nextToken()
+
+		if !p.curTokenIs(lexer.COLON) && !p.curTokenIs(lexer.EQUALS) {
+			p.curError("':'")
+			continue
+		}
+		p.nextToken()
+
+		obj[key] = p.parseAnnotationValue()
+		if p.curTokenIs(lexer.COMMA) {
+			p.nextToken()
+		}
+	}
+
+	if p.curTokenIs(lexer.RBRACE) {
+		p.nextToken()
+	}
+
+	return obj
+}
+
 // parseFieldDecl parses: name: Type;
 func (p *Parser) parseFieldDecl() *FieldDecl {
 	field := &FieldDecl{Position: p.curPos()}
 
-	if !p.curTokenIs(lexer.IDENT) {
+	// Allow keywords to be used as field names (e.g., "limit")
+	if !p.curTokenIs(lexer.IDENT) && !p.isKeywordAsIdent() {
 		p.curError("field name")
 		return field
 	}
@@ -459,7 +854,8 @@ func (p *Parser) parseFieldDecl() *FieldDecl {
 	return field
 }
 
-// parseTypeRef parses a type reference like string, int32?, etc.
+// parseTypeRef parses a type reference like string, int32?, etc. or an
+// inline enum { A = 0; B = 1; }.
 func (p *Parser) parseTypeRef() *TypeRef {
 	typeRef := &TypeRef{Position: p.curPos()}
 
@@ -483,12 +879,19 @@ func (p *Parser) parseTypeRef() *TypeRef {
 		typeRef.Name = "timestamp"
 	case lexer.IDENT:
 		typeRef.Name = p.curToken.Literal
+	case lexer.ENUM:
+		// Inline enum; the enclosing declaration names it
+		typeRef.Enum = &EnumDecl{Position: p.curPos(), Inline: true}
+		p.nextToken()
+		p.parseEnumBody(typeRef.Enum)
 	default:
 		p.curError("type name")
 		return typeRef
 	}
 
-	p.nextToken()
+	if typeRef.Enum == nil {
+		p.nextToken()
+	}
 
 	// Check for optional marker
 	if p.curTokenIs(lexer.QUESTION) {
@@ -499,7 +902,7 @@ func (p *Parser) parseTypeRef() *TypeRef {
 	return typeRef
 }
 
-// parseQueryDecl parses: query name(params) { where... order_by... limit... }
+// parseQueryDecl parses: query name(params) { select... where... group_by... having... order_by... limit... }
 func (p *Parser) parseQueryDecl() *QueryDecl {
 	query := &QueryDecl{Position: p.curPos()}
 	p.nextToken() // consume 'query'
@@ -533,6 +936,7 @@ func (p *Parser) parseQueryDecl() *QueryDecl {
 	if p.curTokenIs(lexer.RPAREN) {
 		p.nextToken()
 	}
+	resolveParamDefaults(query.Params)
 
 	// Parse body
 	if !p.curTokenIs(lexer.LBRACE) {
@@ -543,9 +947,22 @@ func (p *Parser) parseQueryDecl() *QueryDecl {
 
 	for !p.curTokenIs(lexer.RBRACE) && !p.curTokenIs(lexer.EOF) {
 		switch p.curToken.Type {
+		case lexer.SELECT:
+			p.nextToken()
+			if p.curTokenIs(lexer.DISTINCT) {
+				query.Distinct = true
+				p.nextToken()
+			}
+			query.Select = p.parseSelect()
 		case lexer.WHERE:
 			p.nextToken()
 			query.Where = p.parseExpression()
+		case lexer.GROUP_BY:
+			p.nextToken()
+			query.GroupBy = p.parseGroupBy()
+		case lexer.HAVING:
+			p.nextToken()
+			query.Having = p.parseExpression()
 		case lexer.ORDER_BY:
 			p.nextToken()
 			query.OrderBy = p.parseOrderBy()
@@ -553,7 +970,7 @@ func (p *Parser) parseQueryDecl() *QueryDecl {
 			p.nextToken()
 			query.Limit = p.parsePrimaryExpr()
 		default:
-			p.curError("where, order_by, limit, or '}'")
+			p.curError("select, where, group_by, having, order_by, limit, or '}'")
 			p.nextToken()
 		}
 	}
@@ -565,9 +982,10 @@ func (p *Parser) parseQueryDecl() *QueryDecl {
 	return query
 }
 
-// parseQueryParam parses: name: Type = default
+// parseQueryParam parses: { annotation } name[?]: Type = default
 func (p *Parser) parseQueryParam() *QueryParam {
-	param := &QueryParam{Position: p.curPos()}
+	annotations := p.parseAnnotations()
+	param := &QueryParam{Position: p.curPos(), Annotations: annotations}
 
 	// Allow keywords to be used as parameter names (e.g., "limit")
 	if !p.curTokenIs(lexer.IDENT) && !p.isKeywordAsIdent() {
@@ -578,6 +996,11 @@ func (p *Parser) parseQueryParam() *QueryParam {
 	param.Name = p.curToken.Literal
 	p.nextToken()
 
+	if p.curTokenIs(lexer.QUESTION) {
+		param.Optional = true
+		p.nextToken()
+	}
+
 	if !p.curTokenIs(lexer.COLON) {
 		p.curError("':'")
 		return param
@@ -585,17 +1008,123 @@ func (p *Parser) parseQueryParam() *QueryParam {
 	p.nextToken()
 
 	param.Type = p.parseTypeRef()
+	if param.Type.Enum != nil {
+		p.addError(param.Type.Position, "inline enum is only allowed as a field type")
+	}
 
-	// Optional default value
+	// Optional default: a literal value, or an expression such as NOW()
+	// evaluated when the caller passes nothing
 	if p.curTokenIs(lexer.EQUALS) {
 		p.nextToken()
-		param.Default = p.parseValue()
-		p.nextToken()
+		if p.curTokenIs(lexer.LBRACKET) || p.curTokenIs(lexer.PLUS) {
+			param.Default = p.parseValue()
+		} else {
+			param.DefaultExpr = p.parseExpression()
+			if val, ok := literalValue(param.DefaultExpr); ok {
+				param.Default, param.DefaultExpr = val, nil
+			}
+		}
 	}
 
 	return param
 }
 
+// literalValue returns the value of a literal or negated number literal
+// expression.
+func literalValue(expr Expr) (interface{}, bool) {
+	switch e := expr.(type) {
+	case *LiteralExpr:
+		return e.Value, e.Value != nil
+	case *UnaryExpr:
+		if lit, ok := e.Operand.(*LiteralExpr); ok && e.Op == "-" {
+			switch v := lit.Value.(type) {
+			case int64:
+				return -v, true
+			case float64:
+				return -v, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// resolveParamDefaults turns a default that is a bare name into a literal
+// value, such as an enum value, unless it names one of params.
+func resolveParamDefaults(params []*QueryParam) {
+	names := make(map[string]bool, len(params))
+	for _, param := range params {
+		names[param.Name] = true
+	}
+	for _, param := range params {
+		if id, ok := param.DefaultExpr.(*IdentExpr); ok && !names[id.Name] {
+			param.Default, param.DefaultExpr = id.Name, nil
+		}
+	}
+}
+
+// parseSelect parses: field, AGG(field), COUNT(*)
+// Aggregates are kept in their source form, e.g. "COUNT(id)". As in
+// expressions, a field may be named with a keyword.
+func (p *Parser) parseSelect() []string {
+	var items []string
+
+	for p.curTokenIs(lexer.IDENT) || p.isKeywordAsIdent() {
+		item := p.curToken.Literal
+		p.nextToken()
+
+		if p.curTokenIs(lexer.LPAREN) {
+			p.nextToken()
+			arg := p.curToken.Literal
+			if !p.curTokenIs(lexer.IDENT) && !p.isKeywordAsIdent() && !p.curTokenIs(lexer.STAR) {
+				p.curError("field name or '*'")
+			}
+			p.nextToken()
+			if !p.curTokenIs(lexer.RPAREN) {
+				p.curError("')'")
+			} else {
+				p.nextToken()
+			}
+			item = fmt.Sprintf("%s(%s)", item, arg)
+		}
+
+		items = append(items, item)
+
+		if p.curTokenIs(lexer.COMMA) {
+			p.nextToken()
+		} else {
+			break
+		}
+	}
+
+	if len(items) == 0 {
+		p.curError("field name")
+	}
+
+	return items
+}
+
+// parseGroupBy parses: field, field2
+func (p *Parser) parseGroupBy() []string {
+	var fields []string
+
+	for p.curTokenIs(lexer.IDENT) || p.isKeywordAsIdent() {
+		fields = append(fields, p.curToken.Literal)
+		p.nextToken()
+
+		if p.curTokenIs(lexer.COMMA) {
+			p.nextToken()
+		} else {
+			break
+		}
+	}
+
+	if len(fields) == 0 {
+		p.curError("field name")
+	}
+
+	return fields
+}
+
 // parseOrderBy parses: field ASC, field2 DESC
 func (p *Parser) parseOrderBy() []*OrderByField {
 	var fields []*OrderByField
@@ -603,7 +1132,7 @@ func (p *Parser) parseOrderBy() []*OrderByField {
 	for {
 		field := &OrderByField{Position: p.curPos()}
 
-		if !p.curTokenIs(lexer.IDENT) {
+		if !p.curTokenIs(lexer.IDENT) && !p.isKeywordAsIdent() {
 			break
 		}
 
@@ -618,6 +1147,20 @@ func (p *Parser) parseOrderBy() []*OrderByField {
 			p.nextToken()
 		}
 
+		if p.curTokenIs(lexer.NULLS) {
+			p.nextToken()
+			switch {
+			case p.curTokenIs(lexer.FIRST):
+				field.Nulls = NullsFirst
+				p.nextToken()
+			case p.curTokenIs(lexer.LAST):
+				field.Nulls = NullsLast
+				p.nextToken()
+			default:
+				p.curError("FIRST or LAST")
+			}
+		}
+
 		fields = append(fields, field)
 
 		if p.curTokenIs(lexer.COMMA) {
@@ -677,7 +1220,7 @@ func (p *Parser) parseCompareExpr() Expr {
 		right := p.parseAddExpr()
 		return &BinaryExpr{Position: pos, Left: left, Op: op, Right: right}
 
-	case lexer.LIKE:
+	case lexer.LIKE, lexer.ILIKE:
 		op := p.curToken.Literal
 		pos := p.curPos()
 		p.nextToken()
@@ -739,7 +1282,18 @@ func (p *Parser) parseMulExpr() Expr {
 }
 
 // parseUnaryExpr parses: NOT expr or -expr
+// Every level of expression nesting passes through here, so this is where
+// the MaxExprDepth limit is enforced.
 func (p *Parser) parseUnaryExpr() Expr {
+	p.exprDepth++
+	defer func() { p.exprDepth-- }()
+	if p.exprDepth > p.MaxExprDepth {
+		pos := p.curPos()
+		p.addError(pos, "expression nested too deeply (maximum depth is %d)", p.MaxExprDepth)
+		p.skipOperand()
+		return &LiteralExpr{Position: pos, Value: nil}
+	}
+
 	if p.curTokenIs(lexer.NOT) {
 		pos := p.curPos()
 		p.nextToken()
@@ -757,14 +1311,43 @@ func (p *Parser) parseUnaryExpr() Expr {
 	return p.parsePrimaryExpr()
 }
 
+// skipOperand skips the tokens of an operand without recursing: any prefix
+// operators followed by a balanced parenthesized group or a single token.
+func (p *Parser) skipOperand() {
+	for p.curTokenIs(lexer.NOT) || p.curTokenIs(lexer.MINUS) {
+		p.nextToken()
+	}
+	if !p.curTokenIs(lexer.LPAREN) {
+		p.nextToken()
+		return
+	}
+	depth := 0
+	for !p.curTokenIs(lexer.EOF) {
+		switch p.curToken.Type {
+		case lexer.LPAREN:
+			depth++
+		case lexer.RPAREN:
+			depth--
+		}
+		p.nextToken()
+		if depth == 0 {
+			return
+		}
+	}
+}
+
 // parsePrimaryExpr parses primary expressions.
 func (p *Parser) parsePrimaryExpr() Expr {
+	if p.curTokenIs(lexer.CASE) {
+		return p.parseCaseExpr()
+	}
+
 	// Handle keywords that can be used as identifiers in expressions
 	if p.isKeywordAsIdent() {
 		name := p.curToken.Literal
 		pos := p.curPos()
 		p.nextToken()
-		return &IdentExpr{Position: pos, Name: name}
+		return p.parseFieldAccess(&IdentExpr{Position: pos, Name: name})
 	}
 
 	switch p.curToken.Type {
@@ -778,7 +1361,7 @@ func (p *Parser) parsePrimaryExpr() Expr {
 			return p.parseCallExpr(name, pos)
 		}
 
-		return &IdentExpr{Position: pos, Name: name}
+		return p.parseFieldAccess(&IdentExpr{Position: pos, Name: name})
 
 	case lexer.INT:
 		val, _ := strconv.ParseInt(p.curToken.Literal, 10, 64)
@@ -793,10 +1376,8 @@ func (p *Parser) parsePrimaryExpr() Expr {
 		return &LiteralExpr{Position: pos, Value: val}
 
 	case lexer.STRING:
-		val := p.curToken.Literal
 		pos := p.curPos()
-		p.nextToken()
-		return &LiteralExpr{Position: pos, Value: val}
+		return &LiteralExpr{Position: pos, Value: p.parseStringLiteral()}
 
 	case lexer.TRUE:
 		pos := p.curPos()
@@ -812,10 +1393,25 @@ func (p *Parser) parsePrimaryExpr() Expr {
 		pos := p.curPos()
 		p.nextToken()
 		inner := p.parseExpression()
+		if !p.curTokenIs(lexer.COMMA) {
+			if p.curTokenIs(lexer.RPAREN) {
+				p.nextToken()
+			}
+			return &ParenExpr{Position: pos, Inner: inner}
+		}
+
+		// A comma makes the parentheses a row value
+		tuple := &TupleExpr{Position: pos, Elements: []Expr{inner}}
+		for p.curTokenIs(lexer.COMMA) {
+			p.nextToken()
+			tuple.Elements = append(tuple.Elements, p.parseExpression())
+		}
 		if p.curTokenIs(lexer.RPAREN) {
 			p.nextToken()
+		} else {
+			p.curError("')'")
 		}
-		return &ParenExpr{Position: pos, Inner: inner}
+		return tuple
 
 	default:
 		pos := p.curPos()
@@ -825,11 +1421,67 @@ func (p *Parser) parsePrimaryExpr() Expr {
 	}
 }
 
+// parseFieldAccess parses any .field suffixes following base, as in
+// event.calendar.name.
+func (p *Parser) parseFieldAccess(base Expr) Expr {
+	for p.curTokenIs(lexer.DOT) {
+		p.nextToken() // consume '.'
+		if !p.curTokenIs(lexer.IDENT) && !p.isKeywordAsIdent() {
+			p.curError("field name")
+			return base
+		}
+		base = &FieldAccessExpr{Position: base.Pos(), Base: base, Field: p.curToken.Literal}
+		p.nextToken()
+	}
+	return base
+}
+
+// parseCaseExpr parses: CASE WHEN cond THEN expr { WHEN cond THEN expr } [ ELSE expr ] END
+func (p *Parser) parseCaseExpr() Expr {
+	c := &CaseExpr{Position: p.curPos()}
+	p.nextToken() // consume CASE
+
+	for p.curTokenIs(lexer.WHEN) {
+		when := &CaseWhen{Position: p.curPos()}
+		p.nextToken()
+		when.Cond = p.parseExpression()
+		if !p.curTokenIs(lexer.THEN) {
+			p.curError("THEN")
+			return c
+		}
+		p.nextToken()
+		when.Result = p.parseExpression()
+		c.Whens = append(c.Whens, when)
+	}
+	if len(c.Whens) == 0 {
+		p.curError("WHEN")
+		return c
+	}
+
+	if p.curTokenIs(lexer.ELSE) {
+		p.nextToken()
+		c.Else = p.parseExpression()
+	}
+
+	if !p.curTokenIs(lexer.END) {
+		p.curError("END")
+		return c
+	}
+	p.nextToken()
+	return c
+}
+
 // parseCallExpr parses: name(arg, arg, ...)
 func (p *Parser) parseCallExpr(name string, pos lexer.Position) Expr {
 	call := &CallExpr{Position: pos, Name: name}
 	p.nextToken() // consume '('
 
+	// COUNT(*)
+	if p.curTokenIs(lexer.STAR) && p.peekTokenIs(lexer.RPAREN) {
+		call.Args = append(call.Args, &IdentExpr{Position: p.curPos(), Name: "*"})
+		p.nextToken()
+	}
+
 	for !p.curTokenIs(lexer.RPAREN) && !p.curTokenIs(lexer.EOF) {
 		arg := p.parseExpression()
 		call.Args = append(call.Args, arg)
@@ -868,8 +1520,15 @@ func (p *Parser) parseServiceDecl() *ServiceDecl {
 	p.nextToken()
 
 	for !p.curTokenIs(lexer.RBRACE) && !p.curTokenIs(lexer.EOF) {
-		if p.curTokenIs(lexer.RPC) {
-			svc.Methods = append(svc.Methods, p.parseRpcDecl())
+		if p.curTokenIs(lexer.RPC) || p.curTokenIs(lexer.AT) {
+			annotations := p.parseAnnotations()
+			if !p.curTokenIs(lexer.RPC) {
+				p.curError("rpc")
+				continue
+			}
+			rpc := p.parseRpcDecl()
+			rpc.Annotations = annotations
+			svc.Methods = append(svc.Methods, rpc)
 		} else {
 			p.curError("rpc or '}'")
 			p.nextToken()
@@ -960,44 +1619,119 @@ func (p *Parser) parseRpcType() *RpcType {
 	return rpcType
 }
 
-// parseValue parses a literal value.
+// parseValue parses a literal value, a signed number, or a list of values
+// and advances past it.
 func (p *Parser) parseValue() interface{} {
 	switch p.curToken.Type {
 	case lexer.STRING:
-		return p.curToken.Literal
+		return p.parseStringLiteral()
 	case lexer.INT:
 		val, _ := strconv.ParseInt(p.curToken.Literal, 10, 64)
+		p.nextToken()
 		return val
 	case lexer.FLOAT:
 		val, _ := strconv.ParseFloat(p.curToken.Literal, 64)
+		p.nextToken()
+		return val
+	case lexer.MINUS, lexer.PLUS:
+		negate := p.curTokenIs(lexer.MINUS)
+		p.nextToken()
+		if !p.curTokenIs(lexer.INT) && !p.curTokenIs(lexer.FLOAT) {
+			p.curError("number")
+			return nil
+		}
+		val := p.parseValue()
+		if negate {
+			switch v := val.(type) {
+			case int64:
+				return -v
+			case float64:
+				return -v
+			}
+		}
 		return val
 	case lexer.TRUE:
+		p.nextToken()
 		return true
 	case lexer.FALSE:
+		p.nextToken()
 		return false
 	case lexer.IDENT:
-		return p.curToken.Literal
+		val := p.curToken.Literal
+		p.nextToken()
+		return val
+	case lexer.LBRACKET:
+		return p.parseValueList()
 	default:
+		p.curError("value")
+		p.nextToken()
 		return nil
 	}
 }
 
+// parseStringLiteral parses one or more adjacent string literals, which are
+// concatenated as in C: "^a" "b$" is "^ab$". Each piece keeps its own token,
+// so positions of later tokens are unaffected.
+func (p *Parser) parseStringLiteral() string {
+	var sb strings.Builder
+	for p.curTokenIs(lexer.STRING) {
+		sb.WriteString(p.curToken.Literal)
+		p.nextToken()
+	}
+	return sb.String()
+}
+
+// parseValueList parses: [value, value, ...]
+func (p *Parser) parseValueList() []interface{} {
+	p.nextToken() // consume '['
+	var values []interface{}
+
+	for !p.curTokenIs(lexer.RBRACKET) && !p.curTokenIs(lexer.EOF) {
+		values = append(values, p.parseValue())
+		if p.curTokenIs(lexer.COMMA) {
+			p.nextToken()
+		}
+	}
+
+	if p.curTokenIs(lexer.RBRACKET) {
+		p.nextToken()
+	}
+
+	return values
+}
+
 // Parse is a convenience function to parse a string.
+// On failure the returned error is an ErrorList.
 func Parse(input string) (*File, error) {
 	p := NewFromString(input)
 	file := p.ParseFile()
 	if len(p.errors) > 0 {
-		return nil, fmt.Errorf("parse errors: %v", p.errors)
+		return nil, ErrorList(p.errors)
 	}
 	return file, nil
 }
 
+// ParseExpr parses input as a single expression, such as the condition of a
+// @check annotation. On failure the returned error is an ErrorList.
+func ParseExpr(input string) (Expr, error) {
+	p := NewFromString(input)
+	expr := p.parseExpression()
+	if len(p.errors) == 0 && !p.curTokenIs(lexer.EOF) {
+		p.addError(p.curPos(), "unexpected %s after expression", p.curToken.Literal)
+	}
+	if len(p.errors) > 0 {
+		return nil, ErrorList(p.errors)
+	}
+	return expr, nil
+}
+
 // ParseFile is a convenience function to parse a file.
+// On failure the returned error is an ErrorList.
 func ParseFile(input, filename string) (*File, error) {
 	p := NewFromStringWithFilename(input, filename)
 	file := p.ParseFile()
 	if len(p.errors) > 0 {
-		return nil, fmt.Errorf("parse errors: %v", p.errors)
+		return nil, ErrorList(p.errors)
 	}
 	return file, nil
 }
@@ -1,6 +1,8 @@
 package parser
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -212,6 +214,231 @@ entity Item {
 	}
 }
 
+func TestParseOptionalQueryParam(t *testing.T) {
+	input := `
+entity Event {
+    @pk id: string;
+    start_date: timestamp;
+
+    query upcoming(after?: timestamp, n: int32 = 10) {
+        where start_date > after
+        limit n
+    }
+}
+`
+
+	file, err := Parse(input)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	query := file.Entities[0].Queries[0]
+	after, n := query.Params[0], query.Params[1]
+	if after.Name != "after" || !after.Optional || after.Type.Name != "timestamp" {
+		t.Errorf("Expected optional after: timestamp, got %s", after)
+	}
+	if !after.Nullable() || after.Type.Optional {
+		t.Errorf("Expected after to be nullable by name only, got %s", after)
+	}
+	if n.Optional || n.Nullable() {
+		t.Errorf("Expected n not to be optional, got %s", n)
+	}
+	if s := query.String(); !strings.Contains(s, "upcoming(after?: timestamp, n: int32 = 10)") {
+		t.Errorf("Expected round trip, got %s", s)
+	}
+}
+
+func TestParseQueryHaving(t *testing.T) {
+	input := `
+entity Event {
+    @pk id: string;
+    calendar_name: string;
+
+    query busyCalendars(min: int32) {
+        select calendar_name, COUNT(*)
+        group_by calendar_name
+        having COUNT(id) > min
+        order_by calendar_name
+    }
+}
+`
+
+	file, err := Parse(input)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	query := file.Entities[0].Queries[0]
+	if query.Having == nil {
+		t.Fatal("Expected a HAVING expression")
+	}
+	if s := query.Having.String(); s != "COUNT(id) > min" {
+		t.Errorf("Having = %s, want COUNT(id) > min", s)
+	}
+	if len(query.OrderBy) != 1 {
+		t.Errorf("Expected order_by after having, got %v", query.OrderBy)
+	}
+	if got := ReferencedParams(query); len(got) != 1 || got[0] != "min" {
+		t.Errorf("ReferencedParams = %v, want [min]", got)
+	}
+
+	expr, err := ParseExpr("COUNT(*) >= 2")
+	if err != nil {
+		t.Fatalf("ParseExpr error: %v", err)
+	}
+	if s := expr.String(); s != "COUNT(*) >= 2" {
+		t.Errorf("Expected COUNT(*) round trip, got %s", s)
+	}
+}
+
+func TestParseQueryDistinct(t *testing.T) {
+	input := `
+entity Event {
+    @pk id: string;
+    calendar_name: string;
+    distinct: bool;
+
+    query calendars() {
+        select distinct calendar_name
+    }
+
+    query all() {
+        select calendar_name
+    }
+}
+`
+
+	file, err := Parse(input)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	calendars := file.Entities[0].Queries[0]
+	if !calendars.Distinct || len(calendars.Select) != 1 || calendars.Select[0] != "calendar_name" {
+		t.Errorf("Expected select distinct calendar_name, got %s", calendars)
+	}
+	if s := calendars.String(); s != "query calendars() { select distinct calendar_name }" {
+		t.Errorf("Expected round trip, got %s", s)
+	}
+	if file.Entities[0].Queries[1].Distinct {
+		t.Error("Expected a plain select not to be distinct")
+	}
+	if f := file.Entities[0].Fields[2]; f.Name != "distinct" {
+		t.Errorf("Expected keyword field distinct, got %s", f.Name)
+	}
+
+	if _, err := Parse(`entity E { @pk id: string; query q() { select distinct } }`); err == nil {
+		t.Error("Expected an error for distinct without a select list")
+	}
+}
+
+func TestParseQueryGroupBy(t *testing.T) {
+	input := `
+package test;
+
+entity Event {
+    @pk id: string;
+    calendar_name: string;
+    location: string?;
+
+    query countsByCalendar() {
+        where location IS NOT NULL
+        group_by calendar_name, location
+        order_by calendar_name ASC
+    }
+}
+`
+
+	file, err := Parse(input)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	query := file.Entities[0].Queries[0]
+	if len(query.GroupBy) != 2 || query.GroupBy[0] != "calendar_name" || query.GroupBy[1] != "location" {
+		t.Errorf("Expected GROUP BY [calendar_name location], got %v", query.GroupBy)
+	}
+	if query.Where == nil || len(query.OrderBy) != 1 {
+		t.Errorf("Expected WHERE and ORDER BY alongside GROUP BY")
+	}
+}
+
+func TestParseQuerySelect(t *testing.T) {
+	input := `
+package test;
+
+entity Event {
+    @pk id: string;
+    title: string;
+    calendar_name: string;
+
+    query titles() {
+        select id, title
+    }
+
+    query countsByCalendar() {
+        select calendar_name, COUNT(id), COUNT(*)
+        group_by calendar_name
+    }
+}
+`
+
+	file, err := Parse(input)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	titles := file.Entities[0].Queries[0]
+	if len(titles.Select) != 2 || titles.Select[0] != "id" || titles.Select[1] != "title" {
+		t.Errorf("Expected SELECT [id title], got %v", titles.Select)
+	}
+
+	counts := file.Entities[0].Queries[1]
+	want := []string{"calendar_name", "COUNT(id)", "COUNT(*)"}
+	if len(counts.Select) != len(want) {
+		t.Fatalf("Expected SELECT %v, got %v", want, counts.Select)
+	}
+	for i := range want {
+		if counts.Select[i] != want[i] {
+			t.Errorf("Select[%d] = %q, want %q", i, counts.Select[i], want[i])
+		}
+	}
+
+	if fn, field := SplitSelectItem("COUNT(id)"); fn != "COUNT" || field != "id" {
+		t.Errorf("SplitSelectItem(COUNT(id)) = %q, %q", fn, field)
+	}
+}
+
+func TestReferencedParams(t *testing.T) {
+	input := `
+package test;
+
+entity Item {
+    @pk id: string;
+    title: string;
+    notes: string?;
+    priority: int32;
+
+    query search(unused: string, term: string, max: int32) {
+        where title LIKE term OR notes LIKE term AND priority > 0
+        order_by title ASC
+        limit max
+    }
+}
+`
+
+	file, err := Parse(input)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	got := ReferencedParams(file.Entities[0].Queries[0])
+	want := []string{"term", "max"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("ReferencedParams = %v, want %v", got, want)
+	}
+}
+
 func TestParseAnnotationArguments(t *testing.T) {
 	input := `
 package test;
@@ -265,6 +492,59 @@ entity Item {
 	}
 }
 
+func TestParseAnnotationObjectValues(t *testing.T) {
+	input := `
+package test;
+
+@index(fields: ["a", "b"], options: { unique: true, where: { column: "a", min: 1 } })
+@partition(rules: [{ key: "a", buckets: 4 }, { key: "b", buckets: 8 }])
+entity Item {
+    @pk id: string;
+}
+`
+
+	file, err := Parse(input)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	entity := file.Entities[0]
+
+	// Nested object argument
+	index := entity.GetAnnotation("index")
+	if index == nil || len(index.Args) != 2 {
+		t.Fatalf("Expected @index with 2 args, got %+v", index)
+	}
+	options, ok := index.Args[1].Value.(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected object value for options, got %T", index.Args[1].Value)
+	}
+	if options["unique"] != true {
+		t.Errorf("Expected unique: true, got %v", options["unique"])
+	}
+	where, ok := options["where"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected nested object for where, got %T", options["where"])
+	}
+	if where["column"] != "a" || where["min"] != int64(1) {
+		t.Errorf("Unexpected nested object: %v", where)
+	}
+
+	// List of objects
+	partition := entity.GetAnnotation("partition")
+	if partition == nil || len(partition.Args) != 1 {
+		t.Fatalf("Expected @partition with 1 arg, got %+v", partition)
+	}
+	rules, ok := partition.Args[0].Value.([]interface{})
+	if !ok || len(rules) != 2 {
+		t.Fatalf("Expected list of 2 objects, got %v", partition.Args[0].Value)
+	}
+	second, ok := rules[1].(map[string]interface{})
+	if !ok || second["key"] != "b" || second["buckets"] != int64(8) {
+		t.Errorf("Unexpected second rule: %v", rules[1])
+	}
+}
+
 func TestParseImports(t *testing.T) {
 	input := `
 package acos;
@@ -293,3 +573,1088 @@ entity Test {
 		t.Errorf("Expected import 'other/types.dataproto', got '%s'", file.Imports[1].Path)
 	}
 }
+
+func TestParseErrorPositions(t *testing.T) {
+	input := `package test;
+
+entity Item {
+    @pk id string;
+}
+`
+
+	_, err := ParseFile(input, "item.dataproto")
+	if err == nil {
+		t.Fatal("Expected parse error")
+	}
+
+	list, ok := err.(ErrorList)
+	if !ok {
+		t.Fatalf("Expected ErrorList, got %T", err)
+	}
+
+	first := list[0]
+	if first.Position.Line != 4 || first.Position.Column != 12 {
+		t.Errorf("Expected error at 4:12, got %d:%d", first.Position.Line, first.Position.Column)
+	}
+	if first.Position.Filename != "item.dataproto" {
+		t.Errorf("Expected filename 'item.dataproto', got '%s'", first.Position.Filename)
+	}
+	if first.Message != "expected ':', got string" {
+		t.Errorf("Unexpected message: %s", first.Message)
+	}
+
+	p := NewFromString(input)
+	p.ParseFile()
+	if got := p.Errors()[0]; got != "line 4:12: expected ':', got string" {
+		t.Errorf("Unexpected Errors() string: %s", got)
+	}
+	if len(p.DetailedErrors()) != len(p.Errors()) {
+		t.Errorf("Expected DetailedErrors and Errors to have the same length")
+	}
+}
+
+func TestFieldRange(t *testing.T) {
+	file, err := Parse(`
+entity Item {
+    @range(1, 10) quantity: int32;
+    @range(min: 0.5, max: 2) ratio: double;
+    note: string;
+}
+`)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	fields := file.Entities[0].Fields
+	if min, max, ok := fields[0].Range(); !ok || min != 1 || max != 10 {
+		t.Errorf("Expected range 1..10, got %v..%v (ok=%v)", min, max, ok)
+	}
+	if min, max, ok := fields[1].Range(); !ok || min != 0.5 || max != 2 {
+		t.Errorf("Expected range 0.5..2, got %v..%v (ok=%v)", min, max, ok)
+	}
+	if _, _, ok := fields[2].Range(); ok {
+		t.Error("Expected no range on unannotated field")
+	}
+}
+
+func TestTableName(t *testing.T) {
+	file, err := Parse(`
+@table("events")
+entity Event {}
+
+@table(schema: "app", name: "tasks")
+entity Task {}
+
+@table(schema: "app", "notes")
+entity Note {}
+
+entity Plain {}
+`)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	for i, want := range []struct{ table, schema string }{
+		{"events", ""},
+		{"tasks", "app"},
+		{"notes", "app"},
+		{"", ""},
+	} {
+		entity := file.Entities[i]
+		if got := entity.TableName(); got != want.table {
+			t.Errorf("%s: TableName() = %q, want %q", entity.Name, got, want.table)
+		}
+		if got := entity.SchemaName(); got != want.schema {
+			t.Errorf("%s: SchemaName() = %q, want %q", entity.Name, got, want.schema)
+		}
+	}
+}
+
+func TestExpressionDepthLimit(t *testing.T) {
+	const depth = 100000
+	input := "entity Event {\n    @pk id: string;\n    query deep() {\n        where " +
+		strings.Repeat("(", depth) + "id" + strings.Repeat(")", depth) + " = \"x\"\n    }\n}\n"
+
+	_, err := Parse(input)
+	if err == nil {
+		t.Fatal("Expected an error for deeply nested expression")
+	}
+	errs, ok := err.(ErrorList)
+	if !ok {
+		t.Fatalf("Expected ErrorList, got %T", err)
+	}
+	want := fmt.Sprintf("expression nested too deeply (maximum depth is %d)", DefaultMaxExprDepth)
+	if len(errs) != 1 || errs[0].Message != want {
+		t.Errorf("Expected single %q error, got %v", want, errs)
+	}
+
+	// The limit is configurable
+	p := NewFromString("entity Event {\n    @pk id: string;\n    query q() {\n        where ((id)) = \"x\"\n    }\n}\n")
+	p.MaxExprDepth = 2
+	p.ParseFile()
+	if len(p.DetailedErrors()) == 0 {
+		t.Error("Expected an error with MaxExprDepth = 2")
+	}
+}
+
+func TestSignedAndListDefaults(t *testing.T) {
+	file, err := Parse(`
+entity Item {
+    @default(-1) position: int32;
+    @default(+2.5) weight: double;
+    @default([1, 2, 3]) sizes: string;
+
+    query recent(offset: int32 = -10, ids: string = ["a", "b"]) {
+        where position > offset
+    }
+}
+`)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	entity := file.Entities[0]
+	defaults := make([]interface{}, len(entity.Fields))
+	for i, f := range entity.Fields {
+		defaults[i] = f.GetAnnotation("default").Args[0].Value
+	}
+	if defaults[0] != int64(-1) {
+		t.Errorf("Expected int64(-1), got %#v", defaults[0])
+	}
+	if defaults[1] != 2.5 {
+		t.Errorf("Expected 2.5, got %#v", defaults[1])
+	}
+	if list, ok := defaults[2].([]interface{}); !ok || len(list) != 3 || list[2] != int64(3) {
+		t.Errorf("Expected [1 2 3], got %#v", defaults[2])
+	}
+
+	params := entity.Queries[0].Params
+	if params[0].Default != int64(-10) {
+		t.Errorf("Expected int64(-10), got %#v", params[0].Default)
+	}
+	if list, ok := params[1].Default.([]interface{}); !ok || len(list) != 2 || list[0] != "a" {
+		t.Errorf("Expected [a b], got %#v", params[1].Default)
+	}
+
+	if _, err := Parse(`entity Item { @default(-x) n: int32; }`); err == nil {
+		t.Error("Expected an error for a sign without a number")
+	}
+}
+
+func TestExpressionDefaults(t *testing.T) {
+	file, err := Parse(`
+enum Status { OPEN = 0; }
+
+entity Item {
+    @pk id: string;
+    created: timestamp;
+
+    query recent(since: timestamp = NOW(), status: Status = OPEN, offset: int32 = 5, until: timestamp = since + 1000) {
+        where created > since AND created < until
+    }
+}
+`)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	params := file.Entities[0].Queries[0].Params
+	if call, ok := params[0].DefaultExpr.(*CallExpr); !ok || call.Name != "NOW" || params[0].Default != nil {
+		t.Errorf("Expected NOW() default expression, got %#v, %#v", params[0].DefaultExpr, params[0].Default)
+	}
+	if params[1].Default != "OPEN" || params[1].DefaultExpr != nil {
+		t.Errorf("Expected enum value default OPEN, got %#v, %#v", params[1].Default, params[1].DefaultExpr)
+	}
+	if params[2].Default != int64(5) || params[2].DefaultExpr != nil {
+		t.Errorf("Expected literal default 5, got %#v, %#v", params[2].Default, params[2].DefaultExpr)
+	}
+	if got := params[3].String(); got != "until: timestamp = since + 1000" {
+		t.Errorf("String() = %q, want %q", got, "until: timestamp = since + 1000")
+	}
+}
+
+func TestRpcStreamingKind(t *testing.T) {
+	file, err := Parse(`
+service Sync {
+    rpc Get(GetRequest) returns (Event);
+    rpc Watch(WatchRequest) returns (stream Event);
+    rpc Upload(stream Event) returns (Result);
+    rpc Chat(stream Event) returns (stream Event);
+}
+`)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	want := []StreamingKind{Unary, ServerStreaming, ClientStreaming, BidiStreaming}
+	for i, rpc := range file.Services[0].Methods {
+		if got := rpc.StreamingKind(); got != want[i] {
+			t.Errorf("%s: expected %s, got %s", rpc.Name, want[i], got)
+		}
+	}
+}
+
+func TestReservedDecl(t *testing.T) {
+	file, err := Parse(`
+entity Event {
+    reserved 3, 5 to 9, 100 to max;
+    reserved "old_name", "legacy";
+    @pk id: string;
+}
+`)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	entity := file.Entities[0]
+	if len(entity.Reserved) != 2 {
+		t.Fatalf("Expected 2 reserved statements, got %d", len(entity.Reserved))
+	}
+	wantRanges := []ReservedRange{{3, 3}, {5, 9}, {100, ReservedMax}}
+	ranges := entity.Reserved[0].Ranges
+	if len(ranges) != len(wantRanges) {
+		t.Fatalf("Expected ranges %v, got %v", wantRanges, ranges)
+	}
+	for i, r := range ranges {
+		if r != wantRanges[i] {
+			t.Errorf("Range %d: expected %v, got %v", i, wantRanges[i], r)
+		}
+	}
+	if names := entity.Reserved[1].Names; len(names) != 2 || names[0] != "old_name" || names[1] != "legacy" {
+		t.Errorf("Expected reserved names [old_name legacy], got %v", names)
+	}
+	if !entity.IsReservedNumber(7) || entity.IsReservedNumber(4) || !entity.IsReservedName("legacy") {
+		t.Error("Reserved lookups do not match the declaration")
+	}
+}
+
+func TestEnumOptions(t *testing.T) {
+	file, err := Parse(`
+enum Status {
+    option allow_alias = true;
+    DELETED = 3;
+    REMOVED = 3;
+}
+
+enum Plain {
+    A = 0;
+}
+`)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	if !file.Enums[0].AllowAlias() || len(file.Enums[0].Values) != 2 {
+		t.Errorf("Expected allow_alias with 2 values, got %+v", file.Enums[0])
+	}
+	if file.Enums[1].AllowAlias() {
+		t.Error("Expected no allow_alias without the option")
+	}
+}
+
+func TestParseILike(t *testing.T) {
+	input := `
+package test;
+
+entity Item {
+    @pk id: string;
+    title: string;
+
+    query search(term: string) {
+        where title ILIKE term
+    }
+}
+`
+
+	file, err := Parse(input)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	bin, ok := file.Entities[0].Queries[0].Where.(*BinaryExpr)
+	if !ok || bin.Op != "ILIKE" {
+		t.Fatalf("Expected ILIKE comparison, got %#v", file.Entities[0].Queries[0].Where)
+	}
+}
+
+func TestParseCaseExpr(t *testing.T) {
+	input := `
+package test;
+
+entity Event {
+    @pk id: string;
+    is_all_day: bool;
+    priority: int32;
+
+    query ranked() {
+        where CASE WHEN is_all_day THEN 1 WHEN priority > 5 THEN 2 ELSE 0 END > 0
+    }
+}
+`
+
+	file, err := Parse(input)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	bin, ok := file.Entities[0].Queries[0].Where.(*BinaryExpr)
+	if !ok {
+		t.Fatalf("Expected BinaryExpr, got %T", file.Entities[0].Queries[0].Where)
+	}
+	c, ok := bin.Left.(*CaseExpr)
+	if !ok {
+		t.Fatalf("Expected CaseExpr, got %T", bin.Left)
+	}
+	if len(c.Whens) != 2 || c.Else == nil {
+		t.Fatalf("Expected 2 WHEN branches and an ELSE, got %s", c)
+	}
+	if cond := c.Whens[1].Cond.String(); cond != "priority > 5" {
+		t.Errorf("Expected second condition priority > 5, got %s", cond)
+	}
+
+	if _, err := Parse(`entity E { @pk id: string; query q() { where CASE WHEN true THEN 1 } }`); err == nil {
+		t.Error("Expected an error for CASE without END")
+	}
+}
+
+func TestParseUnterminatedString(t *testing.T) {
+	_, err := Parse("@table(\"events\nentity Event {\n    @pk id: string;\n}\n")
+	if err == nil {
+		t.Fatal("Expected a parse error")
+	}
+	errs := err.(ErrorList)
+	want := `unterminated string starting at line 1, column 8: "events`
+	if errs[0].Message != want || errs[0].Position.Line != 1 || errs[0].Position.Column != 8 {
+		t.Errorf("Expected %q at 1:8, got %v", want, errs[0])
+	}
+}
+
+func TestLengthConstraint(t *testing.T) {
+	file, err := Parse(`
+entity Item {
+    @length(5) code: string;
+    @length(1, 500) title: string;
+    @length(min: 1, max: 500) summary: string;
+    @length(max: 100) notes: string;
+    @length(min: 2) tag: string;
+    name: string;
+}
+`)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	str := func(v *int64) string {
+		if v == nil {
+			return "nil"
+		}
+		return fmt.Sprint(*v)
+	}
+	tests := []struct {
+		min, max string
+		ok       bool
+	}{
+		{"nil", "5", true},
+		{"1", "500", true},
+		{"1", "500", true},
+		{"nil", "100", true},
+		{"2", "nil", true},
+		{"nil", "nil", false},
+	}
+	for i, tt := range tests {
+		field := file.Entities[0].Fields[i]
+		lc, ok := field.LengthConstraint()
+		if ok != tt.ok || str(lc.Min) != tt.min || str(lc.Max) != tt.max {
+			t.Errorf("%s: LengthConstraint() = {%s %s} %v, want {%s %s} %v",
+				field.Name, str(lc.Min), str(lc.Max), ok, tt.min, tt.max, tt.ok)
+		}
+	}
+}
+
+func TestParseOrderByNulls(t *testing.T) {
+	input := `
+package test;
+
+entity Event {
+    @pk id: string;
+    start_date: timestamp?;
+    title: string;
+
+    query upcoming() {
+        order_by start_date DESC NULLS LAST, title NULLS FIRST, id
+    }
+}
+`
+
+	file, err := Parse(input)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	orderBy := file.Entities[0].Queries[0].OrderBy
+	if len(orderBy) != 3 {
+		t.Fatalf("Expected 3 order_by fields, got %d", len(orderBy))
+	}
+	if !orderBy[0].Descending || orderBy[0].Nulls != NullsLast {
+		t.Errorf("Expected start_date DESC NULLS LAST, got %s", orderBy[0])
+	}
+	if orderBy[1].Descending || orderBy[1].Nulls != NullsFirst {
+		t.Errorf("Expected title ASC NULLS FIRST, got %s", orderBy[1])
+	}
+	if orderBy[2].Nulls != NullsDefault {
+		t.Errorf("Expected default null ordering for id, got %s", orderBy[2])
+	}
+}
+
+func TestParseOrderByNullsRequiresPlacement(t *testing.T) {
+	_, err := Parse(`
+package test;
+
+entity Event {
+    @pk id: string;
+    query all() { order_by id NULLS }
+}
+`)
+	if err == nil || !strings.Contains(err.Error(), "expected FIRST or LAST") {
+		t.Errorf("Expected FIRST or LAST error, got %v", err)
+	}
+}
+
+func TestParseKeywordFieldNames(t *testing.T) {
+	input := `
+package test;
+
+entity Page {
+    @pk id: string;
+    @default(20) limit: int32;
+    order: int32;
+    query: string?;
+    reserved 9;
+
+    query first() {
+        order_by order ASC
+        limit limit
+    }
+}
+`
+
+	file, err := Parse(input)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	entity := file.Entities[0]
+	if len(entity.Fields) != 4 {
+		t.Fatalf("Expected 4 fields, got %d", len(entity.Fields))
+	}
+	for i, name := range []string{"id", "limit", "order", "query"} {
+		if entity.Fields[i].Name != name {
+			t.Errorf("Expected field %d to be %s, got %s", i, name, entity.Fields[i].Name)
+		}
+	}
+	if !entity.Fields[1].HasAnnotation("default") {
+		t.Error("Expected @default to attach to the limit field")
+	}
+	if len(entity.Fields[2].Annotations) != 0 {
+		t.Errorf("Expected no annotations on order, got %v", entity.Fields[2].Annotations)
+	}
+	if len(entity.Reserved) != 1 || len(entity.Queries) != 1 {
+		t.Errorf("Expected reserved and query declarations to still parse, got %d and %d",
+			len(entity.Reserved), len(entity.Queries))
+	}
+}
+
+func TestParseReservedAsName(t *testing.T) {
+	input := `
+package test;
+
+entity Seat {
+    @pk id: string;
+    reserved: bool;
+    reserved 7;
+
+    query byReserved(reserved: bool) {
+        where reserved = reserved
+    }
+}
+
+message SeatRequest {
+    reserved: bool;
+    reserved 2 to 4, "old_name";
+}
+`
+
+	file, err := Parse(input)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	entity := file.Entities[0]
+	if len(entity.Fields) != 2 || entity.Fields[1].Name != "reserved" {
+		t.Fatalf("Expected a field named reserved, got %v", entity.Fields)
+	}
+	if len(entity.Reserved) != 1 || entity.Reserved[0].Ranges[0].Start != 7 {
+		t.Errorf("Expected reserved 7 to still parse, got %v", entity.Reserved)
+	}
+	if len(entity.Queries) != 1 {
+		t.Fatalf("Expected 1 query, got %d", len(entity.Queries))
+	}
+	params := entity.Queries[0].Params
+	if len(params) != 1 || params[0].Name != "reserved" {
+		t.Errorf("Expected a param named reserved, got %v", params)
+	}
+
+	msg := file.Messages[0]
+	if len(msg.Fields) != 1 || msg.Fields[0].Name != "reserved" {
+		t.Fatalf("Expected a message field named reserved, got %v", msg.Fields)
+	}
+	if len(msg.Reserved) != 1 {
+		t.Fatalf("Expected 1 reserved statement in the message, got %d", len(msg.Reserved))
+	}
+	r := msg.Reserved[0]
+	if len(r.Ranges) != 1 || r.Ranges[0] != (ReservedRange{Start: 2, End: 4}) || len(r.Names) != 1 {
+		t.Errorf("Expected reserved 2 to 4 and old_name, got %v", r)
+	}
+}
+
+func TestParseKeywordFieldsInClauses(t *testing.T) {
+	input := `
+package test;
+
+entity Post {
+    @pk id: string;
+    message: string;
+    query: string?;
+
+    query byMessage() {
+        select message, query, COUNT(message)
+        group_by message, query
+        order_by message DESC, query
+    }
+}
+`
+
+	file, err := Parse(input)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	query := file.Entities[0].Queries[0]
+	if got := strings.Join(query.Select, " "); got != "message query COUNT(message)" {
+		t.Errorf("Expected SELECT [message query COUNT(message)], got %v", query.Select)
+	}
+	if got := strings.Join(query.GroupBy, " "); got != "message query" {
+		t.Errorf("Expected GROUP BY [message query], got %v", query.GroupBy)
+	}
+	if len(query.OrderBy) != 2 || query.OrderBy[0].Field != "message" || !query.OrderBy[0].Descending ||
+		query.OrderBy[1].Field != "query" {
+		t.Errorf("Expected ORDER BY message DESC, query, got %+v", query.OrderBy)
+	}
+}
+
+func TestParseFieldDoc(t *testing.T) {
+	input := `
+package test;
+
+entity Event {
+    // Primary key
+    @pk
+    id: string;
+
+    // Shown in the list
+    // as the event name
+    title: string; // not a doc comment
+    notes: string?;
+}
+`
+
+	file, err := Parse(input)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	fields := file.Entities[0].Fields
+	want := []string{"Primary key", "Shown in the list\nas the event name", ""}
+	for i, doc := range want {
+		if fields[i].Doc != doc {
+			t.Errorf("Doc of %s = %q, want %q", fields[i].Name, fields[i].Doc, doc)
+		}
+	}
+}
+
+func TestParseFieldAccess(t *testing.T) {
+	input := `
+package test;
+
+entity Event {
+    @pk id: string;
+    calendar: Calendar;
+
+    query inCalendar(name: string) {
+        where event.calendar.name = name
+    }
+}
+`
+
+	file, err := Parse(input)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	bin, ok := file.Entities[0].Queries[0].Where.(*BinaryExpr)
+	if !ok {
+		t.Fatalf("Expected BinaryExpr, got %T", file.Entities[0].Queries[0].Where)
+	}
+	access, ok := bin.Left.(*FieldAccessExpr)
+	if !ok || access.Field != "name" {
+		t.Fatalf("Expected access to name, got %#v", bin.Left)
+	}
+	if inner, ok := access.Base.(*FieldAccessExpr); !ok || inner.Field != "calendar" {
+		t.Fatalf("Expected access to calendar as base, got %#v", access.Base)
+	}
+	root, path := access.Path()
+	if root == nil || root.Name != "event" || strings.Join(path, ".") != "calendar.name" {
+		t.Errorf("Expected root event and path calendar.name, got %v %v", root, path)
+	}
+	if s := bin.String(); s != "event.calendar.name = name" {
+		t.Errorf("Expected round trip, got %s", s)
+	}
+
+	if _, err := Parse(`entity E { @pk id: string; query q() { where e. = 1 } }`); err == nil {
+		t.Error("Expected an error for a trailing dot")
+	}
+}
+
+func TestParseTupleExpr(t *testing.T) {
+	expr, err := ParseExpr("(start_date, id) > (after_date, after_id) AND (id)")
+	if err != nil {
+		t.Fatalf("ParseExpr error: %v", err)
+	}
+
+	and := expr.(*BinaryExpr)
+	cmp, ok := and.Left.(*BinaryExpr)
+	if !ok || cmp.Op != ">" {
+		t.Fatalf("Expected > comparison, got %#v", and.Left)
+	}
+	for _, side := range []Expr{cmp.Left, cmp.Right} {
+		if tuple, ok := side.(*TupleExpr); !ok || len(tuple.Elements) != 2 {
+			t.Errorf("Expected a tuple of 2 elements, got %#v", side)
+		}
+	}
+	if _, ok := and.Right.(*ParenExpr); !ok {
+		t.Errorf("Expected parentheses without a comma to stay a ParenExpr, got %#v", and.Right)
+	}
+	if s := expr.String(); s != "(start_date, id) > (after_date, after_id) AND (id)" {
+		t.Errorf("Expected round trip, got %s", s)
+	}
+
+	if _, err := ParseExpr("(a, b > 1"); err == nil || !strings.Contains(err.Error(), "expected ')'") {
+		t.Errorf("Expected unclosed tuple error, got %v", err)
+	}
+}
+
+func TestAdjacentStringConcatenation(t *testing.T) {
+	input := `
+package test;
+
+option java_package = "com.example" ".calendar";
+
+entity Event {
+    @pk id: string;
+    @pattern("^a" "b$") code: string;
+    @default("multi" "" "part") note: string;
+
+    query search() {
+        where note = "x" "y"
+    }
+}
+`
+
+	file, err := Parse(input)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	if got := file.Options[0].Value; got != "com.example.calendar" {
+		t.Errorf("Expected joined option value, got %#v", got)
+	}
+	entity := file.Entities[0]
+	if got := entity.Fields[1].Pattern(); got != "^ab$" {
+		t.Errorf("Expected pattern ^ab$, got %q", got)
+	}
+	if got := entity.Fields[2].GetAnnotation("default").Args[0].Value; got != "multipart" {
+		t.Errorf("Expected default multipart, got %#v", got)
+	}
+	if where := entity.Queries[0].Where.String(); where != `note = "xy"` {
+		t.Errorf("Expected joined literal in WHERE, got %s", where)
+	}
+
+	// The token after the joined literal keeps its own position
+	if _, err := Parse(`entity E { @pattern("a"
+    "b" 1) f: string; }`); err == nil || !strings.Contains(err.Error(), "2:9") {
+		t.Errorf("Expected an error at 2:9, got %v", err)
+	}
+}
+
+func TestParseChecks(t *testing.T) {
+	input := `
+package test;
+
+@check("end_date >= start_date")
+entity Booking {
+    @pk id: string;
+    start_date: timestamp;
+    end_date: timestamp;
+    @check("guests > 0 AND guests <= 8") guests: int32;
+    @check("nights >") nights: int32;
+}
+`
+
+	file, err := Parse(input)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	checks := file.Entities[0].Checks()
+	if len(checks) != 3 {
+		t.Fatalf("Expected 3 checks, got %d", len(checks))
+	}
+	if checks[0].Field != nil || checks[0].Expr == nil || checks[0].Expr.String() != "end_date >= start_date" {
+		t.Errorf("Expected entity-level check end_date >= start_date, got %#v", checks[0])
+	}
+	if checks[1].Field == nil || checks[1].Field.Name != "guests" {
+		t.Errorf("Expected check on guests, got %#v", checks[1])
+	}
+	if bin, ok := checks[1].Expr.(*BinaryExpr); !ok || bin.Op != "AND" {
+		t.Errorf("Expected AND condition, got %#v", checks[1].Expr)
+	}
+	if checks[2].Condition != "nights >" || checks[2].Expr != nil {
+		t.Errorf("Expected unparsed condition, got %#v", checks[2])
+	}
+
+	if _, err := ParseExpr("a = 1 b"); err == nil || !strings.Contains(err.Error(), "unexpected b after expression") {
+		t.Errorf("Expected trailing token error, got %v", err)
+	}
+}
+
+func TestParseComputed(t *testing.T) {
+	input := `
+package test;
+
+entity Person {
+    @pk id: string;
+    first: string;
+    last: string;
+    @computed("first || ' ' || last") full_name: string;
+    @computed("first ||") broken: string;
+}
+`
+
+	file, err := Parse(input)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	entity := file.Entities[0]
+	src, expr, ok := entity.Field("full_name").Computed()
+	if !ok || src != "first || ' ' || last" {
+		t.Fatalf("Expected computed expression, got %q, %v", src, ok)
+	}
+	outer, isBin := expr.(*BinaryExpr)
+	if !isBin || outer.Op != "||" {
+		t.Fatalf("Expected || expression, got %#v", expr)
+	}
+	inner, isBin := outer.Left.(*BinaryExpr)
+	if !isBin {
+		t.Fatalf("Expected left-nested ||, got %#v", outer.Left)
+	}
+	if lit, isLit := inner.Right.(*LiteralExpr); !isLit || lit.Value != " " {
+		t.Errorf("Expected single-quoted literal ' ', got %#v", inner.Right)
+	}
+
+	if _, expr, ok := entity.Field("broken").Computed(); !ok || expr != nil {
+		t.Errorf("Expected unparsed computed expression, got %#v", expr)
+	}
+	if _, _, ok := entity.Field("first").Computed(); ok {
+		t.Error("Expected no computed expression on a stored field")
+	}
+}
+
+func TestFieldReferences(t *testing.T) {
+	input := `
+package acos;
+
+entity CalendarEvent {
+    @pk id: string;
+    @required title: string;
+    @indexed start_date: timestamp;
+    end_date: timestamp?;
+    calendar_name: string?;
+    notes: string?;
+
+    query eventsByDateRange(after: timestamp, before: timestamp) {
+        where start_date >= after AND start_date < before
+        order_by start_date ASC
+    }
+
+    query upcomingEvents(limit: int32 = 50) {
+        where start_date >= NOW()
+        order_by start_date ASC
+        limit limit
+    }
+
+    query byCalendar(calendar_name: string) {
+        select title, COUNT(id)
+        where calendar_name = calendar_name
+        group_by title
+    }
+}
+`
+
+	file, err := Parse(input)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	refs := FieldReferences(file.Entities[0])
+
+	names := func(queries []*QueryDecl) string {
+		var out []string
+		for _, q := range queries {
+			out = append(out, q.Name)
+		}
+		return strings.Join(out, ",")
+	}
+	for field, want := range map[string]string{
+		"start_date": "eventsByDateRange,upcomingEvents",
+		"title":      "byCalendar",
+		"id":         "byCalendar",
+	} {
+		if got := names(refs[field]); got != want {
+			t.Errorf("FieldReferences[%s] = %s, want %s", field, got, want)
+		}
+	}
+	// The parameter shadows the field it is named after
+	for _, field := range []string{"calendar_name", "end_date", "notes"} {
+		if _, ok := refs[field]; ok {
+			t.Errorf("Expected no references to %s, got %s", field, names(refs[field]))
+		}
+	}
+}
+
+func TestParseOptionNames(t *testing.T) {
+	input := `
+package test;
+
+option go_package = "example.com/test";
+option (custom.opt).field = [1, 2];
+option (acme.http).rule.limit = 10;
+`
+
+	file, err := Parse(input)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	if len(file.Options) != 3 {
+		t.Fatalf("Expected 3 options, got %d", len(file.Options))
+	}
+
+	plain := file.Options[0]
+	if plain.Name != "go_package" || plain.IsExtension() || len(plain.NameParts) != 1 {
+		t.Errorf("Expected plain option go_package, got %#v", plain)
+	}
+
+	custom := file.Options[1]
+	if custom.Name != "(custom.opt).field" || !custom.IsExtension() {
+		t.Errorf("Expected extension option (custom.opt).field, got %#v", custom)
+	}
+	wantParts := []OptionNamePart{{Name: "custom.opt", Extension: true}, {Name: "field"}}
+	if fmt.Sprint(custom.NameParts) != fmt.Sprint(wantParts) {
+		t.Errorf("Expected parts %v, got %v", wantParts, custom.NameParts)
+	}
+	list, ok := custom.Value.([]interface{})
+	if !ok || len(list) != 2 || list[0] != int64(1) || list[1] != int64(2) {
+		t.Errorf("Expected list value [1, 2], got %#v", custom.Value)
+	}
+	if s := custom.String(); s != "option (custom.opt).field = [1, 2];" {
+		t.Errorf("Expected round trip, got %s", s)
+	}
+
+	if got := file.Options[2].Name; got != "(acme.http).rule.limit" {
+		t.Errorf("Expected (acme.http).rule.limit, got %s", got)
+	}
+
+	for _, bad := range []string{
+		`option (custom.opt = 1;`,
+		`option custom. = 1;`,
+	} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+}
+
+func TestParseMessage(t *testing.T) {
+	input := `
+package test;
+
+@deprecated
+message GetEventsRequest {
+    since: timestamp?;
+    @deprecated limit: int32;
+    message: string;
+}
+
+service Events {
+    rpc GetEvents(GetEventsRequest) returns (stream Event);
+}
+`
+
+	file, err := Parse(input)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	if len(file.Messages) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(file.Messages))
+	}
+
+	msg := file.Messages[0]
+	if msg.Name != "GetEventsRequest" || len(msg.Annotations) != 1 {
+		t.Errorf("Expected deprecated GetEventsRequest, got %s", msg)
+	}
+	if len(msg.Fields) != 3 {
+		t.Fatalf("Expected 3 fields, got %d", len(msg.Fields))
+	}
+	if f := msg.Fields[0]; f.Name != "since" || !f.Type.Optional {
+		t.Errorf("Expected optional since, got %s", f)
+	}
+	if f := msg.Fields[1]; f.Name != "limit" || !f.HasAnnotation("deprecated") {
+		t.Errorf("Expected deprecated limit, got %s", f)
+	}
+	if f := msg.Fields[2]; f.Name != "message" {
+		t.Errorf("Expected keyword field message, got %s", f)
+	}
+	if s := msg.String(); s != "@deprecated message GetEventsRequest { since: timestamp?; @deprecated limit: int32; message: string; }" {
+		t.Errorf("Expected round trip, got %s", s)
+	}
+
+	if _, err := Parse(`message M { query q() { limit 1 } }`); err == nil {
+		t.Error("Expected an error for a query in a message")
+	}
+}
+
+func TestParseInlineEnum(t *testing.T) {
+	input := `
+entity Task {
+    @pk id: string;
+    status: enum { ACTIVE = 0; DONE = 1; };
+    review_state: enum { PENDING = 0; APPROVED = 1; }?;
+}
+`
+
+	file, err := Parse(input)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	if len(file.Enums) != 2 {
+		t.Fatalf("Expected 2 enums, got %d", len(file.Enums))
+	}
+
+	status := file.Enums[0]
+	if status.Name != "TaskStatus" || !status.Inline {
+		t.Errorf("Expected inline enum TaskStatus, got %s", status.Name)
+	}
+	if len(status.Values) != 2 || status.Values[1].Name != "DONE" || status.Values[1].Number != 1 {
+		t.Errorf("Expected ACTIVE and DONE, got %s", status)
+	}
+	if f := file.Entities[0].Fields[1]; f.Type.Name != "TaskStatus" || f.Type.Enum != status {
+		t.Errorf("Expected status to refer to TaskStatus, got %s", f.Type.Name)
+	}
+
+	review := file.Entities[0].Fields[2]
+	if review.Type.Name != "TaskReviewState" || !review.Type.Optional {
+		t.Errorf("Expected optional TaskReviewState, got %s", review.Type.Name)
+	}
+	if s := review.String(); s != "review_state: enum { PENDING = 0; APPROVED = 1; }?" {
+		t.Errorf("Expected round trip, got %s", s)
+	}
+
+	if _, err := Parse(`entity T { @pk id: string; query q(s: enum { A = 0; }) { limit 1 } }`); err == nil {
+		t.Error("Expected an error for an inline enum parameter")
+	}
+}
+
+func TestParseEntityExtends(t *testing.T) {
+	input := `
+entity Audited {
+    @pk id: string;
+    created_at: timestamp;
+}
+
+entity Timestamped extends Audited {
+    updated_at: timestamp;
+}
+
+entity Note extends Timestamped {
+    body: string;
+}
+`
+
+	file, err := Parse(input)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	note := file.Entities[2]
+	if note.Extends != "Timestamped" {
+		t.Errorf("Expected Note to extend Timestamped, got %q", note.Extends)
+	}
+	var got []string
+	for _, field := range note.Fields {
+		got = append(got, field.Name+":"+field.Inherited)
+	}
+	want := "id:Audited created_at:Audited updated_at:Timestamped body:"
+	if s := strings.Join(got, " "); s != want {
+		t.Errorf("Expected fields %q, got %q", want, s)
+	}
+	if !note.Fields[0].IsPrimaryKey() {
+		t.Error("Expected the inherited id to keep @pk")
+	}
+	if note.Fields[0] == file.Entities[0].Fields[0] {
+		t.Error("Expected the inherited field to be a copy")
+	}
+
+	if s := note.String(); s != "entity Note extends Timestamped { body: string; }" {
+		t.Errorf("Expected inherited fields to be left out, got %s", s)
+	}
+}
+
+func TestParseQueryAnnotations(t *testing.T) {
+	input := `
+entity Task {
+    @pk id: string;
+
+    @returns(one)
+    query byId(key: string) {
+        where id = key
+    }
+
+    query all() {
+        limit 10
+    }
+}
+`
+
+	file, err := Parse(input)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	queries := file.Entities[0].Queries
+	if len(queries) != 2 {
+		t.Fatalf("Expected 2 queries, got %d", len(queries))
+	}
+	if !queries[0].ReturnsOne() || queries[1].ReturnsOne() {
+		t.Errorf("Expected only byId to return one row")
+	}
+	if s := queries[0].String(); s != "@returns(\"one\") query byId(key: string) { where id = key }" {
+		t.Errorf("Expected round trip, got %s", s)
+	}
+}
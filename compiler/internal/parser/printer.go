@@ -0,0 +1,435 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// String methods render nodes in a compact form of DataProto syntax for
+// debugging. They tolerate nil receivers and nil sub-nodes, which print as
+// <nil>, so partially parsed trees can be inspected too.
+
+const nilNode = "<nil>"
+
+func (f *File) String() string {
+	if f == nil {
+		return nilNode
+	}
+	var lines []string
+	if f.Package != nil {
+		lines = append(lines, fmt.Sprintf("package %s;", f.Package.Name))
+	}
+	for _, imp := range f.Imports {
+		if imp != nil {
+			lines = append(lines, fmt.Sprintf("import %q;", imp.Path))
+		}
+	}
+	for _, opt := range f.Options {
+		lines = append(lines, opt.String())
+	}
+	for _, enum := range f.Enums {
+		if !enum.Inline {
+			lines = append(lines, enum.String())
+		}
+	}
+	for _, entity := range f.Entities {
+		lines = append(lines, entity.String())
+	}
+	for _, msg := range f.Messages {
+		lines = append(lines, msg.String())
+	}
+	for _, svc := range f.Services {
+		lines = append(lines, svc.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (o *OptionDecl) String() string {
+	if o == nil {
+		return nilNode
+	}
+	value := formatValue(o.Value)
+	if name, ok := o.Value.(string); ok && o.Ident {
+		value = name
+	}
+	return fmt.Sprintf("option %s = %s;", o.Name, value)
+}
+
+func (e *EnumDecl) String() string {
+	if e == nil {
+		return nilNode
+	}
+	var parts []string
+	for _, opt := range e.Options {
+		parts = append(parts, opt.String())
+	}
+	for _, val := range e.Values {
+		if val != nil {
+			parts = append(parts, annotationPrefix(val.Annotations)+fmt.Sprintf("%s = %d;", val.Name, val.Number))
+		}
+	}
+	if e.Inline {
+		return fmt.Sprintf("enum { %s }", strings.Join(parts, " "))
+	}
+	return fmt.Sprintf("enum %s { %s }", e.Name, strings.Join(parts, " "))
+}
+
+func (e *EntityDecl) String() string {
+	if e == nil {
+		return nilNode
+	}
+	var sb strings.Builder
+	sb.WriteString(annotationPrefix(e.Annotations))
+	sb.WriteString("entity " + e.Name)
+	if e.Extends != "" {
+		sb.WriteString(" extends " + e.Extends)
+	}
+	sb.WriteString(" {")
+	for _, field := range e.Fields {
+		// Inherited fields are printed by the base entity
+		if field.Inherited == "" {
+			sb.WriteString(" " + field.String() + ";")
+		}
+	}
+	for _, r := range e.Reserved {
+		sb.WriteString(" " + r.String())
+	}
+	for _, query := range e.Queries {
+		sb.WriteString(" " + query.String())
+	}
+	sb.WriteString(" }")
+	return sb.String()
+}
+
+func (m *MessageDecl) String() string {
+	if m == nil {
+		return nilNode
+	}
+	var sb strings.Builder
+	sb.WriteString(annotationPrefix(m.Annotations))
+	sb.WriteString(fmt.Sprintf("message %s {", m.Name))
+	for _, field := range m.Fields {
+		sb.WriteString(" " + field.String() + ";")
+	}
+	for _, r := range m.Reserved {
+		sb.WriteString(" " + r.String())
+	}
+	sb.WriteString(" }")
+	return sb.String()
+}
+
+func (r *ReservedDecl) String() string {
+	if r == nil {
+		return nilNode
+	}
+	var items []string
+	for _, rng := range r.Ranges {
+		switch {
+		case rng.Start == rng.End:
+			items = append(items, strconv.FormatInt(rng.Start, 10))
+		case rng.End == ReservedMax:
+			items = append(items, fmt.Sprintf("%d to max", rng.Start))
+		default:
+			items = append(items, fmt.Sprintf("%d to %d", rng.Start, rng.End))
+		}
+	}
+	for _, name := range r.Names {
+		items = append(items, strconv.Quote(name))
+	}
+	return fmt.Sprintf("reserved %s;", strings.Join(items, ", "))
+}
+
+func (a *Annotation) String() string {
+	if a == nil {
+		return nilNode
+	}
+	if len(a.Args) == 0 {
+		return "@" + a.Name
+	}
+	var args []string
+	for _, arg := range a.Args {
+		if arg.Name != "" {
+			args = append(args, arg.Name+": "+formatValue(arg.Value))
+		} else {
+			args = append(args, formatValue(arg.Value))
+		}
+	}
+	return fmt.Sprintf("@%s(%s)", a.Name, strings.Join(args, ", "))
+}
+
+func (f *FieldDecl) String() string {
+	if f == nil {
+		return nilNode
+	}
+	return annotationPrefix(f.Annotations) + f.Name + ": " + f.Type.String()
+}
+
+func (t *TypeRef) String() string {
+	if t == nil {
+		return nilNode
+	}
+	name := t.Name
+	if t.Enum != nil {
+		name = t.Enum.String()
+	}
+	if t.Optional {
+		return name + "?"
+	}
+	return name
+}
+
+func (q *QueryDecl) String() string {
+	if q == nil {
+		return nilNode
+	}
+	var params []string
+	for _, p := range q.Params {
+		params = append(params, p.String())
+	}
+
+	var clauses []string
+	if len(q.Select) > 0 {
+		sel := "select "
+		if q.Distinct {
+			sel += "distinct "
+		}
+		clauses = append(clauses, sel+strings.Join(q.Select, ", "))
+	}
+	if q.Where != nil {
+		clauses = append(clauses, "where "+q.Where.String())
+	}
+	if len(q.GroupBy) > 0 {
+		clauses = append(clauses, "group_by "+strings.Join(q.GroupBy, ", "))
+	}
+	if q.Having != nil {
+		clauses = append(clauses, "having "+q.Having.String())
+	}
+	if len(q.OrderBy) > 0 {
+		var fields []string
+		for _, o := range q.OrderBy {
+			fields = append(fields, o.String())
+		}
+		clauses = append(clauses, "order_by "+strings.Join(fields, ", "))
+	}
+	if q.Limit != nil {
+		clauses = append(clauses, "limit "+q.Limit.String())
+	}
+
+	return annotationPrefix(q.Annotations) + fmt.Sprintf("query %s(%s) { %s }", q.Name, strings.Join(params, ", "), strings.Join(clauses, " "))
+}
+
+func (q *QueryParam) String() string {
+	if q == nil {
+		return nilNode
+	}
+	s := annotationPrefix(q.Annotations) + q.Name
+	if q.Optional {
+		s += "?"
+	}
+	s += ": " + q.Type.String()
+	if q.Default != nil {
+		s += " = " + formatValue(q.Default)
+	} else if q.DefaultExpr != nil {
+		s += " = " + q.DefaultExpr.String()
+	}
+	return s
+}
+
+func (o *OrderByField) String() string {
+	if o == nil {
+		return nilNode
+	}
+	s := o.Field + " ASC"
+	if o.Descending {
+		s = o.Field + " DESC"
+	}
+	if o.Nulls != NullsDefault {
+		s += " " + o.Nulls.String()
+	}
+	return s
+}
+
+func (b *BinaryExpr) String() string {
+	if b == nil {
+		return nilNode
+	}
+	return fmt.Sprintf("%s %s %s", exprString(b.Left), b.Op, exprString(b.Right))
+}
+
+func (u *UnaryExpr) String() string {
+	if u == nil {
+		return nilNode
+	}
+	if u.Op == "NOT" {
+		return "NOT " + exprString(u.Operand)
+	}
+	return u.Op + exprString(u.Operand)
+}
+
+func (i *IsNullExpr) String() string {
+	if i == nil {
+		return nilNode
+	}
+	if i.Not {
+		return exprString(i.Operand) + " IS NOT NULL"
+	}
+	return exprString(i.Operand) + " IS NULL"
+}
+
+func (i *IdentExpr) String() string {
+	if i == nil {
+		return nilNode
+	}
+	return i.Name
+}
+
+func (f *FieldAccessExpr) String() string {
+	if f == nil {
+		return nilNode
+	}
+	return exprString(f.Base) + "." + f.Field
+}
+
+func (l *LiteralExpr) String() string {
+	if l == nil {
+		return nilNode
+	}
+	if l.Value == nil {
+		return "NULL"
+	}
+	return formatValue(l.Value)
+}
+
+func (c *CallExpr) String() string {
+	if c == nil {
+		return nilNode
+	}
+	var args []string
+	for _, arg := range c.Args {
+		args = append(args, exprString(arg))
+	}
+	return fmt.Sprintf("%s(%s)", c.Name, strings.Join(args, ", "))
+}
+
+func (p *ParenExpr) String() string {
+	if p == nil {
+		return nilNode
+	}
+	return "(" + exprString(p.Inner) + ")"
+}
+
+func (t *TupleExpr) String() string {
+	if t == nil {
+		return nilNode
+	}
+	var elems []string
+	for _, elem := range t.Elements {
+		elems = append(elems, exprString(elem))
+	}
+	return "(" + strings.Join(elems, ", ") + ")"
+}
+
+func (c *CaseExpr) String() string {
+	if c == nil {
+		return nilNode
+	}
+	var sb strings.Builder
+	sb.WriteString("CASE")
+	for _, when := range c.Whens {
+		if when != nil {
+			sb.WriteString(" WHEN " + exprString(when.Cond) + " THEN " + exprString(when.Result))
+		}
+	}
+	if c.Else != nil {
+		sb.WriteString(" ELSE " + c.Else.String())
+	}
+	sb.WriteString(" END")
+	return sb.String()
+}
+
+func (s *ServiceDecl) String() string {
+	if s == nil {
+		return nilNode
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("service %s {", s.Name))
+	for _, rpc := range s.Methods {
+		sb.WriteString(" " + rpc.String())
+	}
+	sb.WriteString(" }")
+	return sb.String()
+}
+
+func (r *RpcDecl) String() string {
+	if r == nil {
+		return nilNode
+	}
+	return annotationPrefix(r.Annotations) +
+		fmt.Sprintf("rpc %s(%s) returns (%s);", r.Name, r.RequestType.String(), r.ResponseType.String())
+}
+
+func (r *RpcType) String() string {
+	if r == nil {
+		return nilNode
+	}
+	if r.Stream {
+		return "stream " + r.Name
+	}
+	return r.Name
+}
+
+// annotationPrefix renders annotations followed by a space each, to precede
+// the declaration they annotate.
+func annotationPrefix(annotations []*Annotation) string {
+	var sb strings.Builder
+	for _, ann := range annotations {
+		sb.WriteString(ann.String())
+		sb.WriteString(" ")
+	}
+	return sb.String()
+}
+
+// exprString renders an expression, guarding against a nil interface, which
+// a method call on the Expr itself would not survive.
+func exprString(e Expr) string {
+	if e == nil {
+		return nilNode
+	}
+	return e.String()
+}
+
+// formatValue renders an option, annotation or default value as it would be
+// written in a schema.
+func formatValue(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return strconv.Quote(val)
+	case int64:
+		return strconv.FormatInt(val, 10)
+	case float64:
+		return strconv.FormatFloat(val, 'g', -1, 64)
+	case bool:
+		return strconv.FormatBool(val)
+	case []interface{}:
+		items := make([]string, len(val))
+		for i, item := range val {
+			items[i] = formatValue(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		items := make([]string, len(keys))
+		for i, k := range keys {
+			items[i] = k + ": " + formatValue(val[k])
+		}
+		return "{" + strings.Join(items, ", ") + "}"
+	default:
+		return fmt.Sprint(val)
+	}
+}
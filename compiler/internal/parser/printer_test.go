@@ -0,0 +1,69 @@
+package parser
+
+import "testing"
+
+func TestBinaryExprString(t *testing.T) {
+	file, err := Parse(`
+entity Event {
+    @pk id: string;
+    title: string;
+    start_date: timestamp;
+
+    query search(term: string, after: timestamp) {
+        where (title LIKE "%" || term || "%" OR title IS NULL) AND start_date >= after
+    }
+}
+`)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	got := file.Entities[0].Queries[0].Where.String()
+	want := `(title LIKE "%" || term || "%" OR title IS NULL) AND start_date >= after`
+	if got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestEntityDeclString(t *testing.T) {
+	file, err := Parse(`
+@table("events")
+entity Event {
+    @pk id: string;
+    @length(max: 500) notes: string?;
+    reserved 3, 10 to max;
+
+    query recent(n: int32 = 10) {
+        order_by id DESC
+        limit n
+    }
+}
+`)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	got := file.Entities[0].String()
+	want := `@table("events") entity Event { @pk id: string; @length(max: 500) notes: string?; ` +
+		`reserved 3, 10 to max; query recent(n: int32 = 10) { order_by id DESC limit n } }`
+	if got != want {
+		t.Errorf("String() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestStringNilNodes(t *testing.T) {
+	var entity *EntityDecl
+	if got := entity.String(); got != "<nil>" {
+		t.Errorf("nil EntityDecl String() = %q", got)
+	}
+
+	expr := &BinaryExpr{Op: "AND", Left: &IdentExpr{Name: "a"}}
+	if got := expr.String(); got != "a AND <nil>" {
+		t.Errorf("String() = %q, want %q", got, "a AND <nil>")
+	}
+
+	field := &FieldDecl{Name: "x"}
+	if got := field.String(); got != "x: <nil>" {
+		t.Errorf("String() = %q, want %q", got, "x: <nil>")
+	}
+}
@@ -0,0 +1,159 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Loader returns the source text of the schema file at path.
+type Loader func(path string) (string, error)
+
+// Program is a set of parsed files: the requested roots plus everything they
+// import, transitively.
+type Program struct {
+	// Files holds every parsed file in dependency order, so a file always
+	// appears after the files it imports.
+	Files []*File
+
+	files   map[string]*File
+	imports map[string][]string
+}
+
+// ParseFiles parses the given files and resolves their imports from disk.
+func ParseFiles(paths []string) (*Program, error) {
+	return ParseFilesWithLoader(paths, func(path string) (string, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	})
+}
+
+// ParseFilesWithLoader parses the given files and resolves their imports using
+// load. Import paths are relative to the directory of the importing file.
+// Import cycles are reported as errors.
+func ParseFilesWithLoader(paths []string, load Loader) (*Program, error) {
+	r := &resolver{
+		load:  load,
+		state: make(map[string]int),
+		prog: &Program{
+			files:   make(map[string]*File),
+			imports: make(map[string][]string),
+		},
+	}
+
+	for _, path := range paths {
+		if err := r.visit(filepath.Clean(path), nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return r.prog, nil
+}
+
+// File returns the parsed file for path, or nil.
+func (p *Program) File(path string) *File {
+	return p.files[filepath.Clean(path)]
+}
+
+// ImportsOf returns the files directly imported by file.
+func (p *Program) ImportsOf(file *File) []*File {
+	var result []*File
+	for _, path := range p.imports[file.Position.Filename] {
+		result = append(result, p.files[path])
+	}
+	return result
+}
+
+// Merged returns a single File containing the declarations of every file in
+// the program. The package is taken from the last file in dependency order,
+// which is a root file.
+func (p *Program) Merged() *File {
+	merged := &File{}
+	for _, f := range p.Files {
+		if f.Package != nil {
+			merged.Package = f.Package
+		}
+		merged.Options = append(merged.Options, f.Options...)
+		merged.Enums = append(merged.Enums, f.Enums...)
+		merged.Entities = append(merged.Entities, f.Entities...)
+		merged.Messages = append(merged.Messages, f.Messages...)
+		merged.Services = append(merged.Services, f.Services...)
+	}
+	if len(p.Files) > 0 {
+		merged.Position = p.Files[len(p.Files)-1].Position
+	}
+	return merged
+}
+
+// ResolveImport returns the path of an import relative to the importing file.
+func ResolveImport(from, importPath string) string {
+	return filepath.Join(filepath.Dir(from), importPath)
+}
+
+// Visit states for cycle detection.
+const (
+	unvisited = iota
+	visiting
+	visited
+)
+
+type resolver struct {
+	load  Loader
+	state map[string]int
+	prog  *Program
+}
+
+func (r *resolver) visit(path string, stack []string) error {
+	switch r.state[path] {
+	case visited:
+		return nil
+	case visiting:
+		cycle := append(stack, path)
+		for i, p := range cycle {
+			if p == path {
+				cycle = cycle[i:]
+				break
+			}
+		}
+		return fmt.Errorf("import cycle: %s", strings.Join(cycle, " -> "))
+	}
+
+	r.state[path] = visiting
+	stack = append(stack, path)
+
+	src, err := r.load(path)
+	if err != nil {
+		if len(stack) > 1 {
+			return fmt.Errorf("%s: import %q: %w", stack[len(stack)-2], path, err)
+		}
+		return err
+	}
+
+	file, err := ParseFile(src, path)
+	if err != nil {
+		return err
+	}
+
+	var imported []*EntityDecl
+	for _, imp := range file.Imports {
+		dep := ResolveImport(path, imp.Path)
+		r.prog.imports[path] = append(r.prog.imports[path], dep)
+		if err := r.visit(dep, stack); err != nil {
+			return err
+		}
+		imported = append(imported, r.prog.files[dep].Entities...)
+	}
+	if len(imported) > 0 {
+		// Entities may extend an entity of a file they import
+		inheritFields(file.Entities, imported)
+	}
+
+	r.state[path] = visited
+	r.prog.files[path] = file
+	r.prog.Files = append(r.prog.Files, file)
+	return nil
+}
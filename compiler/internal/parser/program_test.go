@@ -0,0 +1,131 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// mapLoader serves schema sources from memory.
+func mapLoader(files map[string]string) Loader {
+	return func(path string) (string, error) {
+		src, ok := files[path]
+		if !ok {
+			return "", fmt.Errorf("file not found: %s", path)
+		}
+		return src, nil
+	}
+}
+
+func TestParseFilesResolvesImports(t *testing.T) {
+	files := map[string]string{
+		"common.dataproto": `
+package acos;
+
+enum Status {
+    ACTIVE = 0;
+    DONE = 1;
+}
+`,
+		"task.dataproto": `
+package acos;
+
+import "common.dataproto";
+
+entity Task {
+    @pk id: string;
+    status: Status;
+}
+`,
+	}
+
+	prog, err := ParseFilesWithLoader([]string{"task.dataproto"}, mapLoader(files))
+	if err != nil {
+		t.Fatalf("ParseFiles error: %v", err)
+	}
+
+	if len(prog.Files) != 2 {
+		t.Fatalf("Expected 2 files, got %d", len(prog.Files))
+	}
+	if prog.Files[0].Pos().Filename != "common.dataproto" {
+		t.Errorf("Expected imported file first, got '%s'", prog.Files[0].Pos().Filename)
+	}
+
+	task := prog.File("task.dataproto")
+	imports := prog.ImportsOf(task)
+	if len(imports) != 1 || imports[0] != prog.File("common.dataproto") {
+		t.Errorf("Expected task.dataproto to import common.dataproto, got %v", imports)
+	}
+
+	merged := prog.Merged()
+	if len(merged.Enums) != 1 || len(merged.Entities) != 1 {
+		t.Errorf("Expected merged file with 1 enum and 1 entity, got %d and %d",
+			len(merged.Enums), len(merged.Entities))
+	}
+}
+
+func TestParseFilesDetectsCycles(t *testing.T) {
+	files := map[string]string{
+		"a.dataproto": `package x; import "b.dataproto";`,
+		"b.dataproto": `package x; import "a.dataproto";`,
+	}
+
+	_, err := ParseFilesWithLoader([]string{"a.dataproto"}, mapLoader(files))
+	if err == nil {
+		t.Fatal("Expected import cycle error")
+	}
+	if !strings.Contains(err.Error(), "import cycle: a.dataproto -> b.dataproto -> a.dataproto") {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestParseFilesInheritsImportedBase(t *testing.T) {
+	files := map[string]string{
+		"base.dataproto": `
+package acos;
+
+entity Base {
+    @pk id: string;
+    createdAt: timestamp;
+}
+`,
+		"task.dataproto": `
+package acos;
+
+import "base.dataproto";
+
+entity Task extends Base {
+    title: string;
+}
+
+entity Subtask extends Task {
+    parent: string;
+}
+`,
+	}
+
+	prog, err := ParseFilesWithLoader([]string{"task.dataproto"}, mapLoader(files))
+	if err != nil {
+		t.Fatalf("ParseFiles error: %v", err)
+	}
+
+	task := prog.File("task.dataproto")
+	want := map[string][]string{
+		"Task":    {"id:Base", "createdAt:Base", "title:"},
+		"Subtask": {"id:Base", "createdAt:Base", "title:Task", "parent:"},
+	}
+	for _, entity := range task.Entities {
+		var got []string
+		for _, field := range entity.Fields {
+			got = append(got, field.Name+":"+field.Inherited)
+		}
+		if strings.Join(got, ",") != strings.Join(want[entity.Name], ",") {
+			t.Errorf("Expected %s fields %v, got %v", entity.Name, want[entity.Name], got)
+		}
+	}
+
+	base := prog.File("base.dataproto").Entities[0]
+	if len(base.Fields) != 2 {
+		t.Errorf("Expected Base to keep 2 fields, got %d", len(base.Fields))
+	}
+}
@@ -0,0 +1,65 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aurora/dataproto/internal/lexer"
+)
+
+// tabWidth is the number of spaces a tab expands to in rendered snippets.
+const tabWidth = 4
+
+// RenderError renders e as a snippet of src, the source it was parsed from.
+func RenderError(src string, e ParseError) string {
+	return RenderSnippet(src, e.Position, "error", e.Message)
+}
+
+// RenderSnippet renders a diagnostic in the style of cargo: a
+// "severity: message" header, the position, and the source line with a
+// caret under the column. Tabs are expanded so the caret lines up. A
+// position past the end of src points just past its last line, and one
+// without a line renders the header only.
+func RenderSnippet(src string, pos lexer.Position, severity, message string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s: %s\n", severity, message)
+	if pos.Line < 1 {
+		return sb.String()
+	}
+
+	lines := strings.Split(strings.TrimSuffix(src, "\n"), "\n")
+	line, column := pos.Line, pos.Column
+	if line > len(lines) {
+		line = len(lines)
+		column = len(lines[line-1]) + 1
+	}
+	text := strings.TrimSuffix(lines[line-1], "\r")
+	if column < 1 {
+		column = 1
+	}
+	if column > len(text)+1 {
+		column = len(text) + 1
+	}
+
+	location := fmt.Sprintf("%d:%d", pos.Line, pos.Column)
+	if pos.Filename != "" {
+		location = pos.Filename + ":" + location
+	}
+
+	gutter := strings.Repeat(" ", len(fmt.Sprint(line)))
+	fmt.Fprintf(&sb, "%s--> %s\n", gutter, location)
+	fmt.Fprintf(&sb, "%s |\n", gutter)
+	fmt.Fprintf(&sb, "%d | %s\n", line, expandTabs(text))
+	fmt.Fprintf(&sb, "%s | %s^\n", gutter, strings.Repeat(" ", displayWidth(text[:column-1])))
+	return sb.String()
+}
+
+func expandTabs(s string) string {
+	return strings.ReplaceAll(s, "\t", strings.Repeat(" ", tabWidth))
+}
+
+// displayWidth counts the columns s takes once rendered, one per rune.
+func displayWidth(s string) int {
+	return utf8.RuneCountInString(expandTabs(s))
+}
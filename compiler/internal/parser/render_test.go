@@ -0,0 +1,55 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/aurora/dataproto/internal/lexer"
+)
+
+func TestRenderError(t *testing.T) {
+	src := "package test;\nentity Event {\n\t@pk id: ;\n}\n"
+	_, err := Parse(src)
+	list, ok := err.(ErrorList)
+	if !ok || len(list) == 0 {
+		t.Fatalf("Expected an ErrorList, got %v", err)
+	}
+
+	got := RenderError(src, list[0])
+	want := "error: expected type name, got ;\n" +
+		" --> 3:10\n" +
+		"  |\n" +
+		"3 |     @pk id: ;\n" +
+		"  |             ^\n"
+	if got != want {
+		t.Errorf("RenderError() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRenderSnippet(t *testing.T) {
+	src := "entity A {\n  x: Foo;\n}\n"
+
+	got := RenderSnippet(src, lexer.Position{Filename: "a.dataproto", Line: 2, Column: 6}, "warning", "unused")
+	want := "warning: unused\n" +
+		" --> a.dataproto:2:6\n" +
+		"  |\n" +
+		"2 |   x: Foo;\n" +
+		"  |      ^\n"
+	if got != want {
+		t.Errorf("RenderSnippet() =\n%s\nwant\n%s", got, want)
+	}
+
+	// Past the end of the source the caret follows the last line
+	got = RenderSnippet(src, lexer.Position{Line: 9, Column: 1}, "error", "unexpected EOF")
+	want = "error: unexpected EOF\n" +
+		" --> 9:1\n" +
+		"  |\n" +
+		"3 | }\n" +
+		"  |  ^\n"
+	if got != want {
+		t.Errorf("RenderSnippet() past EOF =\n%s\nwant\n%s", got, want)
+	}
+
+	if got := RenderSnippet(src, lexer.Position{}, "error", "no position"); got != "error: no position\n" {
+		t.Errorf("RenderSnippet() without a position = %q", got)
+	}
+}